@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+
+	"vault-audit-mcp/internal/audit"
+	"vault-audit-mcp/internal/config"
+	"vault-audit-mcp/internal/loki"
+)
+
+// runExport implements `vault-audit-mcp export`, writing an evidence bundle
+// without going through an MCP client. The manifest is printed to stdout.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	configPath := fs.String("config", os.Getenv("VAULT_AUDIT_CONFIG"), "Path to a YAML or TOML config file")
+	profile := fs.String("profile", os.Getenv("VAULT_AUDIT_PROFILE"), "Config file profile to use")
+	start := fs.String("start", "", "Start time: RFC3339, a date, Unix epoch, now, -2h, now-7d, today or yesterday (default 15m before --end)")
+	end := fs.String("end", "", "End time, in the same forms as --start (default now)")
+	last := fs.String("last", "", "Duration ending at --end, e.g. 7d, instead of --start")
+	timezone := fs.String("timezone", "", "IANA timezone for dates, today and yesterday (default UTC)")
+	format := fs.String("format", audit.ExportNDJSON, "Output format: ndjson, csv, cef, leef or ocsf")
+	columns := fs.String("columns", "", "Comma-separated CSV columns (default: "+strings.Join(audit.DefaultExportColumns, ",")+")")
+	dir := fs.String("out", ".", "Directory to write the data file and manifest to")
+	maxEvents := fs.Int("max-events", 0, "Stop after this many events (default: no limit)")
+	timeout := fs.Duration("timeout", 0, "Give up and write a partial export after this long (default: none)")
+	namespace := fs.String("namespace", "", "Filter by namespace")
+	namespacePrefix := fs.String("namespace-prefix", "", "Filter by namespace subtree: the namespace and its descendants")
+	operation := fs.String("operation", "", "Filter by operation")
+	mountType := fs.String("mount-type", "", "Filter by mount type")
+	mountClass := fs.String("mount-class", "", "Filter by mount class")
+	status := fs.String("status", "", "Filter by status: ok or error")
+	policy := fs.String("policy", "", "Filter by policy name")
+	entityID := fs.String("entity-id", "", "Filter by entity ID")
+	tenant := fs.String("tenant", "", "Loki tenant(s) to query")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*configPath, *profile)
+	if err != nil {
+		return err
+	}
+	backend, err := cfg.NewBackend()
+	if err != nil {
+		return err
+	}
+	if path := cfg.IdentitySnapshot; path != "" {
+		identity, err := audit.LoadIdentityStore(path)
+		if err != nil {
+			return err
+		}
+		backend = audit.WithIdentity(backend, identity)
+	}
+
+	// Long ranges are allowed; Export splits them into segments.
+	startTime, endTime, err := audit.ParseRange(audit.TimeRange{Start: *start, End: *end, Last: *last, Timezone: *timezone}, 0)
+	if err != nil {
+		return err
+	}
+
+	ctx := loki.WithTenant(context.Background(), *tenant)
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	var cols []string
+	for _, c := range strings.Split(*columns, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			cols = append(cols, c)
+		}
+	}
+
+	manifest, err := audit.Export(ctx, backend, audit.SearchFilter{
+		Start:      startTime,
+		End:        endTime,
+		Namespace:  *namespace,
+		Operation:  *operation,
+		MountType:  *mountType,
+		MountClass: *mountClass,
+		Status:     *status,
+		Policy:     *policy,
+		EntityID:   *entityID,
+
+		NamespacePrefix: *namespacePrefix,
+	}, audit.ExportQuery{
+		Namespace:  *namespace,
+		Operation:  *operation,
+		MountType:  *mountType,
+		MountClass: *mountClass,
+		Status:     *status,
+		Policy:     *policy,
+		EntityID:   *entityID,
+		Tenant:     *tenant,
+
+		NamespacePrefix: *namespacePrefix,
+	}, audit.ExportOptions{
+		Dir:       *dir,
+		Format:    *format,
+		Columns:   cols,
+		MaxEvents: *maxEvents,
+	})
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(manifest)
+}
@@ -2,54 +2,122 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"log"
+	"net/http"
 	"os"
-	"strings"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
 	"vault-audit-mcp/internal/audit"
-	"vault-audit-mcp/internal/loki"
+	"vault-audit-mcp/internal/config"
+	"vault-audit-mcp/internal/metrics"
+	"vault-audit-mcp/internal/tracing"
 )
 
+const serverVersion = "0.1.0"
+
 func main() {
-	lokiURL := os.Getenv("LOKI_URL")
-	if lokiURL == "" {
-		lokiURL = "http://localhost:3100"
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:]); err != nil {
+			log.Fatalf("export failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "watch" {
+		if err := runWatch(os.Args[2:]); err != nil {
+			log.Fatalf("watch failed: %v", err)
+		}
+		return
+	}
+
+	configPath := flag.String("config", os.Getenv("VAULT_AUDIT_CONFIG"), "Path to a YAML or TOML config file")
+	profile := flag.String("profile", os.Getenv("VAULT_AUDIT_PROFILE"), "Config file profile to use")
+	flag.Parse()
+
+	// Environment variables override values from the selected profile.
+	cfg, err := config.Load(*configPath, *profile)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
 
-	opts := &loki.ClientOptions{
-		BearerToken:   os.Getenv("LOKI_BEARER_TOKEN"),
-		TLSSkipVerify: strings.EqualFold(os.Getenv("LOKI_TLS_SKIP_VERIFY"), "true"),
+	// The OTLP exporter also reads the standard OTEL_EXPORTER_OTLP_*
+	// variables (endpoint, headers, insecure).
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:       cfg.Tracing.Exporter,
+		Endpoint:       cfg.Tracing.Endpoint,
+		File:           cfg.Tracing.File,
+		ServiceName:    "vault-audit-mcp",
+		ServiceVersion: serverVersion,
+	})
+	if err != nil {
+		log.Fatalf("invalid tracing configuration: %v", err)
 	}
 
 	server := mcp.NewServer(&mcp.Implementation{
 		Name:    "vault-audit-mcp",
-		Version: "0.1.0",
+		Version: serverVersion,
 	}, nil)
 
-	// Optional: override base Loki stream selector labels.
-	// Example: LOKI_BASE_LABELS='{"kubernetes_namespace_name":"hashicorp-vault"}'
-	var labelsCfg *audit.LabelConfig
-	if raw := os.Getenv("LOKI_BASE_LABELS"); raw != "" {
-		var baseLabels map[string]string
-		if err := json.Unmarshal([]byte(raw), &baseLabels); err != nil {
-			log.Fatalf("invalid LOKI_BASE_LABELS JSON: %v", err)
-		}
-		labelsCfg = &audit.LabelConfig{
-			BaseLabels:     baseLabels,
-			UseVaultLabels: false,
-		}
-		log.Printf("using custom base labels: %v (vault label filters disabled)", baseLabels)
+	backend, err := cfg.NewBackend()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if cache, ok := backend.(*audit.CachingBackend); ok {
+		registerCacheMetrics(cache)
+	}
+	svc, err := cfg.NewService(context.Background(), backend)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
-
-	backend := audit.NewLokiBackend(loki.NewClient(lokiURL, opts), labelsCfg)
-	svc := audit.NewService(backend)
 	svc.AddTools(server)
+	svc.AddResources(server)
+
+	// Investigation prompts: built-ins plus optional local templates.
+	prompts, err := audit.LoadPromptTemplates(cfg.PromptsDir)
+	if err != nil {
+		log.Fatalf("invalid prompts directory: %v", err)
+	}
+	svc.AddPrompts(server, prompts)
+
+	// Optional: Prometheus metrics listener.
+	if addr := cfg.MetricsAddr; addr != "" {
+		server.AddReceivingMiddleware(metrics.ToolMiddleware(audit.ToolNames))
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		go func() {
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				log.Fatalf("metrics listener failed: %v", err)
+			}
+		}()
+		log.Printf("serving metrics on http://%s/metrics", addr)
+	}
+
+	runErr := server.Run(context.Background(), &mcp.StdioTransport{})
 
-	// Handle resource requests - required for MCP protocol
-	if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
-		log.Fatalf("server failed: %v", err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("failed to flush traces: %v", err)
+	}
+	if runErr != nil {
+		log.Fatalf("server failed: %v", runErr)
 	}
 }
+
+// registerCacheMetrics exposes the result cache statistics.
+func registerCacheMetrics(cache *audit.CachingBackend) {
+	metrics.NewCounterFunc("vault_audit_mcp_cache_hits_total", "Result cache hits.",
+		func() float64 { return float64(cache.Stats().Hits) })
+	metrics.NewCounterFunc("vault_audit_mcp_cache_misses_total", "Result cache misses.",
+		func() float64 { return float64(cache.Stats().Misses) })
+	metrics.NewCounterFunc("vault_audit_mcp_cache_evictions_total", "Result cache evictions.",
+		func() float64 { return float64(cache.Stats().Evictions) })
+	metrics.NewGaugeFunc("vault_audit_mcp_cache_entries", "Entries held in the result cache.",
+		func() float64 { return float64(cache.Stats().Entries) })
+	metrics.NewGaugeFunc("vault_audit_mcp_cache_bytes", "Approximate bytes held in the result cache.",
+		func() float64 { return float64(cache.Stats().Bytes) })
+	metrics.NewGaugeFunc("vault_audit_mcp_cache_max_bytes", "Configured result cache memory bound.",
+		func() float64 { return float64(cache.Stats().MaxBytes) })
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"vault-audit-mcp/internal/audit"
+	"vault-audit-mcp/internal/config"
+	"vault-audit-mcp/internal/loki"
+	"vault-audit-mcp/internal/notify"
+)
+
+// runWatch implements `vault-audit-mcp watch`, evaluating the alert rules of
+// a rules file every interval until interrupted. With --once it evaluates
+// once and prints the alerts sent to stdout.
+func runWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	configPath := fs.String("config", os.Getenv("VAULT_AUDIT_CONFIG"), "Path to a YAML or TOML config file")
+	profile := fs.String("profile", os.Getenv("VAULT_AUDIT_PROFILE"), "Config file profile to use")
+	rulesPath := fs.String("rules", os.Getenv("VAULT_AUDIT_WATCH_RULES"), "Path to a YAML, TOML or JSON alert rules file")
+	once := fs.Bool("once", false, "Evaluate the rules once and exit, e.g. from cron")
+	tenant := fs.String("tenant", "", "Loki tenant(s) to query")
+	fs.Parse(args)
+
+	if *rulesPath == "" {
+		return fmt.Errorf("--rules or VAULT_AUDIT_WATCH_RULES is required")
+	}
+	rules, err := config.LoadWatch(*rulesPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load(*configPath, *profile)
+	if err != nil {
+		return err
+	}
+	backend, err := cfg.NewBackend()
+	if err != nil {
+		return err
+	}
+	if path := cfg.IdentitySnapshot; path != "" {
+		identity, err := audit.LoadIdentityStore(path)
+		if err != nil {
+			return err
+		}
+		backend = audit.WithIdentity(backend, identity)
+	}
+	watcher, err := audit.NewWatcher(backend, rules.WatchConfig, rules.NewSinks())
+	if err != nil {
+		return fmt.Errorf("invalid rules file %s: %w", *rulesPath, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = loki.WithTenant(ctx, *tenant)
+
+	// An evaluation may take up to the query timeout, or else one interval.
+	timeout := time.Duration(cfg.Limits.QueryTimeout)
+	if timeout <= 0 {
+		timeout = watcher.Interval()
+	}
+	evaluate := func() ([]notify.Alert, error) {
+		evalCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		alerts, err := watcher.Evaluate(evalCtx, time.Now())
+		for _, a := range alerts {
+			log.Printf("watch: sent alert %s: %d events", a.Title(), a.Count)
+		}
+		return alerts, err
+	}
+
+	if *once {
+		// Alerts sent before a failure are still printed; the error makes
+		// the process exit non-zero.
+		alerts, err := evaluate()
+		out, merr := json.MarshalIndent(alerts, "", "  ")
+		if merr != nil {
+			return merr
+		}
+		if _, perr := fmt.Printf("%s\n", out); perr != nil {
+			return perr
+		}
+		return err
+	}
+
+	log.Printf("watching %d rules every %s", len(rules.Rules), watcher.Interval())
+	ticker := time.NewTicker(watcher.Interval())
+	defer ticker.Stop()
+	for {
+		if _, err := evaluate(); err != nil {
+			log.Printf("watch: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
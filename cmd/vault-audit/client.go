@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"vault-audit-mcp/internal/config"
+)
+
+const clientVersion = "0.1.0"
+
+// connect opens an MCP session. With --server the command line is started
+// and spoken to over stdio. Without it the audit tools are served in-process
+// from the backend in the selected config profile; exportDir then replaces
+// the profile's export directory.
+func connect(ctx context.Context, c *commonFlags, exportDir string) (*mcp.ClientSession, error) {
+	var transport mcp.Transport
+	switch {
+	case c.server == "":
+		t, err := directTransport(ctx, c, exportDir)
+		if err != nil {
+			return nil, err
+		}
+		transport = t
+	default:
+		fields := strings.Fields(c.server)
+		cmd := exec.Command(fields[0], fields[1:]...)
+		cmd.Stderr = os.Stderr
+		transport = &mcp.CommandTransport{Command: cmd}
+	}
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "vault-audit", Version: clientVersion}, nil)
+	session, err := client.Connect(ctx, transport, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", serverName(c), err)
+	}
+	return session, nil
+}
+
+// directTransport starts an in-process server backed by the configured
+// backend and returns the client end of an in-memory transport.
+func directTransport(ctx context.Context, c *commonFlags, exportDir string) (mcp.Transport, error) {
+	cfg, err := config.Load(c.config, c.profile)
+	if err != nil {
+		return nil, err
+	}
+	backend, err := cfg.NewBackend()
+	if err != nil {
+		return nil, err
+	}
+	svc, err := cfg.NewService(ctx, backend)
+	if err != nil {
+		return nil, err
+	}
+	if exportDir != "" {
+		svc.SetExportDir(exportDir)
+	}
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "vault-audit-mcp", Version: clientVersion}, nil)
+	svc.AddTools(server)
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := server.Connect(ctx, serverTransport, nil); err != nil {
+		return nil, err
+	}
+	return clientTransport, nil
+}
+
+func serverName(c *commonFlags) string {
+	if c.server == "" {
+		return "the in-process server"
+	}
+	return c.server
+}
+
+// resultDecoder is implemented by results that need custom decoding; its
+// errors are returned as-is.
+type resultDecoder interface {
+	decode(data []byte) error
+}
+
+// callTool calls a tool and decodes its JSON result into out.
+func callTool(ctx context.Context, session *mcp.ClientSession, name string, args map[string]any, out any) error {
+	res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: name, Arguments: args})
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	if res.IsError {
+		return fmt.Errorf("%s failed: %s", name, resultText(res))
+	}
+
+	var data []byte
+	if res.StructuredContent != nil {
+		if data, err = json.Marshal(res.StructuredContent); err != nil {
+			return err
+		}
+	} else {
+		data = []byte(resultText(res))
+	}
+
+	if d, ok := out.(resultDecoder); ok {
+		return d.decode(data)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("unexpected %s result: %w", name, err)
+	}
+	return nil
+}
+
+func resultText(res *mcp.CallToolResult) string {
+	var parts []string
+	for _, c := range res.Content {
+		if tc, ok := c.(*mcp.TextContent); ok {
+			parts = append(parts, tc.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,1009 @@
+// Command vault-audit queries Vault audit events from the command line.
+//
+// It calls the vault-audit-mcp tools either in-process against the backend
+// described by --config, or on a running MCP server over stdio or HTTP
+// (--server). Exit codes are stable for use in scripts and cron checks:
+//
+//	0  success
+//	1  the query failed
+//	2  invalid usage
+//	3  events matched and --fail-on-match was set, or changes were flagged
+//	   and --fail-on-change was set
+//	4  the results are incomplete (the query deadline was reached)
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"vault-audit-mcp/internal/audit"
+)
+
+const (
+	exitOK         = 0
+	exitError      = 1
+	exitUsage      = 2
+	exitMatch      = 3
+	exitIncomplete = 4
+)
+
+const usage = `Usage: vault-audit <command> [flags]
+
+Commands:
+  search     List audit events matching filters
+  aggregate  Count events grouped by a dimension
+  trace      Summarize the events of one request ID
+  details    Show the full (redacted) events of one request ID
+  explain    Explain why a request was denied
+  entity     Look up an identity entity by name, alias name or ID
+  policies   Compare ACL policy files with the requests they granted
+  simulate   Replay recent requests against a proposed policy
+  compare    Compare a time window with a baseline window
+  report     Generate a compliance evidence report (SOC 2, PCI DSS, ISO 27001)
+  export     Write matching events to an NDJSON, CSV or SIEM evidence bundle
+  tail       Follow new events as they arrive
+
+Run 'vault-audit <command> -h' for the flags of a command.
+`
+
+// errUsage marks errors caused by invalid flags or arguments.
+var errUsage = errors.New("usage error")
+
+// exitStatus carries a non-error exit status, such as exitMatch.
+type exitStatus int
+
+func (s exitStatus) Error() string { return fmt.Sprintf("exit status %d", int(s)) }
+
+var commands = map[string]func(ctx context.Context, args []string) error{
+	"search":    runSearch,
+	"aggregate": runAggregate,
+	"trace":     runTrace,
+	"details":   runDetails,
+	"explain":   runExplain,
+	"entity":    runEntity,
+	"policies":  runPolicies,
+	"simulate":  runSimulate,
+	"compare":   runCompare,
+	"report":    runReport,
+	"export":    runExport,
+	"tail":      runTail,
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("vault-audit: ")
+
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(exitUsage)
+	}
+	run, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(exitUsage)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, os.Args[2:])
+	stop()
+
+	var status exitStatus
+	switch {
+	case err == nil:
+		os.Exit(exitOK)
+	case errors.As(err, &status):
+		os.Exit(int(status))
+	case errors.Is(err, errUsage):
+		log.Print(err)
+		os.Exit(exitUsage)
+	default:
+		log.Print(err)
+		os.Exit(exitError)
+	}
+}
+
+// commonFlags are accepted by every command.
+type commonFlags struct {
+	server  string
+	config  string
+	profile string
+	output  string
+	tenant  string
+	timeout time.Duration
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.server, "server", os.Getenv("VAULT_AUDIT_SERVER"), "MCP server command line to call over stdio. Default: query the backend directly")
+	fs.StringVar(&c.config, "config", os.Getenv("VAULT_AUDIT_CONFIG"), "Config file for direct queries")
+	fs.StringVar(&c.profile, "profile", os.Getenv("VAULT_AUDIT_PROFILE"), "Config file profile for direct queries")
+	fs.StringVar(&c.output, "output", outputTable, "Output format: table, json or ndjson")
+	fs.StringVar(&c.tenant, "tenant", "", "Loki tenant(s) to query")
+	fs.DurationVar(&c.timeout, "timeout", 0, "Give up after this long (default: none)")
+}
+
+func (c *commonFlags) validate() error {
+	switch c.output {
+	case outputTable, outputJSON, outputNDJSON:
+		return nil
+	}
+	return fmt.Errorf("%w: invalid --output %q, must be table, json or ndjson", errUsage, c.output)
+}
+
+// rangeFlags select the queried time range.
+type rangeFlags struct {
+	start, end, last, timezone string
+}
+
+func (r *rangeFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&r.start, "start", "", "Start time: RFC3339, a date, Unix epoch, now, -2h, now-7d, today or yesterday (default 15m before --end)")
+	fs.StringVar(&r.end, "end", "", "End time, in the same forms as --start (default now)")
+	fs.StringVar(&r.last, "last", "", "Duration ending at --end, e.g. 90m or 7d, instead of --start")
+	fs.StringVar(&r.timezone, "timezone", "", "IANA timezone for dates, today and yesterday (default UTC)")
+}
+
+// args adds the range tool arguments. Times are resolved here so that
+// servers only need to understand RFC3339.
+func (r *rangeFlags) args(now time.Time, args map[string]any) error {
+	loc := time.UTC
+	if r.timezone != "" {
+		l, err := time.LoadLocation(r.timezone)
+		if err != nil {
+			return fmt.Errorf("%w: invalid --timezone %q", errUsage, r.timezone)
+		}
+		loc = l
+	}
+	for _, f := range []struct{ name, value, arg string }{
+		{"start", r.start, "start_rfc3339"},
+		{"end", r.end, "end_rfc3339"},
+	} {
+		if f.value == "" {
+			continue
+		}
+		t, err := audit.ParseTime(f.value, now, loc)
+		if err != nil {
+			return fmt.Errorf("%w: invalid --%s: %v", errUsage, f.name, err)
+		}
+		args[f.arg] = t.Format(time.RFC3339Nano)
+	}
+	setArg(args, "last", r.last)
+	return nil
+}
+
+// filterFlags are the event filters shared by search, export and tail.
+type filterFlags struct {
+	namespace, namespacePrefix, operation, mountType, mountClass, status, policy, entityID string
+}
+
+func (f *filterFlags) register(fs *flag.FlagSet, withIdentity bool) {
+	fs.StringVar(&f.namespace, "namespace", "", "Filter by namespace")
+	fs.StringVar(&f.namespacePrefix, "namespace-prefix", "", "Filter by namespace subtree: the namespace and its descendants")
+	fs.StringVar(&f.operation, "operation", "", "Filter by operation")
+	fs.StringVar(&f.mountType, "mount-type", "", "Filter by mount type")
+	fs.StringVar(&f.mountClass, "mount-class", "", "Filter by mount class")
+	fs.StringVar(&f.status, "status", "", "Filter by status: ok or error")
+	if withIdentity {
+		fs.StringVar(&f.policy, "policy", "", "Filter by policy name")
+		fs.StringVar(&f.entityID, "entity-id", "", "Filter by entity ID")
+	}
+}
+
+func (f *filterFlags) args(args map[string]any) {
+	setArg(args, "namespace", f.namespace)
+	setArg(args, "namespace_prefix", f.namespacePrefix)
+	setArg(args, "operation", f.operation)
+	setArg(args, "mount_type", f.mountType)
+	setArg(args, "mount_class", f.mountClass)
+	setArg(args, "status", f.status)
+	setArg(args, "policy", f.policy)
+	setArg(args, "entity_id", f.entityID)
+}
+
+// matchFlags are the match filters shared by search, export and tail.
+type matchFlags struct {
+	displayName, auditType, pathPrefix, pathGlob, pathRegex, remoteCIDR string
+	mountPoint, tokenType, roleName                                     string
+	anyOf, exclude                                                      fieldSetFlag
+}
+
+func (f *matchFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.displayName, "display-name", "", "Filter by token display name")
+	fs.StringVar(&f.auditType, "audit-type", "", "Filter by audit entry type: request or response")
+	fs.StringVar(&f.pathPrefix, "path-prefix", "", "Filter by request path prefix")
+	fs.StringVar(&f.pathGlob, "path-glob", "", "Filter by request path glob (* within a segment, ** across segments)")
+	fs.StringVar(&f.pathRegex, "path-regex", "", "Filter by request path regular expression")
+	fs.StringVar(&f.remoteCIDR, "remote-cidr", "", "Filter by remote address: comma-separated addresses or CIDR ranges")
+	fs.StringVar(&f.mountPoint, "mount-point", "", "Filter by mount point")
+	fs.StringVar(&f.tokenType, "token-type", "", "Filter by token type: service or batch")
+	fs.StringVar(&f.roleName, "role-name", "", "Filter by auth role name")
+	fs.Var(&f.anyOf, "any", "Also match these values, as field=v1,v2 (repeatable), e.g. operation=delete,update")
+	fs.Var(&f.exclude, "exclude", "Drop events matching these values, as field=v1,v2 (repeatable), e.g. namespace=ci/")
+}
+
+func (f *matchFlags) args(args map[string]any) {
+	setArg(args, "display_name", f.displayName)
+	setArg(args, "audit_type", f.auditType)
+	setArg(args, "path_prefix", f.pathPrefix)
+	setArg(args, "path_glob", f.pathGlob)
+	setArg(args, "path_regex", f.pathRegex)
+	setArg(args, "mount_point", f.mountPoint)
+	setArg(args, "token_type", f.tokenType)
+	setArg(args, "role_name", f.roleName)
+	if f.remoteCIDR != "" {
+		args["remote_cidr"] = splitList(f.remoteCIDR)
+	}
+	if len(f.anyOf) > 0 {
+		args["any_of"] = map[string][]string(f.anyOf)
+	}
+	if len(f.exclude) > 0 {
+		args["exclude"] = map[string][]string(f.exclude)
+	}
+}
+
+// fieldSetFields are the field names accepted by --any and --exclude.
+var fieldSetFields = []string{
+	"namespace", "namespace_prefix", "operation", "mount_type", "mount_class", "status", "policy", "entity_id",
+	"display_name", "audit_type", "path_prefix", "path_glob", "path_regex", "remote_cidr",
+	"mount_point", "mount_accessor", "token_type", "role_name",
+}
+
+// fieldSetFlag collects repeated field=v1,v2 values into an audit.FieldSet
+// shaped map.
+type fieldSetFlag map[string][]string
+
+func (f *fieldSetFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(*f))
+	for k, v := range *f {
+		parts = append(parts, k+"="+strings.Join(v, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (f *fieldSetFlag) Set(s string) error {
+	field, values, ok := strings.Cut(s, "=")
+	field = strings.ReplaceAll(strings.TrimSpace(field), "-", "_")
+	if !ok || splitList(values) == nil {
+		return fmt.Errorf("want field=value[,value...], e.g. operation=delete,update")
+	}
+	if !slices.Contains(fieldSetFields, field) {
+		return fmt.Errorf("unknown field %q, want one of %s", field, strings.Join(fieldSetFields, ", "))
+	}
+	if *f == nil {
+		*f = make(fieldSetFlag)
+	}
+	(*f)[field] = append((*f)[field], splitList(values)...)
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping empty elements.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// setArg sets a string tool argument, omitting empty values.
+func setArg(args map[string]any, name, value string) {
+	if value != "" {
+		args[name] = value
+	}
+}
+
+// parseFlags parses args and rejects unexpected positional arguments beyond
+// maxArgs; a negative maxArgs accepts any number.
+func parseFlags(fs *flag.FlagSet, args []string, c *commonFlags, maxArgs int) error {
+	if err := fs.Parse(args); err != nil {
+		// The flag package has already printed the error or the help text.
+		if errors.Is(err, flag.ErrHelp) {
+			return exitStatus(exitOK)
+		}
+		return exitStatus(exitUsage)
+	}
+	if maxArgs >= 0 && fs.NArg() > maxArgs {
+		return fmt.Errorf("%w: unexpected arguments: %s", errUsage, strings.Join(fs.Args()[maxArgs:], " "))
+	}
+	return c.validate()
+}
+
+// withTimeout applies --timeout to ctx.
+func (c *commonFlags) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(ctx, c.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func runSearch(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	var c commonFlags
+	var r rangeFlags
+	var f filterFlags
+	var m matchFlags
+	c.register(fs)
+	r.register(fs)
+	f.register(fs, true)
+	m.register(fs)
+	limit := fs.Int("limit", audit.DefaultLimit, "Max events to return")
+	failOnMatch := fs.Bool("fail-on-match", false, "Exit with status 3 when any event matches")
+	if err := parseFlags(fs, args, &c, 0); err != nil {
+		return err
+	}
+
+	toolArgs := map[string]any{"limit": *limit, "output_format": "events"}
+	if err := r.args(time.Now().UTC(), toolArgs); err != nil {
+		return err
+	}
+	f.args(toolArgs)
+	m.args(toolArgs)
+	setArg(toolArgs, "tenant", c.tenant)
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	session, err := connect(ctx, &c, "")
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var result audit.EventList
+	if err := callTool(ctx, session, "audit.search_events", toolArgs, &result); err != nil {
+		return err
+	}
+	if err := writeEvents(os.Stdout, c.output, &result); err != nil {
+		return err
+	}
+	return resultStatus(result.Incomplete, *failOnMatch && result.TotalEvents > 0)
+}
+
+func runAggregate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("aggregate", flag.ContinueOnError)
+	var c commonFlags
+	var r rangeFlags
+	var f filterFlags
+	c.register(fs)
+	r.register(fs)
+	f.register(fs, false)
+	by := fs.String("by", "operation", "Dimension: namespace, operation, mount_type, mount_class, status, mount_point, mount_accessor, token_type, role_name, status_code or policy_allowed")
+	tree := fs.Bool("tree", false, "With --by namespace, show the namespace hierarchy with counts including descendants")
+	descendants := fs.Bool("include-descendants", false, "With --by namespace, count each namespace's descendants in its bucket")
+	depth := fs.Int("depth", 0, "With --by namespace, roll namespaces deeper than this many levels up into their ancestor")
+	if err := parseFlags(fs, args, &c, 0); err != nil {
+		return err
+	}
+
+	dimension := *by
+	if !strings.HasPrefix(dimension, "vault_") {
+		dimension = "vault_" + dimension
+	}
+	toolArgs := map[string]any{"by": dimension}
+	if err := r.args(time.Now().UTC(), toolArgs); err != nil {
+		return err
+	}
+	f.args(toolArgs)
+	if *tree {
+		toolArgs["namespace_view"] = "tree"
+	}
+	if *descendants {
+		toolArgs["include_descendants"] = true
+	}
+	if *depth != 0 {
+		toolArgs["depth"] = *depth
+	}
+	setArg(toolArgs, "tenant", c.tenant)
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	session, err := connect(ctx, &c, "")
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var result aggregateResult
+	if err := callTool(ctx, session, "audit.aggregate", toolArgs, &result); err != nil {
+		return err
+	}
+	if err := writeBuckets(os.Stdout, c.output, *by, &result); err != nil {
+		return err
+	}
+	return resultStatus(result.Incomplete, false)
+}
+
+func runTrace(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("trace", flag.ContinueOnError)
+	var c commonFlags
+	var r rangeFlags
+	c.register(fs)
+	r.register(fs)
+	limit := fs.Int("limit", audit.DefaultLimit, "Max events to scan")
+	if err := parseFlags(fs, args, &c, 1); err != nil {
+		return err
+	}
+	requestID := fs.Arg(0)
+	if requestID == "" {
+		return fmt.Errorf("%w: trace needs a request ID: vault-audit trace [flags] <request-id>", errUsage)
+	}
+
+	toolArgs := map[string]any{"request_id": requestID, "limit": *limit}
+	if err := r.args(time.Now().UTC(), toolArgs); err != nil {
+		return err
+	}
+	setArg(toolArgs, "tenant", c.tenant)
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	session, err := connect(ctx, &c, "")
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var result audit.TraceSummary
+	if err := callTool(ctx, session, "audit.trace", toolArgs, &result); err != nil {
+		return err
+	}
+	if err := writeTrace(os.Stdout, c.output, &result); err != nil {
+		return err
+	}
+	return resultStatus(result.Incomplete, false)
+}
+
+func runDetails(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("details", flag.ContinueOnError)
+	var c commonFlags
+	c.register(fs)
+	if err := parseFlags(fs, args, &c, 1); err != nil {
+		return err
+	}
+	requestID := fs.Arg(0)
+	if requestID == "" {
+		return fmt.Errorf("%w: details needs a request ID: vault-audit details [flags] <request-id>", errUsage)
+	}
+	toolArgs := map[string]any{"request_id": requestID}
+	setArg(toolArgs, "tenant", c.tenant)
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	session, err := connect(ctx, &c, "")
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var result detailsResult
+	if err := callTool(ctx, session, "audit.get_event_details", toolArgs, &result); err != nil {
+		return err
+	}
+	return writeDetails(os.Stdout, c.output, result.events)
+}
+
+func runExplain(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	var c commonFlags
+	var r rangeFlags
+	c.register(fs)
+	r.register(fs)
+	displayName := fs.String("display-name", "", "Actor token display name")
+	entityID := fs.String("entity-id", "", "Actor entity ID")
+	path := fs.String("path", "", "Request path prefix or glob")
+	namespace := fs.String("namespace", "", "Vault namespace")
+	if err := parseFlags(fs, args, &c, 1); err != nil {
+		return err
+	}
+	toolArgs := map[string]any{}
+	setArg(toolArgs, "request_id", fs.Arg(0))
+	setArg(toolArgs, "display_name", *displayName)
+	setArg(toolArgs, "entity_id", *entityID)
+	setArg(toolArgs, "path", *path)
+	setArg(toolArgs, "namespace", *namespace)
+	if len(toolArgs) == 0 {
+		return fmt.Errorf("%w: explain needs a request ID or --display-name, --entity-id or --path: vault-audit explain [flags] [request-id]", errUsage)
+	}
+	if err := r.args(time.Now().UTC(), toolArgs); err != nil {
+		return err
+	}
+	setArg(toolArgs, "tenant", c.tenant)
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	session, err := connect(ctx, &c, "")
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var result explainResult
+	if err := callTool(ctx, session, "audit.explain_denial", toolArgs, &result); err != nil {
+		return err
+	}
+	if err := writeExplanation(os.Stdout, c.output, &result.DenialExplanation); err != nil {
+		return err
+	}
+	return resultStatus(result.Incomplete, false)
+}
+
+func runEntity(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("entity", flag.ContinueOnError)
+	var c commonFlags
+	c.register(fs)
+	id := fs.String("id", "", "Entity ID")
+	if err := parseFlags(fs, args, &c, 1); err != nil {
+		return err
+	}
+	toolArgs := map[string]any{}
+	setArg(toolArgs, "name", fs.Arg(0))
+	setArg(toolArgs, "id", *id)
+	if len(toolArgs) == 0 {
+		return fmt.Errorf("%w: entity needs a name or --id: vault-audit entity [flags] [name]", errUsage)
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	session, err := connect(ctx, &c, "")
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var result entityResult
+	if err := callTool(ctx, session, "audit.lookup_entity", toolArgs, &result); err != nil {
+		return err
+	}
+	return writeEntities(os.Stdout, c.output, &result.EntityLookup)
+}
+
+func runPolicies(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("policies", flag.ContinueOnError)
+	var c commonFlags
+	var r rangeFlags
+	c.register(fs)
+	r.register(fs)
+	maxEvents := fs.Int("max-events", 0, "Stop after scanning this many events (default: no limit)")
+	if err := parseFlags(fs, args, &c, -1); err != nil {
+		return err
+	}
+	toolArgs := map[string]any{}
+	if err := r.args(time.Now().UTC(), toolArgs); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		toolArgs["policies"] = fs.Args()
+	}
+	if *maxEvents > 0 {
+		toolArgs["max_events"] = *maxEvents
+	}
+	setArg(toolArgs, "tenant", c.tenant)
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	session, err := connect(ctx, &c, "")
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var report audit.PolicyUsageReport
+	if err := callTool(ctx, session, "audit.policy_usage", toolArgs, &report); err != nil {
+		return err
+	}
+	if err := writePolicyUsage(os.Stdout, c.output, &report); err != nil {
+		return err
+	}
+	return resultStatus(report.Incomplete, false)
+}
+
+func runSimulate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	var c commonFlags
+	var r rangeFlags
+	c.register(fs)
+	r.register(fs)
+	name := fs.String("name", "", "Name of the policy the proposal replaces (default: the file name)")
+	namespace := fs.String("namespace", "", "Namespace the policy is defined in (default: root)")
+	maxEvents := fs.Int("max-events", 0, "Stop after scanning this many events (default: no limit)")
+	if err := parseFlags(fs, args, &c, 1); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: simulate needs a policy file: vault-audit simulate [flags] <policy.hcl|->", errUsage)
+	}
+	file := fs.Arg(0)
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	if *name == "" && file != "-" {
+		base := file[strings.LastIndex(file, "/")+1:]
+		*name = strings.TrimSuffix(strings.TrimSuffix(base, ".hcl"), ".json")
+	}
+	if *name == "" {
+		return fmt.Errorf("%w: --name is required when reading the policy from stdin", errUsage)
+	}
+
+	toolArgs := map[string]any{"name": *name, "policy": string(data)}
+	if err := r.args(time.Now().UTC(), toolArgs); err != nil {
+		return err
+	}
+	setArg(toolArgs, "namespace", *namespace)
+	if *maxEvents > 0 {
+		toolArgs["max_events"] = *maxEvents
+	}
+	setArg(toolArgs, "tenant", c.tenant)
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	session, err := connect(ctx, &c, "")
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var sim audit.PolicySimulation
+	if err := callTool(ctx, session, "audit.simulate_policy", toolArgs, &sim); err != nil {
+		return err
+	}
+	if err := writeSimulation(os.Stdout, c.output, &sim); err != nil {
+		return err
+	}
+	return resultStatus(sim.Incomplete, false)
+}
+
+func runCompare(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	var c commonFlags
+	var r rangeFlags
+	var f filterFlags
+	c.register(fs)
+	r.register(fs)
+	f.register(fs, true)
+	baseStart := fs.String("baseline-start", "", "Start of the baseline window, in the same forms as --start")
+	baseEnd := fs.String("baseline-end", "", "End of the baseline window, in the same forms as --start")
+	offset := fs.String("baseline-offset", "", "Compare with the window shifted back by this duration, e.g. 24h (default 7d)")
+	threshold := fs.Float64("threshold", 0, "Flag entries that changed by at least this percentage (default 50)")
+	minCount := fs.Int("min-count", 0, "Flag only changes of at least this many events (default 5)")
+	top := fs.Int("top", 0, "Entries listed per dimension (default 10)")
+	maxEvents := fs.Int("max-events", 0, "Stop counting a window after this many events (default 100000)")
+	failOnChange := fs.Bool("fail-on-change", false, "Exit with status 3 when any change is flagged")
+	if err := parseFlags(fs, args, &c, 0); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	toolArgs := map[string]any{}
+	if err := r.args(now, toolArgs); err != nil {
+		return err
+	}
+	baseline := rangeFlags{start: *baseStart, end: *baseEnd, timezone: r.timezone}
+	baseArgs := map[string]any{}
+	if err := baseline.args(now, baseArgs); err != nil {
+		return err
+	}
+	for from, to := range map[string]string{"start_rfc3339": "baseline_start_rfc3339", "end_rfc3339": "baseline_end_rfc3339"} {
+		if v, ok := baseArgs[from]; ok {
+			toolArgs[to] = v
+		}
+	}
+	setArg(toolArgs, "baseline_offset", *offset)
+	f.args(toolArgs)
+	if *threshold > 0 {
+		toolArgs["threshold_percent"] = *threshold
+	}
+	if *minCount > 0 {
+		toolArgs["min_count"] = *minCount
+	}
+	if *top > 0 {
+		toolArgs["top"] = *top
+	}
+	if *maxEvents > 0 {
+		toolArgs["max_events"] = *maxEvents
+	}
+	setArg(toolArgs, "tenant", c.tenant)
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	session, err := connect(ctx, &c, "")
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var cmp audit.WindowComparison
+	if err := callTool(ctx, session, "audit.compare_windows", toolArgs, &cmp); err != nil {
+		return err
+	}
+	if err := writeComparison(os.Stdout, c.output, &cmp); err != nil {
+		return err
+	}
+	return resultStatus(cmp.Incomplete, *failOnChange && len(cmp.Highlights) > 0)
+}
+
+func runReport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	var c commonFlags
+	var r rangeFlags
+	c.register(fs)
+	r.register(fs)
+	quarter := fs.String("quarter", "", "Calendar quarter, e.g. 2026-Q3, or last (default: the last complete quarter unless --start, --end or --last is given)")
+	namespacePrefix := fs.String("namespace-prefix", "", "Limit the report to a namespace and its descendants")
+	noEvidence := fs.Bool("no-evidence", false, "Do not write the events of each query as evidence files")
+	evidenceFormat := fs.String("evidence-format", audit.ExportNDJSON, "Evidence format: ndjson, csv, cef, leef or ocsf")
+	top := fs.Int("top", 0, "Rows listed per table (default 20)")
+	maxEvents := fs.Int("max-events", 0, "Stop each query after this many events (default: no limit)")
+	out := fs.String("out", ".", "Directory to write the report to (direct queries only; a server writes to its own export directory)")
+	if err := parseFlags(fs, args, &c, 1); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: report needs a template: vault-audit report [flags] <soc2|pci_dss|iso27001|custom>", errUsage)
+	}
+
+	toolArgs := map[string]any{"template": fs.Arg(0), "evidence_format": *evidenceFormat}
+	if *quarter != "" {
+		toolArgs["quarter"] = *quarter
+		setArg(toolArgs, "timezone", r.timezone)
+	}
+	if err := r.args(time.Now().UTC(), toolArgs); err != nil {
+		return err
+	}
+	setArg(toolArgs, "namespace_prefix", *namespacePrefix)
+	if *noEvidence {
+		toolArgs["evidence"] = false
+	}
+	if *top > 0 {
+		toolArgs["top"] = *top
+	}
+	if *maxEvents > 0 {
+		toolArgs["max_events"] = *maxEvents
+	}
+	setArg(toolArgs, "tenant", c.tenant)
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	session, err := connect(ctx, &c, *out)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var rep audit.ComplianceReport
+	if err := callTool(ctx, session, "audit.compliance_report", toolArgs, &rep); err != nil {
+		return err
+	}
+	if err := writeReport(os.Stdout, c.output, &rep); err != nil {
+		return err
+	}
+	return resultStatus(rep.Incomplete, false)
+}
+
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	var c commonFlags
+	var r rangeFlags
+	var f filterFlags
+	var m matchFlags
+	c.register(fs)
+	r.register(fs)
+	f.register(fs, true)
+	m.register(fs)
+	format := fs.String("format", audit.ExportNDJSON, "Export format: ndjson, csv, cef, leef or ocsf")
+	columns := fs.String("columns", "", "Comma-separated CSV columns")
+	maxEvents := fs.Int("max-events", 0, "Stop after this many events (default: no limit)")
+	out := fs.String("out", ".", "Directory to write the bundle to (direct queries only; a server writes to its own export directory)")
+	if err := parseFlags(fs, args, &c, 0); err != nil {
+		return err
+	}
+
+	toolArgs := map[string]any{"format": *format}
+	if err := r.args(time.Now().UTC(), toolArgs); err != nil {
+		return err
+	}
+	f.args(toolArgs)
+	m.args(toolArgs)
+	setArg(toolArgs, "tenant", c.tenant)
+	if *maxEvents > 0 {
+		toolArgs["max_events"] = *maxEvents
+	}
+	if *columns != "" {
+		var cols []string
+		for _, col := range strings.Split(*columns, ",") {
+			if col = strings.TrimSpace(col); col != "" {
+				cols = append(cols, col)
+			}
+		}
+		toolArgs["columns"] = cols
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	session, err := connect(ctx, &c, *out)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var manifest audit.ExportManifest
+	if err := callTool(ctx, session, "audit.export", toolArgs, &manifest); err != nil {
+		return err
+	}
+	if err := writeManifest(os.Stdout, c.output, &manifest); err != nil {
+		return err
+	}
+	return resultStatus(manifest.Incomplete, false)
+}
+
+func runTail(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	var c commonFlags
+	var f filterFlags
+	var m matchFlags
+	c.register(fs)
+	f.register(fs, true)
+	m.register(fs)
+	since := fs.String("since", "-1m", "Print events since this time before following: RFC3339 or relative like -5m")
+	interval := fs.Duration("interval", 10*time.Second, "Poll interval")
+	if err := parseFlags(fs, args, &c, 0); err != nil {
+		return err
+	}
+	if c.output == outputJSON {
+		return fmt.Errorf("%w: tail supports --output table or ndjson", errUsage)
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("%w: --interval must be positive", errUsage)
+	}
+	cursor, err := audit.ParseTime(*since, time.Now().UTC(), time.UTC)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --since: %v", errUsage, err)
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	session, err := connect(ctx, &c, "")
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	t := newTailer(os.Stdout, c.output, cursor)
+	for {
+		toolArgs := map[string]any{
+			"output_format": "events",
+			"limit":         audit.DefaultMaxQueryLimit,
+			"start_rfc3339": t.cursor.Format(time.RFC3339),
+			"end_rfc3339":   time.Now().UTC().Format(time.RFC3339),
+		}
+		f.args(toolArgs)
+		m.args(toolArgs)
+		setArg(toolArgs, "tenant", c.tenant)
+
+		var result audit.EventList
+		if err := callTool(ctx, session, "audit.search_events", toolArgs, &result); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err := t.write(result.Events); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// resultStatus maps result flags to the exit status.
+func resultStatus(incomplete, matched bool) error {
+	if incomplete {
+		log.Print("results are incomplete: the query deadline was reached")
+		return exitStatus(exitIncomplete)
+	}
+	if matched {
+		return exitStatus(exitMatch)
+	}
+	return nil
+}
+
+// aggregateResult accepts the plain and the partial aggregate result, and
+// the namespace tree.
+type aggregateResult struct {
+	Buckets    []audit.Bucket
+	Tree       *audit.NamespaceNode
+	Incomplete bool
+}
+
+func (a *aggregateResult) decode(data []byte) error {
+	if len(data) > 0 && data[0] == '[' {
+		return json.Unmarshal(data, &a.Buckets)
+	}
+	var partial struct {
+		audit.PartialAggregate
+		Tree *audit.NamespaceNode `json:"tree"`
+	}
+	if err := json.Unmarshal(data, &partial); err != nil {
+		return err
+	}
+	a.Buckets, a.Tree, a.Incomplete = partial.Buckets, partial.Tree, partial.Incomplete
+	return nil
+}
+
+// explainResult accepts an explanation or the "no failed request" result.
+type explainResult struct {
+	audit.DenialExplanation
+}
+
+func (e *explainResult) decode(data []byte) error {
+	var notFound struct {
+		RequestID string `json:"request_id"`
+		Error     string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &notFound); err != nil {
+		return err
+	}
+	if notFound.RequestID == "" && notFound.Error != "" {
+		return errors.New(notFound.Error)
+	}
+	return json.Unmarshal(data, &e.DenialExplanation)
+}
+
+// entityResult accepts an entity lookup or the "no entity" result.
+type entityResult struct {
+	audit.EntityLookup
+}
+
+func (e *entityResult) decode(data []byte) error {
+	var notFound struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &notFound); err != nil {
+		return err
+	}
+	if notFound.Error != "" {
+		return errors.New(notFound.Error)
+	}
+	return json.Unmarshal(data, &e.EntityLookup)
+}
+
+// detailsResult accepts the event list or the "not found" object returned by
+// audit.get_event_details.
+type detailsResult struct {
+	events []audit.Event
+}
+
+func (d *detailsResult) decode(data []byte) error {
+	if len(data) > 0 && data[0] == '{' {
+		var notFound struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(data, &notFound); err != nil {
+			return err
+		}
+		return errors.New(notFound.Error)
+	}
+	return json.Unmarshal(data, &d.events)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"vault-audit-mcp/internal/audit"
+)
+
+func TestTailerSkipsSeenEvents(t *testing.T) {
+	base := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	tl := newTailer(&buf, outputNDJSON, base)
+
+	// Newest first, as returned by search.
+	first := []audit.Event{
+		{Time: base.Add(2 * time.Second), RequestID: "b"},
+		{Time: base.Add(time.Second), RequestID: "a"},
+	}
+	if err := tl.write(first); err != nil {
+		t.Fatal(err)
+	}
+	second := []audit.Event{
+		{Time: base.Add(3 * time.Second), RequestID: "c"},
+		{Time: base.Add(2 * time.Second), RequestID: "b"},
+	}
+	if err := tl.write(second); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), buf.String())
+	}
+	for i, id := range []string{`"a"`, `"b"`, `"c"`} {
+		if !strings.Contains(lines[i], id) {
+			t.Errorf("line %d = %s, want request %s", i, lines[i], id)
+		}
+	}
+}
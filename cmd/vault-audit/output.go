@@ -0,0 +1,632 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"vault-audit-mcp/internal/audit"
+)
+
+// Output formats.
+const (
+	outputTable  = "table"
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+)
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeNDJSON writes each element of items on its own line.
+func writeNDJSON[T any](w io.Writer, items []T) error {
+	enc := json.NewEncoder(w)
+	for i := range items {
+		if err := enc.Encode(&items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var eventColumns = "TIME\tSTATUS\tOPERATION\tNAMESPACE\tPATH\tACTOR\tREQUEST_ID"
+
+func eventRow(ev *audit.Event) string {
+	actor := ev.Display
+	if actor == "" {
+		actor = ev.EntityID
+	}
+	return strings.Join([]string{
+		ev.Time.UTC().Format(time.RFC3339),
+		dash(ev.Status),
+		dash(ev.Operation),
+		dash(ev.Namespace),
+		dash(ev.Path),
+		dash(actor),
+		dash(ev.RequestID),
+	}, "\t")
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func writeEvents(w io.Writer, output string, result *audit.EventList) error {
+	switch output {
+	case outputJSON:
+		return writeJSON(w, result)
+	case outputNDJSON:
+		return writeNDJSON(w, result.Events)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, eventColumns)
+	for i := range result.Events {
+		fmt.Fprintln(tw, eventRow(&result.Events[i]))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d events between %s and %s\n", result.TotalEvents, result.StartTime, result.EndTime)
+	return err
+}
+
+func writeBuckets(w io.Writer, output, by string, result *aggregateResult) error {
+	if result.Tree != nil {
+		return writeNamespaceTree(w, output, result)
+	}
+	switch output {
+	case outputJSON:
+		return writeJSON(w, audit.PartialAggregate{Buckets: result.Buckets, Incomplete: result.Incomplete})
+	case outputNDJSON:
+		return writeNDJSON(w, result.Buckets)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tCOUNT\n", strings.ToUpper(strings.TrimPrefix(by, "vault_")))
+	for _, b := range result.Buckets {
+		fmt.Fprintf(tw, "%s\t%g\n", dash(b.Key), b.Value)
+	}
+	return tw.Flush()
+}
+
+// writeNamespaceTree prints the namespace tree indented by depth, or as
+// one node per line (without children) for ndjson.
+func writeNamespaceTree(w io.Writer, output string, result *aggregateResult) error {
+	var nodes []*audit.NamespaceNode
+	var walk func(n *audit.NamespaceNode)
+	walk = func(n *audit.NamespaceNode) {
+		nodes = append(nodes, n)
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(result.Tree)
+
+	switch output {
+	case outputJSON:
+		return writeJSON(w, audit.NamespaceTreeResult{Tree: result.Tree, Incomplete: result.Incomplete})
+	case outputNDJSON:
+		flat := make([]audit.NamespaceNode, len(nodes))
+		for i, n := range nodes {
+			flat[i] = *n
+			flat[i].Children = nil
+		}
+		return writeNDJSON(w, flat)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tCOUNT\tTOTAL")
+	for _, n := range nodes {
+		name := n.Namespace
+		if name == "" {
+			name = "(root)"
+		} else if i := strings.LastIndex(strings.TrimSuffix(name, "/"), "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		fmt.Fprintf(tw, "%s%s\t%g\t%g\n", strings.Repeat("  ", n.Depth), name, n.Count, n.Total)
+	}
+	return tw.Flush()
+}
+
+func writeTrace(w io.Writer, output string, result *audit.TraceSummary) error {
+	switch output {
+	case outputJSON:
+		return writeJSON(w, result)
+	case outputNDJSON:
+		return json.NewEncoder(w).Encode(result)
+	}
+	fmt.Fprintf(w, "Request:    %s\n", result.RequestID)
+	fmt.Fprintf(w, "Events:     %d\n", result.TotalEvents)
+	if result.Timeline != "" {
+		fmt.Fprintf(w, "Timeline:   %s\n", result.Timeline)
+	}
+	if len(result.Namespaces) > 0 {
+		fmt.Fprintf(w, "Namespaces: %s\n", strings.Join(result.Namespaces, ", "))
+	}
+	if len(result.Operations) > 0 {
+		fmt.Fprintf(w, "Operations: %s\n", strings.Join(result.Operations, ", "))
+	}
+	if len(result.SampleEvents) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, eventColumns)
+	for i := range result.SampleEvents {
+		fmt.Fprintln(tw, eventRow(&result.SampleEvents[i]))
+	}
+	return tw.Flush()
+}
+
+func writeDetails(w io.Writer, output string, events []audit.Event) error {
+	switch output {
+	case outputJSON:
+		return writeJSON(w, events)
+	case outputNDJSON:
+		return writeNDJSON(w, events)
+	}
+	for i, ev := range events {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, f := range []struct{ name, value string }{
+			{"Time", ev.Time.UTC().Format(time.RFC3339Nano)},
+			{"Request ID", ev.RequestID},
+			{"Type", ev.AuditType},
+			{"Status", ev.Status},
+			{"Operation", ev.Operation},
+			{"Namespace", ev.Namespace},
+			{"Path", ev.Path},
+			{"Mount", strings.Trim(ev.MountType+" "+ev.MountClass, " ")},
+			{"Mount point", strings.Trim(ev.MountPoint+" "+ev.MountAccessor, " ")},
+			{"Actor", ev.Display},
+			{"Entity", strings.TrimSpace(ev.EntityName + " " + ev.EntityID)},
+			{"Alias", strings.TrimSpace(ev.AliasName + " " + ev.AliasMountPath)},
+			{"Groups", strings.Join(ev.Groups, ", ")},
+			{"Role", ev.RoleName},
+			{"Token type", ev.TokenType},
+			{"Token accessor", ev.TokenAccessor},
+			{"Remote address", ev.RemoteAddr},
+			{"Policies", strings.Join(ev.Policies, ", ")},
+			{"Token policies", strings.Join(ev.TokenPolicies, ", ")},
+			{"Identity policies", strings.Join(ev.IdentityPolicies, ", ")},
+			{"Policy decision", policyDecision(ev.PolicyResults)},
+			{"HTTP status", statusCode(ev.StatusCode)},
+			{"Forwarded from", ev.ForwardedFrom},
+			{"Auth metadata", joinMap(ev.AuthMetadata)},
+			{"Headers", joinMap(ev.Headers)},
+		} {
+			if f.value != "" {
+				fmt.Fprintf(tw, "%s:\t%s\n", f.name, f.value)
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if ev.Raw != nil {
+			raw, err := json.MarshalIndent(ev.Raw, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Raw:\n%s\n", raw)
+		}
+	}
+	return nil
+}
+
+func writeExplanation(w io.Writer, output string, exp *audit.DenialExplanation) error {
+	switch output {
+	case outputJSON:
+		return writeJSON(w, exp)
+	case outputNDJSON:
+		return json.NewEncoder(w).Encode(exp)
+	}
+	actor := exp.DisplayName
+	if entity := strings.TrimSpace(exp.EntityName + " " + exp.EntityID); entity != "" {
+		actor = strings.TrimSpace(actor + " (" + entity + ")")
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, f := range []struct{ name, value string }{
+		{"Request ID", exp.RequestID},
+		{"Time", exp.Time},
+		{"Error class", exp.ErrorClass},
+		{"Error", exp.Error},
+		{"Namespace", exp.Namespace},
+		{"Operation", exp.Operation},
+		{"Path", exp.Path},
+		{"Mount point", exp.MountPoint},
+		{"Actor", actor},
+		{"Role", exp.RoleName},
+		{"Groups", strings.Join(exp.Groups, ", ")},
+		{"Token type", exp.TokenType},
+		{"Policies", strings.Join(exp.Policies, ", ")},
+		{"Token policies", strings.Join(exp.TokenPolicies, ", ")},
+		{"Identity policies", strings.Join(exp.IdentityPolicies, ", ")},
+		{"Policy decision", policyDecision(exp.PolicyResults)},
+	} {
+		if f.value != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", f.name, strings.TrimSpace(f.value))
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(exp.Findings) > 0 {
+		fmt.Fprintln(w, "\nFindings:")
+		for _, f := range exp.Findings {
+			fmt.Fprintf(w, "  - %s\n", f)
+		}
+	}
+	if len(exp.RecentSuccesses) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w, "\nRecent successful requests by the same actor:")
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, eventColumns)
+	for i := range exp.RecentSuccesses {
+		fmt.Fprintln(tw, eventRow(&exp.RecentSuccesses[i]))
+	}
+	return tw.Flush()
+}
+
+// policyDecision describes Vault's policy_results, e.g. "allowed by default".
+func policyDecision(pr *audit.PolicyResults) string {
+	if pr == nil {
+		return ""
+	}
+	if !pr.Allowed {
+		return "denied"
+	}
+	names := make([]string, len(pr.GrantingPolicies))
+	for i, p := range pr.GrantingPolicies {
+		names[i] = p.Name
+	}
+	if len(names) == 0 {
+		return "allowed"
+	}
+	return "allowed by " + strings.Join(names, ", ")
+}
+
+// joinMap formats m as "k=v; k=v" sorted by key.
+func joinMap(m map[string]string) string {
+	keys := slices.Sorted(maps.Keys(m))
+	for i, k := range keys {
+		keys[i] = k + "=" + m[k]
+	}
+	return strings.Join(keys, "; ")
+}
+
+func statusCode(code int) string {
+	if code == 0 {
+		return ""
+	}
+	return strconv.Itoa(code)
+}
+
+func writeEntities(w io.Writer, output string, result *audit.EntityLookup) error {
+	switch output {
+	case outputJSON:
+		return writeJSON(w, result)
+	case outputNDJSON:
+		return writeNDJSON(w, result.Entities)
+	}
+	for i, e := range result.Entities {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		aliases := make([]string, len(e.Aliases))
+		for j, a := range e.Aliases {
+			aliases[j] = a.Name
+			if mount := strings.Trim(a.MountPath+" "+a.MountType, " "); mount != "" {
+				aliases[j] += " (" + mount + ")"
+			}
+		}
+		disabled := ""
+		if e.Disabled {
+			disabled = "yes"
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, f := range []struct{ name, value string }{
+			{"Name", e.Name},
+			{"ID", e.ID},
+			{"Disabled", disabled},
+			{"Aliases", strings.Join(aliases, ", ")},
+			{"Groups", strings.Join(e.Groups, ", ")},
+			{"Policies", strings.Join(e.Policies, ", ")},
+		} {
+			if f.value != "" {
+				fmt.Fprintf(tw, "%s:\t%s\n", f.name, f.value)
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "\nSnapshot loaded at %s\n", result.SnapshotLoadedAt)
+	return nil
+}
+
+// writePolicyUsage prints a table of rule usage per policy followed by its
+// suggested policy, or one policy per line for ndjson.
+func writePolicyUsage(w io.Writer, output string, report *audit.PolicyUsageReport) error {
+	switch output {
+	case outputJSON:
+		return writeJSON(w, report)
+	case outputNDJSON:
+		return writeNDJSON(w, report.Policies)
+	}
+	for i := range report.Policies {
+		p := &report.Policies[i]
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "Policy %s%s (%s): %d requests\n\n", p.Namespace, p.Name, p.File, p.Requests)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "PATH\tCAPABILITIES\tREQUESTS\tUNUSED\tLAST USED")
+		for _, r := range p.Rules {
+			unused := strings.Join(r.Unused, ", ")
+			if r.Requests == 0 && !slices.Contains(r.Capabilities, "deny") {
+				unused = "(rule)"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", r.Path, strings.Join(r.Capabilities, ", "), r.Requests, dash(unused), dash(r.LastUsed))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if p.SuggestedPolicy != "" && (len(p.UnusedRules) > 0 || len(p.UnusedCapabilities) > 0) {
+			fmt.Fprintf(w, "\n%s", p.SuggestedPolicy)
+		}
+	}
+	fmt.Fprintf(w, "\n%d of %d successful requests granted by these policy files between %s and %s\n",
+		report.EventsAttributed, report.EventsScanned, report.Start, report.End)
+	if len(report.UnknownPolicies) > 0 {
+		fmt.Fprintf(w, "No policy file for: %s\n", strings.Join(report.UnknownPolicies, ", "))
+	}
+	return nil
+}
+
+// writeSimulation prints the rule changes and the newly denied and allowed
+// requests, or one group per line (with its direction) for ndjson.
+func writeSimulation(w io.Writer, output string, sim *audit.PolicySimulation) error {
+	switch output {
+	case outputJSON:
+		return writeJSON(w, sim)
+	case outputNDJSON:
+		type impact struct {
+			Change string `json:"change"`
+			audit.PolicyImpact
+		}
+		var lines []impact
+		for _, g := range sim.NewlyDenied {
+			lines = append(lines, impact{"newly_denied", g})
+		}
+		for _, g := range sim.NewlyAllowed {
+			lines = append(lines, impact{"newly_allowed", g})
+		}
+		return writeNDJSON(w, lines)
+	}
+
+	if len(sim.RuleChanges) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "PATH\tCURRENT\tPROPOSED")
+		for _, c := range sim.RuleChanges {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Path, dash(strings.Join(c.Before, ", ")), dash(strings.Join(c.After, ", ")))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+	for _, section := range []struct {
+		title  string
+		total  int
+		groups []audit.PolicyImpact
+	}{
+		{"Newly denied", sim.NewlyDeniedRequests, sim.NewlyDenied},
+		{"Newly allowed", sim.NewlyAllowedRequests, sim.NewlyAllowed},
+	} {
+		fmt.Fprintf(w, "%s: %d requests\n", section.title, section.total)
+		if len(section.groups) == 0 {
+			fmt.Fprintln(w)
+			continue
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ACTOR\tNAMESPACE\tPATH\tOPERATIONS\tREQUESTS\tLAST SEEN\tRULE")
+		for _, g := range section.groups {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", g.Actor, dash(g.Namespace), g.Path,
+				strings.Join(g.Operations, ", "), g.Requests, g.LastSeen, dash(g.Rule))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d requests carrying %s replayed between %s and %s\n", sim.EventsEvaluated, sim.Policy, sim.Start, sim.End)
+	for _, note := range sim.Notes {
+		fmt.Fprintf(w, "Note: %s\n", note)
+	}
+	return nil
+}
+
+func writeComparison(w io.Writer, output string, cmp *audit.WindowComparison) error {
+	switch output {
+	case outputJSON:
+		return writeJSON(w, cmp)
+	case outputNDJSON:
+		type entry struct {
+			Dimension string `json:"dimension"`
+			audit.EntryDelta
+		}
+		var lines []entry
+		for _, d := range cmp.Dimensions {
+			for _, e := range d.Entries {
+				lines = append(lines, entry{d.Dimension, e})
+			}
+		}
+		return writeNDJSON(w, lines)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "WINDOW\tSTART\tEND\tEVENTS\tERRORS\tERROR RATE")
+	for _, s := range []struct {
+		name  string
+		stats audit.WindowStats
+	}{{"current", cmp.Current}, {"baseline", cmp.Baseline}} {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%.1f%%\n", s.name, s.stats.Start, s.stats.End, s.stats.Events, s.stats.Errors, s.stats.ErrorRate)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if cmp.BaselineScale != 0 {
+		fmt.Fprintf(w, "Baseline counts scaled by %g to the current window length.\n", cmp.BaselineScale)
+	}
+	fmt.Fprintln(w)
+
+	for _, d := range cmp.Dimensions {
+		if len(d.Entries) == 0 {
+			continue
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "%s\tCURRENT\tBASELINE\tCHANGE\tSTATUS\n", strings.ToUpper(strings.ReplaceAll(d.Dimension, "_", " ")))
+		for _, e := range d.Entries {
+			change := fmt.Sprintf("%+g", e.Change)
+			if e.ChangePercent != nil {
+				change += fmt.Sprintf(" (%+g%%)", *e.ChangePercent)
+			}
+			fmt.Fprintf(tw, "%s\t%g\t%g\t%s\t%s\n", e.Key, e.Current, e.Baseline, change, dash(e.Status))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(cmp.Highlights) == 0 {
+		fmt.Fprintln(w, "No significant changes.")
+	}
+	for _, h := range cmp.Highlights {
+		fmt.Fprintf(w, "* %s\n", h)
+	}
+	return nil
+}
+
+func writeReport(w io.Writer, output string, rep *audit.ComplianceReport) error {
+	switch output {
+	case outputJSON:
+		return writeJSON(w, rep)
+	case outputNDJSON:
+		type query struct {
+			Control string `json:"control"`
+			audit.ReportQueryResult
+		}
+		var lines []query
+		for _, c := range rep.Controls {
+			for _, q := range c.Queries {
+				lines = append(lines, query{c.ID, q})
+			}
+		}
+		return writeNDJSON(w, lines)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONTROL\tQUERY\tEVENTS\tFAILED\tLAST SEEN")
+	for _, c := range rep.Controls {
+		for _, q := range c.Queries {
+			events := fmt.Sprint(q.Events)
+			if q.Skipped {
+				events = "not run"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", c.ID, q.Name, events, q.Errors, dash(q.LastSeen))
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Report:\t%s\n", rep.Title)
+	fmt.Fprintf(tw, "Period:\t%s - %s\n", rep.Start, rep.End)
+	fmt.Fprintf(tw, "Markdown:\t%s\n", rep.MarkdownFile)
+	fmt.Fprintf(tw, "HTML:\t%s\n", rep.HTMLFile)
+	fmt.Fprintf(tw, "Manifest:\t%s\n", rep.ManifestFile)
+	if rep.Truncated {
+		fmt.Fprintf(tw, "Truncated:\tyes (max events reached)\n")
+	}
+	return tw.Flush()
+}
+
+func writeManifest(w io.Writer, output string, m *audit.ExportManifest) error {
+	switch output {
+	case outputJSON:
+		return writeJSON(w, m)
+	case outputNDJSON:
+		return json.NewEncoder(w).Encode(m)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "File:\t%s\n", m.File)
+	fmt.Fprintf(tw, "Manifest:\t%s\n", m.ManifestFile)
+	fmt.Fprintf(tw, "Format:\t%s\n", m.Format)
+	fmt.Fprintf(tw, "Range:\t%s - %s\n", m.StartTime, m.EndTime)
+	fmt.Fprintf(tw, "Events:\t%d\n", m.EventCount)
+	fmt.Fprintf(tw, "SHA-256:\t%s\n", m.SHA256)
+	if m.Truncated {
+		fmt.Fprintf(tw, "Truncated:\tyes (max events reached)\n")
+	}
+	return tw.Flush()
+}
+
+// tailer prints events oldest first, skipping events already printed.
+type tailer struct {
+	w      io.Writer
+	output string
+	cursor time.Time
+	seen   map[string]bool
+	header bool
+}
+
+func newTailer(w io.Writer, output string, start time.Time) *tailer {
+	return &tailer{w: w, output: output, cursor: start, seen: make(map[string]bool)}
+}
+
+// write prints the events not seen before and advances the cursor to the
+// newest timestamp. events are newest first, as returned by search.
+func (t *tailer) write(events []audit.Event) error {
+	var fresh []audit.Event
+	for i := len(events) - 1; i >= 0; i-- {
+		ev := events[i]
+		key := ev.Time.String() + "\x00" + ev.RequestID + "\x00" + ev.AuditType + "\x00" + ev.Path
+		if ev.Time.Before(t.cursor) || t.seen[key] {
+			continue
+		}
+		if ev.Time.After(t.cursor) {
+			// Only events at the cursor instant can be returned again.
+			t.cursor = ev.Time
+			t.seen = make(map[string]bool)
+		}
+		t.seen[key] = true
+		fresh = append(fresh, ev)
+	}
+	if t.output == outputNDJSON {
+		return writeNDJSON(t.w, fresh)
+	}
+	tw := tabwriter.NewWriter(t.w, 0, 4, 2, ' ', 0)
+	if !t.header {
+		fmt.Fprintln(tw, eventColumns)
+		t.header = true
+	}
+	for i := range fresh {
+		fmt.Fprintln(tw, eventRow(&fresh[i]))
+	}
+	return tw.Flush()
+}
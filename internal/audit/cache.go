@@ -0,0 +1,435 @@
+package audit
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"vault-audit-mcp/internal/loki"
+)
+
+// Cache defaults.
+const (
+	DefaultCacheSettleDelay = 5 * time.Minute
+	DefaultCacheMaxBytes    = 64 << 20
+)
+
+// CacheOptions configures a CachingBackend. Zero values use the defaults.
+type CacheOptions struct {
+	// SettleDelay is how far behind now a window must end before its results
+	// are considered immutable and cacheable, allowing for ingestion lag.
+	SettleDelay time.Duration
+	// MaxBytes bounds the approximate memory used by cached results.
+	MaxBytes int64
+	// Window is the size of the aligned time windows events are cached in.
+	Window time.Duration
+}
+
+// CacheStats reports cache effectiveness.
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+	MaxBytes  int64  `json:"max_bytes"`
+}
+
+// CachingBackend wraps a Backend and caches results for time windows that
+// have settled (ended more than SettleDelay ago), so repeated questions about
+// the same incident are answered from memory.
+//
+// Search and Trace results are cached per aligned window and reused by any
+// query overlapping that window, including queries whose bounds fall inside
+// it. Aggregate results are cached by exact filter and range once the whole
+// range has settled.
+type CachingBackend struct {
+	inner       Backend
+	settleDelay time.Duration
+	window      time.Duration
+	maxBytes    int64
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front is most recently used
+	bytes   int64
+	stats   CacheStats
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+
+	events  []Event
+	buckets []Bucket
+}
+
+// NewCachingBackend wraps inner with a result cache.
+func NewCachingBackend(inner Backend, opts *CacheOptions) *CachingBackend {
+	if inner == nil {
+		panic("backend cannot be nil")
+	}
+	if opts == nil {
+		opts = &CacheOptions{}
+	}
+	c := &CachingBackend{
+		inner:       inner,
+		settleDelay: opts.SettleDelay,
+		window:      opts.Window,
+		maxBytes:    opts.MaxBytes,
+		now:         time.Now,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+	}
+	if c.settleDelay <= 0 {
+		c.settleDelay = DefaultCacheSettleDelay
+	}
+	if c.window <= 0 {
+		c.window = queryChunkDuration
+	}
+	if c.maxBytes <= 0 {
+		c.maxBytes = DefaultCacheMaxBytes
+	}
+	return c
+}
+
+// Stats returns a snapshot of the cache statistics.
+func (c *CachingBackend) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = len(c.entries)
+	s.Bytes = c.bytes
+	s.MaxBytes = c.maxBytes
+	return s
+}
+
+// Search returns audit events matching the filter, newest first.
+func (c *CachingBackend) Search(ctx context.Context, filter *SearchFilter) ([]Event, error) {
+	filter.Limit = c.QueryLimits().eventLimit(filter.Limit)
+
+	base := *filter
+	base.Start, base.End, base.Limit = time.Time{}, time.Time{}, 0
+	base.Namespace = normalizeNamespace(base.Namespace)
+	base.NamespacePrefix = normalizeNamespace(base.NamespacePrefix)
+
+	return c.windowedEvents(ctx, cacheKey("search", ctx, base), filter.Start, filter.End, filter.Limit,
+		func(ctx context.Context, start, end time.Time, limit int) ([]Event, error) {
+			f := base
+			f.Start, f.End, f.Limit = start, end, limit
+			return c.inner.Search(ctx, &f)
+		})
+}
+
+// Trace returns events for a request ID, newest first.
+func (c *CachingBackend) Trace(ctx context.Context, filter *TraceFilter) ([]Event, error) {
+	filter.Limit = c.QueryLimits().eventLimit(filter.Limit)
+	if filter.RequestID == "" {
+		return nil, fmt.Errorf("request_id is required")
+	}
+
+	return c.windowedEvents(ctx, cacheKey("trace", ctx, filter.RequestID), filter.Start, filter.End, filter.Limit,
+		func(ctx context.Context, start, end time.Time, limit int) ([]Event, error) {
+			return c.inner.Trace(ctx, &TraceFilter{Start: start, End: end, Limit: limit, RequestID: filter.RequestID})
+		})
+}
+
+// Aggregate returns bucketed counts, cached once the whole range has settled.
+func (c *CachingBackend) Aggregate(ctx context.Context, filter *AggregateFilter, by string) ([]Bucket, error) {
+	if !c.settled(filter.End) {
+		return c.inner.Aggregate(ctx, filter, by)
+	}
+
+	f := *filter
+	f.Namespace = normalizeNamespace(f.Namespace)
+	f.NamespacePrefix = normalizeNamespace(f.NamespacePrefix)
+	key := cacheKey("aggregate", ctx, struct {
+		By     string
+		Filter AggregateFilter
+	}{by, f})
+
+	if e, ok := c.get(key); ok {
+		return append([]Bucket(nil), e.buckets...), nil
+	}
+	buckets, err := c.inner.Aggregate(ctx, filter, by)
+	if err != nil {
+		// Partial counts are returned but never cached.
+		return buckets, err
+	}
+	c.put(&cacheEntry{key: key, buckets: append([]Bucket(nil), buckets...), size: estimateSize(buckets)})
+	return buckets, nil
+}
+
+// FindByHMAC delegates to the wrapped backend. HMAC lookups are not cached.
+func (c *CachingBackend) FindByHMAC(ctx context.Context, filter *HMACFilter) ([]HMACMatch, error) {
+	searcher, ok := c.inner.(HMACSearcher)
+	if !ok {
+		return nil, fmt.Errorf("backend does not support HMAC search")
+	}
+	return searcher.FindByHMAC(ctx, filter)
+}
+
+// SearchInstant delegates to the wrapped backend. Instant reads are not
+// cached.
+func (c *CachingBackend) SearchInstant(ctx context.Context, filter *SearchFilter, t time.Time) ([]Event, error) {
+	searcher, ok := c.inner.(instantSearcher)
+	if !ok {
+		return nil, errNoInstantSearch
+	}
+	return searcher.SearchInstant(ctx, filter, t)
+}
+
+// QueryLimits returns the limits of the wrapped backend.
+func (c *CachingBackend) QueryLimits() QueryLimits {
+	return limitsOf(c.inner)
+}
+
+type fetchFunc func(ctx context.Context, start, end time.Time, limit int) ([]Event, error)
+
+// windowedEvents walks aligned windows covering [start,end] newest first,
+// serving settled windows from the cache and clipping them to the range.
+// Adjacent windows missing from the cache, including unsettled ones, are
+// fetched with one inner call. Progress is reported per aligned window rather
+// than by the inner backend. When the deadline is reached the events gathered
+// so far are returned with ErrIncomplete.
+func (c *CachingBackend) windowedEvents(ctx context.Context, keyPrefix string, start, end time.Time, limit int, fetch fetchFunc) ([]Event, error) {
+	if err := c.QueryLimits().checkRange(start, end); err != nil {
+		return nil, err
+	}
+
+	innerCtx := WithProgress(ctx, nil)
+	windows := alignedWindowsReverse(start, end, c.window)
+	events := make([]Event, 0, limit)
+	var next *cacheEntry // entry found while looking for the end of a run
+	for i := 0; i < len(windows); {
+		if err := ctx.Err(); err != nil {
+			if err = deadlineErr(ctx, err); errors.Is(err, ErrIncomplete) {
+				return events, err
+			}
+			return nil, err
+		}
+		remaining := limit - len(events)
+		if remaining <= 0 {
+			break
+		}
+
+		e := next
+		if e == nil {
+			e = c.lookup(keyPrefix, windows[i])
+		}
+		next = nil
+		var windowEvents []Event
+		var err error
+		n, more := 1, true
+		if e != nil {
+			windowEvents = e.events
+		} else {
+			for i+n < len(windows) {
+				if next = c.lookup(keyPrefix, windows[i+n]); next != nil {
+					break
+				}
+				n++
+			}
+			windowEvents, more, err = c.fetchRun(innerCtx, keyPrefix, windows[i:i+n], start, end, remaining, fetch)
+			if err != nil && !errors.Is(err, ErrIncomplete) {
+				return nil, err
+			}
+		}
+
+		for _, ev := range windowEvents {
+			if ev.Time.Before(start) || ev.Time.After(end) {
+				continue
+			}
+			events = append(events, ev)
+			if len(events) >= limit {
+				break
+			}
+		}
+		if err != nil {
+			return events, err
+		}
+		i += n
+		reportProgress(ctx, Progress{WindowsScanned: i, EventsMatched: len(events), WindowsRemaining: len(windows) - i})
+		if !more {
+			break
+		}
+	}
+	return events, nil
+}
+
+// lookup returns the cache entry for a settled window, or nil when the window
+// is unsettled or not cached.
+func (c *CachingBackend) lookup(keyPrefix string, w timeWindow) *cacheEntry {
+	if !c.settled(w.End) {
+		return nil
+	}
+	e, _ := c.get(windowKey(keyPrefix, w))
+	return e
+}
+
+// fetchRun fetches adjacent windows, newest first, with one inner call over
+// their combined range clipped to [start,end], and caches the settled windows
+// the result covers completely. Each window keeps only the events in its
+// half-open range, so an event on a boundary is returned once.
+//
+// When the call hits its limit, the window holding the oldest event returned
+// is only partly covered; its events are returned and more is false, as the
+// result already reaches the limit of the query.
+func (c *CachingBackend) fetchRun(ctx context.Context, keyPrefix string, windows []timeWindow, start, end time.Time, remaining int, fetch fetchFunc) ([]Event, bool, error) {
+	newest, oldest := windows[0], windows[len(windows)-1]
+	fetchLimit := remaining
+	if c.settled(oldest.End) {
+		// Fetch settled windows completely so they can be cached.
+		fetchLimit = c.QueryLimits().MaxLimit
+	}
+	evs, err := fetch(ctx, maxTime(oldest.Start, start), minTime(newest.End, end), fetchLimit)
+	if err != nil && !errors.Is(err, ErrIncomplete) {
+		return nil, false, err
+	}
+	sortNewestFirst(evs)
+	// A result cut short by the limit still covers the windows newer than its
+	// oldest event; one cut short by the deadline is never cached.
+	complete := err == nil && len(evs) < fetchLimit
+
+	var out []Event
+	j := 0
+	for _, w := range windows {
+		// Events from w.End on belong to a newer window.
+		for j < len(evs) && !evs[j].Time.Before(w.End) {
+			j++
+		}
+		k := j
+		for k < len(evs) && !evs[k].Time.Before(w.Start) {
+			k++
+		}
+		windowEvents := evs[j:k:k]
+		j = k
+		out = append(out, windowEvents...)
+		if !complete && (len(evs) == 0 || !w.Start.After(evs[len(evs)-1].Time)) {
+			return out, false, err
+		}
+		if err == nil && c.settled(w.End) {
+			c.put(&cacheEntry{key: windowKey(keyPrefix, w), events: windowEvents, size: estimateSize(windowEvents)})
+		}
+	}
+	return out, true, err
+}
+
+// windowKey is the cache key of the events of one aligned window.
+func windowKey(keyPrefix string, w timeWindow) string {
+	return fmt.Sprintf("%s|%d", keyPrefix, w.Start.UnixNano())
+}
+
+func (c *CachingBackend) settled(end time.Time) bool {
+	return !end.After(c.now().Add(-c.settleDelay))
+}
+
+func (c *CachingBackend) get(key string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry), true
+}
+
+func (c *CachingBackend) put(e *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e.size > c.maxBytes {
+		return
+	}
+	if el, ok := c.entries[e.key]; ok {
+		c.bytes -= el.Value.(*cacheEntry).size
+		c.lru.Remove(el)
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.bytes += e.size
+
+	for c.bytes > c.maxBytes {
+		oldest := c.lru.Back()
+		if oldest == nil {
+			break
+		}
+		old := oldest.Value.(*cacheEntry)
+		c.lru.Remove(oldest)
+		delete(c.entries, old.key)
+		c.bytes -= old.size
+		c.stats.Evictions++
+	}
+}
+
+// cacheKey builds a key from a kind, the per-call tenant and a normalized
+// filter value.
+func cacheKey(kind string, ctx context.Context, v any) string {
+	b, _ := json.Marshal(v)
+	return kind + "|" + loki.TenantFromContext(ctx) + "|" + string(b)
+}
+
+// estimateSize approximates the memory held by v using its JSON encoding.
+func estimateSize(v any) int64 {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return int64(len(b))
+}
+
+// alignedWindowsReverse returns windows aligned to multiples of size that
+// cover [start,end], newest first. Alignment makes windows reusable across
+// queries with different bounds.
+func alignedWindowsReverse(start, end time.Time, size time.Duration) []timeWindow {
+	if !end.After(start) {
+		return []timeWindow{{Start: start.Truncate(size), End: start.Truncate(size).Add(size)}}
+	}
+	var windows []timeWindow
+	cursor := end.Truncate(size)
+	if cursor.Equal(end) {
+		cursor = cursor.Add(-size)
+	}
+	for cursor.Add(size).After(start) {
+		windows = append(windows, timeWindow{Start: cursor, End: cursor.Add(size)})
+		cursor = cursor.Add(-size)
+	}
+	return windows
+}
+
+// eventsInWindow keeps events in the half-open window [w.Start, w.End), so
+// an event on a boundary belongs to exactly one window.
+func eventsInWindow(events []Event, w timeWindow) []Event {
+	out := events[:0]
+	for _, ev := range events {
+		if !ev.Time.Before(w.Start) && ev.Time.Before(w.End) {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+func sortNewestFirst(events []Event) {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.After(events[j].Time) })
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,179 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// timedBackend serves fixed events by time range and counts backend calls.
+type timedBackend struct {
+	events    []Event
+	limits    QueryLimits
+	searches  int
+	aggregate int
+}
+
+func (b *timedBackend) QueryLimits() QueryLimits {
+	return b.limits
+}
+
+func (b *timedBackend) inRange(start, end time.Time, limit int) []Event {
+	var out []Event
+	for _, ev := range b.events {
+		if !ev.Time.Before(start) && !ev.Time.After(end) {
+			out = append(out, ev)
+			if len(out) >= limit {
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (b *timedBackend) Search(ctx context.Context, filter *SearchFilter) ([]Event, error) {
+	b.searches++
+	return b.inRange(filter.Start, filter.End, filter.Limit), nil
+}
+
+func (b *timedBackend) Aggregate(ctx context.Context, filter *AggregateFilter, by string) ([]Bucket, error) {
+	b.aggregate++
+	return []Bucket{{Key: "read", Value: 3}}, nil
+}
+
+func (b *timedBackend) Trace(ctx context.Context, filter *TraceFilter) ([]Event, error) {
+	return b.inRange(filter.Start, filter.End, filter.Limit), nil
+}
+
+func newTestCache(inner Backend, now time.Time) *CachingBackend {
+	c := NewCachingBackend(inner, &CacheOptions{SettleDelay: 5 * time.Minute, Window: 10 * time.Minute})
+	c.now = func() time.Time { return now }
+	return c
+}
+
+func TestCacheReusesSettledWindows(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	inner := &timedBackend{events: []Event{
+		{Time: now.Add(-95 * time.Minute), RequestID: "a"},
+		{Time: now.Add(-75 * time.Minute), RequestID: "b"},
+		{Time: now.Add(-65 * time.Minute), RequestID: "c"},
+	}}
+	cache := newTestCache(inner, now)
+	ctx := context.Background()
+
+	start, end := now.Add(-100*time.Minute), now.Add(-60*time.Minute)
+	first, err := cache.Search(ctx, &SearchFilter{Start: start, End: end})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(first) != 3 || first[0].RequestID != "c" {
+		t.Fatalf("got %v, want 3 events newest first", first)
+	}
+	calls := inner.searches
+
+	// An overlapping query inside the same windows is served from memory.
+	second, err := cache.Search(ctx, &SearchFilter{Start: now.Add(-80 * time.Minute), End: end})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if inner.searches != calls {
+		t.Errorf("overlapping search made %d backend calls, want 0", inner.searches-calls)
+	}
+	if len(second) != 2 {
+		t.Errorf("got %d events, want 2 clipped to the narrower range", len(second))
+	}
+
+	stats := cache.Stats()
+	if stats.Hits == 0 || stats.Misses == 0 || stats.Entries == 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCacheSkipsUnsettledWindows(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	inner := &timedBackend{events: []Event{{Time: now.Add(-time.Minute), RequestID: "fresh"}}}
+	cache := newTestCache(inner, now)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Search(ctx, &SearchFilter{Start: now.Add(-3 * time.Minute), End: now}); err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+	}
+	if inner.searches != 2 {
+		t.Errorf("recent window should not be cached, got %d backend calls", inner.searches)
+	}
+	if cache.Stats().Entries != 0 {
+		t.Error("no entries should be cached for unsettled windows")
+	}
+}
+
+func TestCacheFetchesAdjacentMissesTogether(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	inner := &timedBackend{events: []Event{
+		{Time: now.Add(-10 * time.Minute), RequestID: "boundary"},
+		{Time: now.Add(-30 * time.Minute), RequestID: "b"},
+		{Time: now.Add(-55 * time.Minute), RequestID: "c"},
+	}}
+	cache := newTestCache(inner, now)
+	cache.settleDelay = 15 * time.Minute
+	ctx := context.Background()
+
+	// Six windows, the two newest unsettled, with an event on the boundary
+	// between them.
+	for i := range 2 {
+		calls := inner.searches
+		events, err := cache.Search(ctx, &SearchFilter{Start: now.Add(-time.Hour), End: now})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(events) != 3 || events[0].RequestID != "boundary" {
+			t.Errorf("search %d got %v, want 3 events with the boundary event once", i, events)
+		}
+		if got := inner.searches - calls; got != 1 {
+			t.Errorf("search %d made %d backend calls, want 1", i, got)
+		}
+	}
+}
+
+func TestCacheAggregateAndEviction(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	inner := &timedBackend{}
+	cache := newTestCache(inner, now)
+	cache.maxBytes = 64
+	ctx := context.Background()
+
+	past := &AggregateFilter{Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)}
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Aggregate(ctx, past, LabelOperation); err != nil {
+			t.Fatalf("Aggregate failed: %v", err)
+		}
+	}
+	if inner.aggregate != 1 {
+		t.Errorf("settled aggregate made %d backend calls, want 1", inner.aggregate)
+	}
+
+	for i := 0; i < 5; i++ {
+		f := &AggregateFilter{Start: now.Add(-time.Duration(i+3) * time.Hour), End: now.Add(-time.Hour)}
+		cache.Aggregate(ctx, f, LabelOperation)
+	}
+	stats := cache.Stats()
+	if stats.Bytes > stats.MaxBytes || stats.Evictions == 0 {
+		t.Errorf("cache should stay within its memory bound, got %+v", stats)
+	}
+}
+
+func TestAlignedWindowsReverse(t *testing.T) {
+	start := time.Date(2026, 3, 1, 10, 5, 0, 0, time.UTC)
+	end := time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC)
+	windows := alignedWindowsReverse(start, end, 10*time.Minute)
+	if len(windows) != 3 {
+		t.Fatalf("got %d windows, want 3", len(windows))
+	}
+	if !windows[0].Start.Equal(time.Date(2026, 3, 1, 10, 20, 0, 0, time.UTC)) {
+		t.Errorf("newest window starts at %v", windows[0].Start)
+	}
+	if !windows[2].Start.Equal(time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("oldest window starts at %v", windows[2].Start)
+	}
+}
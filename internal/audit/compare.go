@@ -0,0 +1,368 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Comparison defaults.
+const (
+	DefaultBaselineOffset   = "7d"
+	DefaultChangeThreshold  = 50.0
+	DefaultChangeMinCount   = 5
+	DefaultCompareTop       = 10
+	DefaultCompareMaxEvents = 100000
+)
+
+// Dimensions compared by CompareWindows.
+const (
+	CompareNamespace  = "namespace"
+	CompareOperation  = "operation"
+	CompareMountType  = "mount_type"
+	ComparePath       = "path"
+	CompareActor      = "actor"
+	CompareCategory   = "category"
+	CompareErrorClass = "error_class"
+)
+
+var compareDimensions = []string{CompareNamespace, CompareOperation, CompareMountType, ComparePath, CompareActor, CompareCategory, CompareErrorClass}
+
+// Change statuses of a compared entry.
+const (
+	ChangeNew         = "new"
+	ChangeDisappeared = "disappeared"
+	ChangeIncreased   = "increased"
+	ChangeDecreased   = "decreased"
+)
+
+// CompareQuery runs one filter over a current and a baseline window.
+type CompareQuery struct {
+	Filter        SearchFilter
+	BaselineStart time.Time
+	BaselineEnd   time.Time
+	// ThresholdPercent and MinCount flag entries whose count changed by at
+	// least this percentage and this many events.
+	ThresholdPercent float64
+	MinCount         int
+	// Top caps the entries listed per dimension.
+	Top int
+	// MaxEvents caps the events counted per window; 0 means no cap.
+	MaxEvents int
+}
+
+// WindowComparison holds the per-dimension deltas between two windows.
+type WindowComparison struct {
+	Current  WindowStats `json:"current"`
+	Baseline WindowStats `json:"baseline"`
+	// BaselineScale is applied to baseline counts when the windows differ in
+	// length, so that both are compared per current window.
+	BaselineScale float64 `json:"baseline_scale,omitempty"`
+	// ErrorRateChange is the change of the error rate in percentage points.
+	ErrorRateChange float64          `json:"error_rate_change"`
+	Dimensions      []DimensionDelta `json:"dimensions"`
+	// Highlights describe the flagged changes, largest first.
+	Highlights []string `json:"highlights,omitempty"`
+	Incomplete bool     `json:"incomplete,omitempty"`
+}
+
+// WindowStats summarizes one window.
+type WindowStats struct {
+	Start  string `json:"start"`
+	End    string `json:"end"`
+	Events int    `json:"events"`
+	Errors int    `json:"errors"`
+	// ErrorRate is the percentage of events with status error.
+	ErrorRate float64 `json:"error_rate"`
+	// Truncated is set when MaxEvents stopped counting early.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// DimensionDelta lists the entries of one dimension by size of change.
+type DimensionDelta struct {
+	Dimension   string       `json:"dimension"`
+	New         int          `json:"new"`
+	Disappeared int          `json:"disappeared"`
+	Changed     int          `json:"changed"`
+	Entries     []EntryDelta `json:"entries"`
+}
+
+// EntryDelta compares the count of one value in both windows.
+type EntryDelta struct {
+	Key      string  `json:"key"`
+	Current  float64 `json:"current"`
+	Baseline float64 `json:"baseline"`
+	Change   float64 `json:"change"`
+	// ChangePercent is relative to the baseline; omitted for new entries.
+	ChangePercent *float64 `json:"change_percent,omitempty"`
+	// Status is new, disappeared, increased or decreased for flagged
+	// entries, and empty otherwise.
+	Status string `json:"status,omitempty"`
+}
+
+// windowCounts holds the counts of one window.
+type windowCounts struct {
+	events, errors int
+	dims           map[string]map[string]int
+}
+
+func newWindowCounts() *windowCounts {
+	w := &windowCounts{dims: make(map[string]map[string]int)}
+	for _, d := range compareDimensions {
+		w.dims[d] = make(map[string]int)
+	}
+	return w
+}
+
+func (w *windowCounts) add(ev *Event) {
+	w.events++
+	if ev.Status == "error" {
+		w.errors++
+	}
+	for _, d := range compareDimensions {
+		if key := dimensionValue(d, ev); key != "" {
+			w.dims[d][key]++
+		}
+	}
+}
+
+// dimensionValue returns the key of ev in a comparison dimension, or "" when
+// the dimension does not apply, as error_class for successful events.
+func dimensionValue(dimension string, ev *Event) string {
+	switch dimension {
+	case CompareNamespace:
+		return namespaceName(policyNamespace(ev.Namespace))
+	case CompareOperation:
+		return noneIfEmpty(ev.Operation)
+	case CompareMountType:
+		return noneIfEmpty(ev.MountType)
+	case ComparePath:
+		return noneIfEmpty(strings.TrimPrefix(ev.Path, "/"))
+	case CompareActor:
+		return eventActor(ev)
+	case CompareCategory:
+		return string(AnalyzeEvent(ev).Category)
+	case CompareErrorClass:
+		if ev.Status == "error" {
+			return classifyError(eventError(ev), ev.PolicyResults)
+		}
+	}
+	return ""
+}
+
+// noneIfEmpty keys missing values as aggregations do.
+func noneIfEmpty(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+// eventActor names the actor of an event: its display name, else its
+// entity.
+func eventActor(ev *Event) string {
+	switch {
+	case ev.Display != "":
+		return ev.Display
+	case ev.EntityName != "":
+		return "entity " + ev.EntityName
+	case ev.EntityID != "":
+		return "entity " + ev.EntityID
+	}
+	return "(unknown)"
+}
+
+func (w *windowCounts) stats(start, end time.Time, truncated bool) WindowStats {
+	s := WindowStats{
+		Start:     start.UTC().Format(time.RFC3339),
+		End:       end.UTC().Format(time.RFC3339),
+		Events:    w.events,
+		Errors:    w.errors,
+		Truncated: truncated,
+	}
+	if w.events > 0 {
+		s.ErrorRate = roundPercent(float64(w.errors) / float64(w.events) * 100)
+	}
+	return s
+}
+
+func roundPercent(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// countWindow counts the events matching filter between start and end.
+func countWindow(ctx context.Context, backend Backend, filter SearchFilter, start, end time.Time, maxEvents int) (*windowCounts, bool, error) {
+	filter.Start, filter.End = start, end
+	counts := newWindowCounts()
+	_, truncated, err := exportEvents(ctx, backend, filter, maxEvents, func(ev *Event) error {
+		counts.add(ev)
+		return nil
+	})
+	return counts, truncated, err
+}
+
+// compareDimension builds the delta of one dimension, with the baseline
+// scaled by scale.
+func compareDimension(name string, cur, base map[string]int, scale float64, q *CompareQuery) DimensionDelta {
+	d := DimensionDelta{Dimension: name, Entries: []EntryDelta{}}
+	keys := make(map[string]bool, len(cur)+len(base))
+	for k := range cur {
+		keys[k] = true
+	}
+	for k := range base {
+		keys[k] = true
+	}
+	var entries []EntryDelta
+	for k := range keys {
+		e := EntryDelta{
+			Key:      k,
+			Current:  float64(cur[k]),
+			Baseline: math.Round(float64(base[k])*scale*10) / 10,
+		}
+		e.Change = math.Round((e.Current-e.Baseline)*10) / 10
+		if e.Baseline > 0 {
+			pct := roundPercent(e.Change / e.Baseline * 100)
+			e.ChangePercent = &pct
+		}
+		e.Status = changeStatus(&e, cur[k], base[k], q)
+		switch e.Status {
+		case ChangeNew:
+			d.New++
+		case ChangeDisappeared:
+			d.Disappeared++
+		case ChangeIncreased, ChangeDecreased:
+			d.Changed++
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if (a.Status != "") != (b.Status != "") {
+			return a.Status != ""
+		}
+		if ca, cb := math.Abs(a.Change), math.Abs(b.Change); ca != cb {
+			return ca > cb
+		}
+		return a.Key < b.Key
+	})
+	if q.Top > 0 && len(entries) > q.Top {
+		entries = entries[:q.Top]
+	}
+	if entries != nil {
+		d.Entries = entries
+	}
+	return d
+}
+
+// changeStatus flags an entry present in only one window, or whose count
+// changed by at least the threshold percentage and the minimum count.
+func changeStatus(e *EntryDelta, cur, base int, q *CompareQuery) string {
+	switch {
+	case base == 0 && cur >= q.MinCount:
+		return ChangeNew
+	case cur == 0 && base >= q.MinCount:
+		return ChangeDisappeared
+	case cur == 0 || base == 0 || math.Abs(e.Change) < float64(q.MinCount):
+		return ""
+	case e.ChangePercent != nil && *e.ChangePercent >= q.ThresholdPercent:
+		return ChangeIncreased
+	case e.ChangePercent != nil && -*e.ChangePercent >= q.ThresholdPercent:
+		return ChangeDecreased
+	}
+	return ""
+}
+
+// errorRateChanged reports whether the error rate changed by at least the
+// threshold percentage, with at least the minimum count of errors more or
+// fewer.
+func errorRateChanged(res *WindowComparison, errorChange float64, q *CompareQuery) bool {
+	if errorChange < float64(q.MinCount) || res.ErrorRateChange == 0 {
+		return false
+	}
+	if res.Baseline.ErrorRate == 0 {
+		return true
+	}
+	return math.Abs(res.ErrorRateChange)/res.Baseline.ErrorRate*100 >= q.ThresholdPercent
+}
+
+// highlight describes a flagged entry.
+func highlight(dimension string, e *EntryDelta) string {
+	switch e.Status {
+	case ChangeNew:
+		return fmt.Sprintf("new %s %s: %g events", dimension, e.Key, e.Current)
+	case ChangeDisappeared:
+		return fmt.Sprintf("%s %s disappeared: %g events before", dimension, e.Key, e.Baseline)
+	}
+	return fmt.Sprintf("%s %s %s %+g%% (%g -> %g)", dimension, e.Key, e.Status, *e.ChangePercent, e.Baseline, e.Current)
+}
+
+// CompareWindows counts the events matching q.Filter in its window and in
+// the baseline window, and returns per-dimension deltas flagging entries
+// that are new, disappeared, or changed beyond the threshold. Baseline
+// counts are scaled when the windows differ in length.
+func CompareWindows(ctx context.Context, backend Backend, q CompareQuery) (*WindowComparison, error) {
+	if q.ThresholdPercent <= 0 {
+		q.ThresholdPercent = DefaultChangeThreshold
+	}
+	if q.MinCount <= 0 {
+		q.MinCount = DefaultChangeMinCount
+	}
+	if q.Top <= 0 {
+		q.Top = DefaultCompareTop
+	}
+
+	cur, curTruncated, err := countWindow(ctx, backend, q.Filter, q.Filter.Start, q.Filter.End, q.MaxEvents)
+	incomplete := errors.Is(err, ErrIncomplete)
+	if err != nil && !incomplete {
+		return nil, err
+	}
+	base, baseTruncated, err := countWindow(ctx, backend, q.Filter, q.BaselineStart, q.BaselineEnd, q.MaxEvents)
+	if errors.Is(err, ErrIncomplete) {
+		incomplete = true
+	} else if err != nil {
+		return nil, err
+	}
+
+	res := &WindowComparison{
+		Current:    cur.stats(q.Filter.Start, q.Filter.End, curTruncated),
+		Baseline:   base.stats(q.BaselineStart, q.BaselineEnd, baseTruncated),
+		Incomplete: incomplete,
+	}
+	scale := 1.0
+	if curLen, baseLen := q.Filter.End.Sub(q.Filter.Start), q.BaselineEnd.Sub(q.BaselineStart); baseLen > 0 && curLen != baseLen {
+		scale = float64(curLen) / float64(baseLen)
+		res.BaselineScale = math.Round(scale*1000) / 1000
+	}
+	res.ErrorRateChange = roundPercent(res.Current.ErrorRate - res.Baseline.ErrorRate)
+
+	type flagged struct {
+		text   string
+		change float64
+	}
+	var flags []flagged
+	if errorRateChanged(res, math.Abs(float64(cur.errors)-float64(base.errors)*scale), &q) {
+		text := fmt.Sprintf("error rate %.1f%% -> %.1f%% (%+.1f points)", res.Baseline.ErrorRate, res.Current.ErrorRate, res.ErrorRateChange)
+		flags = append(flags, flagged{text, math.Inf(1)})
+	}
+	for _, name := range compareDimensions {
+		d := compareDimension(name, cur.dims[name], base.dims[name], scale, &q)
+		for i := range d.Entries {
+			if e := &d.Entries[i]; e.Status != "" {
+				flags = append(flags, flagged{highlight(strings.ReplaceAll(name, "_", " "), e), math.Abs(e.Change)})
+			}
+		}
+		res.Dimensions = append(res.Dimensions, d)
+	}
+	sort.SliceStable(flags, func(i, j int) bool { return flags[i].change > flags[j].change })
+	for i, f := range flags {
+		if i == 2*q.Top {
+			break
+		}
+		res.Highlights = append(res.Highlights, f.text)
+	}
+	return res, nil
+}
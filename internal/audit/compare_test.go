@@ -0,0 +1,139 @@
+package audit
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// compareEvents returns a current hour ending at end and a two-hour baseline
+// a week earlier with twice the traffic.
+func compareEvents(end time.Time) []Event {
+	denied := map[string]any{"error": "1 error occurred:\n\t* permission denied\n\n"}
+	var events []Event
+	add := func(n int, at time.Time, ev Event) {
+		for i := range n {
+			ev.Time = at.Add(-time.Duration(i) * time.Minute)
+			events = append(events, ev)
+		}
+	}
+	add(10, end.Add(-time.Minute), Event{Display: "approle-app", Operation: "read", Path: "secret/data/app", MountType: "kv"})
+	add(6, end.Add(-20*time.Minute), Event{Display: "oidc-eve", Operation: "delete", Path: "secret/data/new", MountType: "kv", Status: "error", Raw: denied})
+
+	base := end.Add(-7 * 24 * time.Hour)
+	add(20, base.Add(-time.Minute), Event{Display: "approle-app", Operation: "read", Path: "secret/data/app", MountType: "kv"})
+	add(10, base.Add(-30*time.Minute), Event{Display: "approle-web", Operation: "update", Path: "pki/issue/web", MountType: "pki"})
+	add(2, base.Add(-90*time.Minute), Event{Display: "approle-app", Operation: "read", Path: "secret/data/app", MountType: "kv", Status: "error", Raw: denied})
+	return events
+}
+
+func findDelta(t *testing.T, cmp *WindowComparison, dimension, key string) EntryDelta {
+	t.Helper()
+	for _, d := range cmp.Dimensions {
+		if d.Dimension != dimension {
+			continue
+		}
+		for _, e := range d.Entries {
+			if e.Key == key {
+				return e
+			}
+		}
+	}
+	t.Fatalf("no %s entry %q", dimension, key)
+	return EntryDelta{}
+}
+
+func TestCompareWindows(t *testing.T) {
+	end := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	base := end.Add(-7 * 24 * time.Hour)
+	cmp, err := CompareWindows(t.Context(), &timedBackend{events: compareEvents(end), limits: QueryLimits{MaxLimit: 5, MaxDays: 1}}, CompareQuery{
+		Filter:        SearchFilter{Start: end.Add(-time.Hour), End: end},
+		BaselineStart: base.Add(-2 * time.Hour),
+		BaselineEnd:   base,
+	})
+	if err != nil {
+		t.Fatalf("CompareWindows failed: %v", err)
+	}
+
+	if cmp.Current.Events != 16 || cmp.Current.Errors != 6 || cmp.Current.ErrorRate != 37.5 {
+		t.Errorf("current = %+v", cmp.Current)
+	}
+	if cmp.Baseline.Events != 32 || cmp.Baseline.Errors != 2 || cmp.BaselineScale != 0.5 {
+		t.Errorf("baseline = %+v, scale %g", cmp.Baseline, cmp.BaselineScale)
+	}
+	if cmp.ErrorRateChange != 31.2 {
+		t.Errorf("error rate change = %g, want 31.2", cmp.ErrorRateChange)
+	}
+
+	tests := []struct {
+		dimension, key string
+		current        float64
+		baseline       float64
+		status         string
+	}{
+		{CompareOperation, "delete", 6, 0, ChangeNew},
+		{CompareOperation, "update", 0, 5, ChangeDisappeared},
+		{CompareOperation, "read", 10, 11, ""},
+		{CompareActor, "oidc-eve", 6, 0, ChangeNew},
+		{CompareMountType, "pki", 0, 5, ChangeDisappeared},
+		{CompareErrorClass, "permission_denied", 6, 1, ChangeIncreased},
+	}
+	for _, tt := range tests {
+		e := findDelta(t, cmp, tt.dimension, tt.key)
+		if e.Current != tt.current || e.Baseline != tt.baseline || e.Status != tt.status {
+			t.Errorf("%s %s = %+v, want %g/%g %q", tt.dimension, tt.key, e, tt.current, tt.baseline, tt.status)
+		}
+	}
+	if e := findDelta(t, cmp, CompareErrorClass, "permission_denied"); e.ChangePercent == nil || *e.ChangePercent != 500 {
+		t.Errorf("permission_denied change = %v, want 500%%", e.ChangePercent)
+	}
+
+	if len(cmp.Highlights) == 0 || !strings.HasPrefix(cmp.Highlights[0], "error rate 6.3% -> 37.5%") {
+		t.Errorf("highlights = %q", cmp.Highlights)
+	}
+	if !slices.Contains(cmp.Highlights, "new actor oidc-eve: 6 events") {
+		t.Errorf("highlights lack the new actor: %q", cmp.Highlights)
+	}
+}
+
+func TestCompareWindowsTool(t *testing.T) {
+	end := time.Now().UTC().Add(-time.Minute)
+	svc := NewService(&timedBackend{events: compareEvents(end), limits: QueryLimits{MaxLimit: 5, MaxDays: 1}})
+	session := connectService(t, svc)
+
+	res, err := session.CallTool(t.Context(), &mcp.CallToolParams{
+		Name:      "audit.compare_windows",
+		Arguments: map[string]any{"last": "1h", "baseline_start_rfc3339": "-8d"},
+	})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if !res.IsError {
+		t.Error("a baseline start without an end should fail")
+	}
+
+	res, err = session.CallTool(t.Context(), &mcp.CallToolParams{
+		Name:      "audit.compare_windows",
+		Arguments: map[string]any{"last": "1h"},
+	})
+	if err != nil || res.IsError {
+		t.Fatalf("compare_windows failed: %v %v", err, res)
+	}
+	var cmp WindowComparison
+	raw, _ := json.Marshal(res.StructuredContent)
+	if err := json.Unmarshal(raw, &cmp); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	// The default baseline is the same hour a week earlier, which holds all
+	// but the errors of the two-hour baseline traffic.
+	if cmp.Current.Events != 16 || cmp.Baseline.Events != 30 || cmp.BaselineScale != 0 {
+		t.Errorf("current %+v, baseline %+v", cmp.Current, cmp.Baseline)
+	}
+	if e := findDelta(t, &cmp, CompareOperation, "read"); e.Status != ChangeDecreased {
+		t.Errorf("read = %+v, want decreased", e)
+	}
+}
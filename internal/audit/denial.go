@@ -0,0 +1,452 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Error classes reported by ExplainDenial.
+const (
+	ErrorClassPermissionDenied   = "permission_denied"
+	ErrorClassInvalidToken       = "invalid_token"
+	ErrorClassInvalidCredentials = "invalid_credentials"
+	ErrorClassUnsupportedPath    = "unsupported_path"
+	ErrorClassNamespaceNotFound  = "namespace_not_found"
+	ErrorClassRateLimited        = "rate_limited"
+	ErrorClassInvalidRequest     = "invalid_request"
+	ErrorClassRedacted           = "redacted"
+	ErrorClassOther              = "other"
+	// ErrorClassNone marks a request that did not fail.
+	ErrorClassNone = "none"
+)
+
+// denialContrastWindow is how far before a denial ExplainDenial looks for
+// the actor's successful accesses to similar paths.
+const denialContrastWindow = 7 * 24 * time.Hour
+
+// errorClasses maps Vault error substrings to classes, most specific first.
+var errorClasses = []struct{ substr, class string }{
+	{"permission denied", ErrorClassPermissionDenied},
+	{"missing client token", ErrorClassInvalidToken},
+	{"invalid token", ErrorClassInvalidToken},
+	{"bad token", ErrorClassInvalidToken},
+	{"invalid credentials", ErrorClassInvalidCredentials},
+	{"invalid username or password", ErrorClassInvalidCredentials},
+	{"unsupported path", ErrorClassUnsupportedPath},
+	{"unsupported operation", ErrorClassUnsupportedPath},
+	{"no handler for route", ErrorClassUnsupportedPath},
+	{"namespace not found", ErrorClassNamespaceNotFound},
+	{"rate limit", ErrorClassRateLimited},
+	{"invalid request", ErrorClassInvalidRequest},
+}
+
+// DenialQuery selects the denied request: by RequestID, or the most recent
+// failed request matching the actor, path and namespace in [Start,End].
+type DenialQuery struct {
+	Start     time.Time
+	End       time.Time
+	RequestID string
+
+	DisplayName string
+	EntityID    string
+	// Path is a request path prefix or, with wildcards, a glob.
+	Path      string
+	Namespace string
+}
+
+// DenialExplanation describes why a request failed.
+type DenialExplanation struct {
+	RequestID  string `json:"request_id"`
+	Time       string `json:"time"`
+	ErrorClass string `json:"error_class"`
+	// Error is the error as left by the redaction policy.
+	Error string `json:"error,omitempty"`
+
+	Namespace  string `json:"namespace,omitempty"`
+	Path       string `json:"path"`
+	Operation  string `json:"operation,omitempty"`
+	MountPoint string `json:"mount_point,omitempty"`
+	MountType  string `json:"mount_type,omitempty"`
+
+	DisplayName string `json:"display_name,omitempty"`
+	EntityID    string `json:"entity_id,omitempty"`
+	RemoteAddr  string `json:"remote_address,omitempty"`
+	TokenType   string `json:"token_type,omitempty"`
+	RoleName    string `json:"role_name,omitempty"`
+	// EntityName and Groups come from the identity snapshot.
+	EntityName string   `json:"entity_name,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+
+	Policies         []string       `json:"policies,omitempty"`
+	TokenPolicies    []string       `json:"token_policies,omitempty"`
+	IdentityPolicies []string       `json:"identity_policies,omitempty"`
+	PolicyResults    *PolicyResults `json:"policy_results,omitempty"`
+
+	// Findings are short observations to start the investigation from.
+	Findings []string `json:"findings"`
+
+	Request  *Event `json:"request,omitempty"`
+	Response *Event `json:"response,omitempty"`
+
+	// RecentSuccesses are the actor's most recent successful requests to the
+	// most similar paths in the week before the denial, without raw data.
+	RecentSuccesses []Event `json:"recent_successes"`
+
+	Incomplete bool `json:"incomplete,omitempty"`
+
+	at time.Time
+}
+
+// ErrNoDenial is returned when no failed request matches a DenialQuery.
+var ErrNoDenial = errors.New("no failed request found")
+
+// ExplainDenial finds the failed request selected by q, pairs its request
+// and response entries and contrasts it with the actor's recent successful
+// access to similar paths. When a query deadline is reached the explanation
+// gathered so far is returned with ErrIncomplete.
+func ExplainDenial(ctx context.Context, backend Backend, q *DenialQuery) (*DenialExplanation, error) {
+	if q.RequestID == "" && q.DisplayName == "" && q.EntityID == "" && q.Path == "" {
+		return nil, fmt.Errorf("request_id, or at least one of display_name, entity_id or path, is required")
+	}
+
+	var incomplete bool
+	requestID := q.RequestID
+	if requestID == "" {
+		filter := &SearchFilter{
+			Start:       q.Start,
+			End:         q.End,
+			Limit:       limitsOf(backend).MaxLimit,
+			Status:      "error",
+			Namespace:   q.Namespace,
+			EntityID:    q.EntityID,
+			DisplayName: q.DisplayName,
+		}
+		if strings.ContainsAny(q.Path, "*?") {
+			filter.PathGlob = q.Path
+		} else {
+			filter.PathPrefix = q.Path
+		}
+		failed, err := backend.Search(ctx, filter)
+		if err != nil && !errors.Is(err, ErrIncomplete) {
+			return nil, err
+		}
+		incomplete = err != nil
+		if len(failed) == 0 {
+			if incomplete {
+				return nil, fmt.Errorf("%w before the query deadline", ErrNoDenial)
+			}
+			return nil, ErrNoDenial
+		}
+		requestID = failed[0].RequestID
+		if requestID == "" {
+			return nil, fmt.Errorf("the most recent failed request has no request ID")
+		}
+	}
+
+	events, err := backend.Trace(ctx, &TraceFilter{Start: q.Start, End: q.End, Limit: DefaultLimit, RequestID: requestID})
+	if err != nil && !errors.Is(err, ErrIncomplete) {
+		return nil, err
+	}
+	incomplete = incomplete || err != nil
+	// Trace matches the ID anywhere in the line; keep the request's own entries.
+	events = slices.DeleteFunc(events, func(ev Event) bool { return ev.RequestID != requestID })
+	if len(events) == 0 {
+		return nil, fmt.Errorf("%w for request_id %s", ErrNoDenial, requestID)
+	}
+
+	exp := newDenialExplanation(requestID, events)
+	exp.Incomplete = incomplete
+
+	if exp.EntityID != "" || exp.DisplayName != "" {
+		successes, err := recentSuccesses(ctx, backend, exp)
+		if err != nil && !errors.Is(err, ErrIncomplete) {
+			return nil, err
+		}
+		exp.Incomplete = exp.Incomplete || err != nil
+		exp.RecentSuccesses = successes
+	}
+	if exp.RecentSuccesses == nil {
+		exp.RecentSuccesses = []Event{}
+	}
+	exp.Findings = denialFindings(exp)
+
+	if exp.Incomplete {
+		return exp, ErrIncomplete
+	}
+	return exp, nil
+}
+
+// newDenialExplanation builds the explanation from the entries of one
+// request, preferring the failed response entry.
+func newDenialExplanation(requestID string, events []Event) *DenialExplanation {
+	exp := &DenialExplanation{RequestID: requestID}
+	for i := range events {
+		ev := events[i]
+		switch {
+		case ev.AuditType == "response" && (exp.Response == nil || ev.Status == "error"):
+			exp.Response = &ev
+		case ev.AuditType != "response" && exp.Request == nil:
+			exp.Request = &ev
+		}
+	}
+
+	// The response carries the error; the request fills anything it lacks.
+	var sources []*Event
+	for _, ev := range []*Event{exp.Response, exp.Request} {
+		if ev != nil {
+			sources = append(sources, ev)
+		}
+	}
+	str := func(get func(ev *Event) string) string {
+		for _, ev := range sources {
+			if v := get(ev); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	list := func(get func(ev *Event) []string) []string {
+		for _, ev := range sources {
+			if v := get(ev); len(v) > 0 {
+				return v
+			}
+		}
+		return nil
+	}
+
+	exp.at = sources[0].Time.UTC()
+	exp.Time = exp.at.Format(time.RFC3339Nano)
+	exp.Namespace = str(func(ev *Event) string { return ev.Namespace })
+	exp.Path = str(func(ev *Event) string { return ev.Path })
+	exp.Operation = str(func(ev *Event) string { return ev.Operation })
+	exp.MountPoint = str(func(ev *Event) string { return ev.MountPoint })
+	exp.MountType = str(func(ev *Event) string { return ev.MountType })
+	exp.DisplayName = str(func(ev *Event) string { return ev.Display })
+	exp.EntityID = str(func(ev *Event) string { return ev.EntityID })
+	exp.RemoteAddr = str(func(ev *Event) string { return ev.RemoteAddr })
+	exp.TokenType = str(func(ev *Event) string { return ev.TokenType })
+	exp.RoleName = str(func(ev *Event) string { return ev.RoleName })
+	exp.EntityName = str(func(ev *Event) string { return ev.EntityName })
+	exp.Groups = list(func(ev *Event) []string { return ev.Groups })
+	exp.Policies = list(func(ev *Event) []string { return ev.Policies })
+	exp.TokenPolicies = list(func(ev *Event) []string { return ev.TokenPolicies })
+	exp.IdentityPolicies = list(func(ev *Event) []string { return ev.IdentityPolicies })
+	for _, ev := range sources {
+		if ev.PolicyResults != nil {
+			exp.PolicyResults = ev.PolicyResults
+			break
+		}
+	}
+
+	for _, ev := range sources {
+		if e := eventError(ev); e != "" {
+			exp.Error = e
+			break
+		}
+	}
+	exp.ErrorClass = ErrorClassNone
+	if slices.ContainsFunc(sources, func(ev *Event) bool { return ev.Status == "error" }) {
+		exp.ErrorClass = classifyError(exp.Error, exp.PolicyResults)
+	}
+	return exp
+}
+
+// eventError returns the (redacted) error of an audit entry.
+func eventError(ev *Event) string {
+	switch e := ev.Raw["error"].(type) {
+	case string:
+		return e
+	case nil:
+	default:
+		return fmt.Sprint(e)
+	}
+	if errs := stringList(ev.Raw["errors"]); len(errs) > 0 {
+		return strings.Join(errs, "; ")
+	}
+	return ""
+}
+
+// classifyError maps a Vault error to an error class. A denial recorded in
+// policy_results is a permission error even when the message was redacted.
+func classifyError(msg string, pr *PolicyResults) string {
+	lower := strings.ToLower(msg)
+	for _, c := range errorClasses {
+		if strings.Contains(lower, c.substr) {
+			return c.class
+		}
+	}
+	if pr != nil && !pr.Allowed {
+		return ErrorClassPermissionDenied
+	}
+	if msg == "" || strings.Contains(msg, redactedValue) {
+		return ErrorClassRedacted
+	}
+	return ErrorClassOther
+}
+
+// recentSuccesses returns the actor's most recent successful responses on the
+// same mount before the denial, most similar path first, one per path.
+func recentSuccesses(ctx context.Context, backend Backend, exp *DenialExplanation) ([]Event, error) {
+	prefix := exp.MountPoint
+	if prefix == "" {
+		prefix, _, _ = strings.Cut(trimPath(exp.Path), "/")
+	}
+	limits := limitsOf(backend)
+	start := exp.at.Add(-denialContrastWindow)
+	if maxRange := limits.MaxRange(); denialContrastWindow > maxRange {
+		start = exp.at.Add(-maxRange)
+	}
+	filter := &SearchFilter{
+		Start:      start,
+		End:        exp.at,
+		Limit:      limits.MaxLimit,
+		Status:     "ok",
+		AuditType:  "response",
+		Namespace:  exp.Namespace,
+		PathPrefix: prefix,
+	}
+	if exp.EntityID != "" {
+		filter.EntityID = exp.EntityID
+	} else {
+		filter.DisplayName = exp.DisplayName
+	}
+	events, err := backend.Search(ctx, filter)
+	if err != nil && !errors.Is(err, ErrIncomplete) {
+		return nil, err
+	}
+
+	// Events are newest first, so the first per path is the most recent.
+	seen := make(map[string]bool)
+	var out []Event
+	for _, ev := range events {
+		if ev.RequestID == exp.RequestID || seen[ev.Path] {
+			continue
+		}
+		seen[ev.Path] = true
+		ev.Raw = nil
+		out = append(out, ev)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return sharedPrefix(out[i].Path, exp.Path) > sharedPrefix(out[j].Path, exp.Path)
+	})
+	if len(out) > 5 {
+		out = out[:5]
+	}
+	return out, err
+}
+
+// sharedPrefix returns the number of leading path segments a and b share.
+func sharedPrefix(a, b string) int {
+	as, bs := strings.Split(trimPath(a), "/"), strings.Split(trimPath(b), "/")
+	n := 0
+	for n < len(as) && n < len(bs) && as[n] == bs[n] {
+		n++
+	}
+	return n
+}
+
+// denialFindings summarizes what the explanation shows.
+func denialFindings(exp *DenialExplanation) []string {
+	findings := []string{}
+	add := func(format string, args ...any) {
+		findings = append(findings, fmt.Sprintf(format, args...))
+	}
+
+	policies := uniqueStringsOf(exp.Policies, exp.TokenPolicies, exp.IdentityPolicies)
+	switch exp.ErrorClass {
+	case ErrorClassNone:
+		add("Request %s did not fail.", exp.RequestID)
+	case ErrorClassPermissionDenied:
+		if len(policies) == 0 {
+			add("The token's policies were not logged; check the policies attached to %s.", actorName(exp))
+		} else {
+			add("None of the token's policies (%s) grant %s on %s.", strings.Join(policies, ", "), operationOrAccess(exp.Operation), exp.Path)
+		}
+		if len(policies) == 1 && policies[0] == "default" {
+			add("The token only has the default policy, which usually means the auth role attaches no policies or they were misspelled.")
+		}
+	case ErrorClassInvalidToken:
+		add("The token was missing, expired or revoked rather than lacking permissions.")
+	case ErrorClassInvalidCredentials:
+		add("Authentication failed; the credentials were rejected before any policy was evaluated.")
+	case ErrorClassUnsupportedPath:
+		add("Nothing handles %s: the mount may not exist in namespace %s, or the path is wrong (e.g. missing data/ for KV v2).", exp.Path, namespaceName(exp.Namespace))
+	case ErrorClassNamespaceNotFound:
+		add("The namespace %s does not exist, or the token cannot see it.", namespaceName(exp.Namespace))
+	case ErrorClassRateLimited:
+		add("The request hit a rate limit quota, not a policy.")
+	case ErrorClassRedacted:
+		add("The error message is redacted; add it to the redaction policy's allowed_errors to classify it.")
+	}
+
+	if pr := exp.PolicyResults; pr != nil {
+		if !pr.Allowed {
+			add("Vault's policy evaluation (policy_results) denied the request.")
+		} else if len(pr.GrantingPolicies) > 0 {
+			names := make([]string, len(pr.GrantingPolicies))
+			for i, g := range pr.GrantingPolicies {
+				names[i] = g.Name
+			}
+			add("Vault's policy evaluation allowed the request through %s, so it failed after authorization.", strings.Join(names, ", "))
+		}
+	}
+	if exp.TokenType == "batch" && exp.ErrorClass == ErrorClassPermissionDenied {
+		add("The token is a batch token; batch tokens cannot be used outside the namespace or cluster that issued them.")
+	}
+
+	if len(exp.RecentSuccesses) > 0 {
+		ok := exp.RecentSuccesses[0]
+		add("The same actor last succeeded on %s (%s) at %s.", ok.Path, ok.Operation, ok.Time.UTC().Format(time.RFC3339))
+		if okPolicies := uniqueStringsOf(ok.Policies, ok.TokenPolicies, ok.IdentityPolicies); len(okPolicies) > 0 && strings.Join(okPolicies, ",") != strings.Join(policies, ",") {
+			add("That request used different policies (%s), so the token or its role changed.", strings.Join(okPolicies, ", "))
+		}
+	} else if exp.ErrorClass == ErrorClassPermissionDenied {
+		add("The actor has no successful requests on this mount in the week before the denial.")
+	}
+	return findings
+}
+
+func actorName(exp *DenialExplanation) string {
+	if exp.DisplayName != "" {
+		return exp.DisplayName
+	}
+	if exp.EntityName != "" {
+		return "entity " + exp.EntityName
+	}
+	if exp.EntityID != "" {
+		return "entity " + exp.EntityID
+	}
+	return "the token"
+}
+
+func namespaceName(ns string) string {
+	if ns == "" {
+		return "root"
+	}
+	return ns
+}
+
+func operationOrAccess(op string) string {
+	if op == "" {
+		return "access"
+	}
+	return op
+}
+
+// uniqueStringsOf merges lists, keeping the first occurrence of each value.
+func uniqueStringsOf(lists ...[]string) []string {
+	var out []string
+	for _, l := range lists {
+		for _, v := range l {
+			if v != "" && !contains(out, v) {
+				out = append(out, v)
+			}
+		}
+	}
+	return out
+}
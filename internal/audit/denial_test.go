@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// denialLines returns an earlier successful read of a sibling path and a
+// denied update by the same actor.
+func denialLines(now time.Time) []fakeLokiLine {
+	auth := func(policies ...any) map[string]any {
+		return map[string]any{
+			"display_name":   "approle-payments",
+			"entity_id":      "ent-1",
+			"token_policies": policies,
+			"token_type":     "service",
+		}
+	}
+	return []fakeLokiLine{
+		{Time: now.Add(-2 * time.Hour), Entry: map[string]any{
+			"type": "response",
+			"auth": auth("default", "payments-read"),
+			"request": map[string]any{
+				"id": "req-ok", "operation": "read", "path": "secret/data/payments/api",
+				"mount_point": "secret/", "namespace": map[string]any{"path": "team-a/"},
+			},
+		}},
+		{Time: now.Add(-time.Minute), Entry: map[string]any{
+			"type": "request",
+			"auth": auth("default"),
+			"request": map[string]any{
+				"id": "req-denied", "operation": "update", "path": "secret/data/payments/db",
+				"mount_point": "secret/", "namespace": map[string]any{"path": "team-a/"},
+			},
+		}},
+		{Time: now.Add(-time.Minute), Entry: map[string]any{
+			"type":  "response",
+			"error": "1 error occurred:\n\t* permission denied\n\n",
+			"auth":  auth("default"),
+			"request": map[string]any{
+				"id": "req-denied", "operation": "update", "path": "secret/data/payments/db",
+				"mount_point": "secret/", "namespace": map[string]any{"path": "team-a/"},
+			},
+		}},
+	}
+}
+
+func TestExplainDenialByActor(t *testing.T) {
+	now := time.Now().UTC()
+	backend := newFakeLoki(t, denialLines(now))
+
+	exp, err := ExplainDenial(t.Context(), backend, &DenialQuery{
+		Start:       now.Add(-time.Hour),
+		End:         now,
+		DisplayName: "approle-payments",
+		Path:        "secret/data/payments/*",
+	})
+	if err != nil {
+		t.Fatalf("ExplainDenial failed: %v", err)
+	}
+
+	if exp.RequestID != "req-denied" || exp.ErrorClass != ErrorClassPermissionDenied {
+		t.Errorf("got %s/%s, want req-denied/permission_denied", exp.RequestID, exp.ErrorClass)
+	}
+	if exp.Error != "permission denied" {
+		t.Errorf("error = %q, want the allow-listed message only", exp.Error)
+	}
+	if exp.Request == nil || exp.Response == nil {
+		t.Fatal("explanation should pair the request and response entries")
+	}
+	if exp.Namespace != "team-a/" || exp.Path != "secret/data/payments/db" || exp.Operation != "update" {
+		t.Errorf("request = %s %s %s", exp.Namespace, exp.Operation, exp.Path)
+	}
+	if len(exp.TokenPolicies) != 1 || exp.TokenPolicies[0] != "default" {
+		t.Errorf("token policies = %v", exp.TokenPolicies)
+	}
+	if len(exp.RecentSuccesses) != 1 || exp.RecentSuccesses[0].RequestID != "req-ok" {
+		t.Fatalf("recent successes = %+v, want req-ok", exp.RecentSuccesses)
+	}
+	if exp.RecentSuccesses[0].Raw != nil {
+		t.Error("recent successes should not carry raw data")
+	}
+	findings := strings.Join(exp.Findings, "\n")
+	for _, want := range []string{"only has the default policy", "last succeeded on secret/data/payments/api", "different policies (default, payments-read)"} {
+		if !strings.Contains(findings, want) {
+			t.Errorf("findings missing %q:\n%s", want, findings)
+		}
+	}
+}
+
+func TestExplainDenialNotFound(t *testing.T) {
+	now := time.Now().UTC()
+	backend := newFakeLoki(t, denialLines(now))
+
+	_, err := ExplainDenial(t.Context(), backend, &DenialQuery{Start: now.Add(-time.Hour), End: now, DisplayName: "someone-else"})
+	if !errors.Is(err, ErrNoDenial) {
+		t.Errorf("err = %v, want ErrNoDenial", err)
+	}
+	if _, err := ExplainDenial(t.Context(), backend, &DenialQuery{Start: now.Add(-time.Hour), End: now}); err == nil {
+		t.Error("ExplainDenial should require a request ID, actor or path")
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		msg     string
+		results *PolicyResults
+		want    string
+	}{
+		{"1 error occurred: permission denied", nil, ErrorClassPermissionDenied},
+		{"permission denied; invalid token", nil, ErrorClassPermissionDenied},
+		{"missing client token", nil, ErrorClassInvalidToken},
+		{"invalid username or password", nil, ErrorClassInvalidCredentials},
+		{"1 error occurred: * unsupported path", nil, ErrorClassUnsupportedPath},
+		{"request rate limit quota has been exceeded", nil, ErrorClassRateLimited},
+		{redactedValue, &PolicyResults{Allowed: false}, ErrorClassPermissionDenied},
+		{redactedValue, nil, ErrorClassRedacted},
+		{"something else", nil, ErrorClassOther},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.msg, tt.results); got != tt.want {
+			t.Errorf("classifyError(%q) = %s, want %s", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestExplainDenialTool(t *testing.T) {
+	now := time.Now().UTC()
+	session := connectService(t, NewService(newFakeLoki(t, denialLines(now))))
+
+	res, err := session.CallTool(t.Context(), &mcp.CallToolParams{
+		Name:      "audit.explain_denial",
+		Arguments: map[string]any{"request_id": "req-denied"},
+	})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if res.IsError {
+		t.Fatalf("tool error: %v", res.Content)
+	}
+	var exp DenialExplanation
+	raw, _ := json.Marshal(res.StructuredContent)
+	if err := json.Unmarshal(raw, &exp); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if exp.ErrorClass != ErrorClassPermissionDenied || len(exp.RecentSuccesses) != 1 {
+		t.Errorf("got class %s with %d successes", exp.ErrorClass, len(exp.RecentSuccesses))
+	}
+}
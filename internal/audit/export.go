@@ -0,0 +1,433 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export formats.
+const (
+	ExportNDJSON = "ndjson"
+	ExportCSV    = "csv"
+)
+
+// DefaultExportColumns are the Event columns written to CSV exports when
+// none are selected.
+var DefaultExportColumns = []string{
+	"time", "request_id", "audit_type", "namespace", "operation",
+	"mount_type", "path", "status", "display_name", "remote_address",
+}
+
+// exportColumns maps CSV column names to Event fields.
+var exportColumns = map[string]func(ev *Event) string{
+	"time":           func(ev *Event) string { return ev.Time.UTC().Format(time.RFC3339Nano) },
+	"namespace":      func(ev *Event) string { return ev.Namespace },
+	"operation":      func(ev *Event) string { return ev.Operation },
+	"mount_type":     func(ev *Event) string { return ev.MountType },
+	"mount_class":    func(ev *Event) string { return ev.MountClass },
+	"path":           func(ev *Event) string { return ev.Path },
+	"audit_type":     func(ev *Event) string { return ev.AuditType },
+	"status":         func(ev *Event) string { return ev.Status },
+	"request_id":     func(ev *Event) string { return ev.RequestID },
+	"display_name":   func(ev *Event) string { return ev.Display },
+	"remote_address": func(ev *Event) string { return ev.RemoteAddr },
+	"policies":       func(ev *Event) string { return strings.Join(ev.Policies, ";") },
+	"token_policies": func(ev *Event) string { return strings.Join(ev.TokenPolicies, ";") },
+	"entity_id":      func(ev *Event) string { return ev.EntityID },
+
+	"identity_policies": func(ev *Event) string { return strings.Join(ev.IdentityPolicies, ";") },
+	"policy_allowed": func(ev *Event) string {
+		if ev.PolicyResults == nil {
+			return ""
+		}
+		return strconv.FormatBool(ev.PolicyResults.Allowed)
+	},
+	"mount_point":           func(ev *Event) string { return ev.MountPoint },
+	"mount_accessor":        func(ev *Event) string { return ev.MountAccessor },
+	"client_token_accessor": func(ev *Event) string { return ev.TokenAccessor },
+	"token_type":            func(ev *Event) string { return ev.TokenType },
+	"token_ttl": func(ev *Event) string {
+		if ev.TokenTTL == 0 {
+			return ""
+		}
+		return strconv.FormatInt(ev.TokenTTL, 10)
+	},
+	"role_name":      func(ev *Event) string { return ev.RoleName },
+	"entity_name":    func(ev *Event) string { return ev.EntityName },
+	"alias_name":     func(ev *Event) string { return ev.AliasName },
+	"groups":         func(ev *Event) string { return strings.Join(ev.Groups, ";") },
+	"forwarded_from": func(ev *Event) string { return ev.ForwardedFrom },
+	"status_code": func(ev *Event) string {
+		if ev.StatusCode == 0 {
+			return ""
+		}
+		return strconv.Itoa(ev.StatusCode)
+	},
+}
+
+// ExportQuery records the filter an export was produced from.
+type ExportQuery struct {
+	Namespace  string `json:"namespace,omitempty"`
+	Operation  string `json:"operation,omitempty"`
+	MountType  string `json:"mount_type,omitempty"`
+	MountClass string `json:"mount_class,omitempty"`
+	Status     string `json:"status,omitempty"`
+	Policy     string `json:"policy,omitempty"`
+	EntityID   string `json:"entity_id,omitempty"`
+	Tenant     string `json:"tenant,omitempty"`
+
+	NamespacePrefix string `json:"namespace_prefix,omitempty"`
+
+	DisplayName string   `json:"display_name,omitempty"`
+	AuditType   string   `json:"audit_type,omitempty"`
+	PathPrefix  string   `json:"path_prefix,omitempty"`
+	PathGlob    string   `json:"path_glob,omitempty"`
+	PathRegex   string   `json:"path_regex,omitempty"`
+	RemoteCIDRs []string `json:"remote_cidr,omitempty"`
+
+	MountPoint    string `json:"mount_point,omitempty"`
+	MountAccessor string `json:"mount_accessor,omitempty"`
+	TokenType     string `json:"token_type,omitempty"`
+	RoleName      string `json:"role_name,omitempty"`
+
+	AnyOf   *FieldSet `json:"any_of,omitempty"`
+	Exclude *FieldSet `json:"exclude,omitempty"`
+}
+
+// ExportOptions controls where and how an export is written.
+type ExportOptions struct {
+	// Dir is the directory the data file and manifest are written to.
+	Dir string
+	// Format is ExportNDJSON (default), ExportCSV or a SIEM format
+	// (FormatCEF, FormatLEEF, FormatOCSF), written one record per line.
+	Format string
+	// Columns selects CSV columns; defaults to DefaultExportColumns.
+	Columns []string
+	// MaxEvents stops the export after this many events; 0 means no cap.
+	MaxEvents int
+}
+
+// ExportManifest describes an evidence bundle.
+type ExportManifest struct {
+	File         string      `json:"file"`
+	ManifestFile string      `json:"manifest_file"`
+	Format       string      `json:"format"`
+	Columns      []string    `json:"columns,omitempty"`
+	Query        ExportQuery `json:"query"`
+	StartTime    string      `json:"start_time"`
+	EndTime      string      `json:"end_time"`
+	EventCount   int         `json:"event_count"`
+	GeneratedAt  string      `json:"generated_at"`
+	SHA256       string      `json:"sha256"`
+	// Truncated is set when MaxEvents stopped the export early.
+	Truncated bool `json:"truncated,omitempty"`
+	// Incomplete is set when the deadline was reached before the whole range
+	// was exported, or when the backend could not read every event at an
+	// instant holding more events than one search returns.
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// instantSearcher is implemented by backends that can return every event at
+// one instant, however many share it.
+type instantSearcher interface {
+	SearchInstant(ctx context.Context, filter *SearchFilter, t time.Time) ([]Event, error)
+}
+
+// errNoInstantSearch is returned by wrappers whose backend cannot read a
+// whole instant.
+var errNoInstantSearch = errors.New("backend does not support instant search")
+
+// Export runs filter over [filter.Start, filter.End], paging through the
+// backend newest first, and writes every matching (redacted) event to a
+// file in opts.Dir together with a JSON manifest. Ranges longer than the
+// backend's QueryLimits.MaxDays are split into segments.
+func Export(ctx context.Context, backend Backend, filter SearchFilter, query ExportQuery, opts ExportOptions) (*ExportManifest, error) {
+	format := strings.ToLower(opts.Format)
+	if format == "" {
+		format = ExportNDJSON
+	}
+	if format != ExportNDJSON && format != ExportCSV && !IsSIEMFormat(format) {
+		return nil, fmt.Errorf("invalid export format %q, must be ndjson, csv, cef, leef or ocsf", opts.Format)
+	}
+	columns := opts.Columns
+	if format == ExportCSV {
+		if len(columns) == 0 {
+			columns = DefaultExportColumns
+		}
+		for _, c := range columns {
+			if exportColumns[c] == nil {
+				return nil, fmt.Errorf("invalid export column %q", c)
+			}
+		}
+	} else {
+		columns = nil
+	}
+	if !filter.End.After(filter.Start) {
+		return nil, fmt.Errorf("export end time must be after start time")
+	}
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("export directory is not configured")
+	}
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	generated := time.Now().UTC()
+	base := fmt.Sprintf("vault-audit-export-%s", generated.Format("20060102T150405.000000000Z"))
+	ext := format
+	if format == FormatOCSF {
+		ext = ExportNDJSON
+	}
+	dataPath := filepath.Join(opts.Dir, base+"."+ext)
+	manifestPath := filepath.Join(opts.Dir, base+".manifest.json")
+
+	f, err := os.OpenFile(dataPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export file: %w", err)
+	}
+	hash := sha256.New()
+	w := newExportWriter(io.MultiWriter(f, hash), format, columns)
+
+	count, truncated, err := exportEvents(ctx, backend, filter, opts.MaxEvents, w.write)
+	incomplete := errors.Is(err, ErrIncomplete)
+	if err == nil || incomplete {
+		err = w.close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dataPath)
+		return nil, err
+	}
+
+	manifest := &ExportManifest{
+		File:         dataPath,
+		ManifestFile: manifestPath,
+		Format:       format,
+		Columns:      columns,
+		Query:        query,
+		StartTime:    filter.Start.UTC().Format(time.RFC3339),
+		EndTime:      filter.End.UTC().Format(time.RFC3339),
+		EventCount:   count,
+		GeneratedAt:  generated.Format(time.RFC3339),
+		SHA256:       hex.EncodeToString(hash.Sum(nil)),
+		Truncated:    truncated,
+		Incomplete:   incomplete,
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(manifestPath, append(data, '\n'), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write export manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// exportEvents pages through the backend newest first, handing each event
+// to write exactly once. Pages and segments are bounded by the backend's
+// query limits; a full page continues from its oldest timestamp, skipping
+// events already written at that instant. A page whose events all share one
+// timestamp is followed by a read of that whole instant. When the backend
+// cannot read it, the export continues below it and returns ErrIncomplete.
+func exportEvents(ctx context.Context, backend Backend, filter SearchFilter, maxEvents int, write func(*Event) error) (int, bool, error) {
+	limits := limitsOf(backend)
+	maxSpan := limits.MaxRange()
+	count, pages := 0, 0
+	end := filter.End
+	// Progress is reported per page; the inner searches stay quiet.
+	searchCtx := WithProgress(ctx, nil)
+	var boundary time.Time
+	seen := make(map[string]bool)
+	var drained time.Time // last instant read on its own
+	skipped := 0          // instants not read completely
+
+	// emit writes the events not written yet. It returns true once
+	// maxEvents have been written.
+	emit := func(events []Event) (bool, error) {
+		for i := range events {
+			ev := &events[i]
+			if ev.Time.Equal(boundary) {
+				if seen[exportKey(ev)] {
+					continue
+				}
+				seen[exportKey(ev)] = true
+			}
+			if err := write(ev); err != nil {
+				return false, fmt.Errorf("failed to write export: %w", err)
+			}
+			count++
+			if maxEvents > 0 && count >= maxEvents {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	for end.After(filter.Start) {
+		if err := ctx.Err(); err != nil {
+			return count, false, deadlineErr(ctx, err)
+		}
+
+		page := filter
+		page.End = end
+		page.Start = maxTime(filter.Start, end.Add(-maxSpan))
+		page.Limit = limits.MaxLimit
+
+		events, err := backend.Search(searchCtx, &page)
+		if err != nil && !errors.Is(err, ErrIncomplete) {
+			return count, false, err
+		}
+		if done, werr := emit(events); werr != nil || done {
+			return count, done, werr
+		}
+		if err != nil {
+			return count, false, err
+		}
+
+		pages++
+		if len(events) < page.Limit {
+			// Segment exhausted; move to the next older one.
+			end = page.Start
+			reportExportProgress(ctx, filter, end, pages, count)
+			continue
+		}
+
+		oldest := events[len(events)-1].Time
+		if oldest.Equal(drained) {
+			// The backend includes its end bound and this instant was read
+			// already; continue just below it.
+			end = oldest.Add(-time.Nanosecond)
+			reportExportProgress(ctx, filter, end, pages, count)
+			continue
+		}
+		if !oldest.Equal(boundary) {
+			boundary = oldest
+			seen = make(map[string]bool)
+		}
+		for i := range events {
+			if events[i].Time.Equal(oldest) {
+				seen[exportKey(&events[i])] = true
+			}
+		}
+		next := oldest.Add(time.Nanosecond)
+		if !next.Before(end) {
+			// A whole page shares one timestamp, so paging on it would
+			// return the same page forever. Read the rest of that instant,
+			// then continue below it.
+			var instant []Event
+			err := errNoInstantSearch
+			if searcher, ok := backend.(instantSearcher); ok {
+				instant, err = searcher.SearchInstant(searchCtx, &filter, oldest)
+			}
+			if errors.Is(err, errNoInstantSearch) {
+				log.Printf("export: skipping audit events at %s: more share the timestamp than one search returns", oldest.Format(time.RFC3339Nano))
+				skipped++
+			} else if err != nil && !errors.Is(err, ErrIncomplete) {
+				return count, false, err
+			}
+			if done, werr := emit(instant); werr != nil || done {
+				return count, done, werr
+			}
+			if errors.Is(err, ErrIncomplete) {
+				return count, false, err
+			}
+			drained, next = oldest, oldest
+		}
+		end = next
+		reportExportProgress(ctx, filter, end, pages, count)
+	}
+	if skipped > 0 {
+		return count, false, fmt.Errorf("%w: events at %d instants could not all be read", ErrIncomplete, skipped)
+	}
+	return count, false, nil
+}
+
+// reportExportProgress estimates the pages left from the average time span
+// covered per page so far.
+func reportExportProgress(ctx context.Context, filter SearchFilter, cursor time.Time, pages, count int) {
+	remaining := 0
+	if covered := filter.End.Sub(cursor); covered > 0 && cursor.After(filter.Start) {
+		perPage := covered / time.Duration(pages)
+		remaining = int((cursor.Sub(filter.Start) + perPage - 1) / perPage)
+	}
+	reportProgress(ctx, Progress{WindowsScanned: pages, EventsMatched: count, WindowsRemaining: remaining})
+}
+
+func exportKey(ev *Event) string {
+	return ev.RequestID + "\x00" + ev.AuditType + "\x00" + ev.Path
+}
+
+type exportWriter struct {
+	format  string
+	columns []string
+	w       io.Writer
+	enc     *json.Encoder
+	csv     *csv.Writer
+	header  bool
+}
+
+func newExportWriter(w io.Writer, format string, columns []string) *exportWriter {
+	ew := &exportWriter{format: format, columns: columns, w: w}
+	switch {
+	case format == ExportCSV:
+		ew.csv = csv.NewWriter(w)
+	case !IsSIEMFormat(format):
+		ew.enc = json.NewEncoder(w)
+	}
+	return ew
+}
+
+func (w *exportWriter) write(ev *Event) error {
+	if w.enc != nil {
+		return w.enc.Encode(ev)
+	}
+	if w.csv == nil {
+		line, err := FormatSIEM(w.format, ev)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w.w, line+"\n")
+		return err
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	row := make([]string, len(w.columns))
+	for i, c := range w.columns {
+		row[i] = exportColumns[c](ev)
+	}
+	return w.csv.Write(row)
+}
+
+func (w *exportWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+	return w.csv.Write(w.columns)
+}
+
+func (w *exportWriter) close() error {
+	if w.csv == nil {
+		return nil
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
@@ -0,0 +1,233 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestExportPagesAcrossSegments(t *testing.T) {
+	end := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	// Newest first; two events share a timestamp at a page boundary.
+	var events []Event
+	for i := 0; i < 10; i++ {
+		events = append(events, Event{
+			Time:      end.Add(-time.Duration(i*7+1) * time.Hour),
+			RequestID: fmt.Sprintf("req-%d", i),
+			AuditType: "response",
+		})
+	}
+	events[3].Time = events[2].Time
+	backend := &timedBackend{events: events, limits: QueryLimits{MaxLimit: 3, MaxDays: 1}}
+
+	dir := t.TempDir()
+	manifest, err := Export(t.Context(), backend, SearchFilter{Start: end.Add(-4 * 24 * time.Hour), End: end},
+		ExportQuery{Operation: "read"}, ExportOptions{Dir: dir})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if manifest.EventCount != 10 {
+		t.Fatalf("exported %d events, want 10", manifest.EventCount)
+	}
+
+	data, err := os.ReadFile(manifest.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	if manifest.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Error("manifest SHA-256 does not match the data file")
+	}
+
+	f, _ := os.Open(manifest.File)
+	defer f.Close()
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("invalid NDJSON line: %v", err)
+		}
+		if seen[ev.RequestID] {
+			t.Errorf("duplicate event %s", ev.RequestID)
+		}
+		seen[ev.RequestID] = true
+	}
+
+	var onDisk ExportManifest
+	raw, err := os.ReadFile(manifest.ManifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(raw, &onDisk); err != nil || onDisk.SHA256 != manifest.SHA256 || onDisk.Query.Operation != "read" {
+		t.Errorf("manifest file = %s, err %v", raw, err)
+	}
+}
+
+// sharedInstantEvents returns events newest first: one newer event, n
+// events sharing one timestamp and one older event.
+func sharedInstantEvents(end time.Time, n int) []Event {
+	events := []Event{{Time: end.Add(-time.Hour), RequestID: "newer", AuditType: "response"}}
+	for i := 0; i < n; i++ {
+		events = append(events, Event{Time: end.Add(-2 * time.Hour), RequestID: fmt.Sprintf("req-%d", i), AuditType: "response"})
+	}
+	return append(events, Event{Time: end.Add(-3 * time.Hour), RequestID: "older", AuditType: "response"})
+}
+
+// exportedRequestIDs reads the request IDs of an NDJSON export, failing on
+// duplicates.
+func exportedRequestIDs(t *testing.T, path string) map[string]bool {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	ids := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("invalid NDJSON line: %v", err)
+		}
+		if ids[ev.RequestID] {
+			t.Errorf("event %s exported twice", ev.RequestID)
+		}
+		ids[ev.RequestID] = true
+	}
+	return ids
+}
+
+func TestExportReadsInstantsFullerThanAPage(t *testing.T) {
+	end := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	var lines []fakeLokiLine
+	events := sharedInstantEvents(end, 10)
+	for i := len(events) - 1; i >= 0; i-- {
+		lines = append(lines, fakeLokiLine{Time: events[i].Time, Entry: map[string]any{
+			"type":    "response",
+			"request": map[string]any{"id": events[i].RequestID, "operation": "read"},
+		}})
+	}
+	backend := newFakeLoki(t, lines)
+	backend.SetQueryOptions(QueryOptions{Limits: QueryLimits{MaxLimit: 3, MaxDays: 1}})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	manifest, err := Export(ctx, backend, SearchFilter{Start: end.Add(-4 * time.Hour), End: end}, ExportQuery{}, ExportOptions{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if manifest.EventCount != 12 || manifest.Incomplete {
+		t.Errorf("exported %d events, incomplete %v; want all 12", manifest.EventCount, manifest.Incomplete)
+	}
+	if ids := exportedRequestIDs(t, manifest.File); len(ids) != 12 {
+		t.Errorf("exported %d distinct events, want 12", len(ids))
+	}
+}
+
+func TestExportMarksUnreadInstantsIncomplete(t *testing.T) {
+	end := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	// timedBackend cannot read a whole instant.
+	backend := &timedBackend{events: sharedInstantEvents(end, 10), limits: QueryLimits{MaxLimit: 3, MaxDays: 1}}
+
+	manifest, err := Export(t.Context(), backend, SearchFilter{Start: end.Add(-4 * time.Hour), End: end}, ExportQuery{}, ExportOptions{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if !manifest.Incomplete {
+		t.Error("an export skipping events at a shared timestamp should be marked incomplete")
+	}
+	if ids := exportedRequestIDs(t, manifest.File); !ids["newer"] || !ids["older"] {
+		t.Errorf("exported %v, want the events on both sides of the instant", ids)
+	}
+}
+
+func TestExportCSVColumns(t *testing.T) {
+	now := time.Now().UTC()
+	backend := &timedBackend{events: []Event{
+		{Time: now.Add(-time.Minute), RequestID: "req-1", Operation: "read", Path: "secret/data/a,b"},
+	}}
+	manifest, err := Export(t.Context(), backend, SearchFilter{Start: now.Add(-time.Hour), End: now},
+		ExportQuery{}, ExportOptions{Dir: t.TempDir(), Format: "csv", Columns: []string{"request_id", "path"}})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	f, _ := os.Open(manifest.File)
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(rows) != 2 || rows[0][1] != "path" || rows[1][1] != "secret/data/a,b" {
+		t.Errorf("unexpected rows %v", rows)
+	}
+
+	if _, err := Export(t.Context(), backend, SearchFilter{Start: now.Add(-time.Hour), End: now},
+		ExportQuery{}, ExportOptions{Dir: t.TempDir(), Format: "csv", Columns: []string{"raw"}}); err == nil {
+		t.Error("unknown column should be rejected")
+	}
+}
+
+func TestExportToolAppliesSearchFilters(t *testing.T) {
+	end := time.Now().UTC().Truncate(time.Second)
+	line := func(age time.Duration, id, op, path, display, addr string) fakeLokiLine {
+		return fakeLokiLine{Time: end.Add(-age), Entry: map[string]any{
+			"type":    "response",
+			"auth":    map[string]any{"display_name": display},
+			"request": map[string]any{"id": id, "operation": op, "path": path, "remote_address": addr},
+		}}
+	}
+	// Oldest first.
+	backend := newFakeLoki(t, []fakeLokiLine{
+		line(6*time.Minute, "match", "read", "secret/data/app/db", "approle-ci", "10.1.2.3"),
+		line(5*time.Minute, "wrong-path", "read", "sys/health", "approle-ci", "10.1.2.3"),
+		line(4*time.Minute, "wrong-name", "read", "secret/data/app/db", "alice", "10.1.2.3"),
+		line(3*time.Minute, "wrong-addr", "read", "secret/data/app/db", "approle-ci", "192.168.1.7"),
+		line(2*time.Minute, "excluded", "delete", "secret/data/app/db", "approle-ci", "10.1.2.3"),
+		line(1*time.Minute, "also-match", "list", "secret/data/web/db", "approle-ci", "10.1.2.3"),
+	})
+	svc := NewService(backend)
+	svc.SetExportDir(t.TempDir())
+	session := connectService(t, svc)
+
+	res, err := session.CallTool(t.Context(), &mcp.CallToolParams{Name: "audit.export", Arguments: map[string]any{
+		"last":         "10m",
+		"path_glob":    "secret/data/*/db",
+		"display_name": "APPROLE-CI",
+		"remote_cidr":  []string{"10.0.0.0/8"},
+		"audit_type":   "response",
+		"exclude":      map[string]any{"operation": []string{"delete"}},
+	}})
+	if err != nil || res.IsError {
+		t.Fatalf("audit.export failed: %v %+v", err, res)
+	}
+	var manifest ExportManifest
+	if err := json.Unmarshal([]byte(res.Content[0].(*mcp.TextContent).Text), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	ids := exportedRequestIDs(t, manifest.File)
+	if len(ids) != 2 || !ids["match"] || !ids["also-match"] {
+		t.Errorf("exported %v, want match and also-match", ids)
+	}
+	if q := manifest.Query; q.PathGlob != "secret/data/*/db" || len(q.RemoteCIDRs) != 1 || q.Exclude == nil || len(q.Exclude.Operations) != 1 {
+		t.Errorf("manifest query = %+v, want the search filters recorded", q)
+	}
+
+	res, err = session.CallTool(t.Context(), &mcp.CallToolParams{Name: "audit.export", Arguments: map[string]any{
+		"last":       "10m",
+		"path_regex": "(",
+	}})
+	if err == nil && !res.IsError {
+		t.Error("audit.export with an invalid path_regex should fail")
+	}
+}
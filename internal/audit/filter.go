@@ -0,0 +1,406 @@
+package audit
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"vault-audit-mcp/internal/loki"
+)
+
+// FieldSet lists values per event field. In SearchFilter.AnyOf an event
+// matches a field when it matches any listed value (IN); in
+// SearchFilter.Exclude it is dropped when it matches any listed value of any
+// field.
+type FieldSet struct {
+	Namespaces        []string `json:"namespace,omitempty" jsonschema:"Vault namespaces, e.g. team-a/"`
+	NamespacePrefixes []string `json:"namespace_prefix,omitempty" jsonschema:"Vault namespace subtrees: the namespace and its descendants, e.g. org/team-a/"`
+	Operations        []string `json:"operation,omitempty" jsonschema:"Operations, e.g. delete"`
+	MountTypes        []string `json:"mount_type,omitempty" jsonschema:"Mount types, e.g. kv"`
+	MountClasses      []string `json:"mount_class,omitempty" jsonschema:"Mount classes: auth, secret or system"`
+	Statuses          []string `json:"status,omitempty" jsonschema:"ok or error"`
+	Policies          []string `json:"policy,omitempty" jsonschema:"Policy names (policies or token_policies)"`
+	EntityIDs         []string `json:"entity_id,omitempty" jsonschema:"Entity IDs"`
+	DisplayNames      []string `json:"display_name,omitempty" jsonschema:"Token display names"`
+	AuditTypes        []string `json:"audit_type,omitempty" jsonschema:"request or response"`
+	PathPrefixes      []string `json:"path_prefix,omitempty" jsonschema:"Request path prefixes, e.g. secret/data/payments/"`
+	PathGlobs         []string `json:"path_glob,omitempty" jsonschema:"Request path globs; * matches within a segment, ** across segments"`
+	PathRegexes       []string `json:"path_regex,omitempty" jsonschema:"Request path regular expressions (RE2, unanchored)"`
+	RemoteCIDRs       []string `json:"remote_cidr,omitempty" jsonschema:"Remote addresses or CIDR ranges, e.g. 10.0.0.0/8"`
+
+	MountPoints    []string `json:"mount_point,omitempty" jsonschema:"Mount points, e.g. secret/"`
+	MountAccessors []string `json:"mount_accessor,omitempty" jsonschema:"Mount accessors, e.g. kv_1a2b3c4d"`
+	TokenTypes     []string `json:"token_type,omitempty" jsonschema:"Token types: service or batch"`
+	RoleNames      []string `json:"role_name,omitempty" jsonschema:"Auth role names"`
+}
+
+// eventField matches one event field against a set of values.
+type eventField struct {
+	name   string
+	values []string
+	match  func(ev *Event) bool
+}
+
+// searchFilterMatcher post-filters events in Go. Every include field must
+// match; no exclude field may match.
+type searchFilterMatcher struct {
+	include []eventField
+	exclude []eventField
+}
+
+// Validate reports invalid path patterns, CIDR ranges and audit types.
+func (f *SearchFilter) Validate() error {
+	_, err := newSearchFilterMatcher(f)
+	return err
+}
+
+func newSearchFilterMatcher(filter *SearchFilter) (searchFilterMatcher, error) {
+	var m searchFilterMatcher
+	if filter == nil {
+		return m, nil
+	}
+	include, exclude := filter.fieldSets()
+	var err error
+	if m.include, err = fieldMatchers(include); err != nil {
+		return m, err
+	}
+	if m.exclude, err = fieldMatchers(exclude); err != nil {
+		return m, fmt.Errorf("exclude: %w", err)
+	}
+	return m, nil
+}
+
+// fieldSets returns the values an event must match, with the single-value
+// filters merged into the AnyOf sets, and the values it must not match.
+func (f *SearchFilter) fieldSets() (include, exclude *FieldSet) {
+	var anyOf FieldSet
+	if f.AnyOf != nil {
+		anyOf = *f.AnyOf
+	}
+	exclude = &FieldSet{}
+	if f.Exclude != nil {
+		exclude = f.Exclude
+	}
+	with := func(v string, vs []string) []string {
+		if v = strings.TrimSpace(v); v != "" {
+			return append([]string{v}, vs...)
+		}
+		return vs
+	}
+	return &FieldSet{
+		Namespaces:        with(f.Namespace, anyOf.Namespaces),
+		NamespacePrefixes: with(f.NamespacePrefix, anyOf.NamespacePrefixes),
+		Operations:        with(f.Operation, anyOf.Operations),
+		MountTypes:        with(f.MountType, anyOf.MountTypes),
+		MountClasses:      with(f.MountClass, anyOf.MountClasses),
+		Statuses:          with(f.Status, anyOf.Statuses),
+		Policies:          with(f.Policy, anyOf.Policies),
+		EntityIDs:         with(f.EntityID, anyOf.EntityIDs),
+		DisplayNames:      with(f.DisplayName, anyOf.DisplayNames),
+		AuditTypes:        with(f.AuditType, anyOf.AuditTypes),
+		PathPrefixes:      with(f.PathPrefix, anyOf.PathPrefixes),
+		PathGlobs:         with(f.PathGlob, anyOf.PathGlobs),
+		PathRegexes:       with(f.PathRegex, anyOf.PathRegexes),
+		RemoteCIDRs:       append(append([]string(nil), f.RemoteCIDRs...), anyOf.RemoteCIDRs...),
+
+		MountPoints:    with(f.MountPoint, anyOf.MountPoints),
+		MountAccessors: with(f.MountAccessor, anyOf.MountAccessors),
+		TokenTypes:     with(f.TokenType, anyOf.TokenTypes),
+		RoleNames:      with(f.RoleName, anyOf.RoleNames),
+	}, exclude
+}
+
+func fieldMatchers(s *FieldSet) ([]eventField, error) {
+	var out []eventField
+	add := func(name string, values []string, match func(ev *Event, v string) bool) {
+		if len(values) == 0 {
+			return
+		}
+		out = append(out, eventField{name: name, values: values, match: func(ev *Event) bool {
+			for _, v := range values {
+				if match(ev, v) {
+					return true
+				}
+			}
+			return false
+		}})
+	}
+	equal := func(get func(ev *Event) string) func(ev *Event, v string) bool {
+		return func(ev *Event, v string) bool { return strings.EqualFold(get(ev), v) }
+	}
+
+	namespaces := make([]string, len(s.Namespaces))
+	for i, ns := range s.Namespaces {
+		namespaces[i] = normalizeNamespace(ns)
+	}
+	add("namespace", namespaces, equal(func(ev *Event) string { return ev.Namespace }))
+	add("namespace_prefix", namespacePrefixes(s.NamespacePrefixes), func(ev *Event, v string) bool {
+		return strings.HasPrefix(ev.Namespace, v)
+	})
+	add("operation", s.Operations, func(ev *Event, v string) bool {
+		if strings.EqualFold(strings.TrimSpace(v), "login") {
+			return strings.Contains(strings.ToLower(ev.Path), "/login")
+		}
+		return operationMatches(ev.Operation, v)
+	})
+	add("mount_type", s.MountTypes, equal(func(ev *Event) string { return ev.MountType }))
+	add("mount_class", s.MountClasses, equal(func(ev *Event) string { return ev.MountClass }))
+	add("status", s.Statuses, equal(func(ev *Event) string { return ev.Status }))
+	add("policy", s.Policies, func(ev *Event, v string) bool {
+		return containsPolicy(ev.Policies, v) || containsPolicy(ev.TokenPolicies, v)
+	})
+	add("entity_id", s.EntityIDs, equal(func(ev *Event) string { return ev.EntityID }))
+	add("display_name", s.DisplayNames, equal(func(ev *Event) string { return ev.Display }))
+
+	for _, t := range s.AuditTypes {
+		if t != "request" && t != "response" {
+			return nil, fmt.Errorf("invalid audit_type %q, must be request or response", t)
+		}
+	}
+	add("audit_type", s.AuditTypes, equal(func(ev *Event) string { return ev.AuditType }))
+
+	add("mount_point", s.MountPoints, func(ev *Event, v string) bool {
+		return strings.EqualFold(strings.TrimSuffix(ev.MountPoint, "/"), strings.TrimSuffix(strings.TrimSpace(v), "/"))
+	})
+	add("mount_accessor", s.MountAccessors, equal(func(ev *Event) string { return ev.MountAccessor }))
+	add("token_type", s.TokenTypes, equal(func(ev *Event) string { return ev.TokenType }))
+	add("role_name", s.RoleNames, equal(func(ev *Event) string { return ev.RoleName }))
+
+	add("path_prefix", s.PathPrefixes, func(ev *Event, v string) bool {
+		return strings.HasPrefix(trimPath(ev.Path), trimPath(v))
+	})
+
+	patterns := make([]*regexp.Regexp, 0, len(s.PathGlobs)+len(s.PathRegexes))
+	for _, g := range s.PathGlobs {
+		re, err := regexp.Compile(globToRegexp(trimPath(g)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid path_glob %q: %w", g, err)
+		}
+		patterns = append(patterns, re)
+	}
+	for _, r := range s.PathRegexes {
+		re, err := regexp.Compile(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path_regex %q: %w", r, err)
+		}
+		patterns = append(patterns, re)
+	}
+	if len(patterns) > 0 {
+		// Globs and regexes are alternatives for one path field.
+		values := append(append([]string(nil), s.PathGlobs...), s.PathRegexes...)
+		out = append(out, eventField{name: "path", values: values, match: func(ev *Event) bool {
+			p := trimPath(ev.Path)
+			for _, re := range patterns {
+				if re.MatchString(p) {
+					return true
+				}
+			}
+			return false
+		}})
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(s.RemoteCIDRs))
+	for _, c := range s.RemoteCIDRs {
+		p, err := parseCIDR(c)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, p)
+	}
+	if len(prefixes) > 0 {
+		out = append(out, eventField{name: "remote_cidr", values: s.RemoteCIDRs, match: func(ev *Event) bool {
+			addr, err := netip.ParseAddr(strings.TrimSpace(ev.RemoteAddr))
+			if err != nil {
+				return false
+			}
+			addr = addr.Unmap()
+			for _, p := range prefixes {
+				if p.Contains(addr) {
+					return true
+				}
+			}
+			return false
+		}})
+	}
+	return out, nil
+}
+
+// parseCIDR accepts a CIDR range or a single address.
+func parseCIDR(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return p.Masked(), nil
+	}
+	if a, err := netip.ParseAddr(s); err == nil {
+		a = a.Unmap()
+		return netip.PrefixFrom(a, a.BitLen()), nil
+	}
+	return netip.Prefix{}, fmt.Errorf("invalid remote_cidr %q, want an address or CIDR range such as 10.0.0.0/8", s)
+}
+
+// globToRegexp converts a path glob to an anchored regular expression:
+// ** matches anything, * and ? match within one path segment.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// globLiteralPrefix returns the part of a glob before its first wildcard.
+func globLiteralPrefix(glob string) string {
+	if i := strings.IndexAny(glob, "*?"); i >= 0 {
+		return glob[:i]
+	}
+	return glob
+}
+
+func trimPath(p string) string {
+	return strings.TrimPrefix(strings.TrimSpace(p), "/")
+}
+
+func (m searchFilterMatcher) isNoop() bool {
+	return len(m.include) == 0 && len(m.exclude) == 0
+}
+
+func (m searchFilterMatcher) matches(ev Event) bool {
+	for _, f := range m.include {
+		if !f.match(&ev) {
+			return false
+		}
+	}
+	for _, f := range m.exclude {
+		if f.match(&ev) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsPolicy checks if a policy name exists in a slice of policies (case-insensitive)
+func containsPolicy(policies []string, policy string) bool {
+	policyLower := strings.ToLower(policy)
+	for _, p := range policies {
+		if strings.ToLower(p) == policyLower {
+			return true
+		}
+	}
+	return false
+}
+
+func operationMatches(eventOp, filterOp string) bool {
+	if strings.EqualFold(eventOp, filterOp) {
+		return true
+	}
+	filterLower := strings.ToLower(strings.TrimSpace(filterOp))
+	eventLower := strings.ToLower(strings.TrimSpace(eventOp))
+	if filterLower == "write" && eventLower == "update" {
+		return true
+	}
+	if filterLower == "update" && eventLower == "write" {
+		return true
+	}
+	return false
+}
+
+// labelFields maps FieldSet fields to the Vault stream labels they can be
+// pushed down to. Display names are left to the post-filter: it compares
+// them case-insensitively, while a label matcher would not.
+func labelFields(s *FieldSet) []struct {
+	label  string
+	values []string
+} {
+	namespaces := make([]string, len(s.Namespaces))
+	for i, ns := range s.Namespaces {
+		namespaces[i] = normalizeNamespace(ns)
+	}
+	return []struct {
+		label  string
+		values []string
+	}{
+		{LabelNamespace, namespaces},
+		{LabelMountType, s.MountTypes},
+		{LabelMountClass, s.MountClasses},
+		{LabelStatus, s.Statuses},
+		{LabelEntityID, s.EntityIDs},
+		{LabelAuditType, s.AuditTypes},
+	}
+}
+
+// pushDownLabels adds label matchers for filter to sel: equality for a
+// single value, a regex alternation for several, and negative regexes for
+// excluded values. Operations and policies keep their special handling in
+// buildLogQLExpression; everything is post-filtered regardless.
+func pushDownLabels(sel *loki.Selector, filter *SearchFilter) {
+	include, exclude := filter.fieldSets()
+	for _, f := range labelFields(include) {
+		switch len(f.values) {
+		case 0:
+		case 1:
+			sel.Labels[f.label] = f.values[0]
+		default:
+			sel.Matchers = append(sel.Matchers, loki.Matcher{Label: f.label, Op: "=~", Value: quoteAlternatives(f.values)})
+		}
+	}
+	for _, f := range labelFields(exclude) {
+		if len(f.values) > 0 {
+			sel.Matchers = append(sel.Matchers, loki.Matcher{Label: f.label, Op: "!~", Value: quoteAlternatives(f.values)})
+		}
+	}
+	if p := namespacePrefixes(include.NamespacePrefixes); len(p) > 0 {
+		sel.Matchers = append(sel.Matchers, loki.Matcher{Label: LabelNamespace, Op: "=~", Value: namespacePrefixRegexp(p)})
+	}
+	if p := namespacePrefixes(exclude.NamespacePrefixes); len(p) > 0 {
+		sel.Matchers = append(sel.Matchers, loki.Matcher{Label: LabelNamespace, Op: "!~", Value: namespacePrefixRegexp(p)})
+	}
+
+	// A single operation other than the login/write/update aliases is an
+	// exact label match.
+	if len(include.Operations) == 1 {
+		op := include.Operations[0]
+		if opLower := strings.ToLower(op); opLower != "login" && opLower != "write" && opLower != "update" {
+			sel.Labels[LabelOperation] = op
+		}
+	}
+}
+
+// pushDownPath adds a line filter narrowing the scan to lines containing the
+// requested path prefix, or the literal part of a single glob. Regexes and
+// multiple patterns are only post-filtered.
+func pushDownPath(expr string, filter *SearchFilter) string {
+	var literals []string
+	if filter.AnyOf == nil || len(filter.AnyOf.PathPrefixes)+len(filter.AnyOf.PathGlobs)+len(filter.AnyOf.PathRegexes) == 0 {
+		if p := trimPath(filter.PathPrefix); p != "" {
+			literals = append(literals, p)
+		}
+		if g := trimPath(globLiteralPrefix(filter.PathGlob)); g != "" {
+			literals = append(literals, g)
+		}
+	}
+	for _, l := range literals {
+		expr = addSubstringFilter(expr, l)
+	}
+	return expr
+}
+
+func quoteAlternatives(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = regexp.QuoteMeta(v)
+	}
+	return strings.Join(quoted, "|")
+}
@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+
+	"vault-audit-mcp/internal/loki"
+)
+
+func TestSearchFilterMatcher(t *testing.T) {
+	events := []Event{
+		{RequestID: "a", Path: "secret/data/payments/db", Operation: "read", Namespace: "team-a/", AuditType: "request", Display: "approle-payments", RemoteAddr: "10.1.2.3", MountPoint: "secret/", TokenType: "batch", RoleName: "payments"},
+		{RequestID: "b", Path: "/secret/data/payments/api/key", Operation: "update", Namespace: "team-b/", AuditType: "response", Display: "oidc-alice", RemoteAddr: "192.168.1.7"},
+		{RequestID: "c", Path: "auth/kubernetes/login", Operation: "update", Namespace: "ci/", AuditType: "request", RemoteAddr: "::ffff:10.9.9.9"},
+		{RequestID: "d", Path: "sys/policies/acl/admin", Operation: "delete", Namespace: "team-a/", AuditType: "request", RemoteAddr: "2001:db8::1"},
+	}
+
+	tests := []struct {
+		name   string
+		filter SearchFilter
+		want   string
+	}{
+		{"path prefix ignores leading slash", SearchFilter{PathPrefix: "/secret/data/payments/"}, "ab"},
+		{"glob star stays in segment", SearchFilter{PathGlob: "secret/data/*/db"}, "a"},
+		{"glob double star crosses segments", SearchFilter{PathGlob: "secret/**/key"}, "b"},
+		{"regex is unanchored", SearchFilter{PathRegex: `/login$`}, "c"},
+		{"cidr ranges and addresses", SearchFilter{RemoteCIDRs: []string{"10.0.0.0/8", "2001:db8::1"}}, "acd"},
+		{"display name", SearchFilter{DisplayName: "OIDC-alice"}, "b"},
+		{"audit type", SearchFilter{AuditType: "response"}, "b"},
+		{"any of widens the single value", SearchFilter{Operation: "read", AnyOf: &FieldSet{Operations: []string{"delete"}}}, "ad"},
+		{"any of alone", SearchFilter{AnyOf: &FieldSet{Namespaces: []string{"team-b", "ci"}}}, "bc"},
+		{"exclude drops matches", SearchFilter{Exclude: &FieldSet{Namespaces: []string{"team-a"}}}, "bc"},
+		{"exclude combines with include", SearchFilter{Operation: "update", Exclude: &FieldSet{Operations: []string{"login"}}}, "b"},
+		{"exclude cidr", SearchFilter{Exclude: &FieldSet{RemoteCIDRs: []string{"10.0.0.0/8"}}}, "bd"},
+		{"mount point ignores trailing slash", SearchFilter{MountPoint: "secret"}, "a"},
+		{"token type and role", SearchFilter{TokenType: "batch", RoleName: "payments"}, "a"},
+		{"exclude token type", SearchFilter{Exclude: &FieldSet{TokenTypes: []string{"batch"}}}, "bcd"},
+		{"exclude path glob", SearchFilter{Exclude: &FieldSet{PathGlobs: []string{"secret/**"}}}, "cd"},
+		{"namespace prefix matches whole segments", SearchFilter{NamespacePrefix: "team"}, ""},
+		{"namespace prefix", SearchFilter{NamespacePrefix: "team-a"}, "ad"},
+		{"exclude namespace prefix", SearchFilter{Exclude: &FieldSet{NamespacePrefixes: []string{"team-a/", "ci"}}}, "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newSearchFilterMatcher(&tt.filter)
+			if err != nil {
+				t.Fatalf("newSearchFilterMatcher failed: %v", err)
+			}
+			var got strings.Builder
+			for _, ev := range events {
+				if m.matches(ev) {
+					got.WriteString(ev.RequestID)
+				}
+			}
+			if got.String() != tt.want {
+				t.Errorf("matched %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchFilterValidate(t *testing.T) {
+	for _, f := range []SearchFilter{
+		{PathRegex: "secret/(unclosed"},
+		{RemoteCIDRs: []string{"10.0.0.0/33"}},
+		{AuditType: "both"},
+		{Exclude: &FieldSet{RemoteCIDRs: []string{"not-an-ip"}}},
+	} {
+		if err := f.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", f)
+		}
+	}
+	if err := (&SearchFilter{PathGlob: "secret/*", RemoteCIDRs: []string{"10.1.2.3"}}).Validate(); err != nil {
+		t.Errorf("Validate failed: %v", err)
+	}
+}
+
+func TestPushDownFilters(t *testing.T) {
+	filter := &SearchFilter{
+		Namespace:   "team-a",
+		Operation:   "delete",
+		DisplayName: "approle-ci",
+		PathPrefix:  "/secret/data/",
+		AnyOf:       &FieldSet{MountTypes: []string{"kv", "pki"}},
+		Exclude:     &FieldSet{Namespaces: []string{"ci/"}, AuditTypes: []string{"response"}},
+	}
+	sel := loki.Selector{Labels: map[string]string{LabelService: ValueServiceVault}}
+	pushDownLabels(&sel, filter)
+	// The display name is only post-filtered, case-insensitively.
+	want := `{service="vault",vault_namespace="team-a/",vault_operation="delete",` +
+		`vault_mount_type=~"kv|pki",vault_namespace!~"ci/",vault_audit_type!~"response"}`
+	if got := sel.String(); got != want {
+		t.Errorf("selector = %s\nwant %s", got, want)
+	}
+	if got, want := pushDownPath("{}", filter), `({} |= "secret/data/")`; got != want {
+		t.Errorf("path filter = %s, want %s", got, want)
+	}
+
+	sel = loki.Selector{Labels: map[string]string{}}
+	pushDownLabels(&sel, &SearchFilter{NamespacePrefix: "org/team.a", Exclude: &FieldSet{NamespacePrefixes: []string{"org/ci", "org/dev/"}}})
+	if got, want := sel.String(), `{vault_namespace=~"org/team\\.a/.*",vault_namespace!~"(org/ci/|org/dev/).*"}`; got != want {
+		t.Errorf("selector = %s\nwant %s", got, want)
+	}
+
+	// Alternative operations and alias operations are left to the post-filter.
+	sel = loki.Selector{Labels: map[string]string{}}
+	pushDownLabels(&sel, &SearchFilter{Operation: "delete", AnyOf: &FieldSet{Operations: []string{"update"}}})
+	if got := sel.String(); got != "{}" {
+		t.Errorf("selector = %s, want {}", got)
+	}
+	if got := pushDownPath("{}", &SearchFilter{PathGlob: "*/login"}); got != "{}" {
+		t.Errorf("glob without a literal prefix should not add a line filter, got %s", got)
+	}
+}
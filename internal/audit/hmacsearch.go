@@ -0,0 +1,188 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"vault-audit-mcp/internal/loki"
+)
+
+// HMACFilter selects audit entries containing any of the given HMAC values.
+type HMACFilter struct {
+	Start time.Time
+	End   time.Time
+	Limit int
+	HMACs []string // "hmac-sha256:<hex>" values as written by Vault
+}
+
+// HMACMatch is an audit event together with the HMAC values it contained
+// and the (pre-redaction) field paths where they were found.
+type HMACMatch struct {
+	Event  Event               `json:"event"`
+	Fields map[string][]string `json:"fields"` // hmac value -> field paths
+}
+
+// HMACSearcher is implemented by backends that can search for HMAC values
+// across the unredacted audit entry. Redaction may mask the very fields that
+// hold these values (e.g. request.client_token), so matching must happen
+// before the redaction policy is applied.
+type HMACSearcher interface {
+	FindByHMAC(ctx context.Context, filter *HMACFilter) ([]HMACMatch, error)
+}
+
+// LoadAuditHMACKey reads the audit device HMAC key (salt) from path.
+// Surrounding whitespace is trimmed.
+func LoadAuditHMACKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit HMAC key: %w", err)
+	}
+	key := []byte(strings.TrimSpace(string(data)))
+	if len(key) == 0 {
+		return nil, fmt.Errorf("audit HMAC key file %s is empty", path)
+	}
+	return key, nil
+}
+
+// AuditHMAC computes the value Vault writes to the audit log for plaintext
+// when the audit device is keyed with key.
+func AuditHMAC(key []byte, plaintext string) string {
+	return hmacSHA256(key, []byte(plaintext))
+}
+
+// FindByHMAC returns audit events containing any of the filter's HMAC values.
+func (b *LokiBackend) FindByHMAC(ctx context.Context, filter *HMACFilter) ([]HMACMatch, error) {
+	if err := b.queryOpts.Limits.checkRange(filter.Start, filter.End); err != nil {
+		return nil, err
+	}
+	filter.Limit = b.queryOpts.Limits.eventLimit(filter.Limit)
+
+	hexes := make([]string, 0, len(filter.HMACs))
+	for _, h := range filter.HMACs {
+		hex := strings.TrimPrefix(h, "hmac-sha256:")
+		if hex == "" || strings.Trim(hex, "0123456789abcdef") != "" {
+			return nil, fmt.Errorf("invalid HMAC value %q", h)
+		}
+		hexes = append(hexes, hex)
+	}
+	if len(hexes) == 0 {
+		return nil, fmt.Errorf("at least one HMAC value is required")
+	}
+
+	sel := loki.Selector{Labels: b.baseSelector()}
+	query := addRegexFilter(sel.String(), "hmac-sha256:("+strings.Join(hexes, "|")+")")
+
+	matches := make([]HMACMatch, 0)
+	err := b.scan(ctx, query, filter.Start, filter.End, filter.Limit, false, func(t time.Time, stream map[string]string, auditData map[string]any) bool {
+		fields := findHMACFields(auditData, filter.HMACs)
+		if len(fields) == 0 {
+			return false
+		}
+		matches = append(matches, HMACMatch{
+			Event:  b.newEvent(t, stream, auditData),
+			Fields: fields,
+		})
+		return true
+	})
+	if errors.Is(err, ErrIncomplete) {
+		return matches, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loki hmac query failed: %w", err)
+	}
+	return matches, nil
+}
+
+// findHMACFields walks an audit entry and returns, for each wanted HMAC
+// value, the dotted field paths whose string value equals it.
+func findHMACFields(m map[string]any, hmacs []string) map[string][]string {
+	wanted := make(map[string]bool, len(hmacs))
+	for _, h := range hmacs {
+		wanted[h] = true
+	}
+	out := make(map[string][]string)
+	var walk func(v any, path string)
+	walk = func(v any, path string) {
+		switch x := v.(type) {
+		case map[string]any:
+			for k, c := range x {
+				p := k
+				if path != "" {
+					p = path + "." + k
+				}
+				walk(c, p)
+			}
+		case []any:
+			for _, c := range x {
+				walk(c, path)
+			}
+		case string:
+			if wanted[x] && !contains(out[x], path) {
+				out[x] = append(out[x], path)
+			}
+		}
+	}
+	walk(m, "")
+	for _, paths := range out {
+		sort.Strings(paths)
+	}
+	return out
+}
+
+// HMACSearchResult is returned by audit.find_by_hmac. It identifies each
+// supplied value only by its position and HMAC, never by its plaintext.
+type HMACSearchResult struct {
+	StartTime    string             `json:"start_time"`
+	EndTime      string             `json:"end_time"`
+	Values       []HMACValueSummary `json:"values"`
+	TotalMatches int                `json:"total_matches"`
+	Matches      []HMACMatch        `json:"matches"`
+	// Incomplete is set when the query deadline was reached before the
+	// whole range was searched.
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// HMACValueSummary reports how often one supplied value was found.
+type HMACValueSummary struct {
+	Index      int      `json:"index"` // 1-based position in the request
+	HMAC       string   `json:"hmac"`
+	MatchCount int      `json:"match_count"`
+	Fields     []string `json:"fields,omitempty"` // distinct field paths it appeared in
+}
+
+// SummarizeHMACMatches builds the tool result for matches of hmacs, stripping
+// Raw from the returned events to keep the payload small.
+func SummarizeHMACMatches(matches []HMACMatch, hmacs []string, startTime, endTime string) *HMACSearchResult {
+	result := &HMACSearchResult{
+		StartTime:    startTime,
+		EndTime:      endTime,
+		Values:       make([]HMACValueSummary, len(hmacs)),
+		TotalMatches: len(matches),
+		Matches:      make([]HMACMatch, 0, len(matches)),
+	}
+	for i, h := range hmacs {
+		result.Values[i] = HMACValueSummary{Index: i + 1, HMAC: h}
+	}
+	for _, m := range matches {
+		for i, h := range hmacs {
+			paths, ok := m.Fields[h]
+			if !ok {
+				continue
+			}
+			result.Values[i].MatchCount++
+			for _, p := range paths {
+				if !contains(result.Values[i].Fields, p) {
+					result.Values[i].Fields = append(result.Values[i].Fields, p)
+				}
+			}
+		}
+		m.Event.Raw = nil
+		result.Matches = append(result.Matches, m)
+	}
+	return result
+}
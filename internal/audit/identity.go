@@ -0,0 +1,425 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IdentityPollInterval is how often a watched identity snapshot is checked
+// for changes.
+var IdentityPollInterval = 30 * time.Second
+
+// IdentitySnapshot is a local export of Vault identity: entities with their
+// aliases, groups, and mount accessors with their paths. Field names follow
+// the Vault identity and sys/mounts API responses.
+type IdentitySnapshot struct {
+	Entities []SnapshotEntity `json:"entities"`
+	Groups   []SnapshotGroup  `json:"groups"`
+	Mounts   SnapshotMounts   `json:"mounts"`
+}
+
+// SnapshotEntity is an identity entity as read from identity/entity/id/:id.
+type SnapshotEntity struct {
+	ID                string          `json:"id"`
+	Name              string          `json:"name"`
+	Disabled          bool            `json:"disabled,omitempty"`
+	Policies          []string        `json:"policies,omitempty"`
+	Aliases           []SnapshotAlias `json:"aliases,omitempty"`
+	GroupIDs          []string        `json:"group_ids,omitempty"`
+	DirectGroupIDs    []string        `json:"direct_group_ids,omitempty"`
+	InheritedGroupIDs []string        `json:"inherited_group_ids,omitempty"`
+}
+
+// SnapshotAlias is an entity alias on an auth mount.
+type SnapshotAlias struct {
+	ID            string `json:"id,omitempty"`
+	Name          string `json:"name"`
+	MountAccessor string `json:"mount_accessor"`
+	MountPath     string `json:"mount_path,omitempty"`
+	MountType     string `json:"mount_type,omitempty"`
+}
+
+// SnapshotGroup is an identity group as read from identity/group/id/:id.
+type SnapshotGroup struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	Type            string   `json:"type,omitempty"`
+	Policies        []string `json:"policies,omitempty"`
+	MemberEntityIDs []string `json:"member_entity_ids,omitempty"`
+	ParentGroupIDs  []string `json:"parent_group_ids,omitempty"`
+}
+
+// SnapshotMount maps a mount accessor to its path.
+type SnapshotMount struct {
+	Accessor string `json:"accessor"`
+	Path     string `json:"path"`
+	Type     string `json:"type,omitempty"`
+}
+
+// SnapshotMounts accepts a list of mounts, or the path-keyed object returned
+// by sys/mounts and sys/auth.
+type SnapshotMounts []SnapshotMount
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (m *SnapshotMounts) UnmarshalJSON(data []byte) error {
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '{' {
+		var byPath map[string]SnapshotMount
+		if err := json.Unmarshal(data, &byPath); err != nil {
+			return err
+		}
+		*m = make(SnapshotMounts, 0, len(byPath))
+		for path, mount := range byPath {
+			if mount.Path == "" {
+				mount.Path = path
+			}
+			*m = append(*m, mount)
+		}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]SnapshotMount)(m))
+}
+
+// EntityInfo describes an entity resolved from the identity snapshot.
+type EntityInfo struct {
+	ID       string        `json:"id"`
+	Name     string        `json:"name"`
+	Disabled bool          `json:"disabled,omitempty"`
+	Policies []string      `json:"policies,omitempty"`
+	Aliases  []EntityAlias `json:"aliases,omitempty"`
+	// Groups are the names of the groups the entity is a direct or
+	// inherited member of.
+	Groups []string `json:"groups,omitempty"`
+}
+
+// EntityLookup is the audit.lookup_entity result.
+type EntityLookup struct {
+	Entities         []EntityInfo `json:"entities"`
+	SnapshotLoadedAt string       `json:"snapshot_loaded_at"`
+}
+
+// EntityAlias is an entity alias with its auth mount resolved.
+type EntityAlias struct {
+	Name          string `json:"name"`
+	MountPath     string `json:"mount_path,omitempty"`
+	MountType     string `json:"mount_type,omitempty"`
+	MountAccessor string `json:"mount_accessor,omitempty"`
+}
+
+// identityIndex is a loaded snapshot indexed for lookups.
+type identityIndex struct {
+	entities map[string]*EntityInfo // by ID
+	byName   map[string][]*EntityInfo
+	mounts   map[string]SnapshotMount // by accessor
+	loadedAt time.Time
+}
+
+func newIdentityIndex(snap *IdentitySnapshot) *identityIndex {
+	idx := &identityIndex{
+		entities: make(map[string]*EntityInfo, len(snap.Entities)),
+		byName:   make(map[string][]*EntityInfo),
+		mounts:   make(map[string]SnapshotMount, len(snap.Mounts)),
+		loadedAt: time.Now().UTC(),
+	}
+	for _, m := range snap.Mounts {
+		if m.Accessor != "" {
+			idx.mounts[m.Accessor] = m
+		}
+	}
+
+	groups := make(map[string]*SnapshotGroup, len(snap.Groups))
+	memberOf := make(map[string][]string) // entity ID -> group IDs
+	for i := range snap.Groups {
+		g := &snap.Groups[i]
+		groups[g.ID] = g
+		for _, id := range g.MemberEntityIDs {
+			memberOf[id] = append(memberOf[id], g.ID)
+		}
+	}
+
+	for _, e := range snap.Entities {
+		info := &EntityInfo{ID: e.ID, Name: e.Name, Disabled: e.Disabled, Policies: e.Policies}
+		for _, a := range e.Aliases {
+			alias := EntityAlias{Name: a.Name, MountPath: a.MountPath, MountType: a.MountType, MountAccessor: a.MountAccessor}
+			if m, ok := idx.mounts[a.MountAccessor]; ok {
+				if alias.MountPath == "" {
+					alias.MountPath = m.Path
+				}
+				if alias.MountType == "" {
+					alias.MountType = m.Type
+				}
+			}
+			info.Aliases = append(info.Aliases, alias)
+			idx.addName(a.Name, info)
+		}
+		ids := append(append(append(append([]string(nil), e.GroupIDs...), e.DirectGroupIDs...), e.InheritedGroupIDs...), memberOf[e.ID]...)
+		info.Groups = groupNames(groups, ids)
+		idx.entities[e.ID] = info
+		idx.addName(e.Name, info)
+	}
+	return idx
+}
+
+func (idx *identityIndex) addName(name string, info *EntityInfo) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" {
+		return
+	}
+	for _, e := range idx.byName[key] {
+		if e == info {
+			return
+		}
+	}
+	idx.byName[key] = append(idx.byName[key], info)
+}
+
+// groupNames resolves group IDs and their parent groups to sorted names.
+// Unknown IDs are kept as-is.
+func groupNames(groups map[string]*SnapshotGroup, ids []string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for len(ids) > 0 {
+		id := ids[0]
+		ids = ids[1:]
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		g, ok := groups[id]
+		if !ok {
+			names = append(names, id)
+			continue
+		}
+		names = append(names, g.Name)
+		ids = append(ids, g.ParentGroupIDs...)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IdentityStore resolves entity IDs and mount accessors from an identity
+// snapshot file, reloading it when the file changes.
+type IdentityStore struct {
+	path string
+
+	mu      sync.RWMutex
+	index   *identityIndex
+	modTime time.Time
+	size    int64
+}
+
+// LoadIdentityStore reads the identity snapshot at path.
+func LoadIdentityStore(path string) (*IdentityStore, error) {
+	s := &IdentityStore{path: path}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload re-reads the snapshot when the file changed since it was last
+// loaded, and reports whether it did. On error the previous snapshot is kept.
+func (s *IdentityStore) Reload() (bool, error) {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read identity snapshot: %w", err)
+	}
+	s.mu.RLock()
+	unchanged := s.index != nil && fi.ModTime().Equal(s.modTime) && fi.Size() == s.size
+	s.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read identity snapshot: %w", err)
+	}
+	var snap IdentitySnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return false, fmt.Errorf("invalid identity snapshot %s: %w", s.path, err)
+	}
+	idx := newIdentityIndex(&snap)
+
+	s.mu.Lock()
+	s.index, s.modTime, s.size = idx, fi.ModTime(), fi.Size()
+	s.mu.Unlock()
+	return true, nil
+}
+
+// Watch reloads the snapshot whenever the file changes, checking every
+// interval until ctx is done. Reload errors are logged and the previous
+// snapshot stays in use.
+func (s *IdentityStore) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := s.Reload()
+			if err != nil {
+				log.Printf("identity snapshot reload failed: %v", err)
+			} else if changed {
+				entities, mounts := s.Counts()
+				log.Printf("reloaded identity snapshot from %s (%d entities, %d mounts)", s.path, entities, mounts)
+			}
+		}
+	}
+}
+
+// Counts returns the number of entities and mounts in the snapshot.
+func (s *IdentityStore) Counts() (entities, mounts int) {
+	idx := s.current()
+	return len(idx.entities), len(idx.mounts)
+}
+
+// LoadedAt returns when the current snapshot was loaded.
+func (s *IdentityStore) LoadedAt() time.Time {
+	return s.current().loadedAt
+}
+
+func (s *IdentityStore) current() *identityIndex {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index
+}
+
+// LookupEntity returns the entities with the given ID, or whose name or
+// alias name equals name (case-insensitive).
+func (s *IdentityStore) LookupEntity(id, name string) []EntityInfo {
+	idx := s.current()
+	var out []EntityInfo
+	if e, ok := idx.entities[strings.TrimSpace(id)]; ok {
+		out = append(out, *e)
+	}
+	for _, e := range idx.byName[strings.ToLower(strings.TrimSpace(name))] {
+		if len(out) == 0 || out[0].ID != e.ID {
+			out = append(out, *e)
+		}
+	}
+	return out
+}
+
+// Enrich adds the entity name, alias, groups and mount path known for ev.
+func (s *IdentityStore) Enrich(ev *Event) {
+	idx := s.current()
+	if m, ok := idx.mounts[ev.MountAccessor]; ok && ev.MountAccessor != "" {
+		if ev.MountPoint == "" {
+			ev.MountPoint = m.Path
+		}
+		if ev.MountType == "" {
+			ev.MountType = m.Type
+		}
+	}
+	e, ok := idx.entities[ev.EntityID]
+	if !ok || ev.EntityID == "" {
+		return
+	}
+	ev.EntityName = e.Name
+	ev.Groups = slices.Clone(e.Groups)
+	if a := eventAlias(e, ev); a != nil {
+		ev.AliasName = a.Name
+		ev.AliasMountPath = a.MountPath
+	}
+}
+
+// eventAlias picks the alias the event's token was most likely issued
+// through: the alias on the request's mount (logins), the alias whose mount
+// prefixes the token display name (e.g. oidc-alice), or the only alias.
+func eventAlias(e *EntityInfo, ev *Event) *EntityAlias {
+	for i, a := range e.Aliases {
+		if a.MountAccessor != "" && a.MountAccessor == ev.MountAccessor {
+			return &e.Aliases[i]
+		}
+	}
+	display := strings.ToLower(ev.Display)
+	for i, a := range e.Aliases {
+		mount := strings.Trim(strings.TrimPrefix(a.MountPath, "auth/"), "/")
+		if mount != "" && strings.HasPrefix(display, strings.ToLower(strings.ReplaceAll(mount, "/", "-"))+"-") {
+			return &e.Aliases[i]
+		}
+	}
+	if len(e.Aliases) == 1 {
+		return &e.Aliases[0]
+	}
+	return nil
+}
+
+// WithIdentity wraps backend so the events it returns are enriched from
+// store. Wrap outside any result cache, so that a reloaded snapshot applies
+// to cached results too.
+func WithIdentity(backend Backend, store *IdentityStore) Backend {
+	if ib, ok := backend.(*identityBackend); ok {
+		backend = ib.Backend
+	}
+	if store == nil {
+		return backend
+	}
+	return &identityBackend{Backend: backend, identity: store}
+}
+
+// identityBackend enriches the events returned by a backend from an
+// identity snapshot.
+type identityBackend struct {
+	Backend
+	identity *IdentityStore
+}
+
+func (b *identityBackend) enrich(events []Event) {
+	for i := range events {
+		b.identity.Enrich(&events[i])
+	}
+}
+
+// Search returns the wrapped backend's events, enriched.
+func (b *identityBackend) Search(ctx context.Context, filter *SearchFilter) ([]Event, error) {
+	events, err := b.Backend.Search(ctx, filter)
+	b.enrich(events)
+	return events, err
+}
+
+// Trace returns the wrapped backend's events, enriched.
+func (b *identityBackend) Trace(ctx context.Context, filter *TraceFilter) ([]Event, error) {
+	events, err := b.Backend.Trace(ctx, filter)
+	b.enrich(events)
+	return events, err
+}
+
+// SearchInstant returns the wrapped backend's events at t, enriched.
+func (b *identityBackend) SearchInstant(ctx context.Context, filter *SearchFilter, t time.Time) ([]Event, error) {
+	searcher, ok := b.Backend.(instantSearcher)
+	if !ok {
+		return nil, errNoInstantSearch
+	}
+	events, err := searcher.SearchInstant(ctx, filter, t)
+	b.enrich(events)
+	return events, err
+}
+
+// QueryLimits returns the limits of the wrapped backend.
+func (b *identityBackend) QueryLimits() QueryLimits {
+	return limitsOf(b.Backend)
+}
+
+// FindByHMAC returns the wrapped backend's matches, enriched.
+func (b *identityBackend) FindByHMAC(ctx context.Context, filter *HMACFilter) ([]HMACMatch, error) {
+	searcher, ok := b.Backend.(HMACSearcher)
+	if !ok {
+		return nil, fmt.Errorf("backend does not support HMAC search")
+	}
+	matches, err := searcher.FindByHMAC(ctx, filter)
+	for i := range matches {
+		b.identity.Enrich(&matches[i].Event)
+	}
+	return matches, err
+}
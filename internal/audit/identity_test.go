@@ -0,0 +1,158 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const identitySnapshotJSON = `{
+  "entities": [
+    {
+      "id": "ent-1",
+      "name": "alice",
+      "policies": ["dev"],
+      "aliases": [
+        {"name": "alice@example.com", "mount_accessor": "auth_oidc_1"},
+        {"name": "alice", "mount_accessor": "auth_userpass_2", "mount_path": "auth/userpass/"}
+      ],
+      "direct_group_ids": ["grp-dev"]
+    },
+    {"id": "ent-2", "name": "payments-svc", "aliases": [{"name": "role-id-1", "mount_accessor": "auth_approle_3"}]}
+  ],
+  "groups": [
+    {"id": "grp-dev", "name": "developers", "parent_group_ids": ["grp-eng"]},
+    {"id": "grp-eng", "name": "engineering"},
+    {"id": "grp-oncall", "name": "oncall", "member_entity_ids": ["ent-1"]}
+  ],
+  "mounts": {
+    "auth/oidc/": {"accessor": "auth_oidc_1", "type": "oidc"},
+    "auth/approle/": {"accessor": "auth_approle_3", "type": "approle"},
+    "secret/": {"accessor": "kv_4", "type": "kv"}
+  }
+}`
+
+func writeIdentitySnapshot(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "identity.json")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestIdentityStoreEnrich(t *testing.T) {
+	store, err := LoadIdentityStore(writeIdentitySnapshot(t, identitySnapshotJSON))
+	if err != nil {
+		t.Fatalf("LoadIdentityStore failed: %v", err)
+	}
+
+	tests := []struct {
+		name                    string
+		ev                      Event
+		entity, alias, aliasMnt string
+	}{
+		{"display name prefix picks the alias", Event{EntityID: "ent-1", Display: "oidc-alice@example.com"}, "alice", "alice@example.com", "auth/oidc/"},
+		{"login mount picks the alias", Event{EntityID: "ent-1", MountAccessor: "auth_userpass_2", Display: "x"}, "alice", "alice", "auth/userpass/"},
+		{"ambiguous alias is left empty", Event{EntityID: "ent-1", Display: "token"}, "alice", "", ""},
+		{"single alias", Event{EntityID: "ent-2"}, "payments-svc", "role-id-1", "auth/approle/"},
+		{"unknown entity", Event{EntityID: "ent-9"}, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := tt.ev
+			store.Enrich(&ev)
+			if ev.EntityName != tt.entity || ev.AliasName != tt.alias || ev.AliasMountPath != tt.aliasMnt {
+				t.Errorf("got %q/%q/%q, want %q/%q/%q", ev.EntityName, ev.AliasName, ev.AliasMountPath, tt.entity, tt.alias, tt.aliasMnt)
+			}
+		})
+	}
+
+	ev := Event{EntityID: "ent-1", MountAccessor: "kv_4"}
+	store.Enrich(&ev)
+	if want := []string{"developers", "engineering", "oncall"}; !reflect.DeepEqual(ev.Groups, want) {
+		t.Errorf("groups = %v, want %v", ev.Groups, want)
+	}
+	if ev.MountPoint != "secret/" || ev.MountType != "kv" {
+		t.Errorf("mount = %q %q, want secret/ kv", ev.MountPoint, ev.MountType)
+	}
+}
+
+func TestIdentityStoreReload(t *testing.T) {
+	path := writeIdentitySnapshot(t, identitySnapshotJSON)
+	store, err := LoadIdentityStore(path)
+	if err != nil {
+		t.Fatalf("LoadIdentityStore failed: %v", err)
+	}
+	if changed, err := store.Reload(); changed || err != nil {
+		t.Errorf("Reload of an unchanged file = %v, %v", changed, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"entities": [{"id": "ent-1", "name": "alice-renamed"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := store.Reload(); !changed || err != nil {
+		t.Fatalf("Reload = %v, %v, want a reload", changed, err)
+	}
+	if got := store.LookupEntity("ent-1", ""); len(got) != 1 || got[0].Name != "alice-renamed" {
+		t.Errorf("after reload = %+v", got)
+	}
+
+	// A broken snapshot keeps the previous one.
+	if err := os.WriteFile(path, []byte(`{not json`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Reload(); err == nil {
+		t.Error("Reload should fail for invalid JSON")
+	}
+	if entities, _ := store.Counts(); entities != 1 {
+		t.Errorf("entities = %d, want the previous snapshot", entities)
+	}
+}
+
+func TestIdentityEnrichesToolResults(t *testing.T) {
+	store, err := LoadIdentityStore(writeIdentitySnapshot(t, identitySnapshotJSON))
+	if err != nil {
+		t.Fatalf("LoadIdentityStore failed: %v", err)
+	}
+	svc := NewService(&stubBackend{events: []Event{
+		{RequestID: "r1", EntityID: "ent-2", Display: "approle", Operation: "read"},
+	}})
+	svc.SetIdentity(store)
+	session := connectService(t, svc)
+
+	res, err := session.CallTool(t.Context(), &mcp.CallToolParams{Name: "audit.search_events", Arguments: map[string]any{}})
+	if err != nil || res.IsError {
+		t.Fatalf("search failed: %v %v", err, res)
+	}
+	var summary SearchSummary
+	raw, _ := json.Marshal(res.StructuredContent)
+	if err := json.Unmarshal(raw, &summary); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if len(summary.TopActors) != 1 || summary.TopActors[0].EntityName != "payments-svc" || summary.TopActors[0].AliasName != "role-id-1" {
+		t.Errorf("actors = %+v", summary.TopActors)
+	}
+
+	res, err = session.CallTool(t.Context(), &mcp.CallToolParams{Name: "audit.lookup_entity", Arguments: map[string]any{"name": "ALICE@example.com"}})
+	if err != nil || res.IsError {
+		t.Fatalf("lookup failed: %v %v", err, res)
+	}
+	var lookup EntityLookup
+	raw, _ = json.Marshal(res.StructuredContent)
+	if err := json.Unmarshal(raw, &lookup); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if len(lookup.Entities) != 1 || lookup.Entities[0].ID != "ent-1" || lookup.Entities[0].Aliases[0].MountPath != "auth/oidc/" {
+		t.Errorf("lookup = %+v", lookup.Entities)
+	}
+}
@@ -3,24 +3,108 @@ package audit
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
 	"vault-audit-mcp/internal/loki"
+	"vault-audit-mcp/internal/metrics"
 )
 
 // LokiBackend implements Backend using Loki as the storage backend.
 type LokiBackend struct {
 	client    *loki.Client
 	labelsCfg LabelConfig
+	redaction *RedactionPolicy
+	queryOpts QueryOptions
+	debug     bool
 }
 
 const queryChunkDuration = 10 * time.Minute
 const maxPerQueryRangeLimit = 25
 
+// Query tuning defaults.
+const (
+	DefaultQueryParallelism = 4
+	DefaultMinQueryChunk    = 10 * time.Second
+	DefaultMaxQueryChunk    = 6 * time.Hour
+)
+
+// QueryOptions tunes how log queries are split and fetched.
+// Zero values use the defaults.
+type QueryOptions struct {
+	// Parallelism is the number of windows fetched concurrently.
+	Parallelism int
+	// InitialChunk is the first window size; later windows adapt to the
+	// observed event density within [MinChunk, MaxChunk].
+	InitialChunk time.Duration
+	MinChunk     time.Duration
+	MaxChunk     time.Duration
+	// Limits bounds the events and time range of each query.
+	Limits QueryLimits
+}
+
+func (o QueryOptions) withDefaults() QueryOptions {
+	if o.Parallelism <= 0 {
+		o.Parallelism = DefaultQueryParallelism
+	}
+	if o.InitialChunk <= 0 {
+		o.InitialChunk = queryChunkDuration
+	}
+	if o.MinChunk <= 0 {
+		o.MinChunk = DefaultMinQueryChunk
+	}
+	if o.MaxChunk <= 0 {
+		o.MaxChunk = DefaultMaxQueryChunk
+	}
+	if o.MaxChunk < o.MinChunk {
+		o.MaxChunk = o.MinChunk
+	}
+	o.Limits = o.Limits.withDefaults()
+	return o
+}
+
+// nextChunk sizes the next windows so that each request returns about half
+// of its line limit, given lines observed over span. Growth is capped at 4x
+// per batch so one sparse stretch does not overshoot a dense one.
+func (o QueryOptions) nextChunk(current time.Duration, lines int, span time.Duration, perCallLimit int, saturated bool) time.Duration {
+	next := current
+	switch {
+	case span <= 0:
+	case lines == 0:
+		next = current * 4
+	default:
+		target := float64(perCallLimit) / 2
+		if target < 1 {
+			target = 1
+		}
+		perLine := float64(span) / float64(lines)
+		next = time.Duration(perLine * target)
+		if next > current*4 {
+			next = current * 4
+		}
+	}
+	if saturated && next >= current {
+		next = current / 2
+	}
+	if next < o.MinChunk {
+		next = o.MinChunk
+	}
+	if next > o.MaxChunk {
+		next = o.MaxChunk
+	}
+	return next
+}
+
 // NewLokiBackend creates a new Loki backend instance.
 // Pass nil for cfg to use the default custom-label behaviour.
 func NewLokiBackend(client *loki.Client, cfg *LabelConfig) *LokiBackend {
@@ -28,7 +112,36 @@ func NewLokiBackend(client *loki.Client, cfg *LabelConfig) *LokiBackend {
 	if cfg != nil {
 		lc = *cfg
 	}
-	return &LokiBackend{client: client, labelsCfg: lc}
+	return &LokiBackend{
+		client:    client,
+		labelsCfg: lc,
+		redaction: DefaultRedactionPolicy(),
+		queryOpts: QueryOptions{}.withDefaults(),
+	}
+}
+
+// SetDebug enables logging of generated queries and sample lines.
+func (b *LokiBackend) SetDebug(debug bool) {
+	b.debug = debug
+}
+
+// SetQueryOptions sets query parallelism, window sizing and limits.
+func (b *LokiBackend) SetQueryOptions(opts QueryOptions) {
+	b.queryOpts = opts.withDefaults()
+}
+
+// QueryLimits returns the limits applied to each query.
+func (b *LokiBackend) QueryLimits() QueryLimits {
+	return b.queryOpts.Limits
+}
+
+// SetRedactionPolicy replaces the redaction policy applied to every event
+// before it leaves the backend. A nil policy restores the default.
+func (b *LokiBackend) SetRedactionPolicy(p *RedactionPolicy) {
+	if p == nil {
+		p = DefaultRedactionPolicy()
+	}
+	b.redaction = p
 }
 
 // baseSelector returns the Loki stream selector labels for this backend.
@@ -105,49 +218,55 @@ func normalizeNamespace(ns string) string {
 // Search returns audit events matching the provided filter.
 func (b *LokiBackend) Search(ctx context.Context, filter *SearchFilter) ([]Event, error) {
 	// Validate resource limits
-	duration := filter.End.Sub(filter.Start)
-	if duration > time.Duration(MaxQueryDays)*24*time.Hour {
-		return nil, fmt.Errorf("query time range exceeds maximum of %d days", MaxQueryDays)
+	if err := b.queryOpts.Limits.checkRange(filter.Start, filter.End); err != nil {
+		return nil, err
 	}
 
-	debug := strings.EqualFold(os.Getenv("AUDIT_DEBUG_LOG"), "1") ||
-		strings.EqualFold(os.Getenv("AUDIT_DEBUG_LOG"), "true")
-
 	// Normalize limit
-	if filter.Limit <= 0 || filter.Limit > MaxQueryLimit {
-		filter.Limit = DefaultLimit
+	filter.Limit = b.queryOpts.Limits.eventLimit(filter.Limit)
+	return b.search(ctx, filter, filter.Limit)
+}
+
+// SearchInstant returns every event matching filter at instant t, however
+// many share it, ignoring the filter's range and limit. Export uses it to
+// page past a timestamp holding more events than one search returns.
+func (b *LokiBackend) SearchInstant(ctx context.Context, filter *SearchFilter, t time.Time) ([]Event, error) {
+	f := *filter
+	f.Start, f.End = t, t.Add(time.Nanosecond)
+	return b.search(ctx, &f, maxInstantEvents)
+}
+
+// maxInstantEvents bounds the events SearchInstant reads at one instant.
+const maxInstantEvents = 1 << 20
+
+// search returns up to limit events matching filter.
+func (b *LokiBackend) search(ctx context.Context, filter *SearchFilter, limit int) ([]Event, error) {
+	matcher, err := newSearchFilterMatcher(filter)
+	if err != nil {
+		return nil, err
 	}
-	limit := filter.Limit
+
+	debug := b.debug
 
 	// Build label selector
 	sel := loki.Selector{Labels: b.baseSelector()}
 
 	// When Vault-specific labels are available, add them for fast filtering.
 	if b.labelsCfg.UseVaultLabels {
-		if filter.Namespace != "" {
-			sel.Labels[LabelNamespace] = normalizeNamespace(filter.Namespace)
-		}
-		if filter.Status != "" {
-			sel.Labels[LabelStatus] = filter.Status
-		}
-		if filter.MountType != "" {
-			sel.Labels[LabelMountType] = filter.MountType
-		}
-		if filter.MountClass != "" {
-			sel.Labels[LabelMountClass] = filter.MountClass
-		}
-		opLower := strings.ToLower(strings.TrimSpace(filter.Operation))
-		if filter.Operation != "" && opLower != "login" && opLower != "write" && opLower != "update" {
-			sel.Labels[LabelOperation] = filter.Operation
-		}
-		if filter.EntityID != "" {
-			sel.Labels[LabelEntityID] = filter.EntityID
-		}
+		pushDownLabels(&sel, filter)
 	}
 
 	var queryExpr string
 	if b.labelsCfg.UseVaultLabels {
-		queryExpr = buildLogQLExpression(sel.String(), filter.Operation, "", "", filter.Policy)
+		// Alternative operations and policies are only post-filtered.
+		operation, policy := filter.Operation, filter.Policy
+		if filter.AnyOf != nil && len(filter.AnyOf.Operations) > 0 {
+			operation = ""
+		}
+		if filter.AnyOf != nil && len(filter.AnyOf.Policies) > 0 {
+			policy = ""
+		}
+		queryExpr = buildLogQLExpression(sel.String(), operation, "", "", policy)
 	} else {
 		// Content-only mode (CLF/OCP): audit JSON is stringified inside a
 		// "message" field, so inner quotes are backslash-escaped in the raw
@@ -155,196 +274,394 @@ func (b *LokiBackend) Search(ctx context.Context, filter *SearchFilter) ([]Event
 		// backslashes so the filter matches both CLF-wrapped and plain formats.
 		queryExpr = addRegexFilter(sel.String(), `\\?"request\\?":\{`)
 	}
+	queryExpr = pushDownPath(queryExpr, filter)
 	if debug {
-		log.Printf("[audit-debug] search query=%s start=%s end=%s limit=%d", queryExpr, filter.Start.Format(time.RFC3339Nano), filter.End.Format(time.RFC3339Nano), filter.Limit)
+		log.Printf("[audit-debug] search query=%s start=%s end=%s limit=%d", queryExpr, filter.Start.Format(time.RFC3339Nano), filter.End.Format(time.RFC3339Nano), limit)
 	}
 
-	matcher := newSearchFilterMatcher(filter, limit)
-	events := make([]Event, 0, limit)
+	events := make([]Event, 0, min(limit, b.queryOpts.Limits.MaxLimit))
 	logged := 0
-	for _, w := range splitTimeRangeReverse(filter.Start, filter.End, queryChunkDuration) {
-		remaining := limit - len(events)
-		if remaining <= 0 {
-			break
+	err = b.scan(ctx, queryExpr, filter.Start, filter.End, limit, debug, func(t time.Time, stream map[string]string, auditData map[string]any) bool {
+		if debug && logged < 3 {
+			reqBlock, _ := auditData["request"].(map[string]any)
+			reqPath, _ := reqBlock["path"].(string)
+			reqOp, _ := reqBlock["operation"].(string)
+			reqMountType, _ := reqBlock["mount_type"].(string)
+			reqMountClass, _ := reqBlock["mount_class"].(string)
+			log.Printf("[audit-debug] request path=%q op=%q mount_type=%q mount_class=%q", reqPath, reqOp, reqMountType, reqMountClass)
+			logged++
 		}
 
-		perCallLimit := remaining
-		if perCallLimit > maxPerQueryRangeLimit {
-			perCallLimit = maxPerQueryRangeLimit
+		ev := b.newEvent(t, stream, auditData)
+		if !matcher.matches(ev) {
+			return false
 		}
+		events = append(events, ev)
+		return true
+	})
+	if errors.Is(err, ErrIncomplete) {
+		return events, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loki search query failed: %w", err)
+	}
 
-		var resp *loki.QueryRangeResponse
-		for {
-			var err error
-			resp, err = b.client.QueryRange(ctx, queryExpr, w.Start, w.End, perCallLimit)
-			if err == nil {
-				break
-			}
-			if isResponseTooLargeErr(err) && perCallLimit > 1 {
-				perCallLimit = perCallLimit / 2
-				if perCallLimit < 1 {
-					perCallLimit = 1
-				}
-				continue
-			}
-			return nil, fmt.Errorf("loki search query failed: %w", err)
-		}
+	return events, nil
+}
 
-		for _, r := range resp.Data.Result {
-			for _, v := range r.Values {
-				if len(v) != 2 {
-					continue
-				}
+// newEvent redacts auditData in-place and builds the Event for it.
+func (b *LokiBackend) newEvent(t time.Time, stream map[string]string, auditData map[string]any) Event {
+	status := auditStatus(auditData)
+	statusCode := httpStatusCode(auditData)
+	metadata := b.redaction.allowedMetadata(auditData)
+	b.redaction.Apply(auditData)
+
+	ev := Event{
+		Time:   t,
+		Raw:    auditData,
+		Stream: stream,
+	}
+	populateFromAudit(&ev, auditData)
+	ev.Status = status
+	ev.StatusCode = statusCode
+	ev.AuthMetadata = metadata
+	ev.RoleName = firstString(metadata["role_name"], metadata["role"])
+	return ev
+}
 
-				tsStr, ok := v[0].(string)
-				if !ok {
-					log.Printf("failed to assert timestamp as string")
-					continue
-				}
-				t, terr := parseUnixNanoString(tsStr)
-				if terr != nil {
-					log.Printf("failed to parse timestamp: %v", terr)
-					continue
-				}
+// scanFunc receives each decoded, unredacted audit entry. It returns true when
+// the entry was accepted, which counts it against the scan limit.
+type scanFunc func(t time.Time, stream map[string]string, auditData map[string]any) bool
+
+// scan runs a log query over [start,end] newest first, decoding each line
+// into its Vault audit entry and handing it to fn, until limit entries have
+// been accepted or the range is exhausted.
+//
+// Windows are fetched in batches of up to QueryOptions.Parallelism
+// concurrent requests and consumed strictly newest first. When a window
+// returns as many lines as requested, the rest of that window is fetched
+// next (paginating on the oldest returned timestamp) and the remainder of
+// the batch is discarded. A page whose lines all share one timestamp is
+// followed by a query for just that instant with a raised limit. The window
+// size adapts to the observed density so
+// that each request returns roughly half of its line limit.
+func (b *LokiBackend) scan(ctx context.Context, queryExpr string, start, end time.Time, limit int, debug bool, fn scanFunc) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	opts := b.queryOpts
+	chunk := opts.InitialChunk
+	accepted := 0
+	logged := 0
 
-				logStr, ok := v[1].(string)
-				if !ok {
-					log.Printf("failed to assert log as string")
-					continue
-				}
-				parsed := map[string]any{}
-				if err := json.Unmarshal([]byte(logStr), &parsed); err != nil {
-					log.Printf("failed to unmarshal audit log: %v", err)
-					if debug && logged < 3 {
-						log.Printf("[audit-debug] raw_line=%q", truncateDebugLine(logStr))
-						logged++
-					}
-					continue
-				}
+	decoded := 0
+	defer func() {
+		metrics.EventsScanned.Add(float64(decoded))
+		metrics.EventsMatched.Add(float64(accepted))
+	}()
 
-				auditData, ok := extractAuditData(parsed)
-				if !ok {
-					// Not a Vault audit event (e.g. operational log)
+	cursor := end
+	// Lines at exactly the pagination boundary may be returned twice.
+	var boundary time.Time
+	boundarySeen := make(map[string]bool)
+
+	// consume decodes lines newest first and hands them to fn. It returns
+	// true once limit entries have been accepted.
+	consume := func(lines []scanLine) bool {
+		_, decodeSpan := tracer.Start(ctx, "audit.decode_window", trace.WithAttributes(attribute.Int("audit.lines", len(lines))))
+		defer decodeSpan.End()
+		for _, line := range lines {
+			if line.t.Equal(boundary) {
+				key := line.stream + "\x00" + line.text
+				if boundarySeen[key] {
 					continue
 				}
+				boundarySeen[key] = true
+			}
 
+			parsed := map[string]any{}
+			if err := json.Unmarshal([]byte(line.text), &parsed); err != nil {
+				log.Printf("failed to unmarshal audit log: %v", err)
 				if debug && logged < 3 {
-					reqBlock, _ := auditData["request"].(map[string]any)
-					reqPath, _ := reqBlock["path"].(string)
-					reqOp, _ := reqBlock["operation"].(string)
-					reqMountType, _ := reqBlock["mount_type"].(string)
-					reqMountClass, _ := reqBlock["mount_class"].(string)
-					log.Printf("[audit-debug] request path=%q op=%q mount_type=%q mount_class=%q", reqPath, reqOp, reqMountType, reqMountClass)
+					log.Printf("[audit-debug] raw_line=%q", truncateDebugLine(line.text))
 					logged++
 				}
+				continue
+			}
 
-				Redact(auditData)
+			auditData, ok := extractAuditData(parsed)
+			if !ok {
+				// Not a Vault audit event (e.g. operational log)
+				continue
+			}
+			decoded++
 
-				ev := Event{
-					Time:   t,
-					Raw:    auditData,
-					Stream: r.Stream,
+			if fn(line.t, line.labels, auditData) {
+				accepted++
+				if accepted >= limit {
+					return true
 				}
-				populateFromAudit(&ev, auditData)
+			}
+		}
+		return false
+	}
+
+	// drainInstant reads the lines at boundary instant t that were not seen
+	// yet, raising the line limit until the instant is exhausted. It returns
+	// true once limit entries have been accepted.
+	drainInstant := func(t time.Time) (bool, error) {
+		perCallLimit := len(boundarySeen) + min(limit-accepted, opts.Limits.MaxLimit)
+		for {
+			res := b.fetchWindow(ctx, queryExpr, timeWindow{Start: t, End: t.Add(time.Nanosecond)}, perCallLimit)
+			if res.err != nil {
+				return false, res.err
+			}
+			seen := len(boundarySeen)
+			if consume(res.lines) {
+				return true, nil
+			}
+			if len(res.lines) < res.limit {
+				return false, nil
+			}
+			if len(boundarySeen) == seen {
+				// Loki cannot return more lines at once.
+				log.Printf("skipping audit lines at %s: more share the timestamp than one response holds", t.Format(time.RFC3339Nano))
+				return false, nil
+			}
+			perCallLimit = res.limit * 2
+		}
+	}
+
+	scanned := 0
+	for cursor.After(start) || (cursor.Equal(start) && accepted == 0 && start.Equal(end)) {
+		if err := ctx.Err(); err != nil {
+			return deadlineErr(ctx, err)
+		}
+		remaining := limit - accepted
+		if remaining <= 0 {
+			break
+		}
+		perCallLimit := remaining
+		if perCallLimit > maxPerQueryRangeLimit {
+			perCallLimit = maxPerQueryRangeLimit
+		}
 
-				if matcher.matches(ev) {
-					events = append(events, ev)
-					if len(events) >= limit {
-						return events, nil
+		batchStart := cursor.Add(-chunk * time.Duration(opts.Parallelism))
+		if batchStart.Before(start) {
+			batchStart = start
+		}
+		windows := splitTimeRangeReverse(batchStart, cursor, chunk)
+		results := b.fetchWindows(ctx, queryExpr, windows, perCallLimit)
+
+		var scannedLines int
+		var scannedSpan time.Duration
+		saturated := false
+		for i, w := range windows {
+			res := results[i]
+			if res.err != nil {
+				return deadlineErr(ctx, res.err)
+			}
+
+			if consume(res.lines) {
+				return nil
+			}
+
+			scannedLines += len(res.lines)
+			if len(res.lines) >= res.limit && len(res.lines) > 0 {
+				// The window holds more lines than were returned. Continue
+				// from the oldest returned line; later windows in this batch
+				// are older and must wait until this one is exhausted.
+				oldest := res.lines[len(res.lines)-1].t
+				scannedSpan += w.End.Sub(oldest)
+				if !oldest.Equal(boundary) {
+					boundary = oldest
+					boundarySeen = make(map[string]bool)
+				}
+				for _, l := range res.lines {
+					if l.t.Equal(oldest) {
+						boundarySeen[l.stream+"\x00"+l.text] = true
 					}
 				}
+				if res.lines[0].t.Equal(oldest) {
+					// Every line shares one timestamp, so paginating on it
+					// would return the same page forever. Read the rest of
+					// that instant, then continue below it.
+					done, err := drainInstant(oldest)
+					if err != nil {
+						return deadlineErr(ctx, err)
+					}
+					if done {
+						return nil
+					}
+					cursor = oldest
+				} else {
+					// Query through the boundary instant so lines sharing the
+					// oldest timestamp are not skipped.
+					cursor = oldest.Add(time.Nanosecond)
+				}
+				if !cursor.After(w.Start) || cursor.After(w.End) {
+					cursor = w.Start
+				}
+				saturated = true
+				scanned++
+				reportProgress(ctx, Progress{WindowsScanned: scanned, EventsMatched: accepted, WindowsRemaining: windowsLeft(start, cursor, chunk)})
+				break
 			}
+			scannedSpan += w.End.Sub(w.Start)
+			cursor = w.Start
+			scanned++
+			reportProgress(ctx, Progress{WindowsScanned: scanned, EventsMatched: accepted, WindowsRemaining: windowsLeft(start, cursor, chunk)})
+		}
+
+		chunk = opts.nextChunk(chunk, scannedLines, scannedSpan, perCallLimit, saturated)
+		if cursor.Equal(start) {
+			break
 		}
 	}
 
-	return events, nil
+	return nil
+}
+
+// windowsLeft estimates the windows of size chunk needed to cover
+// [start,cursor].
+func windowsLeft(start, cursor time.Time, chunk time.Duration) int {
+	if !cursor.After(start) || chunk <= 0 {
+		return 0
+	}
+	return int((cursor.Sub(start) + chunk - 1) / chunk)
+}
+
+// scanLine is one log line returned by Loki.
+type scanLine struct {
+	t      time.Time
+	text   string
+	labels map[string]string
+	stream string // stable stream identity for de-duplication
+}
+
+type windowResult struct {
+	lines []scanLine // newest first
+	limit int        // line limit the successful request used
+	err   error
+}
+
+// fetchWindows queries each window concurrently and returns the results in
+// window order.
+func (b *LokiBackend) fetchWindows(ctx context.Context, queryExpr string, windows []timeWindow, perCallLimit int) []windowResult {
+	results := make([]windowResult, len(windows))
+	var wg sync.WaitGroup
+	for i, w := range windows {
+		wg.Add(1)
+		go func(i int, w timeWindow) {
+			defer wg.Done()
+			results[i] = b.fetchWindow(ctx, queryExpr, w, perCallLimit)
+		}(i, w)
+	}
+	wg.Wait()
+	return results
+}
+
+// fetchWindow queries one window, halving the line limit when Loki rejects
+// the response as too large.
+func (b *LokiBackend) fetchWindow(ctx context.Context, queryExpr string, w timeWindow, perCallLimit int) windowResult {
+	ctx, span := tracer.Start(ctx, "audit.fetch_window", trace.WithAttributes(
+		attribute.String("audit.window.start", w.Start.Format(time.RFC3339Nano)),
+		attribute.String("audit.window.end", w.End.Format(time.RFC3339Nano)),
+		attribute.Int("audit.limit", perCallLimit),
+	))
+	defer span.End()
+
+	var resp *loki.QueryRangeResponse
+	for {
+		var err error
+		resp, err = b.client.QueryRange(ctx, queryExpr, w.Start, w.End, perCallLimit)
+		if err == nil {
+			break
+		}
+		if isResponseTooLargeErr(err) && perCallLimit > 1 {
+			metrics.LokiResponseTooLarge.Inc()
+			span.AddEvent("response too large; halving limit")
+			perCallLimit = perCallLimit / 2
+			if perCallLimit < 1 {
+				perCallLimit = 1
+			}
+			continue
+		}
+		endSpanErr(span, err)
+		return windowResult{err: err}
+	}
+
+	var lines []scanLine
+	for _, r := range resp.Data.Result {
+		streamID := loki.Selector{Labels: r.Stream}.String()
+		for _, v := range r.Values {
+			if len(v) != 2 {
+				continue
+			}
+
+			tsStr, ok := v[0].(string)
+			if !ok {
+				log.Printf("failed to assert timestamp as string")
+				continue
+			}
+			t, terr := parseUnixNanoString(tsStr)
+			if terr != nil {
+				log.Printf("failed to parse timestamp: %v", terr)
+				continue
+			}
+
+			logStr, ok := v[1].(string)
+			if !ok {
+				log.Printf("failed to assert log as string")
+				continue
+			}
+			lines = append(lines, scanLine{t: t, text: logStr, labels: r.Stream, stream: streamID})
+		}
+	}
+	// Loki returns lines newest first per stream; merge streams.
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].t.After(lines[j].t) })
+	span.SetAttributes(attribute.Int("audit.lines", len(lines)))
+	return windowResult{lines: lines, limit: perCallLimit}
 }
 
 // Aggregate returns event counts grouped by the specified dimension.
 func (b *LokiBackend) Aggregate(ctx context.Context, filter *AggregateFilter, by string) ([]Bucket, error) {
 	// Validate resource limits
-	duration := filter.End.Sub(filter.Start)
-	if duration > time.Duration(MaxQueryDays)*24*time.Hour {
-		return nil, fmt.Errorf("query time range exceeds maximum of %d days", MaxQueryDays)
+	if err := b.queryOpts.Limits.checkRange(filter.Start, filter.End); err != nil {
+		return nil, err
 	}
 
 	// Normalize namespace to ensure trailing slash for consistency with Vault's format
 	filter.Namespace = normalizeNamespace(filter.Namespace)
 
 	// Validate 'by' parameter
-	validDimensions := map[string]bool{
-		LabelNamespace: true,
-		LabelOperation: true,
-		LabelMountType: true,
-		LabelStatus:    true,
-	}
-	if !validDimensions[by] {
+	if !IsAggregateDimension(by) {
 		return nil, fmt.Errorf("invalid aggregation dimension: %q", by)
 	}
 
-	if by == LabelMountClass {
-		events, err := b.Search(ctx, &SearchFilter{
-			Start:      filter.Start,
-			End:        filter.End,
-			Limit:      MaxQueryLimit,
-			Namespace:  filter.Namespace,
-			Operation:  filter.Operation,
-			MountType:  filter.MountType,
-			MountClass: filter.MountClass,
-			Status:     filter.Status,
-		})
-		if err != nil {
-			return nil, err
-		}
-
-		counts := make(map[string]int)
-		for _, ev := range events {
-			key := ev.MountClass
-			if key == "" {
-				key = "(none)"
-			}
-			counts[key]++
-		}
-
-		buckets := make([]Bucket, 0, len(counts))
-		for k, v := range counts {
-			buckets = append(buckets, Bucket{Key: k, Value: float64(v)})
-		}
-		return buckets, nil
-	}
-
 	// When Vault-specific labels aren't available, fall back to search-based aggregation
 	// (we can't do `sum by (vault_operation)` when those labels don't exist).
-	if !b.labelsCfg.UseVaultLabels {
+	// Dimensions parsed from the audit entry are always counted this way.
+	if !b.labelsCfg.UseVaultLabels || !labelDimensions[by] {
 		events, err := b.Search(ctx, &SearchFilter{
 			Start:      filter.Start,
 			End:        filter.End,
-			Limit:      MaxQueryLimit,
+			Limit:      b.queryOpts.Limits.MaxLimit,
 			Namespace:  filter.Namespace,
 			Operation:  filter.Operation,
 			MountType:  filter.MountType,
 			MountClass: filter.MountClass,
 			Status:     filter.Status,
+
+			NamespacePrefix: filter.NamespacePrefix,
 		})
-		if err != nil {
+		if err != nil && !errors.Is(err, ErrIncomplete) {
 			return nil, err
 		}
 
 		counts := make(map[string]int)
-		for _, ev := range events {
-			var key string
-			switch by {
-			case LabelNamespace:
-				key = ev.Namespace
-			case LabelOperation:
-				key = ev.Operation
-			case LabelMountType:
-				key = ev.MountType
-			case LabelMountClass:
-				key = ev.MountClass
-			case LabelStatus:
-				key = ev.Status
-			}
+		for i := range events {
+			key := eventDimension(&events[i], by)
 			if key == "" {
 				key = "(none)"
 			}
@@ -355,7 +672,8 @@ func (b *LokiBackend) Aggregate(ctx context.Context, filter *AggregateFilter, by
 		for k, v := range counts {
 			buckets = append(buckets, Bucket{Key: k, Value: float64(v)})
 		}
-		return buckets, nil
+		// err is nil or ErrIncomplete for partial counts.
+		return buckets, err
 	}
 
 	// Build label selector - use labels for exact filtering (much faster than content search)
@@ -363,6 +681,9 @@ func (b *LokiBackend) Aggregate(ctx context.Context, filter *AggregateFilter, by
 	if filter.Namespace != "" {
 		sel.Labels[LabelNamespace] = normalizeNamespace(filter.Namespace)
 	}
+	if p := namespacePrefixes([]string{filter.NamespacePrefix}); len(p) > 0 {
+		sel.Matchers = append(sel.Matchers, loki.Matcher{Label: LabelNamespace, Op: "=~", Value: namespacePrefixRegexp(p)})
+	}
 	if filter.Status != "" {
 		sel.Labels[LabelStatus] = filter.Status
 	}
@@ -378,7 +699,7 @@ func (b *LokiBackend) Aggregate(ctx context.Context, filter *AggregateFilter, by
 	}
 
 	// Calculate aggregation window based on query duration (e.g., 1% of total duration, min 1m, max 1h)
-	window := duration / 100
+	window := filter.End.Sub(filter.Start) / 100
 	if window < time.Minute {
 		window = time.Minute
 	}
@@ -389,15 +710,20 @@ func (b *LokiBackend) Aggregate(ctx context.Context, filter *AggregateFilter, by
 	// Metric query: count_over_time by label over the calculated window
 	queryExpr := buildLogQLExpression(sel.String(), filter.Operation, "", "", "")
 	query := fmt.Sprintf(`sum by (%s) (count_over_time((%s)[%dm]))`, by, queryExpr, int(window.Minutes()))
-	if strings.EqualFold(os.Getenv("AUDIT_DEBUG_LOG"), "1") ||
-		strings.EqualFold(os.Getenv("AUDIT_DEBUG_LOG"), "true") {
+	if b.debug {
 		log.Printf("[audit-debug] aggregate query=%s start=%s end=%s", query, filter.Start.Format(time.RFC3339Nano), filter.End.Format(time.RFC3339Nano))
 	}
 
+	reportProgress(ctx, Progress{WindowsRemaining: 1})
 	resp, err := b.client.QueryRange(ctx, query, filter.Start, filter.End, 0)
 	if err != nil {
+		// The metric query is a single request, so nothing was counted.
+		if err = deadlineErr(ctx, err); errors.Is(err, ErrIncomplete) {
+			return []Bucket{}, err
+		}
 		return nil, fmt.Errorf("loki aggregate query failed: %w", err)
 	}
+	reportProgress(ctx, Progress{WindowsScanned: 1})
 
 	buckets := []Bucket{}
 	for _, r := range resp.Data.Result {
@@ -412,6 +738,60 @@ func (b *LokiBackend) Aggregate(ctx context.Context, filter *AggregateFilter, by
 	return buckets, nil
 }
 
+// labelDimensions are the aggregation dimensions backed by stream labels,
+// counted with a metric query in labels mode.
+var labelDimensions = map[string]bool{
+	LabelNamespace: true,
+	LabelOperation: true,
+	LabelMountType: true,
+	LabelStatus:    true,
+}
+
+// AggregateDimensions lists the valid aggregation dimensions.
+var AggregateDimensions = []string{
+	LabelNamespace, LabelOperation, LabelMountType, LabelMountClass, LabelStatus,
+	DimensionMountPoint, DimensionMountAccessor, DimensionTokenType, DimensionRoleName,
+	DimensionStatusCode, DimensionPolicyAllowed,
+}
+
+// IsAggregateDimension reports whether by is a valid aggregation dimension.
+func IsAggregateDimension(by string) bool {
+	return slices.Contains(AggregateDimensions, by)
+}
+
+// eventDimension returns the value of an aggregation dimension for ev.
+func eventDimension(ev *Event, by string) string {
+	switch by {
+	case LabelNamespace:
+		return ev.Namespace
+	case LabelOperation:
+		return ev.Operation
+	case LabelMountType:
+		return ev.MountType
+	case LabelMountClass:
+		return ev.MountClass
+	case LabelStatus:
+		return ev.Status
+	case DimensionMountPoint:
+		return ev.MountPoint
+	case DimensionMountAccessor:
+		return ev.MountAccessor
+	case DimensionTokenType:
+		return ev.TokenType
+	case DimensionRoleName:
+		return ev.RoleName
+	case DimensionStatusCode:
+		if ev.StatusCode != 0 {
+			return strconv.Itoa(ev.StatusCode)
+		}
+	case DimensionPolicyAllowed:
+		if ev.PolicyResults != nil {
+			return strconv.FormatBool(ev.PolicyResults.Allowed)
+		}
+	}
+	return ""
+}
+
 func buildLogQLExpression(base, operation, mountType, mountClass, policy string) string {
 	// Most filtering now done via labels for performance.
 	// This function only handles special cases that can't be expressed as simple label filters:
@@ -471,12 +851,12 @@ func applySearchFilters(events []Event, filter *SearchFilter) []Event {
 	}
 
 	limit := filter.Limit
-	if limit <= 0 || limit > MaxQueryLimit {
+	if limit <= 0 || limit > DefaultMaxQueryLimit {
 		limit = DefaultLimit
 	}
 
-	matcher := newSearchFilterMatcher(filter, limit)
-	if matcher.isNoop() {
+	matcher, err := newSearchFilterMatcher(filter)
+	if err != nil || matcher.isNoop() {
 		return events
 	}
 
@@ -493,93 +873,6 @@ func applySearchFilters(events []Event, filter *SearchFilter) []Event {
 	return filtered
 }
 
-type searchFilterMatcher struct {
-	namespace  string
-	operation  string
-	mountType  string
-	mountClass string
-	status     string
-	policy     string
-	entityID   string
-	loginQuery bool
-}
-
-func newSearchFilterMatcher(filter *SearchFilter, _ int) searchFilterMatcher {
-	if filter == nil {
-		return searchFilterMatcher{}
-	}
-	operation := strings.TrimSpace(filter.Operation)
-	return searchFilterMatcher{
-		namespace:  normalizeNamespace(filter.Namespace),
-		operation:  operation,
-		mountType:  strings.TrimSpace(filter.MountType),
-		mountClass: strings.TrimSpace(filter.MountClass),
-		status:     strings.TrimSpace(filter.Status),
-		policy:     strings.TrimSpace(filter.Policy),
-		entityID:   strings.TrimSpace(filter.EntityID),
-		loginQuery: strings.EqualFold(operation, "login"),
-	}
-}
-
-func (m searchFilterMatcher) isNoop() bool {
-	return m.namespace == "" && m.operation == "" && m.mountType == "" && m.mountClass == "" && m.status == "" && m.policy == "" && m.entityID == ""
-}
-
-func (m searchFilterMatcher) matches(ev Event) bool {
-	if m.namespace != "" && !strings.EqualFold(ev.Namespace, m.namespace) {
-		return false
-	}
-	if m.loginQuery {
-		if !strings.Contains(strings.ToLower(ev.Path), "/login") {
-			return false
-		}
-	} else if m.operation != "" && !operationMatches(ev.Operation, m.operation) {
-		return false
-	}
-	if m.mountType != "" && !strings.EqualFold(ev.MountType, m.mountType) {
-		return false
-	}
-	if m.mountClass != "" && !strings.EqualFold(ev.MountClass, m.mountClass) {
-		return false
-	}
-	if m.status != "" && !strings.EqualFold(ev.Status, m.status) {
-		return false
-	}
-	if m.policy != "" && !containsPolicy(ev.Policies, m.policy) && !containsPolicy(ev.TokenPolicies, m.policy) {
-		return false
-	}
-	if m.entityID != "" && !strings.EqualFold(ev.EntityID, m.entityID) {
-		return false
-	}
-	return true
-}
-
-// containsPolicy checks if a policy name exists in a slice of policies (case-insensitive)
-func containsPolicy(policies []string, policy string) bool {
-	policyLower := strings.ToLower(policy)
-	for _, p := range policies {
-		if strings.ToLower(p) == policyLower {
-			return true
-		}
-	}
-	return false
-}
-
-func operationMatches(eventOp, filterOp string) bool {
-	if strings.EqualFold(eventOp, filterOp) {
-		return true
-	}
-	filterLower := strings.ToLower(strings.TrimSpace(filterOp))
-	eventLower := strings.ToLower(strings.TrimSpace(eventOp))
-	if filterLower == "write" && eventLower == "update" {
-		return true
-	}
-	if filterLower == "update" && eventLower == "write" {
-		return true
-	}
-	return false
-}
-
 func truncateDebugLine(line string) string {
 	const maxLen = 500
 	if len(line) > maxLen {
@@ -591,15 +884,12 @@ func truncateDebugLine(line string) string {
 // Trace returns events for a specific request ID.
 func (b *LokiBackend) Trace(ctx context.Context, filter *TraceFilter) ([]Event, error) {
 	// Validate resource limits
-	duration := filter.End.Sub(filter.Start)
-	if duration > time.Duration(MaxQueryDays)*24*time.Hour {
-		return nil, fmt.Errorf("query time range exceeds maximum of %d days", MaxQueryDays)
+	if err := b.queryOpts.Limits.checkRange(filter.Start, filter.End); err != nil {
+		return nil, err
 	}
 
 	// Normalize limit
-	if filter.Limit <= 0 || filter.Limit > MaxQueryLimit {
-		filter.Limit = DefaultLimit
-	}
+	filter.Limit = b.queryOpts.Limits.eventLimit(filter.Limit)
 
 	if filter.RequestID == "" {
 		return nil, fmt.Errorf("request_id is required")
@@ -612,81 +902,15 @@ func (b *LokiBackend) Trace(ctx context.Context, filter *TraceFilter) ([]Event,
 	query := fmt.Sprintf(`%s |= %q`, sel.String(), filter.RequestID)
 
 	events := make([]Event, 0, filter.Limit)
-	for _, w := range splitTimeRangeReverse(filter.Start, filter.End, queryChunkDuration) {
-		remaining := filter.Limit - len(events)
-		if remaining <= 0 {
-			break
-		}
-
-		perCallLimit := remaining
-		if perCallLimit > maxPerQueryRangeLimit {
-			perCallLimit = maxPerQueryRangeLimit
-		}
-
-		var resp *loki.QueryRangeResponse
-		for {
-			var err error
-			resp, err = b.client.QueryRange(ctx, query, w.Start, w.End, perCallLimit)
-			if err == nil {
-				break
-			}
-			if isResponseTooLargeErr(err) && perCallLimit > 1 {
-				perCallLimit = perCallLimit / 2
-				if perCallLimit < 1 {
-					perCallLimit = 1
-				}
-				continue
-			}
-			return nil, fmt.Errorf("loki trace query failed: %w", err)
-		}
-
-		for _, r := range resp.Data.Result {
-			for _, v := range r.Values {
-				if len(v) != 2 {
-					continue
-				}
-
-				tsStr, ok := v[0].(string)
-				if !ok {
-					log.Printf("failed to assert timestamp as string")
-					continue
-				}
-				t, terr := parseUnixNanoString(tsStr)
-				if terr != nil {
-					log.Printf("failed to parse timestamp: %v", terr)
-					continue
-				}
-
-				logStr, ok := v[1].(string)
-				if !ok {
-					log.Printf("failed to assert log as string")
-					continue
-				}
-				parsed := map[string]any{}
-				if err := json.Unmarshal([]byte(logStr), &parsed); err != nil {
-					log.Printf("failed to unmarshal audit log: %v", err)
-					continue
-				}
-
-				auditData, ok := extractAuditData(parsed)
-				if !ok {
-					continue
-				}
-
-				Redact(auditData)
-
-				ev := Event{
-					Time:   t,
-					Raw:    auditData,
-					Stream: r.Stream,
-				}
-				populateFromAudit(&ev, auditData)
-				events = append(events, ev)
-				if len(events) >= filter.Limit {
-					return events, nil
-				}
-			}
-		}
+	err := b.scan(ctx, query, filter.Start, filter.End, filter.Limit, false, func(t time.Time, stream map[string]string, auditData map[string]any) bool {
+		events = append(events, b.newEvent(t, stream, auditData))
+		return true
+	})
+	if errors.Is(err, ErrIncomplete) {
+		return events, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loki trace query failed: %w", err)
 	}
 
 	return events, nil
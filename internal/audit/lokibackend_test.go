@@ -0,0 +1,461 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vault-audit-mcp/internal/loki"
+)
+
+// fakeLokiLine is one log line served by newFakeLoki.
+type fakeLokiLine struct {
+	Time  time.Time
+	Entry map[string]any
+}
+
+// newFakeLoki starts a Loki stand-in that answers every query_range call
+// with up to limit lines in the requested window [start, end), newest first,
+// matching Loki's exclusive end bound.
+func newFakeLoki(t *testing.T, lines []fakeLokiLine) *LokiBackend {
+	t.Helper()
+	return newCountingFakeLoki(t, lines, new(atomic.Int64))
+}
+
+// newCountingFakeLoki is newFakeLoki that also counts query_range requests.
+func newCountingFakeLoki(t *testing.T, lines []fakeLokiLine, requests *atomic.Int64) *LokiBackend {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, _ := time.Parse(time.RFC3339Nano, r.URL.Query().Get("start"))
+		end, _ := time.Parse(time.RFC3339Nano, r.URL.Query().Get("end"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		requests.Add(1)
+
+		values := [][]any{}
+		for i := len(lines) - 1; i >= 0; i-- {
+			l := lines[i]
+			if l.Time.Before(start) || !l.Time.Before(end) {
+				continue
+			}
+			if limit > 0 && len(values) >= limit {
+				break
+			}
+			raw, _ := json.Marshal(l.Entry)
+			values = append(values, []any{fmt.Sprintf("%d", l.Time.UnixNano()), string(raw)})
+		}
+
+		resp := map[string]any{
+			"status": "success",
+			"data": map[string]any{
+				"resultType": "streams",
+				"result": []any{map[string]any{
+					"stream": map[string]string{"service": "vault"},
+					"values": values,
+				}},
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := loki.NewClient(srv.URL, nil)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	return NewLokiBackend(client, nil)
+}
+
+func TestFindByHMACReportsFieldsBeforeRedaction(t *testing.T) {
+	key := []byte("audit-salt")
+	tokenHMAC := AuditHMAC(key, "hvs.plaintext-token")
+	now := time.Now().UTC()
+
+	backend := newFakeLoki(t, []fakeLokiLine{
+		{Time: now.Add(-2 * time.Minute), Entry: map[string]any{
+			"type": "request",
+			"auth": map[string]any{"client_token": tokenHMAC, "display_name": "alice"},
+			"request": map[string]any{
+				"id":           "req-1",
+				"path":         "secret/data/app",
+				"client_token": tokenHMAC,
+			},
+		}},
+		{Time: now.Add(-time.Minute), Entry: map[string]any{
+			"type":    "request",
+			"request": map[string]any{"id": "req-2", "path": "secret/data/other"},
+		}},
+	})
+
+	matches, err := backend.FindByHMAC(t.Context(), &HMACFilter{
+		Start: now.Add(-5 * time.Minute),
+		End:   now,
+		HMACs: []string{tokenHMAC},
+	})
+	if err != nil {
+		t.Fatalf("FindByHMAC failed: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("got %d matches, want 1", len(matches))
+	}
+
+	fields := matches[0].Fields[tokenHMAC]
+	if len(fields) != 2 || fields[0] != "auth.client_token" || fields[1] != "request.client_token" {
+		t.Errorf("fields = %v, want [auth.client_token request.client_token]", fields)
+	}
+	if matches[0].Event.RequestID != "req-1" {
+		t.Errorf("request id = %q, want req-1", matches[0].Event.RequestID)
+	}
+	auth := matches[0].Event.Raw["auth"].(map[string]any)
+	if auth["client_token"] != redactedValue {
+		t.Error("returned event should still be redacted")
+	}
+
+	summary := SummarizeHMACMatches(matches, []string{tokenHMAC}, "", "")
+	if summary.Values[0].MatchCount != 1 || summary.Matches[0].Event.Raw != nil {
+		t.Error("summary should count the match and strip raw data")
+	}
+}
+
+func TestFindByHMACRejectsMalformedValues(t *testing.T) {
+	backend := newFakeLoki(t, nil)
+	_, err := backend.FindByHMAC(t.Context(), &HMACFilter{
+		Start: time.Now().Add(-time.Minute),
+		End:   time.Now(),
+		HMACs: []string{"hmac-sha256:not-hex)|.*"},
+	})
+	if err == nil {
+		t.Fatal("FindByHMAC should reject non-hex HMAC values")
+	}
+}
+
+func TestFindByHMACReturnsPartialMatchesAtDeadline(t *testing.T) {
+	tokenHMAC := AuditHMAC([]byte("audit-salt"), "hvs.plaintext-token")
+	end := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var lines []fakeLokiLine
+	for i := 0; i < 10; i++ {
+		lines = append(lines, fakeLokiLine{Time: end.Add(-time.Duration(10-i) * time.Hour), Entry: map[string]any{
+			"type":    "request",
+			"request": map[string]any{"id": fmt.Sprintf("req-%d", i), "client_token": tokenHMAC},
+		}})
+	}
+	backend := newFakeLoki(t, lines)
+	backend.SetQueryOptions(QueryOptions{Parallelism: 1, InitialChunk: time.Hour, MaxChunk: time.Hour})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	updates := 0
+	ctx = WithProgress(ctx, func(p Progress) {
+		if updates++; updates == 2 {
+			<-ctx.Done()
+		}
+	})
+
+	matches, err := backend.FindByHMAC(ctx, &HMACFilter{Start: end.Add(-24 * time.Hour), End: end, HMACs: []string{tokenHMAC}})
+	if !errors.Is(err, ErrIncomplete) {
+		t.Fatalf("err = %v, want ErrIncomplete", err)
+	}
+	if len(matches) != 2 || matches[0].Event.RequestID != "req-9" {
+		t.Fatalf("partial matches = %v, want the two newest", matches)
+	}
+}
+
+func TestSearchPaginatesDenseWindowsInOrder(t *testing.T) {
+	end := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var lines []fakeLokiLine
+	// A dense burst of 60 events in pairs sharing a timestamp, so pages
+	// end mid-pair, followed by older sparse events.
+	for i := 0; i < 60; i++ {
+		lines = append(lines, fakeLokiLine{Time: end.Add(-30*time.Minute + time.Duration(i/2)*time.Second), Entry: map[string]any{
+			"type":    "response",
+			"request": map[string]any{"id": fmt.Sprintf("burst-%02d", i), "operation": "read", "path": "secret/data/app"},
+		}})
+	}
+	for i := 0; i < 5; i++ {
+		lines = append(lines, fakeLokiLine{Time: end.Add(-time.Duration(90+i*60) * time.Minute), Entry: map[string]any{
+			"type":    "response",
+			"request": map[string]any{"id": fmt.Sprintf("sparse-%d", i), "operation": "read", "path": "secret/data/app"},
+		}})
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].Time.Before(lines[j].Time) })
+
+	var requests atomic.Int64
+	backend := newCountingFakeLoki(t, lines, &requests)
+	backend.SetQueryOptions(QueryOptions{Parallelism: 3})
+
+	events, err := backend.Search(t.Context(), &SearchFilter{Start: end.Add(-24 * time.Hour), End: end, Limit: 100})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(events) != 65 {
+		t.Fatalf("got %d events, want 65", len(events))
+	}
+	seen := make(map[string]bool)
+	for i, ev := range events {
+		if seen[ev.RequestID] {
+			t.Errorf("duplicate event %s", ev.RequestID)
+		}
+		seen[ev.RequestID] = true
+		if i > 0 && ev.Time.After(events[i-1].Time) {
+			t.Fatalf("events not newest first at %d", i)
+		}
+	}
+
+	requests.Store(0)
+	events, err = backend.Search(t.Context(), &SearchFilter{Start: end.Add(-24 * time.Hour), End: end, Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(events) != 10 || events[0].Time != end.Add(-30*time.Minute+29*time.Second) {
+		t.Fatalf("limited search returned %d events starting %v", len(events), events)
+	}
+	if n := requests.Load(); n > 6 {
+		t.Errorf("limited search made %d requests, want early termination", n)
+	}
+}
+
+func TestSearchPaginatesManyLinesAtOneTimestamp(t *testing.T) {
+	end := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var lines []fakeLokiLine
+	lines = append(lines, fakeLokiLine{Time: end.Add(-2 * time.Hour), Entry: map[string]any{
+		"type":    "response",
+		"request": map[string]any{"id": "older", "operation": "read"},
+	}})
+	// More lines than one request returns, all at the same instant.
+	for i := 0; i < 30; i++ {
+		lines = append(lines, fakeLokiLine{Time: end.Add(-time.Hour), Entry: map[string]any{
+			"type":    "response",
+			"request": map[string]any{"id": fmt.Sprintf("same-%02d", i), "operation": "read"},
+		}})
+	}
+	backend := newFakeLoki(t, lines)
+
+	// Without a deadline a livelock would hang the test.
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	events, err := backend.Search(ctx, &SearchFilter{Start: end.Add(-24 * time.Hour), End: end, Limit: 100})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(events) != 31 || events[30].RequestID != "older" {
+		t.Fatalf("got %d events, want the 30 at one timestamp and the older one", len(events))
+	}
+	seen := make(map[string]bool)
+	for _, ev := range events {
+		if seen[ev.RequestID] {
+			t.Errorf("duplicate event %s", ev.RequestID)
+		}
+		seen[ev.RequestID] = true
+	}
+
+	events, err = backend.Search(ctx, &SearchFilter{Start: end.Add(-24 * time.Hour), End: end, Limit: 27})
+	if err != nil || len(events) != 27 {
+		t.Fatalf("limited search returned %d events, %v", len(events), err)
+	}
+}
+
+func TestNextChunkAdaptsToDensity(t *testing.T) {
+	opts := QueryOptions{}.withDefaults()
+	if got := opts.nextChunk(10*time.Minute, 0, 40*time.Minute, 25, false); got != 40*time.Minute {
+		t.Errorf("empty windows should grow 4x, got %v", got)
+	}
+	if got := opts.nextChunk(10*time.Minute, 25, 10*time.Minute, 25, true); got >= 10*time.Minute {
+		t.Errorf("saturated window should shrink, got %v", got)
+	}
+	if got := opts.nextChunk(time.Minute, 0, time.Hour*100, 25, false); got > opts.MaxChunk {
+		t.Errorf("chunk %v exceeds maximum", got)
+	}
+}
+
+func TestSearchReturnsPartialResultsAtDeadline(t *testing.T) {
+	end := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var lines []fakeLokiLine
+	for i := 0; i < 10; i++ {
+		lines = append(lines, fakeLokiLine{Time: end.Add(-time.Duration(10-i) * time.Hour), Entry: map[string]any{
+			"type":    "response",
+			"request": map[string]any{"id": fmt.Sprintf("req-%d", i), "operation": "read"},
+		}})
+	}
+	backend := newFakeLoki(t, lines)
+	backend.SetQueryOptions(QueryOptions{Parallelism: 1, InitialChunk: time.Hour, MaxChunk: time.Hour})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	var updates []Progress
+	ctx = WithProgress(ctx, func(p Progress) {
+		updates = append(updates, p)
+		if len(updates) == 2 {
+			// Simulate a slow backend: the deadline passes mid-scan.
+			<-ctx.Done()
+		}
+	})
+
+	events, err := backend.Search(ctx, &SearchFilter{Start: end.Add(-24 * time.Hour), End: end, Limit: 100})
+	if !errors.Is(err, ErrIncomplete) {
+		t.Fatalf("err = %v, want ErrIncomplete", err)
+	}
+	if len(events) != 2 || events[0].RequestID != "req-9" {
+		t.Fatalf("partial events = %v, want the two newest events", events)
+	}
+	if len(updates) != 2 || updates[0].WindowsScanned != 1 || updates[0].WindowsRemaining != 23 {
+		t.Errorf("unexpected progress updates %+v", updates)
+	}
+}
+
+func TestAggregateByLabelReturnsIncompleteAtDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A slow metric query: answer only once the client gives up.
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+	client, err := loki.NewClient(srv.URL, nil)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	backend := NewLokiBackend(client, nil)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	end := time.Now().UTC()
+	buckets, err := backend.Aggregate(ctx, &AggregateFilter{Start: end.Add(-time.Hour), End: end}, LabelOperation)
+	if !errors.Is(err, ErrIncomplete) {
+		t.Fatalf("err = %v, want ErrIncomplete", err)
+	}
+	if buckets == nil || len(buckets) != 0 {
+		t.Errorf("buckets = %v, want an empty partial result", buckets)
+	}
+}
+
+func TestSearchPopulatesEnrichedFields(t *testing.T) {
+	now := time.Now().UTC()
+	backend := newFakeLoki(t, []fakeLokiLine{
+		{Time: now.Add(-time.Minute), Entry: map[string]any{
+			"type":           "response",
+			"forwarded_from": "vault-1.internal:8201",
+			"auth": map[string]any{
+				"accessor":          "hmac-sha256:abc",
+				"display_name":      "kubernetes-payments-api",
+				"token_type":        "service",
+				"token_ttl":         float64(3600),
+				"token_issue_time":  "2026-10-18T10:00:00Z",
+				"identity_policies": []any{"payments-read"},
+				"metadata": map[string]any{
+					"role":                 "payments",
+					"service_account_name": "api",
+					"service_account_uid":  "6f1c",
+				},
+				"policy_results": map[string]any{
+					"allowed": true,
+					"granting_policies": []any{
+						map[string]any{"name": "payments-read", "namespace_id": "root", "type": "acl"},
+					},
+				},
+			},
+			"request": map[string]any{
+				"id":                    "req-1",
+				"path":                  "secret/data/payments/db",
+				"mount_point":           "secret/",
+				"mount_accessor":        "kv_1a2b",
+				"client_token_accessor": "hmac-sha256:abc",
+				"headers":               map[string]any{"user-agent": []any{"vault-agent/1.17"}},
+			},
+			"response": map[string]any{
+				"data": map[string]any{"http_status_code": float64(204), "password": "hunter2"},
+			},
+		}},
+	})
+
+	events, err := backend.Search(t.Context(), &SearchFilter{Start: now.Add(-5 * time.Minute), End: now})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	ev := events[0]
+
+	if ev.MountPoint != "secret/" || ev.MountAccessor != "kv_1a2b" {
+		t.Errorf("mount = %q %q, want secret/ kv_1a2b", ev.MountPoint, ev.MountAccessor)
+	}
+	if ev.TokenAccessor != "" {
+		t.Errorf("masked accessor should not be reported, got %q", ev.TokenAccessor)
+	}
+	if ev.TokenType != "service" || ev.TokenTTL != 3600 || ev.TokenIssueTime != "2026-10-18T10:00:00Z" {
+		t.Errorf("token = %q %d %q", ev.TokenType, ev.TokenTTL, ev.TokenIssueTime)
+	}
+	if ev.RoleName != "payments" {
+		t.Errorf("role = %q, want payments", ev.RoleName)
+	}
+	if len(ev.AuthMetadata) != 2 || ev.AuthMetadata["service_account_name"] != "api" {
+		t.Errorf("auth metadata = %v, want only the allowed keys", ev.AuthMetadata)
+	}
+	if ev.ForwardedFrom != "vault-1.internal:8201" {
+		t.Errorf("forwarded_from = %q", ev.ForwardedFrom)
+	}
+	if ev.Headers["user-agent"] != "vault-agent/1.17" {
+		t.Errorf("headers = %v", ev.Headers)
+	}
+	if ev.StatusCode != 204 {
+		t.Errorf("status code = %d, want 204", ev.StatusCode)
+	}
+	if len(ev.IdentityPolicies) != 1 || ev.IdentityPolicies[0] != "payments-read" {
+		t.Errorf("identity policies = %v", ev.IdentityPolicies)
+	}
+	if ev.PolicyResults == nil || !ev.PolicyResults.Allowed || ev.PolicyResults.GrantingPolicies[0].Name != "payments-read" {
+		t.Errorf("policy results = %+v", ev.PolicyResults)
+	}
+	if ev.Raw["response"].(map[string]any)["data"] != redactedValue {
+		t.Error("response data should still be redacted")
+	}
+}
+
+func TestAggregateByEventDimension(t *testing.T) {
+	now := time.Now().UTC()
+	entry := func(tokenType string, allowed bool) map[string]any {
+		return map[string]any{
+			"type": "request",
+			"auth": map[string]any{
+				"token_type":     tokenType,
+				"policy_results": map[string]any{"allowed": allowed},
+			},
+			"request": map[string]any{"path": "secret/data/app", "mount_class": "secret"},
+		}
+	}
+	backend := newFakeLoki(t, []fakeLokiLine{
+		{Time: now.Add(-3 * time.Minute), Entry: entry("service", true)},
+		{Time: now.Add(-2 * time.Minute), Entry: entry("batch", false)},
+		{Time: now.Add(-time.Minute), Entry: entry("service", false)},
+	})
+
+	for _, tt := range []struct {
+		by   string
+		want map[string]float64
+	}{
+		{DimensionTokenType, map[string]float64{"service": 2, "batch": 1}},
+		{DimensionPolicyAllowed, map[string]float64{"true": 1, "false": 2}},
+		{LabelMountClass, map[string]float64{"secret": 3}},
+	} {
+		buckets, err := backend.Aggregate(t.Context(), &AggregateFilter{Start: now.Add(-5 * time.Minute), End: now}, tt.by)
+		if err != nil {
+			t.Fatalf("Aggregate(%s) failed: %v", tt.by, err)
+		}
+		got := make(map[string]float64)
+		for _, b := range buckets {
+			got[b.Key] = b.Value
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("Aggregate(%s) = %v, want %v", tt.by, got, tt.want)
+		}
+	}
+
+	if _, err := backend.Aggregate(t.Context(), &AggregateFilter{Start: now.Add(-time.Minute), End: now}, "vault_path"); err == nil {
+		t.Error("Aggregate should reject unknown dimensions")
+	}
+}
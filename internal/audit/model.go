@@ -2,6 +2,7 @@ package audit
 
 import (
 	"context"
+	"fmt"
 	"time"
 )
 
@@ -18,18 +19,85 @@ const (
 	LabelTokenPolicies = "vault_token_policies"
 	LabelEntityID      = "vault_entity_id"
 	LabelDisplayName   = "vault_display_name"
+	LabelAuditType     = "vault_audit_type"
+
+	// Aggregation dimensions counted from parsed events rather than stream
+	// labels, so aggregating by them searches up to QueryLimits.MaxLimit events.
+	DimensionMountPoint    = "vault_mount_point"
+	DimensionMountAccessor = "vault_mount_accessor"
+	DimensionTokenType     = "vault_token_type"
+	DimensionRoleName      = "vault_role_name"
+	DimensionStatusCode    = "vault_status_code"
+	DimensionPolicyAllowed = "vault_policy_allowed"
 
 	// Default Vault audit stream names
 	ValueServiceVault = "vault"
 	ValueKindAudit    = "audit"
 
-// Resource limits
-	MaxQueryLimit   = 500
+	// Resource limits
 	DefaultLimit    = 100
-	MaxQueryDays    = 90
 	DefaultQueryAge = 15 * time.Minute
+
+	// Default query limits; see QueryLimits.
+	DefaultMaxQueryLimit = 500
+	DefaultMaxQueryDays  = 90
 )
 
+// QueryLimits bounds the events and time range of a single query.
+// Zero values use the defaults.
+type QueryLimits struct {
+	// MaxLimit is the most events one query may return.
+	MaxLimit int
+	// MaxDays is the longest query range in days.
+	MaxDays int
+}
+
+func (l QueryLimits) withDefaults() QueryLimits {
+	if l.MaxLimit <= 0 {
+		l.MaxLimit = DefaultMaxQueryLimit
+	}
+	if l.MaxDays <= 0 {
+		l.MaxDays = DefaultMaxQueryDays
+	}
+	return l
+}
+
+// MaxRange returns the longest query range.
+func (l QueryLimits) MaxRange() time.Duration {
+	return time.Duration(l.MaxDays) * 24 * time.Hour
+}
+
+// checkRange rejects a query range longer than MaxDays.
+func (l QueryLimits) checkRange(start, end time.Time) error {
+	if end.Sub(start) > l.MaxRange() {
+		return fmt.Errorf("query time range exceeds maximum of %d days", l.MaxDays)
+	}
+	return nil
+}
+
+// eventLimit returns limit, or DefaultLimit when it is unset or above
+// MaxLimit.
+func (l QueryLimits) eventLimit(limit int) int {
+	if limit <= 0 || limit > l.MaxLimit {
+		return DefaultLimit
+	}
+	return limit
+}
+
+// queryLimiter is implemented by backends with configured query limits.
+type queryLimiter interface {
+	QueryLimits() QueryLimits
+}
+
+// limitsOf returns the query limits of backend, or the defaults when it has
+// none.
+func limitsOf(backend Backend) QueryLimits {
+	if l, ok := backend.(queryLimiter); ok {
+		return l.QueryLimits().withDefaults()
+	}
+	return QueryLimits{}.withDefaults()
+}
+
 // LabelConfig controls how the Loki backend builds stream selectors.
 // When BaseLabels is nil the default {service="vault",log_kind="audit"}
 // selector is used and all Vault-specific label filters are applied.
@@ -66,6 +134,33 @@ type SearchFilter struct {
 	Status     string
 	Policy     string
 	EntityID   string
+	// NamespacePrefix matches a namespace and its descendants, e.g. org/
+	// matches org/ and org/team-a/.
+	NamespacePrefix string
+
+	// DisplayName matches the token display name and AuditType request or
+	// response entries.
+	DisplayName string
+	AuditType   string
+	// PathPrefix, PathGlob and PathRegex match the request path, ignoring a
+	// leading slash. Globs use * within a segment and ** across segments;
+	// regexes are RE2 and unanchored.
+	PathPrefix string
+	PathGlob   string
+	PathRegex  string
+	// RemoteCIDRs matches remote addresses inside any of the ranges.
+	RemoteCIDRs []string
+	// MountPoint ignores a trailing slash. These fields are parsed from the
+	// audit entry and always post-filtered.
+	MountPoint    string
+	MountAccessor string
+	TokenType     string
+	RoleName      string
+
+	// AnyOf adds alternative values per field (IN); Exclude drops events
+	// matching any of its values (NOT IN).
+	AnyOf   *FieldSet
+	Exclude *FieldSet
 }
 
 type AggregateFilter struct {
@@ -76,6 +171,8 @@ type AggregateFilter struct {
 	MountType  string
 	MountClass string
 	Status     string
+	// NamespacePrefix matches a namespace and its descendants.
+	NamespacePrefix string
 }
 
 type TraceFilter struct {
@@ -85,6 +182,20 @@ type TraceFilter struct {
 	RequestID string
 }
 
+// PolicyResults is the policy evaluation Vault records in auth.policy_results.
+type PolicyResults struct {
+	Allowed          bool             `json:"allowed"`
+	GrantingPolicies []GrantingPolicy `json:"granting_policies,omitempty"`
+}
+
+// GrantingPolicy is a policy that granted (part of) a request.
+type GrantingPolicy struct {
+	Name          string `json:"name"`
+	NamespaceID   string `json:"namespace_id,omitempty"`
+	NamespacePath string `json:"namespace_path,omitempty"`
+	Type          string `json:"type,omitempty"`
+}
+
 type Bucket struct {
 	Key   string  `json:"key"`
 	Value float64 `json:"value"`
@@ -107,6 +218,38 @@ type Event struct {
 	Policies      []string `json:"policies,omitempty"`
 	TokenPolicies []string `json:"token_policies,omitempty"`
 	EntityID      string   `json:"entity_id,omitempty"`
+	// IdentityPolicies are the policies granted through identity groups.
+	IdentityPolicies []string `json:"identity_policies,omitempty"`
+	// PolicyResults is the ACL decision logged by Vault 1.15 and later.
+	PolicyResults *PolicyResults `json:"policy_results,omitempty"`
+	// EntityName, AliasName, AliasMountPath and Groups are resolved from the
+	// identity snapshot, when one is configured.
+	EntityName     string   `json:"entity_name,omitempty"`
+	AliasName      string   `json:"alias_name,omitempty"`
+	AliasMountPath string   `json:"alias_mount_path,omitempty"`
+	Groups         []string `json:"groups,omitempty"`
+
+	// Mount and token details
+	MountPoint    string `json:"mount_point,omitempty"`
+	MountAccessor string `json:"mount_accessor,omitempty"`
+	// TokenAccessor is the client token accessor as left by the redaction
+	// policy: empty when masked or dropped, an HMAC when hashed.
+	TokenAccessor  string `json:"client_token_accessor,omitempty"`
+	TokenType      string `json:"token_type,omitempty"` // service, batch
+	TokenTTL       int64  `json:"token_ttl,omitempty"`  // seconds
+	TokenIssueTime string `json:"token_issue_time,omitempty"`
+	// RoleName is the auth role the token was issued for, from the role_name
+	// or role auth metadata.
+	RoleName string `json:"role_name,omitempty"`
+	// AuthMetadata holds the auth metadata keys allowed by the redaction
+	// policy's AllowedMetadata.
+	AuthMetadata  map[string]string `json:"auth_metadata,omitempty"`
+	ForwardedFrom string            `json:"forwarded_from,omitempty"`
+	// Headers are the request headers configured for auditing, multiple
+	// values joined with ", ".
+	Headers map[string]string `json:"headers,omitempty"`
+	// StatusCode is the HTTP status code of the response, when logged.
+	StatusCode int `json:"status_code,omitempty"`
 
 	// Raw is optional; the redacted JSON object.
 	Raw map[string]any `json:"raw,omitempty"`
@@ -0,0 +1,160 @@
+package audit
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// NamespaceNode is one namespace in a namespace tree. The root namespace has
+// an empty path and depth 0; org/team-a/ has depth 2.
+type NamespaceNode struct {
+	Namespace string `json:"namespace"`
+	Depth     int    `json:"depth"`
+	// Count is the events in this namespace itself, plus those of
+	// descendants below the tree's depth limit. Total adds the children's
+	// totals.
+	Count    float64          `json:"count"`
+	Total    float64          `json:"total"`
+	Children []*NamespaceNode `json:"children,omitempty"`
+}
+
+// NamespaceTreeResult is the audit.aggregate result for namespace_view
+// "tree".
+type NamespaceTreeResult struct {
+	Tree       *NamespaceNode `json:"tree"`
+	Incomplete bool           `json:"incomplete,omitempty"`
+}
+
+// namespaceDepth returns how many levels below the root ns is.
+func namespaceDepth(ns string) int {
+	return strings.Count(normalizeNamespace(ns), "/")
+}
+
+// namespaceAncestor returns the ancestor of ns at depth, or ns itself when
+// it is not deeper.
+func namespaceAncestor(ns string, depth int) string {
+	ns = normalizeNamespace(ns)
+	if depth <= 0 {
+		return ""
+	}
+	parts := strings.SplitAfter(ns, "/")
+	if depth >= len(parts)-1 {
+		return ns
+	}
+	return strings.Join(parts[:depth], "")
+}
+
+// rootNamespaceKey reports whether an aggregation key is the root namespace,
+// which Vault logs with an empty path and some label pipelines as "root" (a
+// reserved namespace name).
+func rootNamespaceKey(key string) bool {
+	switch key {
+	case "", "/", "(none)", "root", "root/":
+		return true
+	}
+	return false
+}
+
+// NamespaceTree builds the namespace hierarchy from counts keyed by
+// namespace path, as returned by aggregating by vault_namespace. Ancestors
+// without events of their own are included with a zero count. With depth > 0,
+// deeper namespaces are counted in their ancestor at that depth. Children
+// are ordered by total, largest first.
+func NamespaceTree(buckets []Bucket, depth int) *NamespaceNode {
+	root := &NamespaceNode{}
+	nodes := map[string]*NamespaceNode{"": root}
+	var node func(ns string) *NamespaceNode
+	node = func(ns string) *NamespaceNode {
+		if n, ok := nodes[ns]; ok {
+			return n
+		}
+		d := namespaceDepth(ns)
+		n := &NamespaceNode{Namespace: ns, Depth: d}
+		parent := node(namespaceAncestor(ns, d-1))
+		parent.Children = append(parent.Children, n)
+		nodes[ns] = n
+		return n
+	}
+
+	for _, b := range buckets {
+		ns := ""
+		if !rootNamespaceKey(b.Key) {
+			ns = normalizeNamespace(b.Key)
+		}
+		if depth > 0 {
+			ns = namespaceAncestor(ns, depth)
+		}
+		node(ns).Count += b.Value
+	}
+	sumNamespaceTotals(root)
+	return root
+}
+
+func sumNamespaceTotals(n *NamespaceNode) float64 {
+	n.Total = n.Count
+	for _, c := range n.Children {
+		n.Total += sumNamespaceTotals(c)
+	}
+	sort.Slice(n.Children, func(i, j int) bool {
+		if n.Children[i].Total != n.Children[j].Total {
+			return n.Children[i].Total > n.Children[j].Total
+		}
+		return n.Children[i].Namespace < n.Children[j].Namespace
+	})
+	return n.Total
+}
+
+// RollupNamespaces flattens counts keyed by namespace path into one bucket
+// per namespace in the tree, depth-limited as in NamespaceTree. With
+// includeDescendants each bucket is the namespace's total including its
+// descendants; otherwise its own count. The root namespace is keyed "(root)"
+// and buckets are ordered parents first.
+func RollupNamespaces(buckets []Bucket, depth int, includeDescendants bool) []Bucket {
+	var out []Bucket
+	var walk func(n *NamespaceNode)
+	walk = func(n *NamespaceNode) {
+		v := n.Count
+		if includeDescendants {
+			v = n.Total
+		}
+		key := n.Namespace
+		if key == "" {
+			key = "(root)"
+		}
+		// Ancestors without events of their own are only listed with
+		// their descendants' counts.
+		if v > 0 {
+			out = append(out, Bucket{Key: key, Value: v})
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(NamespaceTree(buckets, depth))
+	return out
+}
+
+// namespacePrefixes normalizes namespace prefixes, dropping empty ones.
+func namespacePrefixes(prefixes []string) []string {
+	out := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		if p = normalizeNamespace(strings.TrimPrefix(strings.TrimSpace(p), "/")); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// namespacePrefixRegexp returns a label regex matching the subtrees of
+// prefixes, e.g. team-a/.* for team-a.
+func namespacePrefixRegexp(prefixes []string) string {
+	quoted := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		quoted[i] = regexp.QuoteMeta(p)
+	}
+	if len(quoted) == 1 {
+		return quoted[0] + ".*"
+	}
+	return "(" + strings.Join(quoted, "|") + ").*"
+}
@@ -0,0 +1,152 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+var namespaceBuckets = []Bucket{
+	{Key: "(none)", Value: 3},
+	{Key: "root", Value: 2},
+	{Key: "org/team-a/app1/", Value: 4},
+	{Key: "org/team-a/", Value: 1},
+	{Key: "org/team-b/app2/", Value: 7},
+	{Key: "ci", Value: 2},
+}
+
+// flattenTree lists "namespace count/total" in tree order.
+func flattenTree(n *NamespaceNode) []string {
+	out := []string{n.Namespace + " " + formatCount(n.Count) + "/" + formatCount(n.Total)}
+	for _, c := range n.Children {
+		out = append(out, flattenTree(c)...)
+	}
+	return out
+}
+
+func formatCount(v float64) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestNamespaceTree(t *testing.T) {
+	tree := NamespaceTree(namespaceBuckets, 0)
+	want := []string{
+		" 5/19",
+		"org/ 0/12",
+		"org/team-b/ 0/7",
+		"org/team-b/app2/ 7/7",
+		"org/team-a/ 1/5",
+		"org/team-a/app1/ 4/4",
+		"ci/ 2/2",
+	}
+	if got := flattenTree(tree); !reflect.DeepEqual(got, want) {
+		t.Errorf("tree = %q\nwant %q", got, want)
+	}
+	if tree.Children[0].Children[1].Depth != 2 {
+		t.Errorf("org/team-a/ depth = %d, want 2", tree.Children[0].Children[1].Depth)
+	}
+
+	want = []string{" 5/19", "org/ 12/12", "ci/ 2/2"}
+	if got := flattenTree(NamespaceTree(namespaceBuckets, 1)); !reflect.DeepEqual(got, want) {
+		t.Errorf("depth 1 tree = %q\nwant %q", got, want)
+	}
+}
+
+func TestRollupNamespaces(t *testing.T) {
+	got := RollupNamespaces(namespaceBuckets, 2, true)
+	want := []Bucket{
+		{Key: "(root)", Value: 19},
+		{Key: "org/", Value: 12},
+		{Key: "org/team-b/", Value: 7},
+		{Key: "org/team-a/", Value: 5},
+		{Key: "ci/", Value: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("with descendants = %v\nwant %v", got, want)
+	}
+
+	// Own counts skip ancestors without events of their own.
+	got = RollupNamespaces(namespaceBuckets, 0, false)
+	want = []Bucket{
+		{Key: "(root)", Value: 5},
+		{Key: "org/team-b/app2/", Value: 7},
+		{Key: "org/team-a/", Value: 1},
+		{Key: "org/team-a/app1/", Value: 4},
+		{Key: "ci/", Value: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("own counts = %v\nwant %v", got, want)
+	}
+}
+
+// bucketBackend returns fixed aggregation buckets and records the filter.
+type bucketBackend struct {
+	stubBackend
+	buckets []Bucket
+	filter  *AggregateFilter
+}
+
+func (b *bucketBackend) Aggregate(ctx context.Context, filter *AggregateFilter, by string) ([]Bucket, error) {
+	b.filter = filter
+	return b.buckets, nil
+}
+
+func TestAggregateNamespaceTreeTool(t *testing.T) {
+	backend := &bucketBackend{buckets: namespaceBuckets}
+	session := connectService(t, NewService(backend))
+
+	res, err := session.CallTool(t.Context(), &mcp.CallToolParams{
+		Name: "audit.aggregate",
+		Arguments: map[string]any{
+			"by":               LabelNamespace,
+			"namespace_prefix": "org",
+			"namespace_view":   "tree",
+			"depth":            2,
+		},
+	})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if res.IsError {
+		t.Fatalf("tool error: %v", res.Content)
+	}
+	if backend.filter.NamespacePrefix != "org" {
+		t.Errorf("namespace prefix = %q, want org", backend.filter.NamespacePrefix)
+	}
+	var result NamespaceTreeResult
+	raw, _ := json.Marshal(res.StructuredContent)
+	if err := json.Unmarshal(raw, &result); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if result.Tree == nil || result.Tree.Total != 19 || len(result.Tree.Children) != 2 {
+		t.Fatalf("tree = %+v", result.Tree)
+	}
+
+	res, err = session.CallTool(t.Context(), &mcp.CallToolParams{
+		Name:      "audit.aggregate",
+		Arguments: map[string]any{"by": LabelOperation, "depth": 1},
+	})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if !res.IsError {
+		t.Error("depth should require by=vault_namespace")
+	}
+}
+
+func TestSummaryNamespaceTree(t *testing.T) {
+	flat := []Event{{Namespace: "team-a/"}, {Namespace: "team-b/"}}
+	if s := SummarizeSearch(flat, len(flat), "", ""); s.NamespaceTree != nil {
+		t.Error("flat namespaces should not get a tree")
+	}
+	nested := []Event{{Namespace: "org/a/"}, {Namespace: "org/b/x/y/"}, {Namespace: "org/"}}
+	s := SummarizeSearch(nested, len(nested), "", "")
+	want := []string{" 0/3", "org/ 1/3", "org/a/ 1/1", "org/b/ 0/1", "org/b/x/ 1/1"}
+	if got := flattenTree(s.NamespaceTree); !reflect.DeepEqual(got, want) {
+		t.Errorf("summary tree = %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,462 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/printer"
+)
+
+// capabilityOrder is the order capabilities are listed in, as in the Vault
+// documentation.
+var capabilityOrder = []string{"deny", "create", "read", "update", "patch", "delete", "list", "sudo", "subscribe", "recover"}
+
+// legacyPolicyCapabilities expands the deprecated policy = "..." setting.
+var legacyPolicyCapabilities = map[string][]string{
+	"deny":  {"deny"},
+	"read":  {"read", "list"},
+	"write": {"create", "read", "update", "delete", "list"},
+	"sudo":  {"create", "read", "update", "delete", "list", "sudo"},
+}
+
+// Policy is a Vault ACL policy parsed from HCL or JSON.
+type Policy struct {
+	Name string `json:"name"`
+	// Namespace is the namespace the policy is defined in; empty for root.
+	Namespace string       `json:"namespace,omitempty"`
+	File      string       `json:"file,omitempty"`
+	Rules     []PolicyRule `json:"rules"`
+}
+
+// PolicyRule is a path stanza of a policy. Stanzas repeating a path are
+// merged, as Vault does.
+type PolicyRule struct {
+	Path         string   `json:"path"`
+	Capabilities []string `json:"capabilities"`
+	// Attributes names the other settings of the stanza, such as
+	// allowed_parameters or required_parameters. They are kept in suggested
+	// policies but not evaluated.
+	Attributes []string `json:"attributes,omitempty"`
+
+	extra []*ast.ObjectItem
+}
+
+// ParsePolicy parses a Vault ACL policy document in HCL or JSON.
+func ParsePolicy(name, src string) (*Policy, error) {
+	root, err := hcl.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("policy %s: %w", name, err)
+	}
+	list, ok := root.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("policy %s: missing root object", name)
+	}
+
+	p := &Policy{Name: name}
+	index := make(map[string]int)
+	for _, item := range list.Filter("path").Items {
+		if len(item.Keys) == 0 {
+			return nil, fmt.Errorf("policy %s: path stanza without a path", name)
+		}
+		path, _ := item.Keys[0].Token.Value().(string)
+		path = strings.TrimPrefix(path, "/")
+		obj, ok := item.Val.(*ast.ObjectType)
+		if !ok {
+			return nil, fmt.Errorf("policy %s: path %q: expected a block", name, path)
+		}
+
+		var stanza struct {
+			Capabilities []string `hcl:"capabilities"`
+			Policy       string   `hcl:"policy"`
+		}
+		if err := hcl.DecodeObject(&stanza, item.Val); err != nil {
+			return nil, fmt.Errorf("policy %s: path %q: %w", name, path, err)
+		}
+		caps := stanza.Capabilities
+		if stanza.Policy != "" {
+			legacy, ok := legacyPolicyCapabilities[stanza.Policy]
+			if !ok {
+				return nil, fmt.Errorf("policy %s: path %q: invalid policy %q", name, path, stanza.Policy)
+			}
+			caps = append(caps, legacy...)
+		}
+		for _, c := range caps {
+			if !slices.Contains(capabilityOrder, c) {
+				return nil, fmt.Errorf("policy %s: path %q: invalid capability %q", name, path, c)
+			}
+		}
+
+		i, ok := index[path]
+		if !ok {
+			i = len(p.Rules)
+			index[path] = i
+			p.Rules = append(p.Rules, PolicyRule{Path: path})
+		}
+		rule := &p.Rules[i]
+		rule.Capabilities = sortCapabilities(append(rule.Capabilities, caps...))
+		for _, attr := range obj.List.Items {
+			if len(attr.Keys) == 0 {
+				continue
+			}
+			key, _ := attr.Keys[0].Token.Value().(string)
+			if key == "capabilities" || key == "policy" {
+				continue
+			}
+			rule.extra = append(rule.extra, attr)
+			if !slices.Contains(rule.Attributes, key) {
+				rule.Attributes = append(rule.Attributes, key)
+			}
+		}
+	}
+	return p, nil
+}
+
+// sortCapabilities removes duplicates and orders caps by capabilityOrder.
+func sortCapabilities(caps []string) []string {
+	var out []string
+	for _, c := range capabilityOrder {
+		if slices.Contains(caps, c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// PolicySet holds the policies loaded from a directory, keyed by namespace
+// and name.
+type PolicySet struct {
+	policies map[string]map[string]*Policy
+}
+
+// LoadPolicies reads every .hcl and .json policy under dir. The file name
+// without its extension is the policy name, and the subdirectory the
+// namespace: dir/team-a/app.hcl is policy app in namespace team-a/.
+func LoadPolicies(dir string) (*PolicySet, error) {
+	set := &PolicySet{policies: make(map[string]map[string]*Policy)}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(path)
+		if d.IsDir() || (ext != ".hcl" && ext != ".json") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		ns := filepath.ToSlash(filepath.Dir(rel))
+		if ns == "." {
+			ns = ""
+		}
+		name := strings.TrimSuffix(filepath.Base(rel), ext)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read policy: %w", err)
+		}
+		p, err := ParsePolicy(name, string(data))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		p.Namespace = normalizeNamespace(ns)
+		p.File = filepath.ToSlash(rel)
+		if prev := set.Lookup(p.Namespace, name); prev != nil && prev.Namespace == p.Namespace {
+			return fmt.Errorf("policy %s is defined in both %s and %s", name, prev.File, p.File)
+		}
+		set.add(p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// NewPolicySet returns a set holding policies.
+func NewPolicySet(policies ...*Policy) *PolicySet {
+	set := &PolicySet{policies: make(map[string]map[string]*Policy)}
+	for _, p := range policies {
+		set.add(p)
+	}
+	return set
+}
+
+func (s *PolicySet) add(p *Policy) {
+	byName := s.policies[p.Namespace]
+	if byName == nil {
+		byName = make(map[string]*Policy)
+		s.policies[p.Namespace] = byName
+	}
+	byName[p.Name] = p
+}
+
+// Lookup returns the policy named name as seen from namespace: the one
+// defined there, or else in the nearest ancestor namespace.
+func (s *PolicySet) Lookup(namespace, name string) *Policy {
+	ns := policyNamespace(namespace)
+	for {
+		if p := s.policies[ns][name]; p != nil {
+			return p
+		}
+		if ns == "" {
+			return nil
+		}
+		ns = parentNamespace(ns)
+	}
+}
+
+// Policies returns every policy, ordered by namespace and name.
+func (s *PolicySet) Policies() []*Policy {
+	var out []*Policy
+	for _, byName := range s.policies {
+		for _, p := range byName {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Namespace != out[j].Namespace {
+			return out[i].Namespace < out[j].Namespace
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// policyNamespace normalizes an event or policy namespace, mapping the
+// root namespace to "".
+func policyNamespace(ns string) string {
+	if rootNamespaceKey(ns) {
+		return ""
+	}
+	return normalizeNamespace(ns)
+}
+
+// parentNamespace returns the parent of a normalized, non-root namespace.
+func parentNamespace(ns string) string {
+	ns = strings.TrimSuffix(ns, "/")
+	if i := strings.LastIndex(ns, "/"); i >= 0 {
+		return ns[:i+1]
+	}
+	return ""
+}
+
+// operationCapability returns the capability a Vault operation requires, or
+// "" for operations that are not checked against a single capability, such
+// as help or renew.
+func operationCapability(op string) string {
+	switch op {
+	case "create", "read", "update", "patch", "delete", "list", "recover":
+		return op
+	case "write":
+		return "update"
+	}
+	return ""
+}
+
+// aclGrant is a capability granted by a rule of a policy.
+type aclGrant struct {
+	policy *Policy
+	rule   int
+}
+
+// aclRule is a path pattern merged across the policies of a token, with
+// the pattern qualified by the policy namespace.
+type aclRule struct {
+	pattern string
+	grants  map[string][]aclGrant
+	denied  bool
+}
+
+// acl evaluates requests against a set of policies the way Vault does:
+// patterns of the same path are merged, deny wins, and the most specific
+// matching pattern decides.
+type acl struct {
+	rules []*aclRule
+}
+
+// aclDecision is the outcome of evaluating a request.
+type aclDecision struct {
+	Allowed bool
+	// Pattern is the deciding rule, qualified by namespace; empty when no
+	// rule matched.
+	Pattern string
+	// Denied is set when the deciding rule is an explicit deny.
+	Denied bool
+	Grants []aclGrant
+}
+
+func newACL(policies []*Policy) *acl {
+	byPattern := make(map[string]*aclRule)
+	a := &acl{}
+	for _, p := range policies {
+		for i, r := range p.Rules {
+			pattern := p.Namespace + r.Path
+			ar := byPattern[pattern]
+			if ar == nil {
+				ar = &aclRule{pattern: pattern, grants: make(map[string][]aclGrant)}
+				byPattern[pattern] = ar
+				a.rules = append(a.rules, ar)
+			}
+			for _, c := range r.Capabilities {
+				if c == "deny" {
+					ar.denied = true
+					continue
+				}
+				ar.grants[c] = append(ar.grants[c], aclGrant{policy: p, rule: i})
+			}
+		}
+	}
+	sort.Slice(a.rules, func(i, j int) bool {
+		return aclHigherPriority(a.rules[i].pattern, a.rules[j].pattern)
+	})
+	return a
+}
+
+// evaluate decides whether capability is granted on path, a request path
+// qualified by its namespace.
+func (a *acl) evaluate(path, capability string) aclDecision {
+	for _, r := range a.rules {
+		if !matchACLPath(r.pattern, path) {
+			continue
+		}
+		d := aclDecision{Pattern: r.pattern, Denied: r.denied}
+		if !r.denied {
+			d.Grants = r.grants[capability]
+			d.Allowed = len(d.Grants) > 0
+		}
+		return d
+	}
+	return aclDecision{}
+}
+
+// matchACLPath matches a policy path pattern: a trailing * matches any
+// suffix, and a + segment matches exactly one path segment.
+func matchACLPath(pattern, path string) bool {
+	glob := strings.HasSuffix(pattern, "*")
+	if glob {
+		pattern = strings.TrimSuffix(pattern, "*")
+	}
+	if !hasSegmentWildcard(pattern) {
+		if glob {
+			return strings.HasPrefix(path, pattern)
+		}
+		return path == pattern
+	}
+
+	want := strings.Split(pattern, "/")
+	got := strings.Split(path, "/")
+	if len(got) < len(want) || (!glob && len(got) != len(want)) {
+		return false
+	}
+	for i, seg := range want {
+		switch {
+		case seg == "+":
+		case glob && i == len(want)-1:
+			if !strings.HasPrefix(got[i], seg) {
+				return false
+			}
+		case got[i] != seg:
+			return false
+		}
+	}
+	return true
+}
+
+func hasSegmentWildcard(pattern string) bool {
+	return segmentWildcards(pattern) > 0
+}
+
+func segmentWildcards(pattern string) int {
+	n := 0
+	for _, seg := range strings.Split(pattern, "/") {
+		if seg == "+" {
+			n++
+		}
+	}
+	return n
+}
+
+// firstWildcard returns the position of the first + segment or trailing *,
+// or the pattern length when there is none.
+func firstWildcard(pattern string) int {
+	pos := len(pattern)
+	if strings.HasSuffix(pattern, "*") {
+		pos = len(pattern) - 1
+	}
+	offset := 0
+	for _, seg := range strings.Split(pattern, "/") {
+		if seg == "+" {
+			return min(pos, offset)
+		}
+		offset += len(seg) + 1
+	}
+	return pos
+}
+
+// aclHigherPriority reports whether pattern a takes precedence over b when
+// both match a path, following Vault's rules: a later first wildcard, no
+// trailing *, fewer + segments, a longer pattern, then the lexically
+// greater one.
+func aclHigherPriority(a, b string) bool {
+	if wa, wb := firstWildcard(a), firstWildcard(b); wa != wb {
+		return wa > wb
+	}
+	if ga, gb := strings.HasSuffix(a, "*"), strings.HasSuffix(b, "*"); ga != gb {
+		return gb
+	}
+	if pa, pb := segmentWildcards(a), segmentWildcards(b); pa != pb {
+		return pa < pb
+	}
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return a > b
+}
+
+// eventPolicyNames returns the distinct policies an event's token carried.
+func eventPolicyNames(ev *Event) []string {
+	var names []string
+	for _, list := range [][]string{ev.Policies, ev.TokenPolicies, ev.IdentityPolicies} {
+		for _, n := range list {
+			if n != "" && !slices.Contains(names, n) {
+				names = append(names, n)
+			}
+		}
+	}
+	return names
+}
+
+// eventACLPath qualifies an event's request path by its namespace, matching
+// the patterns built by newACL.
+func eventACLPath(ev *Event) string {
+	return policyNamespace(ev.Namespace) + strings.TrimPrefix(ev.Path, "/")
+}
+
+// renderRule writes a path stanza with the given capabilities and the
+// rule's other attributes.
+func renderRule(buf *bytes.Buffer, r *PolicyRule, caps []string) {
+	quoted := make([]string, len(caps))
+	for i, c := range caps {
+		quoted[i] = fmt.Sprintf("%q", c)
+	}
+	fmt.Fprintf(buf, "path %q {\n  capabilities = [%s]\n", r.Path, strings.Join(quoted, ", "))
+	for _, attr := range r.extra {
+		var out bytes.Buffer
+		if err := printer.Fprint(&out, attr); err != nil {
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimRight(out.String(), "\n"), "\n") {
+			buf.WriteString("  " + line + "\n")
+		}
+	}
+	buf.WriteString("}\n")
+}
@@ -0,0 +1,270 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const appPolicyHCL = `
+# Application secrets.
+path "secret/data/app/*" {
+  capabilities = ["read", "list"]
+}
+
+path "secret/data/app/*" {
+  capabilities = ["update"]
+}
+
+path "secret/data/app/admin" {
+  capabilities = ["deny"]
+}
+
+path "transit/encrypt/+" {
+  capabilities = ["update"]
+  allowed_parameters = {
+    "plaintext" = []
+  }
+}
+
+path "sys/rotate" {
+  capabilities = ["update", "sudo"]
+}
+
+path "pki/issue/app" {
+  policy = "write"
+}
+`
+
+const ciPolicyJSON = `{
+  "path": {
+    "auth/token/create": {"capabilities": ["create", "update"]},
+    "secret/data/ci/*": {"capabilities": ["read"]}
+  }
+}`
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy("app", appPolicyHCL)
+	if err != nil {
+		t.Fatalf("ParsePolicy failed: %v", err)
+	}
+	var got []string
+	for _, r := range p.Rules {
+		got = append(got, r.Path+" "+strings.Join(r.Capabilities, ","))
+	}
+	want := []string{
+		"secret/data/app/* read,update,list",
+		"secret/data/app/admin deny",
+		"transit/encrypt/+ update",
+		"sys/rotate update,sudo",
+		"pki/issue/app create,read,update,delete,list",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rules = %q\nwant %q", got, want)
+	}
+	if !reflect.DeepEqual(p.Rules[2].Attributes, []string{"allowed_parameters"}) {
+		t.Errorf("attributes = %v", p.Rules[2].Attributes)
+	}
+
+	ci, err := ParsePolicy("ci", ciPolicyJSON)
+	if err != nil {
+		t.Fatalf("ParsePolicy(JSON) failed: %v", err)
+	}
+	if len(ci.Rules) != 2 || ci.Rules[0].Path != "auth/token/create" || len(ci.Rules[0].Capabilities) != 2 {
+		t.Errorf("JSON rules = %+v", ci.Rules)
+	}
+
+	if _, err := ParsePolicy("bad", `path "x" { capabilities = ["raed"] }`); err == nil {
+		t.Error("an invalid capability should fail")
+	}
+}
+
+func TestACLEvaluate(t *testing.T) {
+	a := newACL([]*Policy{
+		{Name: "a", Rules: []PolicyRule{
+			{Path: "secret/*", Capabilities: []string{"read"}},
+			{Path: "secret/data/+/config", Capabilities: []string{"update"}},
+			{Path: "secret/data/team/*", Capabilities: []string{"list"}},
+			{Path: "secret/data/team/locked", Capabilities: []string{"deny"}},
+			{Path: "kv/+/+/creds*", Capabilities: []string{"read"}},
+		}},
+		{Name: "b", Rules: []PolicyRule{
+			{Path: "secret/*", Capabilities: []string{"create"}},
+		}},
+		{Name: "c", Namespace: "team-a/", Rules: []PolicyRule{
+			{Path: "db/creds/app", Capabilities: []string{"read"}},
+		}},
+	})
+
+	tests := []struct {
+		path, capability string
+		pattern          string
+		allowed          bool
+	}{
+		{"secret/foo", "read", "secret/*", true},
+		{"secret/foo", "create", "secret/*", true},
+		{"secret/data/app/config", "update", "secret/data/+/config", true},
+		// The + rule decides, so the broader read grant does not apply.
+		{"secret/data/app/config", "read", "secret/data/+/config", false},
+		{"secret/data/app/x/config", "read", "secret/*", true},
+		// A later first wildcard wins over the + rule.
+		{"secret/data/team/config", "list", "secret/data/team/*", true},
+		{"secret/data/team/locked", "read", "secret/data/team/locked", false},
+		{"kv/a/b/creds-1", "read", "kv/+/+/creds*", true},
+		{"kv/a/creds", "read", "", false},
+		{"team-a/db/creds/app", "read", "team-a/db/creds/app", true},
+		{"db/creds/app", "read", "", false},
+	}
+	for _, tt := range tests {
+		d := a.evaluate(tt.path, tt.capability)
+		if d.Pattern != tt.pattern || d.Allowed != tt.allowed {
+			t.Errorf("evaluate(%q, %q) = %q %v, want %q %v", tt.path, tt.capability, d.Pattern, d.Allowed, tt.pattern, tt.allowed)
+		}
+	}
+	if d := a.evaluate("secret/x", "create"); len(d.Grants) != 1 || d.Grants[0].policy.Name != "b" {
+		t.Errorf("create on secret/x granted by %+v, want b", d.Grants)
+	}
+}
+
+// writePolicyDir writes app.hcl at the root and ci.json in team-a/.
+func writePolicyDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "team-a"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		"app.hcl":         appPolicyHCL,
+		"team-a/ci.json":  ciPolicyJSON,
+		"README.md":       "not a policy",
+		"team-a/notes.md": "neither",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func policyUsageEvents(end time.Time) []Event {
+	at := func(h int) time.Time { return end.Add(-time.Duration(h) * time.Hour) }
+	return []Event{
+		{Time: at(1), AuditType: "response", Operation: "read", Path: "secret/data/app/db", Policies: []string{"default", "app"}},
+		{Time: at(2), AuditType: "request", Operation: "read", Path: "secret/data/app/db", Policies: []string{"app"}},
+		{Time: at(3), AuditType: "response", Operation: "read", Path: "secret/data/app/api", Policies: []string{"app"}},
+		{Time: at(4), AuditType: "response", Operation: "update", Path: "transit/encrypt/app", Policies: []string{"app"}},
+		{Time: at(5), AuditType: "response", Operation: "update", Path: "sys/rotate", Policies: []string{"app"}},
+		{Time: at(6), AuditType: "response", Operation: "read", Path: "secret/data/app/admin", Policies: []string{"app"}, Status: "error"},
+		{Time: at(7), AuditType: "response", Operation: "read", Path: "secret/data/app/x", Policies: []string{"root"}},
+		{Time: at(30), AuditType: "response", Operation: "create", Path: "auth/token/create", Namespace: "team-a/", TokenPolicies: []string{"ci"},
+			PolicyResults: &PolicyResults{Allowed: true, GrantingPolicies: []GrantingPolicy{{Name: "ci", NamespacePath: "team-a/"}}}},
+		// Vault says another policy granted this one.
+		{Time: at(31), AuditType: "response", Operation: "update", Path: "auth/token/create", Namespace: "team-a/", TokenPolicies: []string{"ci", "app"},
+			PolicyResults: &PolicyResults{Allowed: true, GrantingPolicies: []GrantingPolicy{{Name: "tokens"}}}},
+	}
+}
+
+func TestAnalyzePolicyUsage(t *testing.T) {
+	policies, err := LoadPolicies(writePolicyDir(t))
+	if err != nil {
+		t.Fatalf("LoadPolicies failed: %v", err)
+	}
+	if p := policies.Lookup("team-a/child/", "app"); p == nil || p.Namespace != "" {
+		t.Errorf("app from team-a/child/ = %+v, want the root policy", p)
+	}
+
+	end := time.Date(2026, 3, 10, 0, 30, 0, 0, time.UTC)
+	backend := &timedBackend{events: policyUsageEvents(end), limits: QueryLimits{MaxLimit: 2, MaxDays: 1}}
+	report, err := AnalyzePolicyUsage(t.Context(), backend, policies, PolicyUsageQuery{Start: end.Add(-72 * time.Hour), End: end})
+	if err != nil {
+		t.Fatalf("AnalyzePolicyUsage failed: %v", err)
+	}
+	if report.EventsScanned != 7 || report.EventsAttributed != 5 {
+		t.Errorf("scanned %d, attributed %d; want 7, 5", report.EventsScanned, report.EventsAttributed)
+	}
+	if !reflect.DeepEqual(report.UnknownPolicies, []string{"default"}) {
+		t.Errorf("unknown policies = %v", report.UnknownPolicies)
+	}
+	if len(report.Policies) != 2 {
+		t.Fatalf("policies = %+v", report.Policies)
+	}
+
+	app := report.Policies[0]
+	if app.Name != "app" || app.Requests != 4 {
+		t.Errorf("app = %s with %d requests, want 4", app.Name, app.Requests)
+	}
+	if !reflect.DeepEqual(app.UnusedRules, []string{"pki/issue/app"}) {
+		t.Errorf("unused rules = %v", app.UnusedRules)
+	}
+	wantUnused := map[string][]string{"secret/data/app/*": {"update", "list"}}
+	if !reflect.DeepEqual(app.UnusedCapabilities, wantUnused) {
+		t.Errorf("unused capabilities = %v", app.UnusedCapabilities)
+	}
+	if got := app.Rules[0].SamplePaths; !reflect.DeepEqual(got, []string{"secret/data/app/db", "secret/data/app/api"}) {
+		t.Errorf("sample paths = %v", got)
+	}
+
+	suggested := app.SuggestedPolicy
+	for _, want := range []string{
+		"path \"secret/data/app/*\" {\n  capabilities = [\"read\"]\n}",
+		"path \"secret/data/app/admin\" {\n  capabilities = [\"deny\"]\n}",
+		"  allowed_parameters = {",
+		"capabilities = [\"update\", \"sudo\"]",
+		"# Removed unused rule \"pki/issue/app\".",
+	} {
+		if !strings.Contains(suggested, want) {
+			t.Errorf("suggested policy lacks %q:\n%s", want, suggested)
+		}
+	}
+	if strings.Contains(suggested, "path \"pki/issue/app\"") {
+		t.Errorf("suggested policy keeps the unused rule:\n%s", suggested)
+	}
+	if _, err := ParsePolicy("app", suggested); err != nil {
+		t.Errorf("suggested policy does not parse: %v", err)
+	}
+
+	ci := report.Policies[1]
+	if ci.Namespace != "team-a/" || ci.Requests != 1 || ci.Rules[0].Used["create"] != 1 || ci.Rules[0].Used["update"] != 0 {
+		t.Errorf("ci = %+v", ci)
+	}
+}
+
+func TestPolicyUsageTool(t *testing.T) {
+	end := time.Now().UTC().Add(-time.Minute)
+	svc := NewService(&timedBackend{events: policyUsageEvents(end)})
+	session := connectService(t, svc)
+
+	res, err := session.CallTool(t.Context(), &mcp.CallToolParams{Name: "audit.policy_usage", Arguments: map[string]any{}})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if !res.IsError {
+		t.Error("policy_usage without a policy directory should fail")
+	}
+
+	svc.SetPolicyDir(writePolicyDir(t))
+	res, err = session.CallTool(t.Context(), &mcp.CallToolParams{
+		Name:      "audit.policy_usage",
+		Arguments: map[string]any{"policies": []string{"ci"}},
+	})
+	if err != nil || res.IsError {
+		t.Fatalf("policy_usage failed: %v %v", err, res)
+	}
+	var report PolicyUsageReport
+	raw, _ := json.Marshal(res.StructuredContent)
+	if err := json.Unmarshal(raw, &report); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if len(report.Policies) != 1 || report.Policies[0].Name != "ci" || report.Policies[0].Requests != 1 {
+		t.Errorf("report = %+v", report.Policies)
+	}
+	if !reflect.DeepEqual(report.Policies[0].UnusedRules, []string{"secret/data/ci/*"}) {
+		t.Errorf("unused rules = %v", report.Policies[0].UnusedRules)
+	}
+}
@@ -0,0 +1,318 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultPolicyUsageWindow is the window audit.policy_usage scans when no
+// start time is given. Rarely used grants need a long window to show up.
+const DefaultPolicyUsageWindow = "30d"
+
+// maxRuleSamplePaths caps the request paths listed per rule.
+const maxRuleSamplePaths = 5
+
+// PolicyUsageQuery selects the events and policies of a usage report.
+type PolicyUsageQuery struct {
+	Start time.Time
+	End   time.Time
+	// Policies limits the report to these policy names; empty reports all
+	// loaded policies. Every policy is still used to attribute requests.
+	Policies []string
+	// MaxEvents stops the scan after this many events; 0 means no cap.
+	MaxEvents int
+}
+
+// PolicyUsageReport compares the grants of local policy files with the
+// requests they allowed.
+type PolicyUsageReport struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// EventsScanned counts successful responses read; EventsAttributed
+	// those granted by at least one loaded policy.
+	EventsScanned    int `json:"events_scanned"`
+	EventsAttributed int `json:"events_attributed"`
+	// UnknownPolicies are policies carried by tokens without a local file.
+	UnknownPolicies []string      `json:"unknown_policies,omitempty"`
+	Policies        []PolicyUsage `json:"policies"`
+	// Truncated is set when MaxEvents stopped the scan early.
+	Truncated bool `json:"truncated,omitempty"`
+	// Incomplete is set when the deadline was reached before the whole
+	// range was scanned.
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// PolicyUsage is the observed usage of one policy.
+type PolicyUsage struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	File      string `json:"file"`
+	// Requests counts requests this policy granted.
+	Requests int         `json:"requests"`
+	Rules    []RuleUsage `json:"rules"`
+	// UnusedRules are the path rules that granted nothing; deny rules are
+	// never listed.
+	UnusedRules []string `json:"unused_rules,omitempty"`
+	// UnusedCapabilities maps used rules to capabilities never exercised.
+	UnusedCapabilities map[string][]string `json:"unused_capabilities,omitempty"`
+	// SuggestedPolicy keeps only the used rules and capabilities; it is
+	// empty when the policy granted nothing.
+	SuggestedPolicy string `json:"suggested_policy,omitempty"`
+}
+
+// RuleUsage is the observed usage of one path rule.
+type RuleUsage struct {
+	Path         string         `json:"path"`
+	Capabilities []string       `json:"capabilities"`
+	Requests     int            `json:"requests"`
+	Used         map[string]int `json:"used,omitempty"`
+	// Unused lists granted capabilities never exercised. sudo cannot be
+	// observed in audit logs and is never listed.
+	Unused      []string `json:"unused,omitempty"`
+	LastUsed    string   `json:"last_used,omitempty"`
+	SamplePaths []string `json:"sample_paths,omitempty"`
+}
+
+// policyUsageCounter attributes requests to the policy rules that granted
+// them.
+type policyUsageCounter struct {
+	policies *PolicySet
+	acls     map[string]*acl
+	rules    map[*Policy][]RuleUsage
+	requests map[*Policy]int
+	unknown  map[string]bool
+
+	scanned, attributed int
+}
+
+func newPolicyUsageCounter(policies *PolicySet) *policyUsageCounter {
+	return &policyUsageCounter{
+		policies: policies,
+		acls:     make(map[string]*acl),
+		rules:    make(map[*Policy][]RuleUsage),
+		requests: make(map[*Policy]int),
+		unknown:  make(map[string]bool),
+	}
+}
+
+// eventACL returns the merged ACL of the policies an event's token carried,
+// or nil for root tokens and tokens without any loaded policy.
+func (c *policyUsageCounter) eventACL(ev *Event) *acl {
+	var policies []*Policy
+	var key strings.Builder
+	for _, name := range eventPolicyNames(ev) {
+		if name == "root" {
+			return nil
+		}
+		p := c.policies.Lookup(ev.Namespace, name)
+		if p == nil {
+			c.unknown[name] = true
+			continue
+		}
+		policies = append(policies, p)
+		key.WriteString(p.Namespace + p.Name + "\x00")
+	}
+	if len(policies) == 0 {
+		return nil
+	}
+	a := c.acls[key.String()]
+	if a == nil {
+		a = newACL(policies)
+		c.acls[key.String()] = a
+	}
+	return a
+}
+
+func (c *policyUsageCounter) add(ev *Event) {
+	if ev.AuditType == "request" || ev.Status == "error" {
+		return
+	}
+	if ev.PolicyResults != nil && !ev.PolicyResults.Allowed {
+		return
+	}
+	c.scanned++
+	capability := operationCapability(ev.Operation)
+	a := c.eventACL(ev)
+	if capability == "" || a == nil {
+		return
+	}
+	d := a.evaluate(eventACLPath(ev), capability)
+	if !d.Allowed {
+		return
+	}
+
+	attributed := false
+	for _, g := range d.Grants {
+		if !grantedPer(ev.PolicyResults, g.policy) {
+			continue
+		}
+		attributed = true
+		c.requests[g.policy]++
+		rules := c.rules[g.policy]
+		if rules == nil {
+			rules = make([]RuleUsage, len(g.policy.Rules))
+			c.rules[g.policy] = rules
+		}
+		u := &rules[g.rule]
+		u.Requests++
+		if u.Used == nil {
+			u.Used = make(map[string]int)
+		}
+		u.Used[capability]++
+		if t := ev.Time.UTC().Format(time.RFC3339); t > u.LastUsed {
+			u.LastUsed = t
+		}
+		path := strings.TrimPrefix(eventACLPath(ev), g.policy.Namespace)
+		if len(u.SamplePaths) < maxRuleSamplePaths && !slices.Contains(u.SamplePaths, path) {
+			u.SamplePaths = append(u.SamplePaths, path)
+		}
+	}
+	if attributed {
+		c.attributed++
+	}
+}
+
+// grantedPer reports whether Vault's logged decision agrees that p granted
+// the request. Without policy_results every evaluated grant counts.
+func grantedPer(results *PolicyResults, p *Policy) bool {
+	if results == nil || len(results.GrantingPolicies) == 0 {
+		return true
+	}
+	for _, g := range results.GrantingPolicies {
+		if g.Name == p.Name && (g.NamespacePath == "" || policyNamespace(g.NamespacePath) == p.Namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *policyUsageCounter) report(q *PolicyUsageQuery) *PolicyUsageReport {
+	rep := &PolicyUsageReport{
+		Start:            q.Start.UTC().Format(time.RFC3339),
+		End:              q.End.UTC().Format(time.RFC3339),
+		EventsScanned:    c.scanned,
+		EventsAttributed: c.attributed,
+		Policies:         []PolicyUsage{},
+	}
+	for name := range c.unknown {
+		rep.UnknownPolicies = append(rep.UnknownPolicies, name)
+	}
+	sort.Strings(rep.UnknownPolicies)
+
+	for _, p := range c.policies.Policies() {
+		if len(q.Policies) > 0 && !slices.Contains(q.Policies, p.Name) {
+			continue
+		}
+		rep.Policies = append(rep.Policies, c.policyUsage(p, rep))
+	}
+	return rep
+}
+
+func (c *policyUsageCounter) policyUsage(p *Policy, rep *PolicyUsageReport) PolicyUsage {
+	u := PolicyUsage{
+		Name:      p.Name,
+		Namespace: p.Namespace,
+		File:      p.File,
+		Requests:  c.requests[p],
+		Rules:     make([]RuleUsage, len(p.Rules)),
+	}
+	observed := c.rules[p]
+	for i, r := range p.Rules {
+		ru := RuleUsage{Path: r.Path, Capabilities: r.Capabilities}
+		if observed != nil {
+			o := observed[i]
+			ru.Requests, ru.Used, ru.LastUsed, ru.SamplePaths = o.Requests, o.Used, o.LastUsed, o.SamplePaths
+		}
+		denies := slices.Contains(r.Capabilities, "deny")
+		switch {
+		case denies:
+		case ru.Requests == 0:
+			u.UnusedRules = append(u.UnusedRules, r.Path)
+		default:
+			for _, capability := range r.Capabilities {
+				if capability != "sudo" && ru.Used[capability] == 0 {
+					ru.Unused = append(ru.Unused, capability)
+				}
+			}
+			if len(ru.Unused) > 0 {
+				if u.UnusedCapabilities == nil {
+					u.UnusedCapabilities = make(map[string][]string)
+				}
+				u.UnusedCapabilities[r.Path] = ru.Unused
+			}
+		}
+		u.Rules[i] = ru
+	}
+	if u.Requests > 0 {
+		u.SuggestedPolicy = suggestPolicy(p, u.Rules, rep)
+	}
+	return u
+}
+
+// suggestPolicy renders p narrowed to the rules and capabilities in use.
+// Deny rules are kept, and sudo is kept on used rules that had it since it
+// cannot be observed.
+func suggestPolicy(p *Policy, usage []RuleUsage, rep *PolicyUsageReport) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s narrowed to the requests observed between %s and %s.\n", p.Name, rep.Start, rep.End)
+	var removed []string
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if slices.Contains(r.Capabilities, "deny") {
+			continue
+		}
+		if usage[i].Requests == 0 {
+			removed = append(removed, r.Path)
+		}
+	}
+	for _, path := range removed {
+		fmt.Fprintf(&buf, "# Removed unused rule %q.\n", path)
+	}
+
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		var caps []string
+		switch {
+		case slices.Contains(r.Capabilities, "deny"):
+			caps = r.Capabilities
+		case usage[i].Requests == 0:
+			continue
+		default:
+			for _, c := range r.Capabilities {
+				if c == "sudo" || usage[i].Used[c] > 0 {
+					caps = append(caps, c)
+				}
+			}
+		}
+		buf.WriteString("\n")
+		renderRule(&buf, r, caps)
+	}
+	return buf.String()
+}
+
+// AnalyzePolicyUsage scans successful responses between q.Start and q.End,
+// attributes each to the policy rules that granted it, and reports unused
+// rules and capabilities with a narrowed policy per policy. Ranges longer
+// than the search limits are paged through.
+func AnalyzePolicyUsage(ctx context.Context, backend Backend, policies *PolicySet, q PolicyUsageQuery) (*PolicyUsageReport, error) {
+	counter := newPolicyUsageCounter(policies)
+	filter := SearchFilter{Start: q.Start, End: q.End, AuditType: "response"}
+	_, truncated, err := exportEvents(ctx, backend, filter, q.MaxEvents, func(ev *Event) error {
+		counter.add(ev)
+		return nil
+	})
+	incomplete := errors.Is(err, ErrIncomplete)
+	if err != nil && !incomplete {
+		return nil, err
+	}
+	rep := counter.report(&q)
+	rep.Truncated = truncated
+	rep.Incomplete = incomplete
+	return rep, nil
+}
@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ErrIncomplete is returned together with partial results when the query
+// deadline was reached before the whole time range was scanned.
+var ErrIncomplete = errors.New("query deadline reached before the whole time range was scanned")
+
+// Progress describes how far a long-running query has got.
+type Progress struct {
+	WindowsScanned int `json:"windows_scanned"`
+	EventsMatched  int `json:"events_matched"`
+	// WindowsRemaining estimates the windows left at the current window size.
+	WindowsRemaining int `json:"windows_remaining"`
+}
+
+// ProgressFunc receives progress updates. It is called from the goroutine
+// running the query.
+type ProgressFunc func(Progress)
+
+type progressKey struct{}
+
+// WithProgress returns a context whose queries report progress to fn.
+// A nil fn disables reporting, e.g. for sub-queries of a larger operation.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+func reportProgress(ctx context.Context, p Progress) {
+	if fn, _ := ctx.Value(progressKey{}).(ProgressFunc); fn != nil {
+		fn(p)
+	}
+}
+
+// deadlineErr converts a failure caused by the context deadline into
+// ErrIncomplete so callers can keep what was scanned. Cancellation and
+// other errors are returned unchanged.
+func deadlineErr(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", ErrIncomplete, err)
+	}
+	return err
+}
+
+// PartialAggregate is returned by audit.aggregate in place of the bucket
+// list when the deadline was reached part way through.
+type PartialAggregate struct {
+	Buckets    []Bucket `json:"buckets"`
+	Incomplete bool     `json:"incomplete"`
+}
+
+// queryContext applies the service query timeout and, when the client sent
+// a progress token, forwards query progress as MCP progress notifications.
+func (s *Service) queryContext(ctx context.Context, req *mcp.CallToolRequest) (context.Context, context.CancelFunc) {
+	if req != nil && req.Session != nil && req.Params != nil {
+		if token := req.Params.GetProgressToken(); token != nil {
+			notifyCtx := ctx
+			ctx = WithProgress(ctx, func(p Progress) {
+				err := req.Session.NotifyProgress(notifyCtx, &mcp.ProgressNotificationParams{
+					ProgressToken: token,
+					Progress:      float64(p.WindowsScanned),
+					Total:         float64(p.WindowsScanned + p.WindowsRemaining),
+					Message: fmt.Sprintf("scanned %d windows, matched %d events, ~%d windows remaining",
+						p.WindowsScanned, p.EventsMatched, p.WindowsRemaining),
+				})
+				if err != nil && notifyCtx.Err() == nil {
+					log.Printf("failed to send progress notification: %v", err)
+				}
+			})
+		}
+	}
+	if s.queryTimeout > 0 {
+		return context.WithTimeout(ctx, s.queryTimeout)
+	}
+	return context.WithCancel(ctx)
+}
@@ -0,0 +1,111 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// slowBackend reports progress and then runs out of time.
+type slowBackend struct {
+	stubBackend
+}
+
+func (b *slowBackend) Search(ctx context.Context, filter *SearchFilter) ([]Event, error) {
+	reportProgress(ctx, Progress{WindowsScanned: 1, EventsMatched: 1, WindowsRemaining: 3})
+	reportProgress(ctx, Progress{WindowsScanned: 2, EventsMatched: 1, WindowsRemaining: 2})
+	return []Event{{Time: filter.End, RequestID: "req-1", Operation: "read"}}, ErrIncomplete
+}
+
+func TestSearchToolSendsProgressAndMarksIncomplete(t *testing.T) {
+	server := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "v0"}, nil)
+	NewService(&slowBackend{}).AddTools(server)
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	ctx := context.Background()
+	if _, err := server.Connect(ctx, serverTransport, nil); err != nil {
+		t.Fatalf("server connect failed: %v", err)
+	}
+	progress := make(chan *mcp.ProgressNotificationParams, 10)
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "v0"}, &mcp.ClientOptions{
+		ProgressNotificationHandler: func(ctx context.Context, req *mcp.ProgressNotificationClientRequest) {
+			progress <- req.Params
+		},
+	})
+	session, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("client connect failed: %v", err)
+	}
+	defer session.Close()
+
+	params := &mcp.CallToolParams{
+		Meta:      mcp.Meta{"progressToken": "search-1"},
+		Name:      "audit.search_events",
+		Arguments: map[string]any{},
+	}
+	res, err := session.CallTool(ctx, params)
+	if err != nil || res.IsError {
+		t.Fatalf("search_events failed: %v %+v", err, res)
+	}
+	var summary SearchSummary
+	raw, _ := json.Marshal(res.StructuredContent)
+	if err := json.Unmarshal(raw, &summary); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if !summary.Incomplete || summary.TotalEvents != 1 {
+		t.Errorf("summary should hold the partial result marked incomplete, got %+v", summary)
+	}
+
+	for want := 1; want <= 2; want++ {
+		select {
+		case p := <-progress:
+			if p.ProgressToken != "search-1" || p.Progress != float64(want) || p.Total != 4 {
+				t.Errorf("unexpected progress notification %+v", p)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("progress notification %d not received", want)
+		}
+	}
+}
+
+// blockingBackend answers trace and HMAC queries only at the deadline.
+type blockingBackend struct {
+	stubBackend
+}
+
+func (b *blockingBackend) Trace(ctx context.Context, filter *TraceFilter) ([]Event, error) {
+	<-ctx.Done()
+	return []Event{{Time: filter.End, RequestID: filter.RequestID}}, deadlineErr(ctx, ctx.Err())
+}
+
+func (b *blockingBackend) FindByHMAC(ctx context.Context, filter *HMACFilter) ([]HMACMatch, error) {
+	<-ctx.Done()
+	return nil, deadlineErr(ctx, ctx.Err())
+}
+
+func TestLookupToolsApplyQueryTimeout(t *testing.T) {
+	svc := NewService(&blockingBackend{})
+	svc.SetQueryTimeout(50 * time.Millisecond)
+	svc.SetAuditHMACKey([]byte("audit-salt"))
+	session := connectService(t, svc)
+
+	for name, args := range map[string]map[string]any{
+		"audit.get_event_details": {"request_id": "req-1"},
+		"audit.find_by_hmac":      {"values": []string{"hvs.token"}},
+	} {
+		res, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: name, Arguments: args})
+		if err != nil || res.IsError {
+			t.Fatalf("%s failed: %v %+v", name, err, res)
+		}
+		var result struct {
+			Incomplete bool `json:"incomplete"`
+		}
+		raw, _ := json.Marshal(res.StructuredContent)
+		if err := json.Unmarshal(raw, &result); err != nil || !result.Incomplete {
+			t.Errorf("%s result = %s, want it marked incomplete", name, raw)
+		}
+	}
+}
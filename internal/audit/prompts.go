@@ -0,0 +1,206 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+//go:embed prompts/*.json prompts/*.md
+var builtinPrompts embed.FS
+
+// defaultInvestigationWindow is the window used when a prompt is rendered
+// without start_rfc3339/end_rfc3339 arguments.
+const defaultInvestigationWindow = 24 * time.Hour
+
+// PromptTemplate is an investigation prompt loaded from a JSON definition.
+//
+// The body is a text/template rendered with the prompt arguments as a
+// map[string]string; arguments that were not supplied render as "".
+// start_rfc3339 and end_rfc3339 default to the last 24 hours.
+type PromptTemplate struct {
+	Name        string           `json:"name"`
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+
+	// Template is the inline template body. TemplateFile, relative to the
+	// definition file, is used instead when Template is empty.
+	Template     string `json:"template,omitempty"`
+	TemplateFile string `json:"template_file,omitempty"`
+
+	tmpl *template.Template
+}
+
+// PromptArgument describes one prompt argument.
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Default     string `json:"default,omitempty"`
+}
+
+// LoadPromptTemplates returns the built-in investigation prompts, overridden
+// or extended by any *.json definitions in dir. An empty dir loads only the
+// built-ins.
+func LoadPromptTemplates(dir string) ([]*PromptTemplate, error) {
+	byName := make(map[string]*PromptTemplate)
+
+	builtins, err := loadPromptDir(builtinPrompts, "prompts")
+	if err != nil {
+		return nil, fmt.Errorf("invalid built-in prompts: %w", err)
+	}
+	for _, p := range builtins {
+		byName[p.Name] = p
+	}
+
+	if dir != "" {
+		local, err := loadPromptDir(os.DirFS(dir), ".")
+		if err != nil {
+			return nil, fmt.Errorf("invalid prompt templates in %s: %w", dir, err)
+		}
+		for _, p := range local {
+			byName[p.Name] = p
+		}
+	}
+
+	out := make([]*PromptTemplate, 0, len(byName))
+	for _, p := range byName {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func loadPromptDir(fsys fs.FS, dir string) ([]*PromptTemplate, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*PromptTemplate, 0, len(files))
+	for _, f := range files {
+		p, err := loadPromptFile(fsys, f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path.Base(f), err)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func loadPromptFile(fsys fs.FS, file string) (*PromptTemplate, error) {
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, err
+	}
+	var p PromptTemplate
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if p.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	for _, a := range p.Arguments {
+		if a.Name == "" {
+			return nil, fmt.Errorf("prompt %s: argument name is required", p.Name)
+		}
+	}
+
+	body := p.Template
+	if body == "" && p.TemplateFile != "" {
+		b, err := fs.ReadFile(fsys, path.Join(path.Dir(file), p.TemplateFile))
+		if err != nil {
+			return nil, fmt.Errorf("prompt %s: %w", p.Name, err)
+		}
+		body = string(b)
+	}
+	if strings.TrimSpace(body) == "" {
+		return nil, fmt.Errorf("prompt %s: template or template_file is required", p.Name)
+	}
+
+	p.tmpl, err = template.New(p.Name).Option("missingkey=zero").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("prompt %s: %w", p.Name, err)
+	}
+	return &p, nil
+}
+
+// Render executes the template with args, applying defaults and checking
+// required arguments.
+func (p *PromptTemplate) Render(args map[string]string) (string, error) {
+	data := make(map[string]string, len(p.Arguments)+2)
+	for _, a := range p.Arguments {
+		v := strings.TrimSpace(args[a.Name])
+		if v == "" {
+			v = a.Default
+		}
+		if v == "" && a.Required {
+			return "", fmt.Errorf("argument %q is required", a.Name)
+		}
+		data[a.Name] = v
+	}
+	// Pass through undeclared arguments so local templates can use them.
+	for k, v := range args {
+		if _, ok := data[k]; !ok {
+			data[k] = v
+		}
+	}
+
+	now := time.Now().UTC()
+	if data["end_rfc3339"] == "" {
+		data["end_rfc3339"] = now.Format(time.RFC3339)
+	}
+	if data["start_rfc3339"] == "" {
+		data["start_rfc3339"] = now.Add(-defaultInvestigationWindow).Format(time.RFC3339)
+	}
+
+	var buf bytes.Buffer
+	if err := p.tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s: %w", p.Name, err)
+	}
+	return buf.String(), nil
+}
+
+// AddPrompts registers investigation prompts with the MCP server.
+func (s *Service) AddPrompts(server *mcp.Server, prompts []*PromptTemplate) {
+	for _, p := range prompts {
+		args := make([]*mcp.PromptArgument, 0, len(p.Arguments))
+		for _, a := range p.Arguments {
+			args = append(args, &mcp.PromptArgument{
+				Name:        a.Name,
+				Description: a.Description,
+				Required:    a.Required,
+			})
+		}
+
+		server.AddPrompt(&mcp.Prompt{
+			Name:        p.Name,
+			Title:       p.Title,
+			Description: p.Description,
+			Arguments:   args,
+		}, func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			text, err := p.Render(req.Params.Arguments)
+			if err != nil {
+				return nil, err
+			}
+			return &mcp.GetPromptResult{
+				Description: p.Description,
+				Messages: []*mcp.PromptMessage{{
+					Role:    "user",
+					Content: &mcp.TextContent{Text: text},
+				}},
+			}, nil
+		})
+	}
+}
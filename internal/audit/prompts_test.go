@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func findPrompt(prompts []*PromptTemplate, name string) *PromptTemplate {
+	for _, p := range prompts {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func TestBuiltinPromptsRender(t *testing.T) {
+	prompts, err := LoadPromptTemplates("")
+	if err != nil {
+		t.Fatalf("LoadPromptTemplates failed: %v", err)
+	}
+	for _, name := range []string{
+		"investigate_failed_logins",
+		"investigate_policy_change",
+		"investigate_root_token_usage",
+		"investigate_unusual_secret_reads",
+	} {
+		p := findPrompt(prompts, name)
+		if p == nil {
+			t.Errorf("built-in prompt %s missing", name)
+			continue
+		}
+		text, err := p.Render(map[string]string{"policy_name": "payments", "namespace": "team-a/"})
+		if err != nil {
+			t.Errorf("%s: Render failed: %v", name, err)
+			continue
+		}
+		if strings.Contains(text, "<no value>") {
+			t.Errorf("%s: rendered text contains <no value>", name)
+		}
+		if !strings.Contains(text, "team-a/") {
+			t.Errorf("%s: namespace argument not rendered", name)
+		}
+	}
+}
+
+func TestPromptRequiredArgument(t *testing.T) {
+	prompts, err := LoadPromptTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := findPrompt(prompts, "investigate_policy_change").Render(nil); err == nil {
+		t.Fatal("Render should fail without policy_name")
+	}
+}
+
+func TestLocalPromptDirectory(t *testing.T) {
+	dir := t.TempDir()
+	def := `{
+		"name": "investigate_failed_logins",
+		"description": "Local override",
+		"arguments": [{"name": "team", "default": "payments"}],
+		"template": "Check {{.team}} from {{.start_rfc3339}}"
+	}`
+	if err := os.WriteFile(filepath.Join(dir, "override.json"), []byte(def), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	prompts, err := LoadPromptTemplates(dir)
+	if err != nil {
+		t.Fatalf("LoadPromptTemplates failed: %v", err)
+	}
+	p := findPrompt(prompts, "investigate_failed_logins")
+	if p.Description != "Local override" {
+		t.Fatal("local template should override the built-in with the same name")
+	}
+
+	session := connectServiceWithPrompts(t, prompts)
+	res, err := session.GetPrompt(context.Background(), &mcp.GetPromptParams{Name: p.Name})
+	if err != nil {
+		t.Fatalf("GetPrompt failed: %v", err)
+	}
+	text := res.Messages[0].Content.(*mcp.TextContent).Text
+	if !strings.HasPrefix(text, "Check payments from ") {
+		t.Errorf("rendered prompt = %q", text)
+	}
+}
+
+func connectServiceWithPrompts(t *testing.T, prompts []*PromptTemplate) *mcp.ClientSession {
+	t.Helper()
+	server := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "v0"}, nil)
+	NewService(&stubBackend{}).AddPrompts(server, prompts)
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := server.Connect(context.Background(), serverTransport, nil); err != nil {
+		t.Fatalf("server connect failed: %v", err)
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "v0"}, nil)
+	session, err := client.Connect(context.Background(), clientTransport, nil)
+	if err != nil {
+		t.Fatalf("client connect failed: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
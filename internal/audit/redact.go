@@ -0,0 +1,471 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// RedactionAction is what a redaction rule does with a matched field.
+type RedactionAction string
+
+const (
+	// ActionDrop removes the field entirely.
+	ActionDrop RedactionAction = "drop"
+	// ActionMask replaces the value with "[redacted]".
+	ActionMask RedactionAction = "mask"
+	// ActionHash replaces the value with a keyed HMAC-SHA256 ("hmac-sha256:<hex>"),
+	// so equal values can still be correlated without being revealed.
+	ActionHash RedactionAction = "hash"
+	// ActionTruncate shortens string values to MaxLength characters.
+	ActionTruncate RedactionAction = "truncate"
+	// ActionKeep leaves the value untouched and shields it from later rules.
+	ActionKeep RedactionAction = "keep"
+)
+
+const redactedValue = "[redacted]"
+
+// RedactionRule applies an action to every field matching a selector.
+//
+// Selectors are dot-separated field paths into the Vault audit entry,
+// optionally prefixed with "$.". A "*" segment matches any single key and
+// "**" matches any number of keys. Arrays are transparent, so "errors"
+// selects the whole array and "response.data.keys" selects the list value.
+//
+//	auth.client_token        exact field
+//	response.data.*          every field directly under response.data
+//	**.secret_id             secret_id at any depth
+type RedactionRule struct {
+	Field     string          `json:"field"`
+	Action    RedactionAction `json:"action"`
+	MaxLength int             `json:"max_length,omitempty"` // truncate only
+
+	segments []string
+}
+
+// RedactionPolicy is an ordered list of rules applied to each audit entry.
+//
+// Rules are evaluated in order and the first rule to reach a field decides
+// it; later rules never touch a field (or its children) an earlier rule
+// decided. When a broad rule covers a field whose descendant was kept by an
+// earlier rule, the broad action is applied to the remaining children only.
+type RedactionPolicy struct {
+	Rules []RedactionRule `json:"rules"`
+
+	// AllowedErrors lists known-safe error strings. When a non-keep rule
+	// matches an "error" or "errors" field, any allowed strings contained in
+	// the value are returned instead of the redacted value, so that e.g.
+	// "permission denied" survives while the rest of the message does not.
+	AllowedErrors []string `json:"allowed_errors,omitempty"`
+
+	// AllowedMetadata lists auth metadata keys (e.g. role_name) copied to
+	// Event.AuthMetadata before the rules run, so they remain available when
+	// auth.metadata itself is masked.
+	AllowedMetadata []string `json:"allowed_metadata,omitempty"`
+
+	// IncludeDefaults appends the default rules and allowed errors after the
+	// policy's own, so a policy only needs to list its exceptions.
+	IncludeDefaults bool `json:"include_defaults,omitempty"`
+
+	// HMACKeyFile is the path to the key used by the hash action.
+	HMACKeyFile string `json:"hmac_key_file,omitempty"`
+
+	hmacKey []byte
+}
+
+// defaultAllowedErrors are Vault error strings that carry no secret material
+// and are the most useful signal when troubleshooting failed requests.
+var defaultAllowedErrors = []string{
+	"permission denied",
+	"invalid token",
+	"bad token",
+	"missing client token",
+	"unsupported path",
+	"unsupported operation",
+	"invalid request",
+	"invalid credentials",
+	"invalid username or password",
+	"namespace not found",
+	"lease not found",
+	"entity not found",
+	"role not found",
+	"no handler for route",
+	"request rate limit quota has been exceeded",
+	"internal error",
+}
+
+// defaultAllowedMetadata are auth metadata keys that name the role or
+// workload a token was issued for, not a credential.
+var defaultAllowedMetadata = []string{
+	"role",
+	"role_name",
+	"service_account_name",
+	"service_account_namespace",
+}
+
+// defaultRedactionRules are the strict rules used when no policy is configured.
+func defaultRedactionRules() []RedactionRule {
+	return []RedactionRule{
+		// Top-level error details; allow-listed errors are preserved.
+		{Field: "error", Action: ActionMask},
+		{Field: "errors", Action: ActionMask},
+
+		// auth block
+		{Field: "auth.client_token", Action: ActionMask},
+		{Field: "auth.accessor", Action: ActionMask},
+		{Field: "auth.secret_id", Action: ActionMask},
+		{Field: "auth.metadata", Action: ActionMask},
+
+		// request block
+		{Field: "request.client_token", Action: ActionMask},
+		{Field: "request.client_token_accessor", Action: ActionMask},
+		{Field: "request.data", Action: ActionMask},
+
+		// response block
+		{Field: "response.auth.client_token", Action: ActionMask},
+		{Field: "response.auth.accessor", Action: ActionMask},
+		{Field: "response.auth.secret_id", Action: ActionMask},
+		{Field: "response.auth.metadata", Action: ActionMask},
+		{Field: "response.secret.data", Action: ActionMask},
+		{Field: "response.data", Action: ActionMask},
+
+		// wrap_info keeps its TTL and creation metadata, not the token.
+		{Field: "response.wrap_info.token", Action: ActionMask},
+		{Field: "response.wrap_info.accessor", Action: ActionMask},
+		{Field: "response.wrap_info.wrapped_accessor", Action: ActionMask},
+	}
+}
+
+// DefaultRedactionPolicy returns the strict built-in redaction policy.
+func DefaultRedactionPolicy() *RedactionPolicy {
+	p := &RedactionPolicy{
+		Rules:           defaultRedactionRules(),
+		AllowedErrors:   append([]string(nil), defaultAllowedErrors...),
+		AllowedMetadata: append([]string(nil), defaultAllowedMetadata...),
+	}
+	if err := p.compile(); err != nil {
+		panic(fmt.Sprintf("invalid default redaction policy: %v", err))
+	}
+	return p
+}
+
+var defaultPolicy = DefaultRedactionPolicy()
+
+// Redact applies the default redaction policy to an audit entry in-place.
+func Redact(m map[string]any) {
+	defaultPolicy.Apply(m)
+}
+
+// LoadRedactionPolicy reads a JSON redaction policy from path and validates it.
+func LoadRedactionPolicy(path string) (*RedactionPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redaction policy: %w", err)
+	}
+	var p RedactionPolicy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid redaction policy JSON: %w", err)
+	}
+	if err := p.Init(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Init merges defaults, loads the HMAC key and validates the policy.
+// It must be called on policies built outside LoadRedactionPolicy.
+func (p *RedactionPolicy) Init() error {
+	if p.IncludeDefaults {
+		p.Rules = append(p.Rules, defaultRedactionRules()...)
+		p.AllowedErrors = append(p.AllowedErrors, defaultAllowedErrors...)
+		p.AllowedMetadata = append(p.AllowedMetadata, defaultAllowedMetadata...)
+		p.IncludeDefaults = false
+	}
+	if p.HMACKeyFile != "" {
+		key, err := os.ReadFile(p.HMACKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read redaction HMAC key: %w", err)
+		}
+		p.SetHMACKey(key)
+	}
+	return p.compile()
+}
+
+// SetHMACKey sets the key used by the hash action. Surrounding whitespace
+// is trimmed so key files written with a trailing newline work as expected.
+func (p *RedactionPolicy) SetHMACKey(key []byte) {
+	p.hmacKey = []byte(strings.TrimSpace(string(key)))
+}
+
+func (p *RedactionPolicy) compile() error {
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		field := strings.TrimPrefix(strings.TrimSpace(r.Field), "$.")
+		if field == "" {
+			return fmt.Errorf("redaction rule %d: field is required", i)
+		}
+		r.segments = strings.Split(field, ".")
+		for _, seg := range r.segments {
+			if seg == "" {
+				return fmt.Errorf("redaction rule %d: invalid field selector %q", i, r.Field)
+			}
+		}
+
+		switch r.Action {
+		case ActionDrop, ActionMask, ActionKeep:
+		case ActionHash:
+			if len(p.hmacKey) == 0 {
+				return fmt.Errorf("redaction rule %d (%s): hash action requires an HMAC key", i, r.Field)
+			}
+		case ActionTruncate:
+			if r.MaxLength <= 0 {
+				return fmt.Errorf("redaction rule %d (%s): truncate action requires max_length > 0", i, r.Field)
+			}
+		default:
+			return fmt.Errorf("redaction rule %d (%s): unknown action %q (want drop, mask, hash, truncate or keep)", i, r.Field, r.Action)
+		}
+	}
+	return nil
+}
+
+// allowedMetadata returns the allowed auth metadata keys of an unredacted
+// audit entry, from auth.metadata or, for login responses,
+// response.auth.metadata.
+func (p *RedactionPolicy) allowedMetadata(m map[string]any) map[string]string {
+	if p == nil || len(p.AllowedMetadata) == 0 {
+		return nil
+	}
+	var out map[string]string
+	for _, block := range []map[string]any{mapAt(m, "auth"), mapAt(m, "response", "auth")} {
+		meta := mapAt(block, "metadata")
+		for _, k := range p.AllowedMetadata {
+			v, ok := meta[k].(string)
+			if !ok || v == "" {
+				continue
+			}
+			if out == nil {
+				out = make(map[string]string)
+			}
+			if _, seen := out[k]; !seen {
+				out[k] = v
+			}
+		}
+	}
+	return out
+}
+
+// Apply redacts the audit entry in-place.
+func (p *RedactionPolicy) Apply(m map[string]any) {
+	if p == nil || m == nil {
+		return
+	}
+	decided := make(map[string]decision)
+	for i := range p.Rules {
+		p.walk(m, nil, i, decided)
+	}
+}
+
+// decision records which rule first decided a field path. Array elements
+// share their array's path, so only decisions by earlier rules block a rule.
+type decision struct {
+	action RedactionAction
+	rule   int
+}
+
+// walk visits every field under node, applying rule to matching fields that
+// no earlier rule has decided.
+func (p *RedactionPolicy) walk(node map[string]any, path []string, rule int, decided map[string]decision) {
+	for k, child := range node {
+		fieldPath := append(append([]string(nil), path...), k)
+		key := strings.Join(fieldPath, ".")
+		if isDecided(decided, key, rule) {
+			continue
+		}
+		if matchSelector(p.Rules[rule].segments, fieldPath) {
+			p.applyAction(node, k, fieldPath, rule, decided)
+			continue
+		}
+		switch c := child.(type) {
+		case map[string]any:
+			p.walk(c, fieldPath, rule, decided)
+		case []any:
+			for _, el := range c {
+				if em, ok := el.(map[string]any); ok {
+					p.walk(em, fieldPath, rule, decided)
+				}
+			}
+		}
+	}
+}
+
+func (p *RedactionPolicy) applyAction(parent map[string]any, key string, path []string, ruleIdx int, decided map[string]decision) {
+	rule := &p.Rules[ruleIdx]
+	joined := strings.Join(path, ".")
+
+	// Respect descendants kept by an earlier rule by redacting siblings only.
+	if rule.Action != ActionKeep && hasKeptDescendant(decided, joined) {
+		if child, ok := parent[key].(map[string]any); ok {
+			for ck := range child {
+				childPath := append(append([]string(nil), path...), ck)
+				if isDecided(decided, strings.Join(childPath, "."), ruleIdx) {
+					continue
+				}
+				p.applyAction(child, ck, childPath, ruleIdx, decided)
+			}
+			return
+		}
+	}
+
+	if _, ok := decided[joined]; !ok {
+		decided[joined] = decision{action: rule.Action, rule: ruleIdx}
+	}
+	if rule.Action == ActionKeep {
+		return
+	}
+
+	val := parent[key]
+	if val == nil {
+		return
+	}
+
+	if isErrorField(key) && len(p.AllowedErrors) > 0 {
+		if safe, ok := p.allowedError(val); ok {
+			parent[key] = safe
+			return
+		}
+	}
+
+	switch rule.Action {
+	case ActionDrop:
+		delete(parent, key)
+	case ActionMask:
+		parent[key] = redactedValue
+	case ActionHash:
+		parent[key] = p.hmacValue(val)
+	case ActionTruncate:
+		if s, ok := val.(string); ok {
+			if utf8.RuneCountInString(s) > rule.MaxLength {
+				parent[key] = truncateRunes(s, rule.MaxLength) + "..."
+			}
+		} else {
+			parent[key] = redactedValue
+		}
+	}
+}
+
+// truncateRunes returns the first n characters of s, never splitting a
+// multi-byte character.
+func truncateRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// allowedError returns only the allow-listed error strings found in val.
+// Arrays are filtered element-wise.
+func (p *RedactionPolicy) allowedError(val any) (any, bool) {
+	switch v := val.(type) {
+	case string:
+		lower := strings.ToLower(v)
+		var found []string
+		for _, a := range p.AllowedErrors {
+			if a != "" && strings.Contains(lower, strings.ToLower(a)) && !contains(found, a) {
+				found = append(found, a)
+			}
+		}
+		if len(found) == 0 {
+			return nil, false
+		}
+		return strings.Join(found, "; "), true
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, el := range v {
+			if safe, ok := p.allowedError(el); ok {
+				out = append(out, safe)
+			} else {
+				out = append(out, redactedValue)
+			}
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// hmacValue computes a Vault-style "hmac-sha256:<hex>" digest of v.
+// Non-string values are hashed over their JSON encoding.
+func (p *RedactionPolicy) hmacValue(v any) string {
+	var data []byte
+	if s, ok := v.(string); ok {
+		data = []byte(s)
+	} else {
+		data, _ = json.Marshal(v)
+	}
+	return hmacSHA256(p.hmacKey, data)
+}
+
+func hmacSHA256(key, data []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func isErrorField(key string) bool {
+	return key == "error" || key == "errors"
+}
+
+// isDecided reports whether path or one of its ancestors was decided by a
+// rule earlier than rule.
+func isDecided(decided map[string]decision, path string, rule int) bool {
+	for {
+		if d, ok := decided[path]; ok && d.rule < rule {
+			return true
+		}
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			return false
+		}
+		path = path[:i]
+	}
+}
+
+func hasKeptDescendant(decided map[string]decision, path string) bool {
+	prefix := path + "."
+	for k, d := range decided {
+		if d.action == ActionKeep && strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSelector reports whether a concrete field path matches selector
+// segments, where "*" matches one segment and "**" matches zero or more.
+func matchSelector(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchSelector(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if pattern[0] != "*" && pattern[0] != path[0] {
+		return false
+	}
+	return matchSelector(pattern[1:], path[1:])
+}
@@ -0,0 +1,258 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// sampleAuditEntry returns a Vault response audit entry populating every block.
+func sampleAuditEntry() map[string]any {
+	return map[string]any{
+		"type":  "response",
+		"error": "1 error occurred:\n\t* permission denied\n\n",
+		"auth": map[string]any{
+			"client_token":   "hmac-sha256:client",
+			"accessor":       "hmac-sha256:accessor",
+			"display_name":   "approle-payments",
+			"policies":       []any{"default", "payments"},
+			"token_policies": []any{"default", "payments"},
+			"metadata":       map[string]any{"role_name": "payments"},
+			"entity_id":      "e-123",
+		},
+		"request": map[string]any{
+			"id":                    "req-1",
+			"operation":             "update",
+			"path":                  "secret/data/payments/db",
+			"client_token":          "hmac-sha256:reqtoken",
+			"client_token_accessor": "hmac-sha256:reqaccessor",
+			"remote_address":        "10.1.2.3",
+			"data":                  map[string]any{"password": "hmac-sha256:pw"},
+		},
+		"response": map[string]any{
+			"mount_type": "kv",
+			"auth": map[string]any{
+				"client_token": "hmac-sha256:newtoken",
+				"accessor":     "hmac-sha256:newaccessor",
+				"secret_id":    "hmac-sha256:secretid",
+				"metadata":     map[string]any{"role_name": "payments"},
+				"display_name": "approle-payments",
+			},
+			"secret": map[string]any{
+				"lease_id": "database/creds/payments/abc",
+				"data":     map[string]any{"username": "v-payments"},
+			},
+			"data": map[string]any{
+				"common_name": "payments.example.com",
+				"private_key": "hmac-sha256:key",
+			},
+			"wrap_info": map[string]any{
+				"token":            "hmac-sha256:wraptoken",
+				"accessor":         "hmac-sha256:wrapaccessor",
+				"wrapped_accessor": "hmac-sha256:wrapped",
+				"ttl":              float64(300),
+				"creation_path":    "sys/wrapping/wrap",
+			},
+		},
+	}
+}
+
+func block(t *testing.T, m map[string]any, path ...string) map[string]any {
+	t.Helper()
+	cur := m
+	for _, p := range path {
+		next, ok := cur[p].(map[string]any)
+		if !ok {
+			t.Fatalf("missing block %s", strings.Join(path, "."))
+		}
+		cur = next
+	}
+	return cur
+}
+
+func TestDefaultPolicyRedactsEveryBlock(t *testing.T) {
+	m := sampleAuditEntry()
+	Redact(m)
+
+	if m["error"] != "permission denied" {
+		t.Errorf("error = %q, want allow-listed %q", m["error"], "permission denied")
+	}
+
+	auth := block(t, m, "auth")
+	for _, f := range []string{"client_token", "accessor", "metadata"} {
+		if auth[f] != redactedValue {
+			t.Errorf("auth.%s should be redacted, got %v", f, auth[f])
+		}
+	}
+	if auth["display_name"] != "approle-payments" || auth["entity_id"] != "e-123" {
+		t.Error("auth identity fields should be preserved")
+	}
+
+	req := block(t, m, "request")
+	for _, f := range []string{"client_token", "client_token_accessor", "data"} {
+		if req[f] != redactedValue {
+			t.Errorf("request.%s should be redacted, got %v", f, req[f])
+		}
+	}
+	if req["path"] != "secret/data/payments/db" || req["remote_address"] != "10.1.2.3" {
+		t.Error("request path and remote_address should be preserved")
+	}
+
+	resp := block(t, m, "response")
+	if resp["data"] != redactedValue {
+		t.Errorf("response.data should be redacted, got %v", resp["data"])
+	}
+	if resp["mount_type"] != "kv" {
+		t.Error("response.mount_type should be preserved")
+	}
+
+	respAuth := block(t, m, "response", "auth")
+	for _, f := range []string{"client_token", "accessor", "secret_id", "metadata"} {
+		if respAuth[f] != redactedValue {
+			t.Errorf("response.auth.%s should be redacted, got %v", f, respAuth[f])
+		}
+	}
+
+	secret := block(t, m, "response", "secret")
+	if secret["data"] != redactedValue {
+		t.Errorf("response.secret.data should be redacted, got %v", secret["data"])
+	}
+	if secret["lease_id"] == redactedValue {
+		t.Error("response.secret.lease_id should be preserved")
+	}
+
+	wrap := block(t, m, "response", "wrap_info")
+	for _, f := range []string{"token", "accessor", "wrapped_accessor"} {
+		if wrap[f] != redactedValue {
+			t.Errorf("response.wrap_info.%s should be redacted, got %v", f, wrap[f])
+		}
+	}
+	if wrap["ttl"] != float64(300) || wrap["creation_path"] != "sys/wrapping/wrap" {
+		t.Error("response.wrap_info metadata should be preserved")
+	}
+}
+
+func TestDefaultPolicyMasksUnknownErrors(t *testing.T) {
+	m := map[string]any{
+		"error":  "failed to connect to db at 10.0.0.5 with password hunter2",
+		"errors": []any{"permission denied", "backend leaked detail"},
+	}
+	Redact(m)
+	if m["error"] != redactedValue {
+		t.Errorf("unknown error should be masked, got %v", m["error"])
+	}
+	errs := m["errors"].([]any)
+	if errs[0] != "permission denied" || errs[1] != redactedValue {
+		t.Errorf("errors should be filtered element-wise, got %v", errs)
+	}
+}
+
+func TestPolicyActions(t *testing.T) {
+	p := &RedactionPolicy{
+		Rules: []RedactionRule{
+			{Field: "response.data.common_name", Action: ActionKeep},
+			{Field: "response.data", Action: ActionMask},
+			{Field: "$.auth.client_token", Action: ActionHash},
+			{Field: "auth.metadata", Action: ActionDrop},
+			{Field: "request.path", Action: ActionTruncate, MaxLength: 6},
+			{Field: "**.secret_id", Action: ActionDrop},
+			{Field: "response.wrap_info.*", Action: ActionMask},
+		},
+	}
+	p.SetHMACKey([]byte("test-key\n"))
+	if err := p.Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	m := sampleAuditEntry()
+	p.Apply(m)
+
+	data := block(t, m, "response", "data")
+	if data["common_name"] != "payments.example.com" {
+		t.Error("kept field under a masked parent should be preserved")
+	}
+	if data["private_key"] != redactedValue {
+		t.Error("sibling of a kept field should be masked")
+	}
+
+	auth := block(t, m, "auth")
+	want := hmacSHA256([]byte("test-key"), []byte("hmac-sha256:client"))
+	if auth["client_token"] != want {
+		t.Errorf("auth.client_token = %v, want %v", auth["client_token"], want)
+	}
+	if _, ok := auth["metadata"]; ok {
+		t.Error("auth.metadata should be dropped")
+	}
+
+	if got := block(t, m, "request")["path"]; got != "secret..." {
+		t.Errorf("request.path = %v, want truncated", got)
+	}
+	// Truncation counts characters and keeps the result valid UTF-8.
+	if got := truncateRunes("sécrets", 2); got != "sé" {
+		t.Errorf("truncateRunes = %q, want %q", got, "sé")
+	}
+	if _, ok := block(t, m, "response", "auth")["secret_id"]; ok {
+		t.Error("response.auth.secret_id should be dropped by ** selector")
+	}
+	if block(t, m, "response", "wrap_info")["ttl"] != redactedValue {
+		t.Error("response.wrap_info.* should mask every field")
+	}
+
+	// No default rules: fields not covered by the policy are untouched.
+	if block(t, m, "request")["data"] == redactedValue {
+		t.Error("request.data should not be redacted without include_defaults")
+	}
+}
+
+func TestLoadRedactionPolicy(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "hmac.key")
+	if err := os.WriteFile(keyPath, []byte("k"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policyPath := filepath.Join(dir, "policy.json")
+	policy := `{
+		"include_defaults": true,
+		"hmac_key_file": "` + keyPath + `",
+		"allowed_errors": ["unknown role"],
+		"rules": [{"field": "request.client_token_accessor", "action": "hash"}]
+	}`
+	if err := os.WriteFile(policyPath, []byte(policy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadRedactionPolicy(policyPath)
+	if err != nil {
+		t.Fatalf("LoadRedactionPolicy failed: %v", err)
+	}
+
+	m := sampleAuditEntry()
+	m["error"] = "unknown role: payments"
+	p.Apply(m)
+
+	req := block(t, m, "request")
+	if !strings.HasPrefix(req["client_token_accessor"].(string), "hmac-sha256:") {
+		t.Error("policy rule should take precedence over defaults")
+	}
+	if req["data"] != redactedValue {
+		t.Error("default rules should apply when include_defaults is set")
+	}
+	if m["error"] != "unknown role" {
+		t.Errorf("custom allowed error should be preserved, got %v", m["error"])
+	}
+}
+
+func TestRedactionPolicyValidation(t *testing.T) {
+	cases := map[string]RedactionPolicy{
+		"hash without key":        {Rules: []RedactionRule{{Field: "auth.client_token", Action: ActionHash}}},
+		"truncate without length": {Rules: []RedactionRule{{Field: "request.path", Action: ActionTruncate}}},
+		"unknown action":          {Rules: []RedactionRule{{Field: "request.path", Action: "scramble"}}},
+		"empty selector segment":  {Rules: []RedactionRule{{Field: "request..path", Action: ActionMask}}},
+	}
+	for name, p := range cases {
+		if err := p.Init(); err == nil {
+			t.Errorf("%s: Init should fail", name)
+		}
+	}
+}
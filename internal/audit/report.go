@@ -0,0 +1,547 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed reports/*.json
+var builtinReports embed.FS
+
+// DefaultReportTop is the number of rows listed per report table.
+const DefaultReportTop = 20
+
+// ReportRemoteAddress groups report tables by client address, in addition
+// to the comparison dimensions.
+const ReportRemoteAddress = "remote_address"
+
+// ReportTemplate maps the controls of a compliance framework to audit
+// queries. Templates are loaded from JSON definitions.
+type ReportTemplate struct {
+	Name        string          `json:"name"`
+	Title       string          `json:"title"`
+	Framework   string          `json:"framework"`
+	Description string          `json:"description,omitempty"`
+	Controls    []ReportControl `json:"controls"`
+}
+
+// ReportControl is one control and the queries that evidence it.
+type ReportControl struct {
+	ID          string        `json:"id"`
+	Title       string        `json:"title"`
+	Description string        `json:"description,omitempty"`
+	Queries     []ReportQuery `json:"queries"`
+}
+
+// ReportQuery selects the events of one evidence table.
+type ReportQuery struct {
+	Name        string       `json:"name"`
+	Title       string       `json:"title"`
+	Description string       `json:"description,omitempty"`
+	Filter      ReportFilter `json:"filter"`
+	// GroupBy is the table dimension: a comparison dimension or
+	// remote_address. Defaults to actor.
+	GroupBy string `json:"group_by,omitempty"`
+}
+
+// ReportFilter matches any of the values per field (IN), like the any_of
+// tool argument, and drops events matching Exclude. Only responses are
+// counted unless audit_type is set.
+type ReportFilter struct {
+	FieldSet
+	Exclude *FieldSet `json:"exclude,omitempty"`
+	// ErrorClasses keeps failed requests of these classes, e.g.
+	// permission_denied.
+	ErrorClasses []string `json:"error_class,omitempty"`
+}
+
+// reportDimensions are the valid GroupBy values.
+var reportDimensions = append(slices.Clone(compareDimensions), ReportRemoteAddress)
+
+var reportErrorClasses = []string{
+	ErrorClassPermissionDenied, ErrorClassInvalidToken, ErrorClassInvalidCredentials,
+	ErrorClassUnsupportedPath, ErrorClassNamespaceNotFound, ErrorClassRateLimited,
+	ErrorClassInvalidRequest, ErrorClassRedacted, ErrorClassOther,
+}
+
+var reportNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// LoadReportTemplates returns the built-in report templates, overridden or
+// extended by any *.json definitions in dir. An empty dir loads only the
+// built-ins.
+func LoadReportTemplates(dir string) ([]*ReportTemplate, error) {
+	byName := make(map[string]*ReportTemplate)
+
+	builtins, err := loadReportDir(builtinReports, "reports")
+	if err != nil {
+		return nil, fmt.Errorf("invalid built-in report templates: %w", err)
+	}
+	for _, t := range builtins {
+		byName[t.Name] = t
+	}
+
+	if dir != "" {
+		local, err := loadReportDir(os.DirFS(dir), ".")
+		if err != nil {
+			return nil, fmt.Errorf("invalid report templates in %s: %w", dir, err)
+		}
+		for _, t := range local {
+			byName[t.Name] = t
+		}
+	}
+
+	out := make([]*ReportTemplate, 0, len(byName))
+	for _, t := range byName {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func loadReportDir(fsys fs.FS, dir string) ([]*ReportTemplate, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*ReportTemplate, 0, len(files))
+	for _, f := range files {
+		data, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, err
+		}
+		var t ReportTemplate
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, fmt.Errorf("%s: invalid JSON: %w", path.Base(f), err)
+		}
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path.Base(f), err)
+		}
+		out = append(out, &t)
+	}
+	return out, nil
+}
+
+func (t *ReportTemplate) validate() error {
+	if !reportNamePattern.MatchString(t.Name) {
+		return fmt.Errorf("name %q must be lowercase letters, digits, - and _", t.Name)
+	}
+	if len(t.Controls) == 0 {
+		return fmt.Errorf("template %s has no controls", t.Name)
+	}
+	for _, c := range t.Controls {
+		if c.ID == "" || len(c.Queries) == 0 {
+			return fmt.Errorf("template %s: every control needs an id and queries", t.Name)
+		}
+		seen := make(map[string]bool)
+		for _, q := range c.Queries {
+			if !reportNamePattern.MatchString(q.Name) || seen[q.Name] {
+				return fmt.Errorf("control %s: query name %q must be unique, lowercase letters, digits, - and _", c.ID, q.Name)
+			}
+			seen[q.Name] = true
+			if q.GroupBy != "" && !slices.Contains(reportDimensions, q.GroupBy) {
+				return fmt.Errorf("control %s query %s: invalid group_by %q, must be one of %s", c.ID, q.Name, q.GroupBy, strings.Join(reportDimensions, ", "))
+			}
+			if err := q.Filter.validate(); err != nil {
+				return fmt.Errorf("control %s query %s: %w", c.ID, q.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (f *ReportFilter) validate() error {
+	for _, class := range f.ErrorClasses {
+		if !slices.Contains(reportErrorClasses, class) {
+			return fmt.Errorf("invalid error_class %q", class)
+		}
+	}
+	filter := f.searchFilter("")
+	return filter.Validate()
+}
+
+// searchFilter returns the backend filter of f, scoped to a namespace
+// subtree when namespacePrefix is set and f selects no namespaces itself.
+// Callers check the scope separately, since it would otherwise be one more
+// alternative.
+func (f *ReportFilter) searchFilter(namespacePrefix string) SearchFilter {
+	anyOf := f.FieldSet
+	filter := SearchFilter{AnyOf: &anyOf, Exclude: f.Exclude}
+	if len(anyOf.Namespaces) == 0 && len(anyOf.NamespacePrefixes) == 0 {
+		filter.NamespacePrefix = namespacePrefix
+	}
+	if len(anyOf.AuditTypes) == 0 {
+		filter.AuditType = "response"
+	}
+	return filter
+}
+
+// ComplianceQuery selects the period and output of a compliance report.
+type ComplianceQuery struct {
+	Start time.Time
+	End   time.Time
+	// NamespacePrefix scopes every query to a namespace subtree.
+	NamespacePrefix string
+	// Dir is the directory the report bundle is created in.
+	Dir string
+	// Evidence writes the events of each query next to the report, in
+	// EvidenceFormat (ndjson by default, or any export format).
+	Evidence       bool
+	EvidenceFormat string
+	// Top caps the rows per table; MaxEvents the events read per query
+	// (0 means no cap).
+	Top       int
+	MaxEvents int
+}
+
+// ComplianceReport is a rendered report bundle.
+type ComplianceReport struct {
+	Template        string          `json:"template"`
+	Title           string          `json:"title"`
+	Framework       string          `json:"framework"`
+	Description     string          `json:"description,omitempty"`
+	Start           string          `json:"start"`
+	End             string          `json:"end"`
+	NamespacePrefix string          `json:"namespace_prefix,omitempty"`
+	GeneratedAt     string          `json:"generated_at"`
+	Controls        []ControlResult `json:"controls"`
+	// Dir holds report.md, report.html, report.json and the evidence
+	// files; file names in the report are relative to it.
+	Dir          string `json:"dir"`
+	MarkdownFile string `json:"markdown_file"`
+	HTMLFile     string `json:"html_file"`
+	ManifestFile string `json:"manifest_file"`
+	// Truncated is set when MaxEvents cut a query short.
+	Truncated bool `json:"truncated,omitempty"`
+	// Incomplete is set when the deadline was reached; later queries were
+	// not run.
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// ControlResult holds the evidence of one control.
+type ControlResult struct {
+	ID          string              `json:"id"`
+	Title       string              `json:"title"`
+	Description string              `json:"description,omitempty"`
+	Events      int                 `json:"events"`
+	Queries     []ReportQueryResult `json:"queries"`
+}
+
+// ReportQueryResult is one evidence table.
+type ReportQueryResult struct {
+	Name        string      `json:"name"`
+	Title       string      `json:"title"`
+	Description string      `json:"description,omitempty"`
+	Events      int         `json:"events"`
+	Errors      int         `json:"errors"`
+	FirstSeen   string      `json:"first_seen,omitempty"`
+	LastSeen    string      `json:"last_seen,omitempty"`
+	GroupBy     string      `json:"group_by"`
+	Rows        []ReportRow `json:"rows"`
+	// OtherGroups counts the groups beyond the listed rows.
+	OtherGroups int           `json:"other_groups,omitempty"`
+	Evidence    *EvidenceFile `json:"evidence,omitempty"`
+	Truncated   bool          `json:"truncated,omitempty"`
+	// Skipped is set when the deadline was reached before the query ran.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// ReportRow counts the events of one group.
+type ReportRow struct {
+	Key      string `json:"key"`
+	Events   int    `json:"events"`
+	Errors   int    `json:"errors"`
+	LastSeen string `json:"last_seen"`
+}
+
+// EvidenceFile is the export of one query's events.
+type EvidenceFile struct {
+	File   string `json:"file"`
+	Format string `json:"format"`
+	SHA256 string `json:"sha256"`
+}
+
+// reportTable counts the events of one query by group.
+type reportTable struct {
+	groupBy       string
+	errorClasses  []string
+	events        int
+	errors        int
+	first, last   time.Time
+	rows          map[string]*ReportRow
+	rowLast       map[string]time.Time
+	writeEvidence func(*Event) error
+}
+
+func (t *reportTable) add(ev *Event) error {
+	if len(t.errorClasses) > 0 && !slices.Contains(t.errorClasses, dimensionValue(CompareErrorClass, ev)) {
+		return nil
+	}
+	if t.writeEvidence != nil {
+		if err := t.writeEvidence(ev); err != nil {
+			return err
+		}
+	}
+	t.events++
+	failed := ev.Status == "error"
+	if failed {
+		t.errors++
+	}
+	if t.first.IsZero() || ev.Time.Before(t.first) {
+		t.first = ev.Time
+	}
+	if ev.Time.After(t.last) {
+		t.last = ev.Time
+	}
+
+	key := groupKey(t.groupBy, ev)
+	row := t.rows[key]
+	if row == nil {
+		row = &ReportRow{Key: key}
+		t.rows[key] = row
+	}
+	row.Events++
+	if failed {
+		row.Errors++
+	}
+	if ev.Time.After(t.rowLast[key]) {
+		t.rowLast[key] = ev.Time
+	}
+	return nil
+}
+
+// groupKey returns the key of ev in a report dimension.
+func groupKey(dimension string, ev *Event) string {
+	if dimension == ReportRemoteAddress {
+		return noneIfEmpty(ev.RemoteAddr)
+	}
+	return dimensionValue(dimension, ev)
+}
+
+func (t *reportTable) result(q *ReportQuery, top int) ReportQueryResult {
+	res := ReportQueryResult{
+		Name:        q.Name,
+		Title:       q.Title,
+		Description: q.Description,
+		Events:      t.events,
+		Errors:      t.errors,
+		GroupBy:     t.groupBy,
+		Rows:        []ReportRow{},
+	}
+	if t.events > 0 {
+		res.FirstSeen = t.first.UTC().Format(time.RFC3339)
+		res.LastSeen = t.last.UTC().Format(time.RFC3339)
+	}
+	for key, row := range t.rows {
+		row.LastSeen = t.rowLast[key].UTC().Format(time.RFC3339)
+		res.Rows = append(res.Rows, *row)
+	}
+	sort.Slice(res.Rows, func(i, j int) bool {
+		a, b := res.Rows[i], res.Rows[j]
+		if a.Events != b.Events {
+			return a.Events > b.Events
+		}
+		return a.Key < b.Key
+	})
+	if len(res.Rows) > top {
+		res.OtherGroups = len(res.Rows) - top
+		res.Rows = res.Rows[:top]
+	}
+	return res
+}
+
+// runReportQuery counts the events of one query, writing them to an
+// evidence file in dir when file is set.
+func runReportQuery(ctx context.Context, backend Backend, rq *ReportQuery, q *ComplianceQuery, dir, file string) (ReportQueryResult, error) {
+	groupBy := rq.GroupBy
+	if groupBy == "" {
+		groupBy = CompareActor
+	}
+	table := &reportTable{
+		groupBy:      groupBy,
+		errorClasses: rq.Filter.ErrorClasses,
+		rows:         make(map[string]*ReportRow),
+		rowLast:      make(map[string]time.Time),
+	}
+
+	var ew *exportWriter
+	var f *os.File
+	hash := sha256.New()
+	if file != "" {
+		var err error
+		f, err = os.OpenFile(filepath.Join(dir, file), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return ReportQueryResult{}, fmt.Errorf("failed to create evidence file: %w", err)
+		}
+		var columns []string
+		if q.EvidenceFormat == ExportCSV {
+			columns = DefaultExportColumns
+		}
+		ew = newExportWriter(io.MultiWriter(f, hash), q.EvidenceFormat, columns)
+		table.writeEvidence = ew.write
+	}
+
+	filter := rq.Filter.searchFilter(q.NamespacePrefix)
+	filter.Start, filter.End = q.Start, q.End
+	// Backends may not apply every field; matching again is cheap.
+	matcher, err := newSearchFilterMatcher(&filter)
+	if err != nil {
+		return ReportQueryResult{}, err
+	}
+	scope, err := newSearchFilterMatcher(&SearchFilter{NamespacePrefix: q.NamespacePrefix})
+	if err != nil {
+		return ReportQueryResult{}, err
+	}
+	_, truncated, err := exportEvents(ctx, backend, filter, q.MaxEvents, func(ev *Event) error {
+		if !matcher.matches(*ev) || !scope.matches(*ev) {
+			return nil
+		}
+		return table.add(ev)
+	})
+	if ew != nil {
+		if err == nil || errors.Is(err, ErrIncomplete) {
+			if cerr := ew.close(); cerr != nil {
+				err = cerr
+			}
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil && !errors.Is(err, ErrIncomplete) {
+		return ReportQueryResult{}, err
+	}
+
+	res := table.result(rq, q.Top)
+	res.Truncated = truncated
+	if ew != nil {
+		res.Evidence = &EvidenceFile{File: file, Format: q.EvidenceFormat, SHA256: hex.EncodeToString(hash.Sum(nil))}
+	}
+	return res, err
+}
+
+// evidenceFileName names the evidence file of a query.
+func evidenceFileName(controlID, query, format string) string {
+	id := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		}
+		return '_'
+	}, controlID)
+	ext := format
+	if format == FormatOCSF {
+		ext = ExportNDJSON
+	}
+	return "evidence/" + id + "_" + query + "." + ext
+}
+
+// GenerateComplianceReport runs every query of tmpl over [q.Start, q.End]
+// and writes a bundle directory in q.Dir holding the report as Markdown,
+// self-contained HTML and JSON, plus the evidence exports. Ranges of any
+// length are paged through like exports.
+func GenerateComplianceReport(ctx context.Context, backend Backend, tmpl *ReportTemplate, q ComplianceQuery) (*ComplianceReport, error) {
+	if !q.End.After(q.Start) {
+		return nil, fmt.Errorf("report end time must be after start time")
+	}
+	if q.Dir == "" {
+		return nil, fmt.Errorf("export directory is not configured")
+	}
+	q.EvidenceFormat = strings.ToLower(q.EvidenceFormat)
+	if q.EvidenceFormat == "" {
+		q.EvidenceFormat = ExportNDJSON
+	}
+	if q.EvidenceFormat != ExportNDJSON && q.EvidenceFormat != ExportCSV && !IsSIEMFormat(q.EvidenceFormat) {
+		return nil, fmt.Errorf("invalid evidence format %q, must be ndjson, csv, cef, leef or ocsf", q.EvidenceFormat)
+	}
+	if q.Top <= 0 {
+		q.Top = DefaultReportTop
+	}
+
+	generated := time.Now().UTC()
+	dir := filepath.Join(q.Dir, fmt.Sprintf("vault-audit-report-%s-%s", tmpl.Name, generated.Format("20060102T150405.000000000Z")))
+	if err := os.MkdirAll(filepath.Join(dir, "evidence"), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	rep := &ComplianceReport{
+		Template:        tmpl.Name,
+		Title:           tmpl.Title,
+		Framework:       tmpl.Framework,
+		Description:     tmpl.Description,
+		Start:           q.Start.UTC().Format(time.RFC3339),
+		End:             q.End.UTC().Format(time.RFC3339),
+		NamespacePrefix: q.NamespacePrefix,
+		GeneratedAt:     generated.Format(time.RFC3339),
+		Controls:        []ControlResult{},
+		Dir:             dir,
+		MarkdownFile:    filepath.Join(dir, "report.md"),
+		HTMLFile:        filepath.Join(dir, "report.html"),
+		ManifestFile:    filepath.Join(dir, "report.json"),
+	}
+	for i := range tmpl.Controls {
+		c := &tmpl.Controls[i]
+		cr := ControlResult{ID: c.ID, Title: c.Title, Description: c.Description}
+		for j := range c.Queries {
+			rq := &c.Queries[j]
+			if rep.Incomplete {
+				cr.Queries = append(cr.Queries, ReportQueryResult{Name: rq.Name, Title: rq.Title, Description: rq.Description, Rows: []ReportRow{}, Skipped: true})
+				continue
+			}
+			file := ""
+			if q.Evidence {
+				file = evidenceFileName(c.ID, rq.Name, q.EvidenceFormat)
+			}
+			res, err := runReportQuery(ctx, backend, rq, &q, dir, file)
+			if errors.Is(err, ErrIncomplete) {
+				rep.Incomplete = true
+			} else if err != nil {
+				return nil, fmt.Errorf("control %s query %s: %w", c.ID, rq.Name, err)
+			}
+			rep.Truncated = rep.Truncated || res.Truncated
+			cr.Events += res.Events
+			cr.Queries = append(cr.Queries, res)
+		}
+		rep.Controls = append(rep.Controls, cr)
+	}
+
+	if err := writeReportFile(rep.MarkdownFile, renderReportMarkdown(rep)); err != nil {
+		return nil, err
+	}
+	html, err := renderReportHTML(rep)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeReportFile(rep.HTMLFile, html); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeReportFile(rep.ManifestFile, append(data, '\n')); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+func writeReportFile(name string, data []byte) error {
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestLoadReportTemplates(t *testing.T) {
+	templates, err := LoadReportTemplates("")
+	if err != nil {
+		t.Fatalf("LoadReportTemplates failed: %v", err)
+	}
+	var names []string
+	for _, tmpl := range templates {
+		names = append(names, tmpl.Name)
+	}
+	if strings.Join(names, ",") != "iso27001,pci_dss,soc2" {
+		t.Errorf("built-in templates = %v", names)
+	}
+
+	dir := t.TempDir()
+	custom := `{"name": "soc2", "title": "Custom", "framework": "SOC 2", "controls": [
+		{"id": "CC6.1", "title": "Access", "queries": [{"name": "root", "title": "Root", "filter": {"policy": ["root"]}}]}]}`
+	if err := os.WriteFile(filepath.Join(dir, "soc2.json"), []byte(custom), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	templates, err = LoadReportTemplates(dir)
+	if err != nil {
+		t.Fatalf("LoadReportTemplates(dir) failed: %v", err)
+	}
+	if len(templates) != 3 || templates[2].Title != "Custom" {
+		t.Errorf("custom template did not override soc2: %+v", templates[2])
+	}
+
+	for _, bad := range []string{
+		`{"name": "x", "controls": []}`,
+		`{"name": "x", "controls": [{"id": "1", "queries": [{"name": "q", "group_by": "color"}]}]}`,
+		`{"name": "x", "controls": [{"id": "1", "queries": [{"name": "q", "filter": {"error_class": ["nope"]}}]}]}`,
+		`{"name": "x", "controls": [{"id": "1", "queries": [{"name": "q", "filter": {"path_regex": ["("]}}]}]}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, "bad.json"), []byte(bad), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadReportTemplates(dir); err == nil {
+			t.Errorf("template %s should be rejected", bad)
+		}
+	}
+}
+
+func reportEvents(end time.Time) []Event {
+	at := func(h int) time.Time { return end.Add(-time.Duration(h) * time.Hour) }
+	denied := map[string]any{"error": "1 error occurred:\n\t* permission denied\n\n"}
+	return []Event{
+		{Time: at(1), AuditType: "response", Display: "root", Operation: "update", Path: "sys/policy/payments", Policies: []string{"root"}},
+		{Time: at(2), AuditType: "request", Display: "root", Operation: "update", Path: "sys/policy/payments", Policies: []string{"root"}},
+		{Time: at(3), AuditType: "response", Display: "oidc-alice", Operation: "update", Path: "sys/audit/file", Policies: []string{"admin"}},
+		{Time: at(4), AuditType: "response", Operation: "update", Path: "auth/userpass/login/bob", Status: "error", RemoteAddr: "10.0.0.9",
+			Raw: map[string]any{"error": "invalid username or password"}},
+		{Time: at(5), AuditType: "response", Operation: "update", Path: "auth/userpass/login/bob", Status: "error", RemoteAddr: "10.0.0.9",
+			Raw: map[string]any{"error": "invalid username or password"}},
+		{Time: at(6), AuditType: "response", Display: "approle-<app>", Operation: "read", Path: "secret/data/payments", Status: "error", Raw: denied},
+		{Time: at(7), AuditType: "response", Display: "approle-app", Operation: "read", Path: "sys/policy/payments", Policies: []string{"app"}},
+		// Outside the namespace scope.
+		{Time: at(8), AuditType: "response", Namespace: "other/", Display: "root", Operation: "update", Path: "sys/mounts/kv", Policies: []string{"root"}},
+		{Time: at(9), AuditType: "response", Display: "oidc-alice", Operation: "delete", Path: "sys/audit/syslog", Policies: []string{"admin"}},
+		// Outside the period.
+		{Time: at(200), AuditType: "response", Display: "root", Operation: "update", Path: "sys/audit/file", Policies: []string{"root"}},
+	}
+}
+
+func findQuery(t *testing.T, rep *ComplianceReport, control, query string) ReportQueryResult {
+	t.Helper()
+	for _, c := range rep.Controls {
+		for _, q := range c.Queries {
+			if c.ID == control && q.Name == query {
+				return q
+			}
+		}
+	}
+	t.Fatalf("no query %s/%s", control, query)
+	return ReportQueryResult{}
+}
+
+func TestGenerateComplianceReport(t *testing.T) {
+	templates, err := LoadReportTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	soc2 := templates[2]
+	end := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	rep, err := GenerateComplianceReport(t.Context(), &timedBackend{events: reportEvents(end), limits: QueryLimits{MaxLimit: 3, MaxDays: 1}}, soc2, ComplianceQuery{
+		Start:           end.Add(-72 * time.Hour),
+		End:             end,
+		NamespacePrefix: "",
+		Dir:             t.TempDir(),
+		Evidence:        true,
+		Top:             1,
+	})
+	if err != nil {
+		t.Fatalf("GenerateComplianceReport failed: %v", err)
+	}
+
+	root := findQuery(t, rep, "CC6.1", "root_token_use")
+	if root.Events != 2 || root.Rows[0].Key != "root" || root.Rows[0].Events != 2 {
+		t.Errorf("root token use = %+v", root)
+	}
+	if q := findQuery(t, rep, "CC6.1", "permission_denied"); q.Events != 1 || q.Rows[0].Key != "approle-<app>" {
+		t.Errorf("permission denied = %+v", q)
+	}
+	if q := findQuery(t, rep, "CC6.2", "policy_changes"); q.Events != 1 {
+		t.Errorf("policy changes = %+v, want only the update", q)
+	}
+	failed := findQuery(t, rep, "CC7.2", "failed_authentications")
+	if failed.Events != 2 || failed.Errors != 2 || failed.Rows[0].Key != "10.0.0.9" {
+		t.Errorf("failed authentications = %+v", failed)
+	}
+	if q := findQuery(t, rep, "CC7.2", "audit_device_changes"); q.Events != 2 || q.LastSeen != "2026-03-09T21:00:00Z" || q.OtherGroups != 1 {
+		t.Errorf("audit device changes = %+v", q)
+	}
+	if q := findQuery(t, rep, "CC8.1", "system_config_changes"); q.Events != 1 {
+		t.Errorf("system config changes = %+v", q)
+	}
+	if rep.Controls[0].Events != 3 {
+		t.Errorf("CC6.1 events = %d, want 3", rep.Controls[0].Events)
+	}
+
+	data, err := os.ReadFile(filepath.Join(rep.Dir, root.Evidence.File))
+	if err != nil {
+		t.Fatalf("evidence file: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != root.Evidence.SHA256 || strings.Count(string(data), "\n") != 2 {
+		t.Errorf("evidence %s does not match its hash or holds the wrong events:\n%s", root.Evidence.File, data)
+	}
+
+	md, err := os.ReadFile(rep.MarkdownFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# SOC 2 Vault access and change evidence",
+		"| Period | 2026-03-07T00:00:00Z to 2026-03-10T00:00:00Z |",
+		"| CC6.1 | Logical access security | 3 |",
+		"### Failed logins\n\n2 events (2 failed)",
+		"| Remote address | Events | Failed | Last seen |",
+		"Evidence: [`evidence/CC6.1_root_token_use.ndjson`]",
+		"_and 1 more_",
+	} {
+		if !strings.Contains(string(md), want) {
+			t.Errorf("markdown lacks %q:\n%s", want, md)
+		}
+	}
+	html, err := os.ReadFile(rep.HTMLFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), "approle-&lt;app&gt;") || !strings.Contains(string(html), `<h2 id="CC7.2">`) {
+		t.Errorf("html report is not escaped or lacks controls:\n%s", html)
+	}
+	if _, err := os.Stat(rep.ManifestFile); err != nil {
+		t.Errorf("manifest: %v", err)
+	}
+
+	scoped, err := GenerateComplianceReport(t.Context(), &timedBackend{events: reportEvents(end), limits: QueryLimits{MaxLimit: 3, MaxDays: 1}}, soc2, ComplianceQuery{
+		Start:           end.Add(-72 * time.Hour),
+		End:             end,
+		NamespacePrefix: "other/",
+		Dir:             t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("scoped report failed: %v", err)
+	}
+	if q := findQuery(t, scoped, "CC6.1", "root_token_use"); q.Events != 1 || q.Evidence != nil {
+		t.Errorf("scoped root token use = %+v", q)
+	}
+}
+
+func TestComplianceReportTool(t *testing.T) {
+	start, _, err := ParseQuarter("last", time.Now().UTC(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewService(&timedBackend{events: reportEvents(start.Add(10 * 24 * time.Hour))})
+	svc.SetExportDir(t.TempDir())
+	session := connectService(t, svc)
+
+	for _, args := range []map[string]any{
+		{"template": "hipaa"},
+		{"template": "soc2", "quarter": "2026-Q1", "last": "7d"},
+	} {
+		res, err := session.CallTool(t.Context(), &mcp.CallToolParams{Name: "audit.compliance_report", Arguments: args})
+		if err != nil {
+			t.Fatalf("CallTool failed: %v", err)
+		}
+		if !res.IsError {
+			t.Errorf("compliance_report %v should fail", args)
+		}
+	}
+
+	res, err := session.CallTool(t.Context(), &mcp.CallToolParams{
+		Name:      "audit.compliance_report",
+		Arguments: map[string]any{"template": "pci_dss", "evidence": false},
+	})
+	if err != nil || res.IsError {
+		t.Fatalf("compliance_report failed: %v %v", err, res)
+	}
+	var rep ComplianceReport
+	raw, _ := json.Marshal(res.StructuredContent)
+	if err := json.Unmarshal(raw, &rep); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if rep.Start != start.Format(time.RFC3339) || rep.Framework != "PCI DSS v4.0" {
+		t.Errorf("report = %s from %s", rep.Framework, rep.Start)
+	}
+	if q := findQuery(t, &rep, "10.2.1.4", "failed_authentications"); q.Events != 2 || q.Evidence != nil {
+		t.Errorf("failed authentications = %+v", q)
+	}
+	entries, err := os.ReadDir(filepath.Join(rep.Dir, "evidence"))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("evidence dir = %v, %v; want empty", entries, err)
+	}
+}
@@ -0,0 +1,171 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// renderReportMarkdown renders a compliance report as Markdown.
+func renderReportMarkdown(rep *ComplianceReport) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", rep.Title)
+	if rep.Description != "" {
+		fmt.Fprintf(&buf, "%s\n\n", rep.Description)
+	}
+	buf.WriteString("| | |\n|---|---|\n")
+	fmt.Fprintf(&buf, "| Framework | %s |\n", markdownCell(rep.Framework))
+	fmt.Fprintf(&buf, "| Period | %s to %s |\n", rep.Start, rep.End)
+	fmt.Fprintf(&buf, "| Scope | %s |\n", markdownCell(reportScope(rep)))
+	fmt.Fprintf(&buf, "| Generated | %s |\n\n", rep.GeneratedAt)
+	for _, note := range reportNotes(rep) {
+		fmt.Fprintf(&buf, "> **Note:** %s\n\n", note)
+	}
+
+	buf.WriteString("## Summary\n\n| Control | Title | Events |\n|---|---|---:|\n")
+	for _, c := range rep.Controls {
+		fmt.Fprintf(&buf, "| %s | %s | %d |\n", markdownCell(c.ID), markdownCell(c.Title), c.Events)
+	}
+
+	for _, c := range rep.Controls {
+		fmt.Fprintf(&buf, "\n## %s %s\n", c.ID, c.Title)
+		if c.Description != "" {
+			fmt.Fprintf(&buf, "\n%s\n", c.Description)
+		}
+		for _, q := range c.Queries {
+			fmt.Fprintf(&buf, "\n### %s\n\n", q.Title)
+			if q.Description != "" {
+				fmt.Fprintf(&buf, "%s\n\n", q.Description)
+			}
+			if q.Skipped {
+				buf.WriteString("Not run: the query deadline was reached.\n")
+				continue
+			}
+			fmt.Fprintf(&buf, "%s\n", querySummary(q))
+			if q.Evidence != nil {
+				fmt.Fprintf(&buf, "\nEvidence: [`%s`](%s) (%s, SHA-256 `%s`)\n", q.Evidence.File, q.Evidence.File, q.Evidence.Format, q.Evidence.SHA256)
+			}
+			if len(q.Rows) == 0 {
+				continue
+			}
+			fmt.Fprintf(&buf, "\n| %s | Events | Failed | Last seen |\n|---|---:|---:|---|\n", groupTitle(q.GroupBy))
+			for _, r := range q.Rows {
+				fmt.Fprintf(&buf, "| %s | %d | %d | %s |\n", markdownCell(r.Key), r.Events, r.Errors, r.LastSeen)
+			}
+			if q.OtherGroups > 0 {
+				fmt.Fprintf(&buf, "\n_and %d more_\n", q.OtherGroups)
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// markdownCell escapes a value for a Markdown table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func reportScope(rep *ComplianceReport) string {
+	if rep.NamespacePrefix == "" {
+		return "all namespaces"
+	}
+	return "namespace " + rep.NamespacePrefix + " and its descendants"
+}
+
+func reportNotes(rep *ComplianceReport) []string {
+	var notes []string
+	if rep.Incomplete {
+		notes = append(notes, "the query deadline was reached; the counts are incomplete and later queries were not run.")
+	}
+	if rep.Truncated {
+		notes = append(notes, "some queries stopped at the event limit; their counts are lower bounds.")
+	}
+	return notes
+}
+
+func querySummary(q ReportQueryResult) string {
+	if q.Events == 0 {
+		return "No events in the period."
+	}
+	s := fmt.Sprintf("%d events (%d failed), first seen %s, last seen %s.", q.Events, q.Errors, q.FirstSeen, q.LastSeen)
+	if q.Truncated {
+		s += " Stopped at the event limit."
+	}
+	return s
+}
+
+// groupTitle is the table heading of a group dimension.
+func groupTitle(dimension string) string {
+	s := strings.ReplaceAll(dimension, "_", " ")
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+var reportHTML = template.Must(template.New("report").Funcs(template.FuncMap{
+	"scope":   reportScope,
+	"notes":   reportNotes,
+	"summary": querySummary,
+	"group":   groupTitle,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2328; max-width: 60rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.5; }
+h1 { border-bottom: 2px solid #d0d7de; padding-bottom: .3rem; }
+h2 { margin-top: 2.5rem; border-bottom: 1px solid #d0d7de; padding-bottom: .2rem; }
+table { border-collapse: collapse; margin: .75rem 0; }
+th, td { border: 1px solid #d0d7de; padding: .3rem .7rem; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+code { font-size: .85em; background: #f6f8fa; padding: .1rem .3rem; border-radius: 4px; word-break: break-all; }
+.note { background: #fff8c5; border: 1px solid #d4a72c; padding: .5rem .8rem; border-radius: 6px; }
+.muted { color: #59636e; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{with .Description}}<p>{{.}}</p>{{end}}
+<table>
+<tr><th>Framework</th><td>{{.Framework}}</td></tr>
+<tr><th>Period</th><td>{{.Start}} to {{.End}}</td></tr>
+<tr><th>Scope</th><td>{{scope .}}</td></tr>
+<tr><th>Generated</th><td>{{.GeneratedAt}}</td></tr>
+</table>
+{{range notes .}}<p class="note"><strong>Note:</strong> {{.}}</p>
+{{end}}
+<h2>Summary</h2>
+<table>
+<tr><th>Control</th><th>Title</th><th>Events</th></tr>
+{{range .Controls}}<tr><td><a href="#{{.ID}}">{{.ID}}</a></td><td>{{.Title}}</td><td class="num">{{.Events}}</td></tr>
+{{end}}</table>
+{{range .Controls}}
+<h2 id="{{.ID}}">{{.ID}} {{.Title}}</h2>
+{{with .Description}}<p>{{.}}</p>{{end}}
+{{range .Queries}}
+<h3>{{.Title}}</h3>
+{{with .Description}}<p>{{.}}</p>{{end}}
+{{if .Skipped}}<p class="muted">Not run: the query deadline was reached.</p>
+{{else}}<p>{{summary .}}</p>
+{{with .Evidence}}<p>Evidence: <a href="{{.File}}"><code>{{.File}}</code></a> ({{.Format}}, SHA-256 <code>{{.SHA256}}</code>)</p>{{end}}
+{{if .Rows}}<table>
+<tr><th>{{group .GroupBy}}</th><th>Events</th><th>Failed</th><th>Last seen</th></tr>
+{{range .Rows}}<tr><td>{{.Key}}</td><td class="num">{{.Events}}</td><td class="num">{{.Errors}}</td><td>{{.LastSeen}}</td></tr>
+{{end}}</table>
+{{if .OtherGroups}}<p class="muted">and {{.OtherGroups}} more</p>{{end}}
+{{end}}{{end}}{{end}}{{end}}
+</body>
+</html>
+`))
+
+// renderReportHTML renders a compliance report as a self-contained HTML
+// page.
+func renderReportHTML(rep *ComplianceReport) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := reportHTML.Execute(&buf, rep); err != nil {
+		return nil, fmt.Errorf("failed to render report: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,292 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"vault-audit-mcp/internal/loki"
+)
+
+// Resource URIs exposed by the server.
+const (
+	resourceScheme        = "vault-audit://"
+	eventResourcePrefix   = resourceScheme + "event/"
+	summaryResourcePrefix = resourceScheme + "summary/"
+	queriesResourceURI    = resourceScheme + "queries"
+)
+
+// Bounds for the in-memory result store.
+const (
+	maxStoredSummaries = 50
+	maxStoredRequests  = 1000
+)
+
+// SavedQuery describes a search whose summary can be re-opened as a resource.
+type SavedQuery struct {
+	QueryHash   string     `json:"query_hash"`
+	Tool        string     `json:"tool"`
+	Args        SearchArgs `json:"args"`
+	StartTime   string     `json:"start_time"`
+	EndTime     string     `json:"end_time"`
+	TotalEvents int        `json:"total_events"`
+	CreatedAt   time.Time  `json:"created_at"`
+	SummaryURI  string     `json:"summary_uri"`
+}
+
+// resultStore keeps recent search summaries and redacted events in memory so
+// MCP clients can re-open them as resources without re-querying the backend.
+// Entries are kept per tenant, so a result is only served to callers naming
+// the tenant it was read from. Both maps are bounded and evict their oldest
+// entries first.
+type resultStore struct {
+	mu sync.Mutex
+
+	queries      map[storeKey]*SavedQuery
+	summaries    map[storeKey]*SearchSummary
+	queryOrder   []storeKey
+	events       map[storeKey][]Event // tenant and request ID -> events
+	requestOrder []storeKey
+}
+
+// storeKey identifies a stored result: a query hash or request ID read from
+// a tenant, which is empty for the configured default.
+type storeKey struct {
+	tenant string
+	id     string
+}
+
+func newResultStore() *resultStore {
+	return &resultStore{
+		queries:   make(map[storeKey]*SavedQuery),
+		summaries: make(map[storeKey]*SearchSummary),
+		events:    make(map[storeKey][]Event),
+	}
+}
+
+// resourceURI returns the URI of a stored result, naming the tenant it was
+// read from.
+func resourceURI(prefix, id, tenant string) string {
+	uri := prefix + url.PathEscape(id)
+	if tenant != "" {
+		uri += "?" + url.Values{"tenant": {tenant}}.Encode()
+	}
+	return uri
+}
+
+// parseResourceURI returns the ID and tenant of a resource URI built by
+// resourceURI.
+func parseResourceURI(uri, prefix string) (id, tenant string, ok bool) {
+	rest, found := strings.CutPrefix(uri, prefix)
+	if !found {
+		return "", "", false
+	}
+	rest, query, _ := strings.Cut(rest, "?")
+	id, err := url.PathUnescape(rest)
+	if err != nil {
+		return "", "", false
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", "", false
+	}
+	return id, values.Get("tenant"), true
+}
+
+// queryHash returns a stable short hash of a normalized search and its
+// resolved time window.
+func queryHash(tool string, args SearchArgs, start, end time.Time) string {
+	args.StartRFC3339 = start.UTC().Format(time.RFC3339)
+	args.EndRFC3339 = end.UTC().Format(time.RFC3339)
+	args.Namespace = normalizeNamespace(args.Namespace)
+	b, _ := json.Marshal(struct {
+		Tool string     `json:"tool"`
+		Args SearchArgs `json:"args"`
+	}{tool, args})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+// saveSummary records a search summary of tenant under its query hash.
+func (s *resultStore) saveSummary(tenant string, q *SavedQuery, summary *SearchSummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := storeKey{tenant, q.QueryHash}
+	if _, exists := s.queries[key]; !exists {
+		s.queryOrder = append(s.queryOrder, key)
+	}
+	s.queries[key] = q
+	s.summaries[key] = summary
+
+	for len(s.queryOrder) > maxStoredSummaries {
+		oldest := s.queryOrder[0]
+		s.queryOrder = s.queryOrder[1:]
+		delete(s.queries, oldest)
+		delete(s.summaries, oldest)
+	}
+}
+
+// rememberEvents records redacted events of tenant by request ID, replacing
+// any events previously stored for the same request.
+func (s *resultStore) rememberEvents(tenant string, events []Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byRequest := make(map[storeKey][]Event)
+	for _, ev := range events {
+		if ev.RequestID != "" {
+			key := storeKey{tenant, ev.RequestID}
+			byRequest[key] = append(byRequest[key], ev)
+		}
+	}
+	for key, evs := range byRequest {
+		if _, exists := s.events[key]; !exists {
+			s.requestOrder = append(s.requestOrder, key)
+		}
+		s.events[key] = evs
+	}
+
+	for len(s.requestOrder) > maxStoredRequests {
+		oldest := s.requestOrder[0]
+		s.requestOrder = s.requestOrder[1:]
+		delete(s.events, oldest)
+	}
+}
+
+func (s *resultStore) summary(tenant, hash string) (*SearchSummary, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sum, ok := s.summaries[storeKey{tenant, hash}]
+	return sum, ok
+}
+
+func (s *resultStore) eventsFor(tenant, requestID string) ([]Event, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	evs, ok := s.events[storeKey{tenant, requestID}]
+	return evs, ok
+}
+
+// savedQueries returns the saved queries of tenant, newest first.
+func (s *resultStore) savedQueries(tenant string) []SavedQuery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]SavedQuery, 0, len(s.queryOrder))
+	for i := len(s.queryOrder) - 1; i >= 0; i-- {
+		if key := s.queryOrder[i]; key.tenant == tenant {
+			out = append(out, *s.queries[key])
+		}
+	}
+	return out
+}
+
+// AddResources registers the audit MCP resources with the server. Each
+// accepts an optional tenant query parameter, e.g.
+// vault-audit://event/<request_id>?tenant=team-a, naming the Loki tenant the
+// result was read from.
+func (s *Service) AddResources(server *mcp.Server) {
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		Name:        "audit-event",
+		Title:       "Vault audit event",
+		Description: "Redacted audit events (request and response) for a Vault request ID.",
+		MIMEType:    "application/json",
+		URITemplate: eventResourcePrefix + "{request_id}{?tenant}",
+	}, s.readEventResource)
+
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		Name:        "audit-search-summary",
+		Title:       "Audit search summary",
+		Description: "A recent audit.search_events summary, addressed by the query_hash returned with the search.",
+		MIMEType:    "application/json",
+		URITemplate: summaryResourcePrefix + "{query_hash}{?tenant}",
+	}, s.readSummaryResource)
+
+	server.AddResource(&mcp.Resource{
+		Name:        "audit-saved-queries",
+		Title:       "Saved audit queries",
+		Description: "Recent searches with their arguments, time range and summary resource URI, newest first.",
+		MIMEType:    "application/json",
+		URI:         queriesResourceURI,
+	}, s.readQueriesResource)
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		Name:        "audit-tenant-saved-queries",
+		Title:       "Saved audit queries for a tenant",
+		Description: "Recent searches of one Loki tenant, newest first.",
+		MIMEType:    "application/json",
+		URITemplate: queriesResourceURI + "{?tenant}",
+	}, s.readQueriesResource)
+}
+
+func (s *Service) readEventResource(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+	requestID, tenant, ok := parseResourceURI(uri, eventResourcePrefix)
+	if !ok || requestID == "" {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+
+	events, ok := s.store.eventsFor(tenant, requestID)
+	if !ok {
+		// Not seen by this server yet; fall back to the same lookback as
+		// audit.get_event_details, in the tenant the URI names.
+		var err error
+		events, err = s.backend.Trace(loki.WithTenant(ctx, tenant), &TraceFilter{
+			Start:     time.Now().UTC().Add(-24 * time.Hour),
+			End:       time.Now().UTC(),
+			Limit:     100,
+			RequestID: requestID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(events) == 0 {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+		s.store.rememberEvents(tenant, events)
+	}
+	return jsonResource(uri, events)
+}
+
+func (s *Service) readSummaryResource(_ context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+	hash, tenant, ok := parseResourceURI(uri, summaryResourcePrefix)
+	if !ok {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	summary, ok := s.store.summary(tenant, hash)
+	if !ok {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	return jsonResource(uri, summary)
+}
+
+func (s *Service) readQueriesResource(_ context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+	_, tenant, ok := parseResourceURI(uri, queriesResourceURI)
+	if !ok {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	return jsonResource(uri, s.store.savedQueries(tenant))
+}
+
+func jsonResource(uri string, v any) (*mcp.ReadResourceResult, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode resource %s: %w", uri, err)
+	}
+	return &mcp.ReadResourceResult{
+		Contents: []*mcp.ResourceContents{{
+			URI:      uri,
+			MIMEType: "application/json",
+			Text:     string(b),
+		}},
+	}, nil
+}
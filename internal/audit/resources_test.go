@@ -0,0 +1,204 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"vault-audit-mcp/internal/loki"
+)
+
+// stubBackend returns a fixed set of events and records trace calls.
+type stubBackend struct {
+	events       []Event
+	traceCalls   int
+	traceTenants []string
+}
+
+func (b *stubBackend) Search(ctx context.Context, filter *SearchFilter) ([]Event, error) {
+	return b.events, nil
+}
+
+func (b *stubBackend) Aggregate(ctx context.Context, filter *AggregateFilter, by string) ([]Bucket, error) {
+	return nil, nil
+}
+
+func (b *stubBackend) Trace(ctx context.Context, filter *TraceFilter) ([]Event, error) {
+	b.traceCalls++
+	b.traceTenants = append(b.traceTenants, loki.TenantFromContext(ctx))
+	var out []Event
+	for _, ev := range b.events {
+		if ev.RequestID == filter.RequestID {
+			out = append(out, ev)
+		}
+	}
+	return out, nil
+}
+
+// connectService serves svc over in-memory transports and returns a client session.
+func connectService(t *testing.T, svc *Service) *mcp.ClientSession {
+	t.Helper()
+	server := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "v0"}, nil)
+	svc.AddTools(server)
+	svc.AddResources(server)
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	ctx := context.Background()
+	if _, err := server.Connect(ctx, serverTransport, nil); err != nil {
+		t.Fatalf("server connect failed: %v", err)
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "v0"}, nil)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("client connect failed: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+func TestSearchSummaryAndEventResources(t *testing.T) {
+	backend := &stubBackend{events: []Event{{
+		Time:      time.Now().UTC(),
+		RequestID: "req-42",
+		Operation: "read",
+		Path:      "secret/data/app",
+		Status:    "ok",
+		Raw:       map[string]any{"type": "response"},
+	}}}
+	session := connectService(t, NewService(backend))
+	ctx := context.Background()
+
+	res, err := session.CallTool(ctx, &mcp.CallToolParams{
+		Name:      "audit.search_events",
+		Arguments: map[string]any{"operation": "read"},
+	})
+	if err != nil || res.IsError {
+		t.Fatalf("search_events failed: %v %+v", err, res)
+	}
+	var summary SearchSummary
+	raw, _ := json.Marshal(res.StructuredContent)
+	if err := json.Unmarshal(raw, &summary); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if summary.ResourceURI == "" || summary.QueryHash == "" {
+		t.Fatal("search summary should carry a resource URI")
+	}
+
+	read, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: summary.ResourceURI})
+	if err != nil {
+		t.Fatalf("read summary resource: %v", err)
+	}
+	var reopened SearchSummary
+	if err := json.Unmarshal([]byte(read.Contents[0].Text), &reopened); err != nil {
+		t.Fatalf("decode summary resource: %v", err)
+	}
+	if reopened.TotalEvents != 1 {
+		t.Errorf("reopened summary total = %d, want 1", reopened.TotalEvents)
+	}
+
+	read, err = session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "vault-audit://event/req-42"})
+	if err != nil {
+		t.Fatalf("read event resource: %v", err)
+	}
+	var events []Event
+	if err := json.Unmarshal([]byte(read.Contents[0].Text), &events); err != nil || len(events) != 1 {
+		t.Fatalf("event resource = %s, err %v", read.Contents[0].Text, err)
+	}
+	if backend.traceCalls != 0 {
+		t.Error("event seen by a search should be served without querying the backend")
+	}
+
+	read, err = session.ReadResource(ctx, &mcp.ReadResourceParams{URI: queriesResourceURI})
+	if err != nil {
+		t.Fatalf("read queries resource: %v", err)
+	}
+	var queries []SavedQuery
+	if err := json.Unmarshal([]byte(read.Contents[0].Text), &queries); err != nil || len(queries) != 1 {
+		t.Fatalf("queries resource = %s, err %v", read.Contents[0].Text, err)
+	}
+	if queries[0].Args.Operation != "read" {
+		t.Errorf("saved query operation = %q, want read", queries[0].Args.Operation)
+	}
+}
+
+func TestResourcesAreScopedByTenant(t *testing.T) {
+	backend := &stubBackend{events: []Event{{Time: time.Now().UTC(), RequestID: "req-42", Operation: "read"}}}
+	session := connectService(t, NewService(backend))
+	ctx := context.Background()
+
+	res, err := session.CallTool(ctx, &mcp.CallToolParams{
+		Name:      "audit.search_events",
+		Arguments: map[string]any{"tenant": "team-a"},
+	})
+	if err != nil || res.IsError {
+		t.Fatalf("search_events failed: %v %+v", err, res)
+	}
+	var summary SearchSummary
+	raw, _ := json.Marshal(res.StructuredContent)
+	if err := json.Unmarshal(raw, &summary); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if want := "vault-audit://summary/" + summary.QueryHash + "?tenant=team-a"; summary.ResourceURI != want {
+		t.Fatalf("resource URI = %q, want %q", summary.ResourceURI, want)
+	}
+	if _, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: summary.ResourceURI}); err != nil {
+		t.Errorf("read summary resource: %v", err)
+	}
+	if _, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "vault-audit://summary/" + summary.QueryHash}); err == nil {
+		t.Error("a summary of team-a should not be served without its tenant")
+	}
+
+	read, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "vault-audit://queries?tenant=team-a"})
+	if err != nil {
+		t.Fatalf("read queries resource: %v", err)
+	}
+	var queries []SavedQuery
+	if err := json.Unmarshal([]byte(read.Contents[0].Text), &queries); err != nil || len(queries) != 1 {
+		t.Errorf("team-a queries = %s, err %v", read.Contents[0].Text, err)
+	}
+	read, err = session.ReadResource(ctx, &mcp.ReadResourceParams{URI: queriesResourceURI})
+	if err != nil {
+		t.Fatalf("read queries resource: %v", err)
+	}
+	if err := json.Unmarshal([]byte(read.Contents[0].Text), &queries); err != nil || len(queries) != 0 {
+		t.Errorf("default tenant queries = %s, err %v", read.Contents[0].Text, err)
+	}
+
+	// The stored events belong to team-a; other tenants are traced in
+	// their own tenant.
+	for _, uri := range []string{
+		"vault-audit://event/req-42?tenant=team-a",
+		"vault-audit://event/req-42?tenant=team-b",
+		"vault-audit://event/req-42",
+	} {
+		if _, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: uri}); err != nil {
+			t.Errorf("read %s: %v", uri, err)
+		}
+	}
+	if want := []string{"team-b", ""}; !reflect.DeepEqual(backend.traceTenants, want) {
+		t.Errorf("trace tenants = %q, want %q", backend.traceTenants, want)
+	}
+}
+
+func TestEventResourceNotFound(t *testing.T) {
+	session := connectService(t, NewService(&stubBackend{}))
+	_, err := session.ReadResource(context.Background(), &mcp.ReadResourceParams{URI: "vault-audit://event/missing"})
+	if err == nil {
+		t.Fatal("reading an unknown event should fail")
+	}
+}
+
+func TestResultStoreEvictsOldest(t *testing.T) {
+	store := newResultStore()
+	for i := 0; i < maxStoredSummaries+5; i++ {
+		hash := queryHash("audit.search_events", SearchArgs{Limit: i}, time.Unix(0, 0), time.Unix(60, 0))
+		store.saveSummary("", &SavedQuery{QueryHash: hash}, &SearchSummary{})
+	}
+	if got := len(store.savedQueries("")); got != maxStoredSummaries {
+		t.Errorf("stored %d summaries, want %d", got, maxStoredSummaries)
+	}
+}
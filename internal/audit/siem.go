@@ -0,0 +1,412 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// SIEM output formats, accepted by audit.search_events and Export.
+const (
+	FormatCEF  = "cef"
+	FormatLEEF = "leef"
+	FormatOCSF = "ocsf"
+)
+
+const (
+	siemVendor  = "HashiCorp"
+	siemProduct = "Vault"
+
+	// ocsfVersion is the OCSF schema version the OCSF mapping follows.
+	ocsfVersion = "1.1.0"
+)
+
+// IsSIEMFormat reports whether format is one of the SIEM output formats.
+func IsSIEMFormat(format string) bool {
+	switch format {
+	case FormatCEF, FormatLEEF, FormatOCSF:
+		return true
+	}
+	return false
+}
+
+// FormatSIEM analyzes ev and renders it as a single CEF, LEEF or OCSF (JSON)
+// record. ev is not modified.
+func FormatSIEM(format string, ev *Event) (string, error) {
+	e := *ev
+	an := AnalyzeEvent(&e)
+	switch format {
+	case FormatCEF:
+		return FormatCEFEvent(&e, an), nil
+	case FormatLEEF:
+		return FormatLEEFEvent(&e, an), nil
+	case FormatOCSF:
+		data, err := json.Marshal(ToOCSF(&e, an))
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return "", fmt.Errorf("invalid SIEM format %q, must be cef, leef or ocsf", format)
+}
+
+// siemSeverity maps EventSeverity to the 0-10 scale used by CEF and LEEF.
+func siemSeverity(s EventSeverity) int {
+	switch s {
+	case SeverityCritical:
+		return 10
+	case SeverityHigh:
+		return 8
+	case SeverityMedium:
+		return 5
+	case SeverityLow:
+		return 3
+	}
+	return 1
+}
+
+func siemOutcome(status string) string {
+	switch status {
+	case "ok":
+		return "success"
+	case "error":
+		return "failure"
+	}
+	return "unknown"
+}
+
+// siemField is one key=value pair of a CEF or LEEF extension; empty values
+// are omitted.
+type siemField struct {
+	key, value string
+}
+
+// siemFields lists the extension fields shared by CEF and LEEF, in output
+// order, using each format's key names.
+func siemFields(ev *Event, an *EventAnalysis, leef bool) []siemField {
+	key := func(cef, leefKey string) string {
+		if leef {
+			return leefKey
+		}
+		return cef
+	}
+	src := ""
+	if net.ParseIP(ev.RemoteAddr) != nil {
+		src = ev.RemoteAddr
+	}
+	fields := []siemField{
+		{key("rt", "devTime"), strconv.FormatInt(ev.Time.UnixMilli(), 10)},
+		{key("act", "action"), ev.Operation},
+		{key("outcome", "outcome"), siemOutcome(ev.Status)},
+		{key("request", "resource"), ev.Path},
+		{key("suser", "usrName"), ev.Display},
+		{key("suid", "identSrc"), ev.EntityID},
+		{"src", src},
+		{key("externalId", "requestId"), ev.RequestID},
+		{key("cat", "cat"), string(an.Category)},
+		{key("msg", "msg"), an.Description},
+	}
+	if leef {
+		fields = append(fields,
+			siemField{"sev", strconv.Itoa(siemSeverity(an.Severity))},
+			siemField{"vaultNamespace", ev.Namespace},
+			siemField{"vaultMountType", ev.MountType},
+			siemField{"vaultMountClass", ev.MountClass},
+			siemField{"vaultAuditType", ev.AuditType},
+			siemField{"vaultPolicies", strings.Join(ev.Policies, ",")},
+		)
+	} else {
+		// CEF has no Vault-specific keys; use the labelled custom strings.
+		for i, cs := range []siemField{
+			{"vaultNamespace", ev.Namespace},
+			{"vaultMountType", ev.MountType},
+			{"vaultMountClass", ev.MountClass},
+			{"vaultAuditType", ev.AuditType},
+			{"vaultPolicies", strings.Join(ev.Policies, ",")},
+		} {
+			if cs.value != "" {
+				n := strconv.Itoa(i + 1)
+				fields = append(fields, siemField{"cs" + n + "Label", cs.key}, siemField{"cs" + n, cs.value})
+			}
+		}
+	}
+	if an.IsAnomaly {
+		fields = append(fields, siemField{key("reason", "reason"), an.AnomalyReason})
+	}
+	return fields
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	leefHeaderEscaper   = strings.NewReplacer(`|`, `\|`, "\n", " ", "\r", " ", "\t", " ")
+	leefValueEscaper    = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+)
+
+// FormatCEFEvent renders ev as an ArcSight Common Event Format record. The
+// signature ID is the event category and the name its description.
+func FormatCEFEvent(ev *Event, an *EventAnalysis) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s||%s|%s|%d|",
+		siemVendor, siemProduct,
+		cefHeaderEscaper.Replace(string(an.Category)),
+		cefHeaderEscaper.Replace(an.Description),
+		siemSeverity(an.Severity))
+	first := true
+	for _, f := range siemFields(ev, an, false) {
+		if f.value == "" {
+			continue
+		}
+		if !first {
+			b.WriteByte(' ')
+		}
+		first = false
+		b.WriteString(f.key)
+		b.WriteByte('=')
+		b.WriteString(cefExtensionEscaper.Replace(f.value))
+	}
+	return b.String()
+}
+
+// FormatLEEFEvent renders ev as an IBM QRadar LEEF 1.0 record with
+// tab-separated attributes. The event ID is the event category.
+func FormatLEEFEvent(ev *Event, an *EventAnalysis) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "LEEF:1.0|%s|%s||%s|", siemVendor, siemProduct, leefHeaderEscaper.Replace(string(an.Category)))
+	b.WriteString("devTimeFormat=epoch")
+	for _, f := range siemFields(ev, an, true) {
+		if f.value == "" {
+			continue
+		}
+		b.WriteByte('\t')
+		b.WriteString(f.key)
+		b.WriteByte('=')
+		b.WriteString(leefValueEscaper.Replace(f.value))
+	}
+	return b.String()
+}
+
+// OCSF class, category and activity identifiers used by ToOCSF.
+const (
+	ocsfClassAuthentication = 3002
+	ocsfCategoryIAM         = 3
+	ocsfClassAPIActivity    = 6003
+	ocsfCategoryApplication = 6
+
+	ocsfActivityLogon  = 1
+	ocsfActivityCreate = 1
+	ocsfActivityRead   = 2
+	ocsfActivityUpdate = 3
+	ocsfActivityDelete = 4
+	ocsfActivityOther  = 99
+)
+
+// OCSFEvent is an OCSF API Activity (6003) or Authentication (3002) event.
+// Only the attributes Vault audit events can populate are included.
+type OCSFEvent struct {
+	ClassUID     int    `json:"class_uid"`
+	ClassName    string `json:"class_name"`
+	CategoryUID  int    `json:"category_uid"`
+	CategoryName string `json:"category_name"`
+	ActivityID   int    `json:"activity_id"`
+	ActivityName string `json:"activity_name"`
+	TypeUID      int    `json:"type_uid"`
+	Time         int64  `json:"time"`
+	SeverityID   int    `json:"severity_id"`
+	Severity     string `json:"severity"`
+	StatusID     int    `json:"status_id"`
+	Status       string `json:"status"`
+	Message      string `json:"message,omitempty"`
+
+	Metadata    OCSFMetadata    `json:"metadata"`
+	Actor       *OCSFActor      `json:"actor,omitempty"`
+	User        *OCSFUser       `json:"user,omitempty"`
+	SrcEndpoint *OCSFEndpoint   `json:"src_endpoint,omitempty"`
+	API         *OCSFAPI        `json:"api,omitempty"`
+	Resources   []OCSFResource  `json:"resources,omitempty"`
+	Unmapped    OCSFVaultFields `json:"unmapped"`
+}
+
+type OCSFMetadata struct {
+	Version string      `json:"version"`
+	UID     string      `json:"uid,omitempty"`
+	LogName string      `json:"log_name"`
+	Product OCSFProduct `json:"product"`
+}
+
+type OCSFProduct struct {
+	Name       string `json:"name"`
+	VendorName string `json:"vendor_name"`
+}
+
+type OCSFActor struct {
+	User *OCSFUser `json:"user,omitempty"`
+}
+
+type OCSFUser struct {
+	Name string `json:"name,omitempty"`
+	UID  string `json:"uid,omitempty"`
+}
+
+type OCSFEndpoint struct {
+	IP string `json:"ip"`
+}
+
+type OCSFAPI struct {
+	Operation string          `json:"operation"`
+	Request   *OCSFAPIRequest `json:"request,omitempty"`
+}
+
+type OCSFAPIRequest struct {
+	UID string `json:"uid"`
+}
+
+type OCSFResource struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+}
+
+// OCSFVaultFields carries Vault-specific attributes that have no OCSF
+// equivalent.
+type OCSFVaultFields struct {
+	Namespace     string        `json:"vault_namespace,omitempty"`
+	MountType     string        `json:"vault_mount_type,omitempty"`
+	MountClass    string        `json:"vault_mount_class,omitempty"`
+	AuditType     string        `json:"vault_audit_type,omitempty"`
+	Policies      []string      `json:"vault_policies,omitempty"`
+	TokenPolicies []string      `json:"vault_token_policies,omitempty"`
+	Category      EventCategory `json:"vault_category"`
+	AnomalyReason string        `json:"vault_anomaly_reason,omitempty"`
+}
+
+// ToOCSF maps ev to OCSF. Login attempts become Authentication events; every
+// other request becomes an API Activity event.
+func ToOCSF(ev *Event, an *EventAnalysis) *OCSFEvent {
+	out := &OCSFEvent{
+		Time:    ev.Time.UnixMilli(),
+		Message: an.Description,
+		Metadata: OCSFMetadata{
+			Version: ocsfVersion,
+			UID:     ev.RequestID,
+			LogName: "audit",
+			Product: OCSFProduct{Name: siemProduct, VendorName: siemVendor},
+		},
+		Unmapped: OCSFVaultFields{
+			Namespace:     ev.Namespace,
+			MountType:     ev.MountType,
+			MountClass:    ev.MountClass,
+			AuditType:     ev.AuditType,
+			Policies:      ev.Policies,
+			TokenPolicies: ev.TokenPolicies,
+			Category:      an.Category,
+			AnomalyReason: an.AnomalyReason,
+		},
+	}
+	out.SeverityID, out.Severity = ocsfSeverity(an.Severity)
+	switch ev.Status {
+	case "ok":
+		out.StatusID, out.Status = 1, "Success"
+	case "error":
+		out.StatusID, out.Status = 2, "Failure"
+	default:
+		out.StatusID, out.Status = 0, "Unknown"
+	}
+	if ev.RemoteAddr != "" && net.ParseIP(ev.RemoteAddr) != nil {
+		out.SrcEndpoint = &OCSFEndpoint{IP: ev.RemoteAddr}
+	}
+	var user *OCSFUser
+	if ev.Display != "" || ev.EntityID != "" {
+		user = &OCSFUser{Name: ev.Display, UID: ev.EntityID}
+	}
+
+	if an.Category == CategoryAuthAttempt {
+		out.ClassUID, out.ClassName = ocsfClassAuthentication, "Authentication"
+		out.CategoryUID, out.CategoryName = ocsfCategoryIAM, "Identity & Access Management"
+		out.ActivityID, out.ActivityName = ocsfActivityLogon, "Logon"
+		out.User = user
+	} else {
+		out.ClassUID, out.ClassName = ocsfClassAPIActivity, "API Activity"
+		out.CategoryUID, out.CategoryName = ocsfCategoryApplication, "Application Activity"
+		out.ActivityID, out.ActivityName = ocsfAPIActivity(ev.Operation)
+		if user != nil {
+			out.Actor = &OCSFActor{User: user}
+		}
+	}
+	out.TypeUID = out.ClassUID*100 + out.ActivityID
+
+	if ev.Operation != "" {
+		out.API = &OCSFAPI{Operation: ev.Operation}
+		if ev.RequestID != "" {
+			out.API.Request = &OCSFAPIRequest{UID: ev.RequestID}
+		}
+	}
+	if ev.Path != "" {
+		out.Resources = []OCSFResource{{Name: ev.Path, Type: ev.MountType}}
+	}
+	return out
+}
+
+func ocsfSeverity(s EventSeverity) (int, string) {
+	switch s {
+	case SeverityCritical:
+		return 5, "Critical"
+	case SeverityHigh:
+		return 4, "High"
+	case SeverityMedium:
+		return 3, "Medium"
+	case SeverityLow:
+		return 2, "Low"
+	}
+	return 1, "Informational"
+}
+
+func ocsfAPIActivity(operation string) (int, string) {
+	switch operation {
+	case "create":
+		return ocsfActivityCreate, "Create"
+	case "read", "list":
+		return ocsfActivityRead, "Read"
+	case "update", "patch":
+		return ocsfActivityUpdate, "Update"
+	case "delete":
+		return ocsfActivityDelete, "Delete"
+	}
+	return ocsfActivityOther, "Other"
+}
+
+// SIEMRecords is the audit.search_events result when a SIEM output format is
+// requested. Records are CEF or LEEF strings, or OCSF objects.
+type SIEMRecords struct {
+	Format      string `json:"format"`
+	StartTime   string `json:"start_time"`
+	EndTime     string `json:"end_time"`
+	TotalEvents int    `json:"total_events"`
+	Records     []any  `json:"records"`
+	Incomplete  bool   `json:"incomplete,omitempty"`
+}
+
+// NewSIEMRecords converts events to format.
+func NewSIEMRecords(format string, events []Event, startTime, endTime string) (*SIEMRecords, error) {
+	out := &SIEMRecords{
+		Format:      format,
+		StartTime:   startTime,
+		EndTime:     endTime,
+		TotalEvents: len(events),
+		Records:     make([]any, 0, len(events)),
+	}
+	for i := range events {
+		if format == FormatOCSF {
+			e := events[i]
+			out.Records = append(out.Records, ToOCSF(&e, AnalyzeEvent(&e)))
+			continue
+		}
+		line, err := FormatSIEM(format, &events[i])
+		if err != nil {
+			return nil, err
+		}
+		out.Records = append(out.Records, line)
+	}
+	return out, nil
+}
@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files under testdata")
+
+// siemSampleEvents covers an API activity event, a failed login and a
+// critical policy change.
+func siemSampleEvents() []Event {
+	ts := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	return []Event{
+		{
+			Time: ts, Namespace: "team-a/", Operation: "read", MountType: "kv", MountClass: "secret",
+			Path: "team-a/secret/data/payments/db", AuditType: "response", Status: "ok",
+			RequestID: "req-1", Display: "approle-payments", RemoteAddr: "10.1.2.3",
+			Policies: []string{"default", "payments"}, EntityID: "e-123",
+		},
+		{
+			Time: ts.Add(time.Second), Operation: "update", MountType: "userpass", MountClass: "auth",
+			Path: "auth/userpass/login/alice", AuditType: "response", Status: "error",
+			RequestID: "req-2", RemoteAddr: "192.0.2.7",
+		},
+		{
+			Time: ts.Add(2 * time.Second), Operation: "update", MountType: "system", MountClass: "system",
+			Path: "sys/policy/payments|admin=all", AuditType: "request", Status: "ok",
+			RequestID: "req-3", Display: "root", Policies: []string{"root"},
+		},
+	}
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "siem", name)
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch\n got:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestSIEMFormatsGolden(t *testing.T) {
+	for _, tc := range []struct {
+		format, file string
+	}{
+		{FormatCEF, "events.cef"},
+		{FormatLEEF, "events.leef"},
+		{FormatOCSF, "events.ocsf.ndjson"},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			for _, ev := range siemSampleEvents() {
+				line, err := FormatSIEM(tc.format, &ev)
+				if err != nil {
+					t.Fatalf("FormatSIEM: %v", err)
+				}
+				buf.WriteString(line)
+				buf.WriteByte('\n')
+			}
+			checkGolden(t, tc.file, buf.Bytes())
+		})
+	}
+}
+
+func TestToOCSFClasses(t *testing.T) {
+	events := siemSampleEvents()
+	want := []struct {
+		class, typeUID, severity int
+	}{
+		{ocsfClassAPIActivity, 600302, 2},
+		{ocsfClassAuthentication, 300201, 4},
+		{ocsfClassAPIActivity, 600303, 5},
+	}
+	for i, w := range want {
+		ev := events[i]
+		got := ToOCSF(&ev, AnalyzeEvent(&ev))
+		if got.ClassUID != w.class || got.TypeUID != w.typeUID || got.SeverityID != w.severity {
+			t.Errorf("event %d: class %d type %d severity %d, want %d %d %d",
+				i, got.ClassUID, got.TypeUID, got.SeverityID, w.class, w.typeUID, w.severity)
+		}
+	}
+}
+
+func TestFormatSIEMDoesNotModifyEvent(t *testing.T) {
+	ev := Event{Path: "secret/data/x", Operation: "read"}
+	if _, err := FormatSIEM(FormatCEF, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.MountType != "" {
+		t.Errorf("mount type was inferred onto the caller's event: %q", ev.MountType)
+	}
+	if _, err := FormatSIEM("syslog", &ev); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestExportSIEMFormat(t *testing.T) {
+	events := siemSampleEvents()
+	backend := &stubBackend{events: events}
+	dir := t.TempDir()
+	manifest, err := Export(context.Background(), backend, SearchFilter{
+		Start: events[0].Time.Add(-time.Minute),
+		End:   events[0].Time.Add(time.Minute),
+	}, ExportQuery{}, ExportOptions{Dir: dir, Format: FormatOCSF})
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if !strings.HasSuffix(manifest.File, ".ndjson") {
+		t.Errorf("OCSF export file %q should use the .ndjson extension", manifest.File)
+	}
+	data, err := os.ReadFile(manifest.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != len(events) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(events))
+	}
+	var rec OCSFEvent
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("line is not OCSF JSON: %v", err)
+	}
+	if rec.Metadata.Product.Name != "Vault" {
+		t.Errorf("unexpected product %+v", rec.Metadata.Product)
+	}
+}
@@ -0,0 +1,352 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultSimulationWindow is the window audit.simulate_policy replays when
+// no start time is given.
+const DefaultSimulationWindow = "7d"
+
+// maxPolicyImpacts caps the actor and path groups listed per direction.
+const maxPolicyImpacts = 100
+
+// PolicySimulationQuery replays the requests of tokens carrying a policy
+// against a proposed version of it.
+type PolicySimulationQuery struct {
+	Start time.Time
+	End   time.Time
+	// Name and Namespace identify the policy being replaced.
+	Name      string
+	Namespace string
+	Proposed  *Policy
+	// Current holds the other policies (and the current version) from the
+	// policy directory; nil when none is configured.
+	Current *PolicySet
+	// MaxEvents stops the scan after this many events; 0 means no cap.
+	MaxEvents int
+}
+
+// PolicySimulation lists the historical requests a policy change would
+// deny or allow.
+type PolicySimulation struct {
+	Policy    string `json:"policy"`
+	Namespace string `json:"namespace,omitempty"`
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	// EventsEvaluated counts the responses whose token carried the policy.
+	EventsEvaluated      int `json:"events_evaluated"`
+	NewlyDeniedRequests  int `json:"newly_denied_requests"`
+	NewlyAllowedRequests int `json:"newly_allowed_requests"`
+	// NewlyDenied and NewlyAllowed group the requests by actor and path,
+	// most requests first.
+	NewlyDenied  []PolicyImpact `json:"newly_denied"`
+	NewlyAllowed []PolicyImpact `json:"newly_allowed"`
+	// RuleChanges compares the proposal with the current policy file, when
+	// there is one.
+	RuleChanges []RuleChange `json:"rule_changes,omitempty"`
+	// UnknownPolicies are other policies carried by the tokens without a
+	// local file; they are assumed to grant nothing beyond what Vault's
+	// policy_results show.
+	UnknownPolicies []string `json:"unknown_policies,omitempty"`
+	Notes           []string `json:"notes,omitempty"`
+	// Truncated is set when MaxEvents stopped the scan early.
+	Truncated bool `json:"truncated,omitempty"`
+	// Incomplete is set when the deadline was reached before the whole
+	// range was scanned.
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// PolicyImpact groups the changed requests of one actor on one path.
+type PolicyImpact struct {
+	Actor      string   `json:"actor"`
+	EntityID   string   `json:"entity_id,omitempty"`
+	Namespace  string   `json:"namespace,omitempty"`
+	Path       string   `json:"path"`
+	Operations []string `json:"operations"`
+	Requests   int      `json:"requests"`
+	FirstSeen  string   `json:"first_seen"`
+	LastSeen   string   `json:"last_seen"`
+	// Rule is the path pattern deciding the request under the proposal;
+	// empty when no rule of the token matches.
+	Rule            string `json:"rule,omitempty"`
+	SampleRequestID string `json:"sample_request_id,omitempty"`
+}
+
+// RuleChange is a path rule added, removed or changed by the proposal.
+type RuleChange struct {
+	Path   string   `json:"path"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// policySimulator replays events one at a time.
+type policySimulator struct {
+	q         *PolicySimulationQuery
+	acls      map[string]*acl
+	denied    map[string]*PolicyImpact
+	allowed   map[string]*PolicyImpact
+	unknown   map[string]bool
+	assumed   int
+	result    PolicySimulation
+	namespace string
+}
+
+func newPolicySimulator(q *PolicySimulationQuery) *policySimulator {
+	return &policySimulator{
+		q:         q,
+		acls:      make(map[string]*acl),
+		denied:    make(map[string]*PolicyImpact),
+		allowed:   make(map[string]*PolicyImpact),
+		unknown:   make(map[string]bool),
+		namespace: policyNamespace(q.Namespace),
+	}
+}
+
+// lookup returns the current version of a policy as seen from namespace.
+func (s *policySimulator) lookup(namespace, name string) *Policy {
+	if s.q.Current == nil {
+		return nil
+	}
+	return s.q.Current.Lookup(namespace, name)
+}
+
+// replaces reports whether the simulated policy is the one named name as
+// seen from an event namespace.
+func (s *policySimulator) replaces(namespace, name string) bool {
+	if name != s.q.Name || !strings.HasPrefix(namespace, s.namespace) {
+		return false
+	}
+	// A policy of the same name in a closer namespace shadows it.
+	cur := s.lookup(namespace, name)
+	return cur == nil || cur.Namespace == s.namespace
+}
+
+func (s *policySimulator) acl(policies []*Policy, proposed bool) *acl {
+	var key strings.Builder
+	fmt.Fprintf(&key, "%v\x00", proposed)
+	for _, p := range policies {
+		key.WriteString(p.Namespace + p.Name + "\x00")
+	}
+	a := s.acls[key.String()]
+	if a == nil {
+		a = newACL(policies)
+		s.acls[key.String()] = a
+	}
+	return a
+}
+
+// observedAllowed reports whether Vault's ACL allowed a response, and false
+// for ok when the request failed before the ACL was consulted.
+func observedAllowed(ev *Event) (allowed, ok bool) {
+	if ev.PolicyResults != nil {
+		return ev.PolicyResults.Allowed, true
+	}
+	if ev.Status != "error" {
+		return true, true
+	}
+	switch classifyError(eventError(ev), nil) {
+	case ErrorClassPermissionDenied:
+		return false, true
+	case ErrorClassInvalidToken, ErrorClassInvalidCredentials, ErrorClassNamespaceNotFound, ErrorClassRedacted:
+		return false, false
+	}
+	return true, true
+}
+
+func (s *policySimulator) add(ev *Event) {
+	if ev.AuditType == "request" {
+		return
+	}
+	capability := operationCapability(ev.Operation)
+	names := eventPolicyNames(ev)
+	if capability == "" || slices.Contains(names, "root") {
+		return
+	}
+	ns := policyNamespace(ev.Namespace)
+
+	var current, proposed []*Policy
+	var unknown []string
+	carried, currentKnown := false, true
+	for _, name := range names {
+		if s.replaces(ns, name) {
+			carried = true
+			proposed = append(proposed, s.q.Proposed)
+			if cur := s.lookup(ns, name); cur != nil {
+				current = append(current, cur)
+			} else {
+				currentKnown = false
+			}
+			continue
+		}
+		p := s.lookup(ns, name)
+		if p == nil {
+			unknown = append(unknown, name)
+			continue
+		}
+		current = append(current, p)
+		proposed = append(proposed, p)
+	}
+	if !carried {
+		return
+	}
+	for _, name := range unknown {
+		s.unknown[name] = true
+	}
+	before, ok := observedAllowed(ev)
+	if !ok {
+		return
+	}
+	s.result.EventsEvaluated++
+
+	path := eventACLPath(ev)
+	d := s.acl(proposed, true).evaluate(path, capability)
+	after := d.Allowed
+	if !after && before && !d.Denied {
+		// Another policy may have granted the request.
+		switch {
+		case ev.PolicyResults != nil && len(ev.PolicyResults.GrantingPolicies) > 0:
+			for _, g := range ev.PolicyResults.GrantingPolicies {
+				if g.Name != s.q.Name {
+					after = true
+				}
+			}
+		case len(unknown) > 0 && currentKnown:
+			// The known policies do not explain the grant, so an unknown
+			// one made it.
+			after = !s.acl(current, false).evaluate(path, capability).Allowed
+		case len(unknown) > 0:
+			s.assumed++
+		}
+	}
+	switch {
+	case before && !after:
+		s.result.NewlyDeniedRequests++
+		s.record(s.denied, ev, d.Pattern)
+	case !before && after:
+		s.result.NewlyAllowedRequests++
+		s.record(s.allowed, ev, d.Pattern)
+	}
+}
+
+func (s *policySimulator) record(groups map[string]*PolicyImpact, ev *Event, pattern string) {
+	actor := eventActor(ev)
+	ns := policyNamespace(ev.Namespace)
+	path := strings.TrimPrefix(ev.Path, "/")
+	key := actor + "\x00" + ev.EntityID + "\x00" + ns + path
+	g := groups[key]
+	t := ev.Time.UTC().Format(time.RFC3339)
+	if g == nil {
+		g = &PolicyImpact{
+			Actor:           actor,
+			EntityID:        ev.EntityID,
+			Namespace:       ns,
+			Path:            path,
+			FirstSeen:       t,
+			LastSeen:        t,
+			Rule:            strings.TrimPrefix(pattern, s.namespace),
+			SampleRequestID: ev.RequestID,
+		}
+		groups[key] = g
+	}
+	g.Requests++
+	if !slices.Contains(g.Operations, ev.Operation) {
+		g.Operations = append(g.Operations, ev.Operation)
+	}
+	g.FirstSeen = min(g.FirstSeen, t)
+	g.LastSeen = max(g.LastSeen, t)
+}
+
+func sortedImpacts(groups map[string]*PolicyImpact) []PolicyImpact {
+	out := make([]PolicyImpact, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Requests != out[j].Requests {
+			return out[i].Requests > out[j].Requests
+		}
+		if out[i].Actor != out[j].Actor {
+			return out[i].Actor < out[j].Actor
+		}
+		return out[i].Namespace+out[i].Path < out[j].Namespace+out[j].Path
+	})
+	if len(out) > maxPolicyImpacts {
+		out = out[:maxPolicyImpacts]
+	}
+	return out
+}
+
+// ruleChanges compares the rules of two versions of a policy.
+func ruleChanges(current, proposed *Policy) []RuleChange {
+	var changes []RuleChange
+	for _, r := range current.Rules {
+		i := slices.IndexFunc(proposed.Rules, func(p PolicyRule) bool { return p.Path == r.Path })
+		switch {
+		case i < 0:
+			changes = append(changes, RuleChange{Path: r.Path, Before: r.Capabilities})
+		case !slices.Equal(r.Capabilities, proposed.Rules[i].Capabilities):
+			changes = append(changes, RuleChange{Path: r.Path, Before: r.Capabilities, After: proposed.Rules[i].Capabilities})
+		}
+	}
+	for _, p := range proposed.Rules {
+		if !slices.ContainsFunc(current.Rules, func(r PolicyRule) bool { return r.Path == p.Path }) {
+			changes = append(changes, RuleChange{Path: p.Path, After: p.Capabilities})
+		}
+	}
+	return changes
+}
+
+func (s *policySimulator) finish() *PolicySimulation {
+	res := &s.result
+	res.Policy = s.q.Name
+	res.Namespace = s.namespace
+	res.Start = s.q.Start.UTC().Format(time.RFC3339)
+	res.End = s.q.End.UTC().Format(time.RFC3339)
+	res.NewlyDenied = sortedImpacts(s.denied)
+	res.NewlyAllowed = sortedImpacts(s.allowed)
+	for name := range s.unknown {
+		res.UnknownPolicies = append(res.UnknownPolicies, name)
+	}
+	sort.Strings(res.UnknownPolicies)
+
+	if cur := s.lookup(s.namespace, s.q.Name); cur != nil && cur.Namespace == s.namespace {
+		res.RuleChanges = ruleChanges(cur, s.q.Proposed)
+	} else {
+		res.Notes = append(res.Notes, fmt.Sprintf("No current policy file for %s; changes are relative to the logged outcome of each request.", s.q.Name))
+	}
+	if s.assumed > 0 {
+		res.Notes = append(res.Notes, fmt.Sprintf("%d requests also carried policies without a local file and no policy_results; they were assumed to be granted by %s alone.", s.assumed, s.q.Name))
+	}
+	if slices.ContainsFunc(s.q.Proposed.Rules, func(r PolicyRule) bool { return len(r.Attributes) > 0 }) {
+		res.Notes = append(res.Notes, "Parameter constraints such as allowed_parameters are not evaluated.")
+	}
+	return res
+}
+
+// SimulatePolicy replays the responses of tokens carrying q.Name between
+// q.Start and q.End against q.Proposed and the token's other policies, and
+// returns the requests that would newly be denied or allowed. The logged
+// outcome of each request is the baseline.
+func SimulatePolicy(ctx context.Context, backend Backend, q PolicySimulationQuery) (*PolicySimulation, error) {
+	sim := newPolicySimulator(&q)
+	filter := SearchFilter{Start: q.Start, End: q.End, AuditType: "response", Policy: q.Name}
+	_, truncated, err := exportEvents(ctx, backend, filter, q.MaxEvents, func(ev *Event) error {
+		sim.add(ev)
+		return nil
+	})
+	incomplete := errors.Is(err, ErrIncomplete)
+	if err != nil && !incomplete {
+		return nil, err
+	}
+	res := sim.finish()
+	res.Truncated = truncated
+	res.Incomplete = incomplete
+	return res, nil
+}
@@ -24,7 +24,13 @@ type SearchSummary struct {
 	TopMountTypes   []MountTypeCount  `json:"top_mount_types,omitempty"`
 	TopMountClasses []MountClassCount `json:"top_mount_classes,omitempty"`
 	TopPolicies     []PolicyCount     `json:"top_policies,omitempty"`
-	SuccessRate     float64           `json:"success_rate"`
+	TopMountPoints  []MountPointCount `json:"top_mount_points,omitempty"`
+	TopTokenTypes   []TokenTypeCount  `json:"top_token_types,omitempty"`
+	TopRoles        []RoleCount       `json:"top_roles,omitempty"`
+	// NamespaceTree rolls the namespace counts up the namespace hierarchy,
+	// down to summaryNamespaceDepth. Only set when namespaces are nested.
+	NamespaceTree *NamespaceNode `json:"namespace_tree,omitempty"`
+	SuccessRate   float64        `json:"success_rate"`
 
 	// Security analysis
 	CriticalEvents int `json:"critical_events"`  // System config, audit config, policy changes
@@ -44,6 +50,25 @@ type SearchSummary struct {
 
 	// Flag indicating if results are complete or summarized
 	Summarized bool `json:"summarized"`
+
+	// Incomplete is set when the query deadline was reached before the whole
+	// time range was scanned.
+	Incomplete bool `json:"incomplete,omitempty"`
+
+	// QueryHash identifies this search; the summary can be re-opened from
+	// ResourceURI (vault-audit://summary/{query_hash}) without re-querying.
+	QueryHash   string `json:"query_hash,omitempty"`
+	ResourceURI string `json:"resource_uri,omitempty"`
+}
+
+// EventList is the audit.search_events result for output_format "events":
+// the matching redacted events without summarization.
+type EventList struct {
+	StartTime   string  `json:"start_time"`
+	EndTime     string  `json:"end_time"`
+	TotalEvents int     `json:"total_events"`
+	Events      []Event `json:"events"`
+	Incomplete  bool    `json:"incomplete,omitempty"`
 }
 
 // ActorActivity represents who (identity) performed actions and what they did
@@ -55,6 +80,12 @@ type ActorActivity struct {
 	Operations  []string `json:"operations,omitempty"`  // What operations they performed
 	Namespaces  []string `json:"namespaces,omitempty"`  // Which namespaces they accessed
 	Policies    []string `json:"policies,omitempty"`    // Unique policies used
+	Roles       []string `json:"roles,omitempty"`       // Auth roles the tokens were issued for
+
+	// Resolved from the identity snapshot, when one is configured
+	EntityName string   `json:"entity_name,omitempty"`
+	AliasName  string   `json:"alias_name,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
 }
 
 type NamespaceCount struct {
@@ -82,6 +113,21 @@ type PolicyCount struct {
 	Count  int    `json:"count"`
 }
 
+type MountPointCount struct {
+	MountPoint string `json:"mount_point"`
+	Count      int    `json:"count"`
+}
+
+type TokenTypeCount struct {
+	TokenType string `json:"token_type"`
+	Count     int    `json:"count"`
+}
+
+type RoleCount struct {
+	RoleName string `json:"role_name"`
+	Count    int    `json:"count"`
+}
+
 // SummarizeSearch creates a condensed summary from raw events.
 func SummarizeSearch(events []Event, totalMatched int, startTime, endTime string) *SearchSummary {
 	summary := &SearchSummary{
@@ -99,8 +145,12 @@ func SummarizeSearch(events []Event, totalMatched int, startTime, endTime string
 	mountTypeCounts := make(map[string]int)
 	mountClassCounts := make(map[string]int)
 	policyCounts := make(map[string]int)
+	mountPointCounts := make(map[string]int)
+	tokenTypeCounts := make(map[string]int)
+	roleCounts := make(map[string]int)
 	successCount := 0
 	errorCount := 0
+	deniedCount := 0
 	seenInsights := make(map[string]bool)
 
 	// Track actors (display_name + remote_addr) and their activities
@@ -130,6 +180,18 @@ func SummarizeSearch(events []Event, totalMatched int, startTime, endTime string
 				policyCounts[p]++
 			}
 		}
+		if event.MountPoint != "" {
+			mountPointCounts[event.MountPoint]++
+		}
+		if event.TokenType != "" {
+			tokenTypeCounts[event.TokenType]++
+		}
+		if event.RoleName != "" {
+			roleCounts[event.RoleName]++
+		}
+		if event.PolicyResults != nil && !event.PolicyResults.Allowed {
+			deniedCount++
+		}
 		if event.Status == "ok" {
 			successCount++
 		} else if event.Status == "error" {
@@ -191,6 +253,18 @@ func SummarizeSearch(events []Event, totalMatched int, startTime, endTime string
 					actor.Policies = append(actor.Policies, p)
 				}
 			}
+
+			if event.RoleName != "" && !contains(actor.Roles, event.RoleName) {
+				actor.Roles = append(actor.Roles, event.RoleName)
+			}
+
+			if actor.EntityName == "" && event.EntityName != "" {
+				actor.EntityName = event.EntityName
+				actor.Groups = event.Groups
+			}
+			if actor.AliasName == "" {
+				actor.AliasName = event.AliasName
+			}
 		}
 	}
 
@@ -199,10 +273,14 @@ func SummarizeSearch(events []Event, totalMatched int, startTime, endTime string
 
 	// Convert to sorted slices (top 5 each)
 	summary.TopNamespaces = topNamespaces(namespaceCounts, 5)
+	summary.NamespaceTree = summaryNamespaceTree(namespaceCounts)
 	summary.TopOperations = topOperations(operationCounts, 5)
 	summary.TopMountTypes = topMountTypes(mountTypeCounts, 5)
 	summary.TopMountClasses = topMountClasses(mountClassCounts, 5)
 	summary.TopPolicies = topPolicies(policyCounts, 10)
+	summary.TopMountPoints = topMountPoints(mountPointCounts, 5)
+	summary.TopTokenTypes = topTokenTypes(tokenTypeCounts, 5)
+	summary.TopRoles = topRoles(roleCounts, 5)
 
 	// Calculate success rate
 	totalWithStatus := successCount + errorCount
@@ -223,6 +301,9 @@ func SummarizeSearch(events []Event, totalMatched int, startTime, endTime string
 	summary.Statistics["total_errors"] = errorCount
 	summary.Statistics["critical_events"] = summary.CriticalEvents
 	summary.Statistics["high_risk_events"] = summary.HighRiskEvents
+	if deniedCount > 0 {
+		summary.Statistics["policy_denied"] = deniedCount
+	}
 
 	// Add summary insights
 	if summary.CriticalEvents > 0 {
@@ -231,6 +312,9 @@ func SummarizeSearch(events []Event, totalMatched int, startTime, endTime string
 	if errorCount > 0 {
 		summary.KeyInsights = append(summary.KeyInsights, fmt.Sprintf("%d failed operations", errorCount))
 	}
+	if deniedCount > 0 {
+		summary.KeyInsights = append(summary.KeyInsights, fmt.Sprintf("%d requests denied by policy", deniedCount))
+	}
 
 	return summary
 }
@@ -247,6 +331,7 @@ type TraceSummary struct {
 	Namespaces   []string `json:"namespaces"`
 	Operations   []string `json:"operations"`
 	Summarized   bool     `json:"summarized"`
+	Incomplete   bool     `json:"incomplete,omitempty"`
 	SampleEvents []Event  `json:"sample_events"`
 }
 
@@ -283,6 +368,24 @@ func SummarizeTrace(events []Event, requestID string, startTime, endTime string)
 
 // Helper functions
 
+// summaryNamespaceDepth limits the namespace tree in search summaries.
+const summaryNamespaceDepth = 3
+
+// summaryNamespaceTree returns the namespace tree for counts, or nil when no
+// namespace has a parent other than the root.
+func summaryNamespaceTree(counts map[string]int) *NamespaceNode {
+	nested := false
+	buckets := make([]Bucket, 0, len(counts))
+	for ns, n := range counts {
+		nested = nested || namespaceDepth(ns) > 1
+		buckets = append(buckets, Bucket{Key: ns, Value: float64(n)})
+	}
+	if !nested {
+		return nil
+	}
+	return NamespaceTree(buckets, summaryNamespaceDepth)
+}
+
 func topNamespaces(counts map[string]int, limit int) []NamespaceCount {
 	var items []NamespaceCount
 	for k, v := range counts {
@@ -343,6 +446,42 @@ func topPolicies(counts map[string]int, limit int) []PolicyCount {
 	return items
 }
 
+func topMountPoints(counts map[string]int, limit int) []MountPointCount {
+	var items []MountPointCount
+	for k, v := range counts {
+		items = append(items, MountPointCount{k, v})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Count > items[j].Count })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+func topTokenTypes(counts map[string]int, limit int) []TokenTypeCount {
+	var items []TokenTypeCount
+	for k, v := range counts {
+		items = append(items, TokenTypeCount{k, v})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Count > items[j].Count })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+func topRoles(counts map[string]int, limit int) []RoleCount {
+	var items []RoleCount
+	for k, v := range counts {
+		items = append(items, RoleCount{k, v})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Count > items[j].Count })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
 func stripRawData(events []Event) []Event {
 	var result []Event
 	for _, e := range events {
@@ -0,0 +1,214 @@
+package audit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeFormsHelp lists the accepted time forms for error messages.
+const timeFormsHelp = "want RFC3339 (2026-01-02T15:04:05Z), a date (2026-01-02), Unix epoch seconds, milliseconds or nanoseconds, now, a time before now (-2h, now-7d), today or yesterday"
+
+// minEpochDigits is the shortest number ParseTime accepts as a Unix epoch
+// (1e8 seconds is March 1973).
+const minEpochDigits = 9
+
+// TimeRange holds the time range arguments shared by the query tools.
+type TimeRange struct {
+	// Start and End accept the forms described by ParseTime.
+	Start string
+	End   string
+	// Last selects the duration ending at End, e.g. 90m or 7d. It cannot be
+	// combined with Start.
+	Last string
+	// Timezone is the IANA zone (e.g. Europe/Berlin) used for dates, today
+	// and yesterday. Defaults to UTC.
+	Timezone string
+}
+
+// ParseTime parses an absolute or relative time:
+//
+//   - RFC3339, e.g. 2026-01-02T15:04:05Z
+//   - a date (midnight in loc), e.g. 2026-01-02
+//   - Unix epoch seconds, milliseconds, microseconds or nanoseconds, told
+//     apart by magnitude; at least 9 digits, so a bare year is rejected
+//   - now, or a time before now: -2h, now-7d, now-90m
+//   - today or yesterday (midnight in loc)
+//
+// Durations accept Go units plus d (24h) and w (7d).
+func ParseTime(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if loc == nil {
+		loc = time.UTC
+	}
+	switch strings.ToLower(s) {
+	case "":
+		return time.Time{}, fmt.Errorf("empty time, %s", timeFormsHelp)
+	case "now":
+		return now, nil
+	case "today":
+		return midnight(now, loc), nil
+	case "yesterday":
+		return midnight(now, loc).AddDate(0, 0, -1), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, loc); err == nil {
+		return t.UTC(), nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && n >= 0 {
+		// Shorter numbers are more likely a year or a typo than a time
+		// before 1973.
+		if len(s) < minEpochDigits {
+			return time.Time{}, fmt.Errorf("invalid time %q: epoch values need at least %d digits, %s", s, minEpochDigits, timeFormsHelp)
+		}
+		return epochTime(n), nil
+	}
+
+	offset := strings.TrimPrefix(strings.ToLower(s), "now")
+	if offset, ok := strings.CutPrefix(offset, "-"); ok {
+		d, err := ParseDuration(offset)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid relative time %q: %v", s, err)
+		}
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, %s", s, timeFormsHelp)
+}
+
+// ParseDuration is time.ParseDuration with d (24h) and w (7d) units, e.g.
+// 90m, 7d or 1w2d. Negative durations are rejected.
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty duration, want e.g. 90m, 2h or 7d")
+	}
+	var total time.Duration
+	rest := s
+	for {
+		i := strings.IndexAny(rest, "dw")
+		if i < 0 {
+			break
+		}
+		n, err := strconv.Atoi(rest[:i])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q, want e.g. 90m, 2h or 7d", s)
+		}
+		unit := 24 * time.Hour
+		if rest[i] == 'w' {
+			unit *= 7
+		}
+		total += time.Duration(n) * unit
+		rest = rest[i+1:]
+	}
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("invalid duration %q, want e.g. 90m, 2h or 7d", s)
+		}
+		total += d
+	}
+	return total, nil
+}
+
+// epochTime interprets n as seconds, milliseconds, microseconds or
+// nanoseconds since the Unix epoch depending on its magnitude.
+func epochTime(n int64) time.Time {
+	switch {
+	case n < 1e11:
+		return time.Unix(n, 0).UTC()
+	case n < 1e14:
+		return time.UnixMilli(n).UTC()
+	case n < 1e17:
+		return time.UnixMicro(n).UTC()
+	}
+	return time.Unix(0, n).UTC()
+}
+
+func midnight(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc).UTC()
+}
+
+// ParseRange resolves r to a UTC range, defaulting to the last
+// DefaultQueryAge. Ranges longer than maxDays are rejected; maxDays <= 0
+// disables the check for callers that page through long ranges themselves.
+func ParseRange(r TimeRange, maxDays int) (time.Time, time.Time, error) {
+	loc := time.UTC
+	if r.Timezone != "" {
+		l, err := time.LoadLocation(r.Timezone)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid timezone %q, want an IANA name such as UTC or Europe/Berlin", r.Timezone)
+		}
+		loc = l
+	}
+
+	now := time.Now().UTC()
+	end := now
+	if r.End != "" {
+		t, err := ParseTime(r.End, now, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end time: %w", err)
+		}
+		end = t
+	}
+
+	start := end.Add(-DefaultQueryAge)
+	switch {
+	case r.Last != "" && r.Start != "":
+		return time.Time{}, time.Time{}, fmt.Errorf("last and start time cannot be combined; last selects the range ending at the end time")
+	case r.Last != "":
+		d, err := ParseDuration(r.Last)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid last: %w", err)
+		}
+		start = end.Add(-d)
+	case r.Start != "":
+		t, err := ParseTime(r.Start, now, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start time: %w", err)
+		}
+		start = t
+	}
+
+	// Validate time range logic
+	if start.After(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start time %s cannot be after end time %s",
+			start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	if maxDays > 0 && end.Sub(start) > time.Duration(maxDays)*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("time range %s to %s is %.1f days, more than the maximum of %d days; narrow the range or use audit.export",
+			start.Format(time.RFC3339), end.Format(time.RFC3339), end.Sub(start).Hours()/24, maxDays)
+	}
+	return start, end, nil
+}
+
+// ParseQuarter resolves a calendar quarter to its UTC range in loc: a
+// quarter like 2026-Q3, or "last" for the most recent complete quarter
+// before now.
+func ParseQuarter(s string, now time.Time, loc *time.Location) (time.Time, time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	var year, q int
+	if strings.EqualFold(strings.TrimSpace(s), "last") {
+		local := now.In(loc)
+		year, q = local.Year(), (int(local.Month())-1)/3
+		if q == 0 {
+			year, q = year-1, 4
+		}
+	} else {
+		y, n, ok := strings.Cut(strings.ToUpper(strings.TrimSpace(s)), "-Q")
+		var err error
+		if year, err = strconv.Atoi(y); !ok || err != nil || len(y) != 4 {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid quarter %q, want e.g. 2026-Q3 or last", s)
+		}
+		if q, err = strconv.Atoi(n); err != nil || q < 1 || q > 4 {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid quarter %q, want e.g. 2026-Q3 or last", s)
+		}
+	}
+	start := time.Date(year, time.Month(3*(q-1)+1), 1, 0, 0, 0, 0, loc)
+	return start.UTC(), start.AddDate(0, 3, 0).UTC(), nil
+}
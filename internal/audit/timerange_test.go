@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2026, 3, 10, 1, 30, 0, 0, time.UTC)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	for _, tc := range []struct {
+		in   string
+		loc  *time.Location
+		want time.Time
+	}{
+		{"2026-03-01T10:00:00+02:00", nil, time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)},
+		{"2026-03-01", nil, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"2026-03-01", berlin, time.Date(2026, 2, 28, 23, 0, 0, 0, time.UTC)},
+		{"now", nil, now},
+		{"-2h", nil, now.Add(-2 * time.Hour)},
+		{"now-7d", nil, now.Add(-7 * 24 * time.Hour)},
+		{"NOW-1w1d", nil, now.Add(-8 * 24 * time.Hour)},
+		{"today", nil, time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)},
+		{"yesterday", nil, time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)},
+		// 01:30 UTC is 02:30 in Berlin; midnight there is 23:00 UTC.
+		{"today", berlin, time.Date(2026, 3, 9, 23, 0, 0, 0, time.UTC)},
+		{"1772368200", nil, time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)},
+		{"1772368200123", nil, time.Date(2026, 3, 1, 12, 30, 0, 123e6, time.UTC)},
+		{"1772368200000000001", nil, time.Date(2026, 3, 1, 12, 30, 0, 1, time.UTC)},
+	} {
+		got, err := ParseTime(tc.in, now, tc.loc)
+		if err != nil {
+			t.Errorf("ParseTime(%q): %v", tc.in, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("ParseTime(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+	for _, in := range []string{"", "tomorrow", "now+1h", "-d", "2026-13-01", "-1x", "2026", "12345678"} {
+		if _, err := ParseTime(in, now, nil); err == nil {
+			t.Errorf("ParseTime(%q) should fail", in)
+		}
+	}
+}
+
+func TestParseRangeLast(t *testing.T) {
+	start, end, err := ParseRange(TimeRange{End: "2026-03-01T12:00:00Z", Last: "90m"}, DefaultMaxQueryDays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC); !start.Equal(want) {
+		t.Errorf("start = %v, want %v", start, want)
+	}
+	if want := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC); !end.Equal(want) {
+		t.Errorf("end = %v, want %v", end, want)
+	}
+
+	if _, _, err := ParseRange(TimeRange{Start: "-1h", Last: "1h"}, DefaultMaxQueryDays); err == nil {
+		t.Error("start and last together should be rejected")
+	}
+	if _, _, err := ParseRange(TimeRange{Timezone: "Mars/Olympus"}, DefaultMaxQueryDays); err == nil {
+		t.Error("unknown timezone should be rejected")
+	}
+}
+
+func TestParseRangeMaxDays(t *testing.T) {
+	_, _, err := ParseRange(TimeRange{Last: "8d"}, 7)
+	if err == nil || !strings.Contains(err.Error(), "maximum of 7 days") {
+		t.Fatalf("expected a max days error, got %v", err)
+	}
+	if _, _, err := ParseRange(TimeRange{Last: "8d"}, 0); err != nil {
+		t.Errorf("maxDays 0 should not limit the range: %v", err)
+	}
+}
+
+func TestParseQuarter(t *testing.T) {
+	now := time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		in         string
+		start, end time.Time
+	}{
+		{"2026-Q3", time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{"2025-q4", time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"last", time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		start, end, err := ParseQuarter(tc.in, now, nil)
+		if err != nil || !start.Equal(tc.start) || !end.Equal(tc.end) {
+			t.Errorf("ParseQuarter(%q) = %v - %v, %v; want %v - %v", tc.in, start, end, err, tc.start, tc.end)
+		}
+	}
+	for _, in := range []string{"", "2026", "2026-Q5", "26-Q1", "Q1-2026"} {
+		if _, _, err := ParseQuarter(in, now, nil); err == nil {
+			t.Errorf("ParseQuarter(%q) should fail", in)
+		}
+	}
+}
@@ -2,15 +2,92 @@ package audit
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"vault-audit-mcp/internal/loki"
 )
 
 // Service provides audit trail functionality through registered MCP tools.
 type Service struct {
 	backend Backend
+
+	// auditHMACKey is the audit device HMAC key used by audit.find_by_hmac.
+	auditHMACKey []byte
+
+	// store keeps recent results for the MCP resources.
+	store *resultStore
+
+	// queryTimeout bounds each query tool call; zero means no deadline.
+	queryTimeout time.Duration
+
+	// enabledTools limits the registered tools; nil registers all of them.
+	enabledTools map[string]bool
+
+	// exportDir is where audit.export writes evidence bundles.
+	exportDir string
+
+	// identity resolves entity and mount names; nil when no snapshot is
+	// configured.
+	identity *IdentityStore
+
+	// policyDir holds the Vault ACL policy files audit.policy_usage reads.
+	policyDir string
+
+	// reportTemplatesDir holds report templates added to the built-ins.
+	reportTemplatesDir string
+}
+
+// ToolNames lists every tool AddTools can register.
+var ToolNames = []string{
+	"audit.search_events",
+	"audit.aggregate",
+	"audit.trace",
+	"audit.get_event_details",
+	"audit.find_by_hmac",
+	"audit.export",
+	"audit.explain_denial",
+	"audit.lookup_entity",
+	"audit.policy_usage",
+	"audit.simulate_policy",
+	"audit.compare_windows",
+	"audit.compliance_report",
+}
+
+// SetExportDir sets the directory audit.export writes files to. Callers
+// cannot choose other locations.
+func (s *Service) SetExportDir(dir string) {
+	s.exportDir = dir
+}
+
+// SetEnabledTools restricts AddTools to the named tools. An empty list
+// enables all tools.
+func (s *Service) SetEnabledTools(names []string) error {
+	if len(names) == 0 {
+		s.enabledTools = nil
+		return nil
+	}
+	known := make(map[string]bool, len(ToolNames))
+	for _, n := range ToolNames {
+		known[n] = true
+	}
+	enabled := make(map[string]bool, len(names))
+	for _, n := range names {
+		if !known[n] {
+			return fmt.Errorf("unknown tool %q", n)
+		}
+		enabled[n] = true
+	}
+	s.enabledTools = enabled
+	return nil
 }
 
 // NewService creates a new audit service with the given backend.
@@ -18,13 +95,60 @@ func NewService(backend Backend) *Service {
 	if backend == nil {
 		panic("backend cannot be nil")
 	}
-	return &Service{backend: backend}
+	return &Service{
+		backend:   backend,
+		store:     newResultStore(),
+		exportDir: filepath.Join(os.TempDir(), "vault-audit-exports"),
+	}
+}
+
+// SetAuditHMACKey configures the audit device HMAC key (salt) used to
+// compute HMACs locally for audit.find_by_hmac.
+func (s *Service) SetAuditHMACKey(key []byte) {
+	s.auditHMACKey = key
+}
+
+// SetIdentity enriches every event the tools return with entity names,
+// aliases, groups and mount paths from store, and enables
+// audit.lookup_entity.
+func (s *Service) SetIdentity(store *IdentityStore) {
+	s.identity = store
+	s.backend = WithIdentity(s.backend, store)
+}
+
+// SetPolicyDir sets the directory of Vault ACL policy files (HCL or JSON)
+// audit.policy_usage compares with observed requests and
+// audit.simulate_policy evaluates alongside a proposed policy. The files are
+// read on every call.
+func (s *Service) SetPolicyDir(dir string) {
+	s.policyDir = dir
+}
+
+// SetReportTemplatesDir sets a directory of report templates (JSON) that
+// override or extend the built-in templates of audit.compliance_report. The
+// files are read on every call.
+func (s *Service) SetReportTemplatesDir(dir string) {
+	s.reportTemplatesDir = dir
+}
+
+// SetQueryTimeout sets the deadline applied to each tool call that queries
+// the backend. When it is reached the tools return partial results marked
+// incomplete.
+func (s *Service) SetQueryTimeout(d time.Duration) {
+	s.queryTimeout = d
+}
+
+// queryLimits returns the query limits of the service backend.
+func (s *Service) queryLimits() QueryLimits {
+	return limitsOf(s.backend)
 }
 
 // SearchArgs defines parameters for the search_events tool.
 type SearchArgs struct {
-	StartRFC3339 string `json:"start_rfc3339,omitempty" jsonschema:"Start time (RFC3339). Defaults to now-15m."`
-	EndRFC3339   string `json:"end_rfc3339,omitempty" jsonschema:"End time (RFC3339). Defaults to now."`
+	StartRFC3339 string `json:"start_rfc3339,omitempty" jsonschema:"Start time: RFC3339, a date, Unix epoch, or relative like -2h, now-7d, today, yesterday. Defaults to 15m before the end time."`
+	EndRFC3339   string `json:"end_rfc3339,omitempty" jsonschema:"End time, in the same forms as start_rfc3339. Defaults to now."`
+	Last         string `json:"last,omitempty" jsonschema:"Duration ending at the end time, e.g. 90m, 24h or 7d. Use instead of start_rfc3339."`
+	Timezone     string `json:"timezone,omitempty" jsonschema:"IANA timezone for dates, today and yesterday, e.g. Europe/Berlin. Defaults to UTC."`
 	Limit        int    `json:"limit,omitempty" jsonschema:"Max number of log lines to return. Max 500, default 100."`
 
 	Namespace  string `json:"namespace,omitempty" jsonschema:"Vault namespace path label value, e.g. myNamespace/"`
@@ -34,75 +158,348 @@ type SearchArgs struct {
 	Status     string `json:"status,omitempty" jsonschema:"ok or error"`
 	Policy     string `json:"policy,omitempty" jsonschema:"Filter by policy name (searches both policies and token_policies)"`
 	EntityID   string `json:"entity_id,omitempty" jsonschema:"Filter by entity ID"`
+
+	NamespacePrefix string `json:"namespace_prefix,omitempty" jsonschema:"Vault namespace subtree: the namespace and all its descendants, e.g. org/team-a/"`
+
+	DisplayName string   `json:"display_name,omitempty" jsonschema:"Filter by token display name, e.g. approle-payments"`
+	AuditType   string   `json:"audit_type,omitempty" jsonschema:"request or response"`
+	PathPrefix  string   `json:"path_prefix,omitempty" jsonschema:"Request path prefix, e.g. secret/data/payments/"`
+	PathGlob    string   `json:"path_glob,omitempty" jsonschema:"Request path glob; * and ? match within a path segment, ** across segments, e.g. secret/data/*/db-*"`
+	PathRegex   string   `json:"path_regex,omitempty" jsonschema:"Request path regular expression (RE2, unanchored), e.g. ^auth/.+/login"`
+	RemoteCIDR  []string `json:"remote_cidr,omitempty" jsonschema:"Remote addresses or CIDR ranges; matches any, e.g. [10.0.0.0/8, 192.168.1.7]"`
+
+	MountPoint    string `json:"mount_point,omitempty" jsonschema:"Filter by mount point, e.g. secret/"`
+	MountAccessor string `json:"mount_accessor,omitempty" jsonschema:"Filter by mount accessor"`
+	TokenType     string `json:"token_type,omitempty" jsonschema:"Filter by token type: service or batch"`
+	RoleName      string `json:"role_name,omitempty" jsonschema:"Filter by auth role name (role_name or role auth metadata)"`
+
+	AnyOf   *FieldSet `json:"any_of,omitempty" jsonschema:"Alternative values per field (IN), combined with the single-value filter for that field"`
+	Exclude *FieldSet `json:"exclude,omitempty" jsonschema:"Drop events matching any of these values (NOT IN)"`
+
+	OutputFormat string `json:"output_format,omitempty" jsonschema:"summary (default); events to return the matching redacted events; or cef, leef or ocsf to return them as SIEM records"`
+
+	Tenant string `json:"tenant,omitempty" jsonschema:"Loki tenant(s) to query, e.g. team-a or team-a|team-b. Defaults to the server's configured tenants."`
 }
 
 // AggregateArgs defines parameters for the aggregate tool.
 type AggregateArgs struct {
-	StartRFC3339 string `json:"start_rfc3339,omitempty" jsonschema:"Start time (RFC3339). Defaults to now-15m."`
-	EndRFC3339   string `json:"end_rfc3339,omitempty" jsonschema:"End time (RFC3339). Defaults to now."`
-	By           string `json:"by" jsonschema:"One of: vault_namespace, vault_operation, vault_mount_type, vault_mount_class, vault_status"`
+	StartRFC3339 string `json:"start_rfc3339,omitempty" jsonschema:"Start time: RFC3339, a date, Unix epoch, or relative like -2h, now-7d, today, yesterday. Defaults to 15m before the end time."`
+	EndRFC3339   string `json:"end_rfc3339,omitempty" jsonschema:"End time, in the same forms as start_rfc3339. Defaults to now."`
+	Last         string `json:"last,omitempty" jsonschema:"Duration ending at the end time, e.g. 90m, 24h or 7d. Use instead of start_rfc3339."`
+	Timezone     string `json:"timezone,omitempty" jsonschema:"IANA timezone for dates, today and yesterday, e.g. Europe/Berlin. Defaults to UTC."`
+	By           string `json:"by" jsonschema:"One of: vault_namespace, vault_operation, vault_mount_type, vault_mount_class, vault_status; or, counted from up to the max query limit of parsed events: vault_mount_point, vault_mount_accessor, vault_token_type, vault_role_name, vault_status_code, vault_policy_allowed"`
 	// Optional filters:
 	Namespace  string `json:"namespace,omitempty" jsonschema:"Filter by namespace."`
 	Operation  string `json:"operation,omitempty" jsonschema:"Filter by operation."`
 	MountType  string `json:"mount_type,omitempty" jsonschema:"Filter by mount type."`
 	MountClass string `json:"mount_class,omitempty" jsonschema:"Filter by mount class."`
 	Status     string `json:"status,omitempty" jsonschema:"Filter by status (ok or error)."`
+
+	NamespacePrefix string `json:"namespace_prefix,omitempty" jsonschema:"Filter by namespace subtree: the namespace and all its descendants."`
+
+	// Namespace roll-up, for by=vault_namespace:
+	NamespaceView      string `json:"namespace_view,omitempty" jsonschema:"flat (default) for one bucket per namespace, or tree for the namespace hierarchy with per-namespace count and total including descendants"`
+	IncludeDescendants bool   `json:"include_descendants,omitempty" jsonschema:"In the flat view, count each namespace's descendants in its bucket"`
+	Depth              int    `json:"depth,omitempty" jsonschema:"Roll namespaces deeper than this many levels up into their ancestor, e.g. 1 for top-level namespaces. Default: no limit."`
+
+	Tenant string `json:"tenant,omitempty" jsonschema:"Loki tenant(s) to query, e.g. team-a or team-a|team-b. Defaults to the server's configured tenants."`
 }
 
 // TraceArgs defines parameters for the trace tool.
 type TraceArgs struct {
-	StartRFC3339 string `json:"start_rfc3339,omitempty" jsonschema:"Start time (RFC3339). Defaults to now-15m."`
-	EndRFC3339   string `json:"end_rfc3339,omitempty" jsonschema:"End time (RFC3339). Defaults to now."`
+	StartRFC3339 string `json:"start_rfc3339,omitempty" jsonschema:"Start time: RFC3339, a date, Unix epoch, or relative like -2h, now-7d, today, yesterday. Defaults to 15m before the end time."`
+	EndRFC3339   string `json:"end_rfc3339,omitempty" jsonschema:"End time, in the same forms as start_rfc3339. Defaults to now."`
+	Last         string `json:"last,omitempty" jsonschema:"Duration ending at the end time, e.g. 90m, 24h or 7d. Use instead of start_rfc3339."`
+	Timezone     string `json:"timezone,omitempty" jsonschema:"IANA timezone for dates, today and yesterday, e.g. Europe/Berlin. Defaults to UTC."`
 	Limit        int    `json:"limit,omitempty" jsonschema:"Max number of log lines to return. Default 100."`
 	RequestID    string `json:"request_id" jsonschema:"Vault request id (request.id) to trace"`
+	Tenant       string `json:"tenant,omitempty" jsonschema:"Loki tenant(s) to query, e.g. team-a or team-a|team-b. Defaults to the server's configured tenants."`
+}
+
+// ExplainDenialArgs defines parameters for the explain_denial tool.
+type ExplainDenialArgs struct {
+	StartRFC3339 string `json:"start_rfc3339,omitempty" jsonschema:"Start time: RFC3339, a date, Unix epoch, or relative like -2h, now-7d, today, yesterday. Defaults to 24h before the end time."`
+	EndRFC3339   string `json:"end_rfc3339,omitempty" jsonschema:"End time, in the same forms as start_rfc3339. Defaults to now."`
+	Last         string `json:"last,omitempty" jsonschema:"Duration ending at the end time, e.g. 90m, 24h or 7d. Use instead of start_rfc3339."`
+	Timezone     string `json:"timezone,omitempty" jsonschema:"IANA timezone for dates, today and yesterday, e.g. Europe/Berlin. Defaults to UTC."`
+
+	RequestID   string `json:"request_id,omitempty" jsonschema:"Vault request id of the denied request. Otherwise the most recent failed request matching the actor and path is explained."`
+	DisplayName string `json:"display_name,omitempty" jsonschema:"Actor token display name, e.g. approle-payments"`
+	EntityID    string `json:"entity_id,omitempty" jsonschema:"Actor entity ID"`
+	Path        string `json:"path,omitempty" jsonschema:"Request path prefix, or a glob such as secret/data/*/db"`
+	Namespace   string `json:"namespace,omitempty" jsonschema:"Vault namespace, e.g. team-a/"`
+
+	Tenant string `json:"tenant,omitempty" jsonschema:"Loki tenant(s) to query, e.g. team-a or team-a|team-b. Defaults to the server's configured tenants."`
+}
+
+// LookupEntityArgs defines parameters for the lookup_entity tool.
+type LookupEntityArgs struct {
+	Name string `json:"name,omitempty" jsonschema:"Entity name or alias name (case-insensitive), e.g. alice@example.com"`
+	ID   string `json:"id,omitempty" jsonschema:"Entity ID"`
+}
+
+// PolicyUsageArgs defines parameters for the policy_usage tool.
+type PolicyUsageArgs struct {
+	StartRFC3339 string `json:"start_rfc3339,omitempty" jsonschema:"Start time: RFC3339, a date, Unix epoch, or relative like -2h, now-7d, today, yesterday. Defaults to 30d before the end time. The range may exceed the search limits; it is paged through."`
+	EndRFC3339   string `json:"end_rfc3339,omitempty" jsonschema:"End time, in the same forms as start_rfc3339. Defaults to now."`
+	Last         string `json:"last,omitempty" jsonschema:"Duration ending at the end time, e.g. 7d or 90d. Use instead of start_rfc3339."`
+	Timezone     string `json:"timezone,omitempty" jsonschema:"IANA timezone for dates, today and yesterday, e.g. Europe/Berlin. Defaults to UTC."`
+
+	Policies  []string `json:"policies,omitempty" jsonschema:"Policy names to report, e.g. payments. Defaults to every policy in the policy directory."`
+	MaxEvents int      `json:"max_events,omitempty" jsonschema:"Stop after scanning this many events. Default: no limit."`
+
+	Tenant string `json:"tenant,omitempty" jsonschema:"Loki tenant(s) to query, e.g. team-a or team-a|team-b. Defaults to the server's configured tenants."`
+}
+
+// SimulatePolicyArgs defines parameters for the simulate_policy tool.
+type SimulatePolicyArgs struct {
+	StartRFC3339 string `json:"start_rfc3339,omitempty" jsonschema:"Start time: RFC3339, a date, Unix epoch, or relative like -2h, now-7d, today, yesterday. Defaults to 7d before the end time. The range may exceed the search limits; it is paged through."`
+	EndRFC3339   string `json:"end_rfc3339,omitempty" jsonschema:"End time, in the same forms as start_rfc3339. Defaults to now."`
+	Last         string `json:"last,omitempty" jsonschema:"Duration ending at the end time, e.g. 7d or 30d. Use instead of start_rfc3339."`
+	Timezone     string `json:"timezone,omitempty" jsonschema:"IANA timezone for dates, today and yesterday, e.g. Europe/Berlin. Defaults to UTC."`
+
+	Name      string `json:"name" jsonschema:"Name of the policy the proposal replaces, e.g. payments"`
+	Namespace string `json:"namespace,omitempty" jsonschema:"Namespace the policy is defined in, e.g. team-a/. Defaults to root."`
+	Policy    string `json:"policy" jsonschema:"Proposed policy document in HCL or JSON"`
+	MaxEvents int    `json:"max_events,omitempty" jsonschema:"Stop after scanning this many events. Default: no limit."`
+
+	Tenant string `json:"tenant,omitempty" jsonschema:"Loki tenant(s) to query, e.g. team-a or team-a|team-b. Defaults to the server's configured tenants."`
+}
+
+// ComplianceReportArgs defines parameters for the compliance_report tool.
+type ComplianceReportArgs struct {
+	Template string `json:"template" jsonschema:"Report template: soc2, pci_dss, iso27001 or a configured custom template"`
+
+	Quarter      string `json:"quarter,omitempty" jsonschema:"Calendar quarter to report on, e.g. 2026-Q3, or last for the most recent complete quarter. The default when no time range is given."`
+	StartRFC3339 string `json:"start_rfc3339,omitempty" jsonschema:"Start time instead of a quarter: RFC3339, a date, Unix epoch, or relative like now-30d. The range may exceed the search limits; it is paged through."`
+	EndRFC3339   string `json:"end_rfc3339,omitempty" jsonschema:"End time, in the same forms as start_rfc3339. Defaults to now."`
+	Last         string `json:"last,omitempty" jsonschema:"Duration ending at the end time, e.g. 90d. Use instead of start_rfc3339."`
+	Timezone     string `json:"timezone,omitempty" jsonschema:"IANA timezone for quarters, dates, today and yesterday, e.g. Europe/Berlin. Defaults to UTC."`
+
+	NamespacePrefix string `json:"namespace_prefix,omitempty" jsonschema:"Limit the report to a namespace and its descendants, e.g. org/team-a/"`
+	Evidence        *bool  `json:"evidence,omitempty" jsonschema:"Write the events of each query as evidence files next to the report. Default true."`
+	EvidenceFormat  string `json:"evidence_format,omitempty" jsonschema:"Evidence file format: ndjson (default), csv, cef, leef or ocsf"`
+	Top             int    `json:"top,omitempty" jsonschema:"Rows listed per table. Default 20."`
+	MaxEvents       int    `json:"max_events,omitempty" jsonschema:"Stop each query after this many events. Default: no limit."`
+
+	Tenant string `json:"tenant,omitempty" jsonschema:"Loki tenant(s) to query, e.g. team-a or team-a|team-b. Defaults to the server's configured tenants."`
+}
+
+// reportRange resolves the report period: a quarter, a time range, or the
+// last complete quarter when neither is given.
+func (a *ComplianceReportArgs) reportRange() (time.Time, time.Time, error) {
+	tr := TimeRange{Start: a.StartRFC3339, End: a.EndRFC3339, Last: a.Last, Timezone: a.Timezone}
+	if a.Quarter == "" && (tr.Start != "" || tr.End != "" || tr.Last != "") {
+		// Long ranges are allowed; every query is paged through.
+		return ParseRange(tr, 0)
+	}
+	if tr.Start != "" || tr.End != "" || tr.Last != "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("quarter cannot be combined with start_rfc3339, end_rfc3339 or last")
+	}
+	loc := time.UTC
+	if a.Timezone != "" {
+		l, err := time.LoadLocation(a.Timezone)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid timezone %q, want an IANA name such as UTC or Europe/Berlin", a.Timezone)
+		}
+		loc = l
+	}
+	quarter := a.Quarter
+	if quarter == "" {
+		quarter = "last"
+	}
+	return ParseQuarter(quarter, time.Now().UTC(), loc)
+}
+
+// CompareWindowsArgs defines parameters for the compare_windows tool.
+type CompareWindowsArgs struct {
+	StartRFC3339 string `json:"start_rfc3339,omitempty" jsonschema:"Start of the current window: RFC3339, a date, Unix epoch, or relative like -2h, now-7d, today, yesterday. Defaults to 15m before the end time."`
+	EndRFC3339   string `json:"end_rfc3339,omitempty" jsonschema:"End of the current window, in the same forms as start_rfc3339. Defaults to now."`
+	Last         string `json:"last,omitempty" jsonschema:"Duration of the current window ending at the end time, e.g. 90m or 24h. Use instead of start_rfc3339."`
+	Timezone     string `json:"timezone,omitempty" jsonschema:"IANA timezone for dates, today and yesterday, e.g. Europe/Berlin. Defaults to UTC."`
+
+	BaselineStartRFC3339 string `json:"baseline_start_rfc3339,omitempty" jsonschema:"Start of the baseline window, in the same forms as start_rfc3339. Requires baseline_end_rfc3339."`
+	BaselineEndRFC3339   string `json:"baseline_end_rfc3339,omitempty" jsonschema:"End of the baseline window."`
+	BaselineOffset       string `json:"baseline_offset,omitempty" jsonschema:"Compare with the current window shifted back by this duration, e.g. 1h, 24h or 7d. Default 7d (the same window last week) unless a baseline window is given."`
+
+	Namespace  string `json:"namespace,omitempty" jsonschema:"Vault namespace path label value, e.g. myNamespace/"`
+	Operation  string `json:"operation,omitempty" jsonschema:"Vault operation label value, e.g. update"`
+	MountType  string `json:"mount_type,omitempty" jsonschema:"Vault mount type label value, e.g. pki"`
+	MountClass string `json:"mount_class,omitempty" jsonschema:"Vault mount class (e.g. auth, secret, system)"`
+	Status     string `json:"status,omitempty" jsonschema:"ok or error"`
+	Policy     string `json:"policy,omitempty" jsonschema:"Filter by policy name (searches both policies and token_policies)"`
+	EntityID   string `json:"entity_id,omitempty" jsonschema:"Filter by entity ID"`
+
+	NamespacePrefix string `json:"namespace_prefix,omitempty" jsonschema:"Vault namespace subtree: the namespace and all its descendants, e.g. org/team-a/"`
+
+	DisplayName string `json:"display_name,omitempty" jsonschema:"Filter by token display name, e.g. approle-payments"`
+	AuditType   string `json:"audit_type,omitempty" jsonschema:"request or response. Set response to count each request once."`
+	PathPrefix  string `json:"path_prefix,omitempty" jsonschema:"Request path prefix, e.g. secret/data/payments/"`
+	PathGlob    string `json:"path_glob,omitempty" jsonschema:"Request path glob; * and ? match within a path segment, ** across segments, e.g. secret/data/*/db-*"`
+	PathRegex   string `json:"path_regex,omitempty" jsonschema:"Request path regular expression (RE2, unanchored), e.g. ^auth/.+/login"`
+	MountPoint  string `json:"mount_point,omitempty" jsonschema:"Filter by mount point, e.g. secret/"`
+
+	AnyOf   *FieldSet `json:"any_of,omitempty" jsonschema:"Alternative values per field (IN), combined with the single-value filter for that field"`
+	Exclude *FieldSet `json:"exclude,omitempty" jsonschema:"Drop events matching any of these values (NOT IN)"`
+
+	ThresholdPercent float64 `json:"threshold_percent,omitempty" jsonschema:"Flag entries whose count changed by at least this percentage. Default 50."`
+	MinCount         int     `json:"min_count,omitempty" jsonschema:"Only flag changes, new and disappeared entries of at least this many events. Default 5."`
+	Top              int     `json:"top,omitempty" jsonschema:"Entries listed per dimension, flagged ones first. Default 10."`
+	MaxEvents        int     `json:"max_events,omitempty" jsonschema:"Stop counting a window after this many events. Default 100000."`
+
+	Tenant string `json:"tenant,omitempty" jsonschema:"Loki tenant(s) to query, e.g. team-a or team-a|team-b. Defaults to the server's configured tenants."`
 }
 
 // GetEventDetailsArgs defines parameters for the get_event_details tool.
 type GetEventDetailsArgs struct {
 	RequestID string `json:"request_id" jsonschema:"Vault request ID to retrieve detailed event for"`
+	Tenant    string `json:"tenant,omitempty" jsonschema:"Loki tenant(s) to query, e.g. team-a or team-a|team-b. Defaults to the server's configured tenants."`
 }
 
-// parseRange parses start and end time strings, returning defaults if not provided.
-func parseRange(startStr, endStr string) (time.Time, time.Time, error) {
-	now := time.Now().UTC()
-	end := now
-	start := now.Add(-DefaultQueryAge)
+// FindByHMACArgs defines parameters for the find_by_hmac tool.
+type FindByHMACArgs struct {
+	StartRFC3339 string   `json:"start_rfc3339,omitempty" jsonschema:"Start time: RFC3339, a date, Unix epoch, or relative like -2h, now-7d, today, yesterday. Defaults to 15m before the end time."`
+	EndRFC3339   string   `json:"end_rfc3339,omitempty" jsonschema:"End time, in the same forms as start_rfc3339. Defaults to now."`
+	Last         string   `json:"last,omitempty" jsonschema:"Duration ending at the end time, e.g. 90m, 24h or 7d. Use instead of start_rfc3339."`
+	Timezone     string   `json:"timezone,omitempty" jsonschema:"IANA timezone for dates, today and yesterday, e.g. Europe/Berlin. Defaults to UTC."`
+	Limit        int      `json:"limit,omitempty" jsonschema:"Max number of matching events to return. Max 500, default 100."`
+	Values       []string `json:"values" jsonschema:"Plaintext values to look up, e.g. a client token or a written secret value. They are HMACed locally and are never logged or returned."`
+	Tenant       string   `json:"tenant,omitempty" jsonschema:"Loki tenant(s) to query, e.g. team-a or team-a|team-b. Defaults to the server's configured tenants."`
+}
 
-	if endStr != "" {
-		t, err := time.Parse(time.RFC3339, endStr)
-		if err != nil {
-			return time.Time{}, time.Time{}, fmt.Errorf("invalid end time: %w", err)
-		}
-		end = t.UTC()
+// ExportArgs defines parameters for the export tool.
+type ExportArgs struct {
+	StartRFC3339 string `json:"start_rfc3339,omitempty" jsonschema:"Start time: RFC3339, a date, Unix epoch, or relative like -2h, now-7d, today, yesterday. Defaults to 15m before the end time. The range may exceed the search limits; it is paged through."`
+	EndRFC3339   string `json:"end_rfc3339,omitempty" jsonschema:"End time, in the same forms as start_rfc3339. Defaults to now."`
+	Last         string `json:"last,omitempty" jsonschema:"Duration ending at the end time, e.g. 90m, 24h or 7d. Use instead of start_rfc3339."`
+	Timezone     string `json:"timezone,omitempty" jsonschema:"IANA timezone for dates, today and yesterday, e.g. Europe/Berlin. Defaults to UTC."`
+
+	Namespace  string `json:"namespace,omitempty" jsonschema:"Vault namespace path label value, e.g. myNamespace/"`
+	Operation  string `json:"operation,omitempty" jsonschema:"Vault operation label value, e.g. update"`
+	MountType  string `json:"mount_type,omitempty" jsonschema:"Vault mount type label value, e.g. pki"`
+	MountClass string `json:"mount_class,omitempty" jsonschema:"Vault mount class (e.g. auth, secret, system)"`
+	Status     string `json:"status,omitempty" jsonschema:"ok or error"`
+	Policy     string `json:"policy,omitempty" jsonschema:"Filter by policy name (searches both policies and token_policies)"`
+	EntityID   string `json:"entity_id,omitempty" jsonschema:"Filter by entity ID"`
+
+	NamespacePrefix string `json:"namespace_prefix,omitempty" jsonschema:"Vault namespace subtree: the namespace and all its descendants, e.g. org/team-a/"`
+
+	DisplayName string   `json:"display_name,omitempty" jsonschema:"Filter by token display name, e.g. approle-payments"`
+	AuditType   string   `json:"audit_type,omitempty" jsonschema:"request or response"`
+	PathPrefix  string   `json:"path_prefix,omitempty" jsonschema:"Request path prefix, e.g. secret/data/payments/"`
+	PathGlob    string   `json:"path_glob,omitempty" jsonschema:"Request path glob; * and ? match within a path segment, ** across segments, e.g. secret/data/*/db-*"`
+	PathRegex   string   `json:"path_regex,omitempty" jsonschema:"Request path regular expression (RE2, unanchored), e.g. ^auth/.+/login"`
+	RemoteCIDR  []string `json:"remote_cidr,omitempty" jsonschema:"Remote addresses or CIDR ranges; matches any, e.g. [10.0.0.0/8, 192.168.1.7]"`
+
+	MountPoint    string `json:"mount_point,omitempty" jsonschema:"Filter by mount point, e.g. secret/"`
+	MountAccessor string `json:"mount_accessor,omitempty" jsonschema:"Filter by mount accessor"`
+	TokenType     string `json:"token_type,omitempty" jsonschema:"Filter by token type: service or batch"`
+	RoleName      string `json:"role_name,omitempty" jsonschema:"Filter by auth role name (role_name or role auth metadata)"`
+
+	AnyOf   *FieldSet `json:"any_of,omitempty" jsonschema:"Alternative values per field (IN), combined with the single-value filter for that field"`
+	Exclude *FieldSet `json:"exclude,omitempty" jsonschema:"Drop events matching any of these values (NOT IN)"`
+
+	Format    string   `json:"format,omitempty" jsonschema:"ndjson (default), csv, or a SIEM format: cef, leef or ocsf (one record per line)"`
+	Columns   []string `json:"columns,omitempty" jsonschema:"CSV columns, e.g. time, request_id, operation, path, status. Defaults to a standard set."`
+	MaxEvents int      `json:"max_events,omitempty" jsonschema:"Stop after this many events. Default: no limit."`
+
+	Tenant string `json:"tenant,omitempty" jsonschema:"Loki tenant(s) to query, e.g. team-a or team-a|team-b. Defaults to the server's configured tenants."`
+}
+
+// timeRange returns the tool's time range arguments.
+func (a *SearchArgs) timeRange() TimeRange {
+	return TimeRange{Start: a.StartRFC3339, End: a.EndRFC3339, Last: a.Last, Timezone: a.Timezone}
+}
+
+func (a *AggregateArgs) timeRange() TimeRange {
+	return TimeRange{Start: a.StartRFC3339, End: a.EndRFC3339, Last: a.Last, Timezone: a.Timezone}
+}
+
+func (a *TraceArgs) timeRange() TimeRange {
+	return TimeRange{Start: a.StartRFC3339, End: a.EndRFC3339, Last: a.Last, Timezone: a.Timezone}
+}
+
+func (a *ExplainDenialArgs) timeRange() TimeRange {
+	r := TimeRange{Start: a.StartRFC3339, End: a.EndRFC3339, Last: a.Last, Timezone: a.Timezone}
+	if r.Start == "" && r.Last == "" {
+		r.Last = "24h"
 	}
-	if startStr != "" {
-		t, err := time.Parse(time.RFC3339, startStr)
+	return r
+}
+
+func (a *PolicyUsageArgs) timeRange() TimeRange {
+	r := TimeRange{Start: a.StartRFC3339, End: a.EndRFC3339, Last: a.Last, Timezone: a.Timezone}
+	if r.Start == "" && r.Last == "" {
+		r.Last = DefaultPolicyUsageWindow
+	}
+	return r
+}
+
+func (a *SimulatePolicyArgs) timeRange() TimeRange {
+	r := TimeRange{Start: a.StartRFC3339, End: a.EndRFC3339, Last: a.Last, Timezone: a.Timezone}
+	if r.Start == "" && r.Last == "" {
+		r.Last = DefaultSimulationWindow
+	}
+	return r
+}
+
+func (a *CompareWindowsArgs) timeRange() TimeRange {
+	return TimeRange{Start: a.StartRFC3339, End: a.EndRFC3339, Last: a.Last, Timezone: a.Timezone}
+}
+
+// baselineRange resolves the baseline window of a current window from start
+// to end.
+func (a *CompareWindowsArgs) baselineRange(start, end time.Time, maxDays int) (time.Time, time.Time, error) {
+	if a.BaselineStartRFC3339 != "" || a.BaselineEndRFC3339 != "" {
+		if a.BaselineStartRFC3339 == "" || a.BaselineEndRFC3339 == "" {
+			return time.Time{}, time.Time{}, fmt.Errorf("baseline_start_rfc3339 and baseline_end_rfc3339 must be given together")
+		}
+		if a.BaselineOffset != "" {
+			return time.Time{}, time.Time{}, fmt.Errorf("baseline_offset cannot be combined with a baseline window")
+		}
+		start, end, err := ParseRange(TimeRange{Start: a.BaselineStartRFC3339, End: a.BaselineEndRFC3339, Timezone: a.Timezone}, maxDays)
 		if err != nil {
-			return time.Time{}, time.Time{}, fmt.Errorf("invalid start time: %w", err)
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid baseline: %w", err)
 		}
-		start = t.UTC()
+		return start, end, nil
 	}
-
-	// Validate time range logic
-	if start.After(end) {
-		return time.Time{}, time.Time{}, fmt.Errorf("start time cannot be after end time")
+	offset := a.BaselineOffset
+	if offset == "" {
+		offset = DefaultBaselineOffset
 	}
+	d, err := ParseDuration(offset)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid baseline_offset: %w", err)
+	}
+	return start.Add(-d), end.Add(-d), nil
+}
 
-	return start, end, nil
+func (a *FindByHMACArgs) timeRange() TimeRange {
+	return TimeRange{Start: a.StartRFC3339, End: a.EndRFC3339, Last: a.Last, Timezone: a.Timezone}
+}
+
+func (a *ExportArgs) timeRange() TimeRange {
+	return TimeRange{Start: a.StartRFC3339, End: a.EndRFC3339, Last: a.Last, Timezone: a.Timezone}
 }
 
 // AddTools registers all audit tools with the MCP server.
 func (s *Service) AddTools(server *mcp.Server) {
 	// audit.search_events
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(s, server, &mcp.Tool{
 		Name:        "audit.search_events",
-		Description: "Search Vault audit events by labels (namespace, operation, mount type, status, policy, entity_id). Returns a structured summary with statistics, top patterns including policy usage, and sample events.",
+		Description: "Search Vault audit events by labels (namespace, operation, mount type, status, policy, entity_id), display name, audit type, request path (prefix, glob or regex) and remote address CIDR. any_of matches several values per field and exclude drops matching values. Returns a structured summary with statistics, top patterns including policy usage, and sample events.",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args SearchArgs) (*mcp.CallToolResult, any, error) {
-		start, end, err := parseRange(args.StartRFC3339, args.EndRFC3339)
+		ctx = loki.WithTenant(ctx, args.Tenant)
+		start, end, err := ParseRange(args.timeRange(), s.queryLimits().MaxDays)
 		if err != nil {
 			return nil, nil, err
 		}
 
+		format := strings.ToLower(args.OutputFormat)
+		if format != "" && format != "summary" && format != "events" && !IsSIEMFormat(format) {
+			return nil, nil, fmt.Errorf("invalid output_format %q, must be summary, events, cef, leef or ocsf", args.OutputFormat)
+		}
+
 		filter := &SearchFilter{
 			Start:      start,
 			End:        end,
@@ -114,35 +511,97 @@ func (s *Service) AddTools(server *mcp.Server) {
 			Status:     args.Status,
 			Policy:     args.Policy,
 			EntityID:   args.EntityID,
+
+			NamespacePrefix: args.NamespacePrefix,
+
+			DisplayName: args.DisplayName,
+			AuditType:   args.AuditType,
+			PathPrefix:  args.PathPrefix,
+			PathGlob:    args.PathGlob,
+			PathRegex:   args.PathRegex,
+			RemoteCIDRs: args.RemoteCIDR,
+
+			MountPoint:    args.MountPoint,
+			MountAccessor: args.MountAccessor,
+			TokenType:     args.TokenType,
+			RoleName:      args.RoleName,
+
+			AnyOf:   args.AnyOf,
+			Exclude: args.Exclude,
+		}
+		if err := filter.Validate(); err != nil {
+			return nil, nil, err
 		}
 
+		ctx, cancel := s.queryContext(ctx, req)
+		defer cancel()
 		events, err := s.backend.Search(ctx, filter)
-		if err != nil {
+		incomplete := errors.Is(err, ErrIncomplete)
+		if err != nil && !incomplete {
 			return nil, nil, err
 		}
 
+		if format == "events" {
+			if events == nil {
+				events = []Event{}
+			}
+			s.store.rememberEvents(loki.TenantFromContext(ctx), events)
+			return nil, &EventList{
+				StartTime:   start.Format(time.RFC3339),
+				EndTime:     end.Format(time.RFC3339),
+				TotalEvents: len(events),
+				Events:      events,
+				Incomplete:  incomplete,
+			}, nil
+		}
+		if IsSIEMFormat(format) {
+			s.store.rememberEvents(loki.TenantFromContext(ctx), events)
+			records, err := NewSIEMRecords(format, events, start.Format(time.RFC3339), end.Format(time.RFC3339))
+			if err != nil {
+				return nil, nil, err
+			}
+			records.Incomplete = incomplete
+			return nil, records, nil
+		}
+
 		// Return summarized results instead of raw events
+		_, span := tracer.Start(ctx, "audit.summarize_search", trace.WithAttributes(attribute.Int("audit.events", len(events))))
 		summary := SummarizeSearch(events, len(events), start.Format(time.RFC3339), end.Format(time.RFC3339))
+		span.End()
+		summary.Incomplete = incomplete
+
+		// Keep the summary and events so they can be re-opened as resources.
+		summary.QueryHash = queryHash("audit.search_events", args, start, end)
+		summary.ResourceURI = resourceURI(summaryResourcePrefix, summary.QueryHash, loki.TenantFromContext(ctx))
+		s.store.rememberEvents(loki.TenantFromContext(ctx), events)
+		s.store.saveSummary(loki.TenantFromContext(ctx), &SavedQuery{
+			QueryHash:   summary.QueryHash,
+			Tool:        "audit.search_events",
+			Args:        args,
+			StartTime:   summary.StartTime,
+			EndTime:     summary.EndTime,
+			TotalEvents: summary.TotalEvents,
+			CreatedAt:   time.Now().UTC(),
+			SummaryURI:  summary.ResourceURI,
+		}, summary)
 		return nil, summary, nil
 	})
 
 	// audit.aggregate
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(s, server, &mcp.Tool{
 		Name:        "audit.aggregate",
-		Description: "Aggregate Vault audit events by counting events grouped by a dimension (namespace, operation, mount_type, mount_class, or status).",
+		Description: "Aggregate Vault audit events by counting events grouped by a dimension (namespace, operation, mount_type, mount_class, status, mount_point, mount_accessor, token_type, role_name, status_code or policy_allowed).",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args AggregateArgs) (*mcp.CallToolResult, any, error) {
-		start, end, err := parseRange(args.StartRFC3339, args.EndRFC3339)
+		ctx = loki.WithTenant(ctx, args.Tenant)
+		start, end, err := ParseRange(args.timeRange(), s.queryLimits().MaxDays)
 		if err != nil {
 			return nil, nil, err
 		}
 
 		// Validate 'by' parameter is one of the valid dimensions
 		byLabel := args.By
-		switch byLabel {
-		case LabelNamespace, LabelOperation, LabelMountType, LabelMountClass, LabelStatus:
-			// Valid dimension, use as-is
-		default:
-			return nil, nil, fmt.Errorf("invalid 'by' parameter: %q, must be one of: vault_namespace, vault_operation, vault_mount_type, vault_mount_class, vault_status", args.By)
+		if !IsAggregateDimension(byLabel) {
+			return nil, nil, fmt.Errorf("invalid 'by' parameter: %q, must be one of: %s", args.By, strings.Join(AggregateDimensions, ", "))
 		}
 
 		filter := &AggregateFilter{
@@ -153,22 +612,48 @@ func (s *Service) AddTools(server *mcp.Server) {
 			MountType:  args.MountType,
 			MountClass: args.MountClass,
 			Status:     args.Status,
+
+			NamespacePrefix: args.NamespacePrefix,
+		}
+
+		view := strings.ToLower(args.NamespaceView)
+		rollup := view != "" || args.IncludeDescendants || args.Depth != 0
+		switch {
+		case view != "" && view != "flat" && view != "tree":
+			return nil, nil, fmt.Errorf("invalid namespace_view %q, must be flat or tree", args.NamespaceView)
+		case args.Depth < 0:
+			return nil, nil, fmt.Errorf("depth must not be negative")
+		case rollup && byLabel != LabelNamespace:
+			return nil, nil, fmt.Errorf("namespace_view, include_descendants and depth require by=%s", LabelNamespace)
 		}
 
+		ctx, cancel := s.queryContext(ctx, req)
+		defer cancel()
 		buckets, err := s.backend.Aggregate(ctx, filter, byLabel)
-		if err != nil {
+		incomplete := errors.Is(err, ErrIncomplete)
+		if err != nil && !incomplete {
 			return nil, nil, err
 		}
 
+		if view == "tree" {
+			return nil, NamespaceTreeResult{Tree: NamespaceTree(buckets, args.Depth), Incomplete: incomplete}, nil
+		}
+		if rollup {
+			buckets = RollupNamespaces(buckets, args.Depth, args.IncludeDescendants)
+		}
+		if incomplete {
+			return nil, PartialAggregate{Buckets: buckets, Incomplete: true}, nil
+		}
 		return nil, buckets, nil
 	})
 
 	// audit.trace
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(s, server, &mcp.Tool{
 		Name:        "audit.trace",
 		Description: "Trace all audit events for a specific Vault request ID across the time range. Returns a timeline summary with key events and patterns.",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args TraceArgs) (*mcp.CallToolResult, any, error) {
-		start, end, err := parseRange(args.StartRFC3339, args.EndRFC3339)
+		ctx = loki.WithTenant(ctx, args.Tenant)
+		start, end, err := ParseRange(args.timeRange(), s.queryLimits().MaxDays)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -184,21 +669,30 @@ func (s *Service) AddTools(server *mcp.Server) {
 			RequestID: args.RequestID,
 		}
 
+		ctx, cancel := s.queryContext(ctx, req)
+		defer cancel()
 		events, err := s.backend.Trace(ctx, filter)
-		if err != nil {
+		incomplete := errors.Is(err, ErrIncomplete)
+		if err != nil && !incomplete {
 			return nil, nil, err
 		}
 
+		s.store.rememberEvents(loki.TenantFromContext(ctx), events)
+
 		// Return summarized trace results instead of raw events
+		_, span := tracer.Start(ctx, "audit.summarize_trace", trace.WithAttributes(attribute.Int("audit.events", len(events))))
 		summary := SummarizeTrace(events, args.RequestID, start.Format(time.RFC3339), end.Format(time.RFC3339))
+		span.End()
+		summary.Incomplete = incomplete
 		return nil, summary, nil
 	})
 
 	// audit.get_event_details
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(s, server, &mcp.Tool{
 		Name:        "audit.get_event_details",
 		Description: "Retrieve detailed information for a specific audit event by request ID. Returns complete event details including request path, role name, entity ID, remote address, and the full raw audit log.",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args GetEventDetailsArgs) (*mcp.CallToolResult, any, error) {
+		ctx = loki.WithTenant(ctx, args.Tenant)
 		if args.RequestID == "" {
 			return nil, nil, fmt.Errorf("request_id is required")
 		}
@@ -211,18 +705,424 @@ func (s *Service) AddTools(server *mcp.Server) {
 			RequestID: args.RequestID,
 		}
 
+		ctx, cancel := s.queryContext(ctx, req)
+		defer cancel()
 		events, err := s.backend.Trace(ctx, filter)
-		if err != nil {
+		incomplete := errors.Is(err, ErrIncomplete)
+		if err != nil && !incomplete {
 			return nil, nil, err
 		}
 
 		if len(events) == 0 {
-			return nil, map[string]any{
+			result := map[string]any{
 				"error": fmt.Sprintf("no events found for request_id: %s", args.RequestID),
+			}
+			if incomplete {
+				result["incomplete"] = true
+			}
+			return nil, result, nil
+		}
+		if incomplete {
+			// Partial events are returned marked incomplete but not kept
+			// for the event resource.
+			return nil, &EventList{
+				StartTime:   filter.Start.Format(time.RFC3339),
+				EndTime:     filter.End.Format(time.RFC3339),
+				TotalEvents: len(events),
+				Events:      events,
+				Incomplete:  true,
 			}, nil
 		}
 
+		s.store.rememberEvents(loki.TenantFromContext(ctx), events)
+
 		// Return all detailed events for this request_id
 		return nil, events, nil
 	})
+
+	// audit.find_by_hmac
+	addTool(s, server, &mcp.Tool{
+		Name:        "audit.find_by_hmac",
+		Description: "Find audit events containing known plaintext values (tokens, accessors, written data) by computing Vault's hmac-sha256 values locally with the audit device key. Returns matching events and the fields the values appeared in; plaintexts are never logged or returned.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args FindByHMACArgs) (*mcp.CallToolResult, any, error) {
+		ctx = loki.WithTenant(ctx, args.Tenant)
+		if len(s.auditHMACKey) == 0 {
+			return nil, nil, fmt.Errorf("audit HMAC key is not configured (set VAULT_AUDIT_HMAC_KEY_FILE)")
+		}
+		searcher, ok := s.backend.(HMACSearcher)
+		if !ok {
+			return nil, nil, fmt.Errorf("backend does not support HMAC search")
+		}
+		if len(args.Values) == 0 {
+			return nil, nil, fmt.Errorf("values is required")
+		}
+
+		start, end, err := ParseRange(args.timeRange(), s.queryLimits().MaxDays)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Only HMACs leave this handler; never include plaintexts in errors.
+		hmacs := make([]string, len(args.Values))
+		for i, v := range args.Values {
+			if v == "" {
+				return nil, nil, fmt.Errorf("value %d is empty", i+1)
+			}
+			hmacs[i] = AuditHMAC(s.auditHMACKey, v)
+		}
+
+		ctx, cancel := s.queryContext(ctx, req)
+		defer cancel()
+		matches, err := searcher.FindByHMAC(ctx, &HMACFilter{
+			Start: start,
+			End:   end,
+			Limit: args.Limit,
+			HMACs: hmacs,
+		})
+		incomplete := errors.Is(err, ErrIncomplete)
+		if err != nil && !incomplete {
+			return nil, nil, err
+		}
+
+		result := SummarizeHMACMatches(matches, hmacs, start.Format(time.RFC3339), end.Format(time.RFC3339))
+		result.Incomplete = incomplete
+		return nil, result, nil
+	})
+
+	// audit.export
+	addTool(s, server, &mcp.Tool{
+		Name:        "audit.export",
+		Description: "Export every matching (redacted) Vault audit event over a time range of any length to an NDJSON, CSV, CEF, LEEF or OCSF file on the server, with a manifest recording the query, time range, event count and SHA-256 of the data. Returns the file path and manifest.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args ExportArgs) (*mcp.CallToolResult, any, error) {
+		ctx = loki.WithTenant(ctx, args.Tenant)
+		// Long ranges are allowed; Export splits them into segments.
+		start, end, err := ParseRange(args.timeRange(), 0)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		filter := SearchFilter{
+			Start:      start,
+			End:        end,
+			Namespace:  args.Namespace,
+			Operation:  args.Operation,
+			MountType:  args.MountType,
+			MountClass: args.MountClass,
+			Status:     args.Status,
+			Policy:     args.Policy,
+			EntityID:   args.EntityID,
+
+			NamespacePrefix: args.NamespacePrefix,
+
+			DisplayName: args.DisplayName,
+			AuditType:   args.AuditType,
+			PathPrefix:  args.PathPrefix,
+			PathGlob:    args.PathGlob,
+			PathRegex:   args.PathRegex,
+			RemoteCIDRs: args.RemoteCIDR,
+
+			MountPoint:    args.MountPoint,
+			MountAccessor: args.MountAccessor,
+			TokenType:     args.TokenType,
+			RoleName:      args.RoleName,
+
+			AnyOf:   args.AnyOf,
+			Exclude: args.Exclude,
+		}
+		if err := filter.Validate(); err != nil {
+			return nil, nil, err
+		}
+		query := ExportQuery{
+			Namespace:  args.Namespace,
+			Operation:  args.Operation,
+			MountType:  args.MountType,
+			MountClass: args.MountClass,
+			Status:     args.Status,
+			Policy:     args.Policy,
+			EntityID:   args.EntityID,
+			Tenant:     args.Tenant,
+
+			NamespacePrefix: args.NamespacePrefix,
+
+			DisplayName: args.DisplayName,
+			AuditType:   args.AuditType,
+			PathPrefix:  args.PathPrefix,
+			PathGlob:    args.PathGlob,
+			PathRegex:   args.PathRegex,
+			RemoteCIDRs: args.RemoteCIDR,
+
+			MountPoint:    args.MountPoint,
+			MountAccessor: args.MountAccessor,
+			TokenType:     args.TokenType,
+			RoleName:      args.RoleName,
+
+			AnyOf:   args.AnyOf,
+			Exclude: args.Exclude,
+		}
+
+		ctx, cancel := s.queryContext(ctx, req)
+		defer cancel()
+		manifest, err := Export(ctx, s.backend, filter, query, ExportOptions{
+			Dir:       s.exportDir,
+			Format:    args.Format,
+			Columns:   args.Columns,
+			MaxEvents: args.MaxEvents,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, manifest, nil
+	})
+
+	// audit.explain_denial
+	addTool(s, server, &mcp.Tool{
+		Name:        "audit.explain_denial",
+		Description: "Explain why a Vault request failed, by request ID or for the most recent failed request of an actor (display_name or entity_id) and/or path. Returns the request/response pair, the error class, the token's policies, token_policies, identity policies and policy_results, the namespace and path requested, findings, and the actor's most recent successful access to similar paths for contrast. Defaults to the last 24h.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args ExplainDenialArgs) (*mcp.CallToolResult, any, error) {
+		ctx = loki.WithTenant(ctx, args.Tenant)
+		start, end, err := ParseRange(args.timeRange(), s.queryLimits().MaxDays)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ctx, cancel := s.queryContext(ctx, req)
+		defer cancel()
+		exp, err := ExplainDenial(ctx, s.backend, &DenialQuery{
+			Start:       start,
+			End:         end,
+			RequestID:   args.RequestID,
+			DisplayName: args.DisplayName,
+			EntityID:    args.EntityID,
+			Path:        args.Path,
+			Namespace:   args.Namespace,
+		})
+		if errors.Is(err, ErrNoDenial) {
+			return nil, map[string]any{
+				"error": fmt.Sprintf("%v between %s and %s", err, start.Format(time.RFC3339), end.Format(time.RFC3339)),
+			}, nil
+		}
+		if err != nil && !errors.Is(err, ErrIncomplete) {
+			return nil, nil, err
+		}
+
+		var pair []Event
+		for _, ev := range []*Event{exp.Request, exp.Response} {
+			if ev != nil {
+				pair = append(pair, *ev)
+			}
+		}
+		s.store.rememberEvents(loki.TenantFromContext(ctx), pair)
+		return nil, exp, nil
+	})
+
+	// audit.lookup_entity
+	addTool(s, server, &mcp.Tool{
+		Name:        "audit.lookup_entity",
+		Description: "Look up a Vault identity entity by name, alias name or ID in the local identity snapshot. Returns the entity ID to search audit events with, its aliases and auth mounts, groups and policies.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args LookupEntityArgs) (*mcp.CallToolResult, any, error) {
+		if s.identity == nil {
+			return nil, nil, fmt.Errorf("identity snapshot is not configured (set VAULT_AUDIT_IDENTITY_SNAPSHOT)")
+		}
+		if args.Name == "" && args.ID == "" {
+			return nil, nil, fmt.Errorf("name or id is required")
+		}
+		entities := s.identity.LookupEntity(args.ID, args.Name)
+		if len(entities) == 0 {
+			return nil, map[string]any{"error": "no entity matches in the identity snapshot"}, nil
+		}
+		return nil, EntityLookup{
+			Entities:         entities,
+			SnapshotLoadedAt: s.identity.LoadedAt().Format(time.RFC3339),
+		}, nil
+	})
+
+	// audit.policy_usage
+	addTool(s, server, &mcp.Tool{
+		Name:        "audit.policy_usage",
+		Description: "Compare the Vault ACL policy files in the configured policy directory with the requests they actually granted over a long window (default 30d). For each policy returns the requests per path rule and capability, unused path rules, unused capabilities, and a suggested least-privilege policy in HCL.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args PolicyUsageArgs) (*mcp.CallToolResult, any, error) {
+		if s.policyDir == "" {
+			return nil, nil, fmt.Errorf("policy directory is not configured (set VAULT_AUDIT_POLICY_DIR)")
+		}
+		ctx = loki.WithTenant(ctx, args.Tenant)
+		// Long ranges are allowed; they are paged through like exports.
+		start, end, err := ParseRange(args.timeRange(), 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		policies, err := LoadPolicies(s.policyDir)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ctx, cancel := s.queryContext(ctx, req)
+		defer cancel()
+		report, err := AnalyzePolicyUsage(ctx, s.backend, policies, PolicyUsageQuery{
+			Start:     start,
+			End:       end,
+			Policies:  args.Policies,
+			MaxEvents: args.MaxEvents,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, report, nil
+	})
+
+	// audit.simulate_policy
+	addTool(s, server, &mcp.Tool{
+		Name:        "audit.simulate_policy",
+		Description: "Simulate a policy change before merging it: replay the historical requests of tokens carrying the named policy (default last 7d) against the proposed HCL or JSON document and the tokens' other policies from the policy directory, using Vault ACL path, glob, + segment and capability semantics. Returns the requests that would newly be denied or newly be allowed, grouped by actor and path, and the rule changes.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args SimulatePolicyArgs) (*mcp.CallToolResult, any, error) {
+		if args.Name == "" || args.Policy == "" {
+			return nil, nil, fmt.Errorf("name and policy are required")
+		}
+		proposed, err := ParsePolicy(args.Name, args.Policy)
+		if err != nil {
+			return nil, nil, err
+		}
+		proposed.Namespace = policyNamespace(args.Namespace)
+		var current *PolicySet
+		if s.policyDir != "" {
+			if current, err = LoadPolicies(s.policyDir); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		ctx = loki.WithTenant(ctx, args.Tenant)
+		// Long ranges are allowed; they are paged through like exports.
+		start, end, err := ParseRange(args.timeRange(), 0)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ctx, cancel := s.queryContext(ctx, req)
+		defer cancel()
+		sim, err := SimulatePolicy(ctx, s.backend, PolicySimulationQuery{
+			Start:     start,
+			End:       end,
+			Name:      args.Name,
+			Namespace: args.Namespace,
+			Proposed:  proposed,
+			Current:   current,
+			MaxEvents: args.MaxEvents,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, sim, nil
+	})
+
+	// audit.compare_windows
+	addTool(s, server, &mcp.Tool{
+		Name:        "audit.compare_windows",
+		Description: "Compare the same filter over two time windows, e.g. before and after a deploy, or a window against the same window last week (the default). Returns event counts and error rates of both windows, and per-dimension deltas for namespaces, operations, mount types, paths, actors, event categories and error classes, flagging entries that are new, disappeared, or changed beyond a threshold, with a list of highlights.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args CompareWindowsArgs) (*mcp.CallToolResult, any, error) {
+		ctx = loki.WithTenant(ctx, args.Tenant)
+		start, end, err := ParseRange(args.timeRange(), s.queryLimits().MaxDays)
+		if err != nil {
+			return nil, nil, err
+		}
+		baseStart, baseEnd, err := args.baselineRange(start, end, s.queryLimits().MaxDays)
+		if err != nil {
+			return nil, nil, err
+		}
+		if args.ThresholdPercent < 0 || args.MinCount < 0 || args.Top < 0 || args.MaxEvents < 0 {
+			return nil, nil, fmt.Errorf("threshold_percent, min_count, top and max_events must not be negative")
+		}
+
+		filter := SearchFilter{
+			Start:      start,
+			End:        end,
+			Namespace:  args.Namespace,
+			Operation:  args.Operation,
+			MountType:  args.MountType,
+			MountClass: args.MountClass,
+			Status:     args.Status,
+			Policy:     args.Policy,
+			EntityID:   args.EntityID,
+
+			NamespacePrefix: args.NamespacePrefix,
+
+			DisplayName: args.DisplayName,
+			AuditType:   args.AuditType,
+			PathPrefix:  args.PathPrefix,
+			PathGlob:    args.PathGlob,
+			PathRegex:   args.PathRegex,
+			MountPoint:  args.MountPoint,
+
+			AnyOf:   args.AnyOf,
+			Exclude: args.Exclude,
+		}
+		if err := filter.Validate(); err != nil {
+			return nil, nil, err
+		}
+		maxEvents := args.MaxEvents
+		if maxEvents == 0 {
+			maxEvents = DefaultCompareMaxEvents
+		}
+
+		ctx, cancel := s.queryContext(ctx, req)
+		defer cancel()
+		cmp, err := CompareWindows(ctx, s.backend, CompareQuery{
+			Filter:           filter,
+			BaselineStart:    baseStart,
+			BaselineEnd:      baseEnd,
+			ThresholdPercent: args.ThresholdPercent,
+			MinCount:         args.MinCount,
+			Top:              args.Top,
+			MaxEvents:        maxEvents,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, cmp, nil
+	})
+
+	// audit.compliance_report
+	addTool(s, server, &mcp.Tool{
+		Name:        "audit.compliance_report",
+		Description: "Generate a compliance evidence report (SOC 2, PCI DSS, ISO 27001 or a custom template) for a period, by default the last complete quarter. Each control of the template maps to audit queries such as root token use, policy and auth method changes, failed logins and audit device changes. Writes a Markdown and a self-contained HTML report with counts and tables per control, a JSON manifest, and the matching events of each query as evidence files on the server. Returns the report summary and file paths.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args ComplianceReportArgs) (*mcp.CallToolResult, any, error) {
+		ctx = loki.WithTenant(ctx, args.Tenant)
+		templates, err := LoadReportTemplates(s.reportTemplatesDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		var tmpl *ReportTemplate
+		names := make([]string, 0, len(templates))
+		for _, t := range templates {
+			names = append(names, t.Name)
+			if t.Name == args.Template {
+				tmpl = t
+			}
+		}
+		if tmpl == nil {
+			return nil, nil, fmt.Errorf("unknown report template %q, must be one of %s", args.Template, strings.Join(names, ", "))
+		}
+		start, end, err := args.reportRange()
+		if err != nil {
+			return nil, nil, err
+		}
+		if args.Top < 0 || args.MaxEvents < 0 {
+			return nil, nil, fmt.Errorf("top and max_events must not be negative")
+		}
+
+		ctx, cancel := s.queryContext(ctx, req)
+		defer cancel()
+		rep, err := GenerateComplianceReport(ctx, s.backend, tmpl, ComplianceQuery{
+			Start:           start,
+			End:             end,
+			NamespacePrefix: args.NamespacePrefix,
+			Dir:             s.exportDir,
+			Evidence:        args.Evidence == nil || *args.Evidence,
+			EvidenceFormat:  args.EvidenceFormat,
+			Top:             args.Top,
+			MaxEvents:       args.MaxEvents,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, rep, nil
+	})
 }
@@ -3,76 +3,10 @@ package audit
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 )
 
-// Redact removes or masks sensitive fields from audit event data.
-// It modifies the map in-place to remove secrets, tokens, and credentials.
-func Redact(m map[string]any) {
-	if m == nil {
-		return
-	}
-
-	// Top-level sensitive fields
-	sensitiveTopLevel := []string{"error", "errors"}
-	for _, field := range sensitiveTopLevel {
-		if val, ok := m[field]; ok && val != nil {
-			m[field] = "[redacted]"
-		}
-	}
-
-	// auth block contains sensitive tokens
-	if auth, ok := m["auth"].(map[string]any); ok {
-		authSensitive := []string{"client_token", "accessor", "secret_id", "metadata"}
-		for _, field := range authSensitive {
-			if auth[field] != nil {
-				auth[field] = "[redacted]"
-			}
-		}
-	}
-
-	// response block may contain sensitive data
-	if resp, ok := m["response"].(map[string]any); ok {
-		// Redact auth within response
-		if auth, ok := resp["auth"].(map[string]any); ok {
-			authSensitive := []string{"client_token", "accessor", "secret_id"}
-			for _, field := range authSensitive {
-				if auth[field] != nil {
-					auth[field] = "[redacted]"
-				}
-			}
-		}
-		// Redact secret data
-		if secret, ok := resp["secret"].(map[string]any); ok {
-			secretSensitive := []string{"data"}
-			for _, field := range secretSensitive {
-				if secret[field] != nil {
-					secret[field] = "[redacted]"
-				}
-			}
-		}
-		// Redact wake-up keys and other sensitive response data
-		respSensitive := []string{"data", "wrap_info"}
-		for _, field := range respSensitive {
-			if _, ok := resp[field]; ok {
-				// For 'data', be selective rather than blanking everything
-				// Some backends return structured data we want to preserve shape of
-				if field == "wrap_info" {
-					resp[field] = "[redacted]"
-				}
-			}
-		}
-	}
-
-	// request block may contain sensitive path or body parameters
-	if req, ok := m["request"].(map[string]any); ok {
-		// Don't redact the path itself, but redact data if present
-		if req["data"] != nil {
-			req["data"] = "[redacted]"
-		}
-	}
-}
-
 func parseUnixNanoString(ns string) (time.Time, error) {
 	n, err := strconv.ParseInt(ns, 10, 64)
 	if err != nil {
@@ -187,14 +121,124 @@ func populateFromAudit(ev *Event, m map[string]any) {
 		}
 	}
 
+	populateDetails(ev, m)
+
 	// status (best-effort)
-	ev.Status = "ok"
-	if _, ok := m["error"]; ok {
-		// error may be null; treat any non-empty as error
-		if m["error"] != nil && fmt.Sprintf("%v", m["error"]) != "" {
-			ev.Status = "error"
+	ev.Status = auditStatus(m)
+}
+
+// populateDetails extracts mount, token, identity and request details. The
+// request and auth blocks take precedence over the response, which carries
+// them for login responses.
+func populateDetails(ev *Event, m map[string]any) {
+	req := mapAt(m, "request")
+	resp := mapAt(m, "response")
+	auth := mapAt(m, "auth")
+	respAuth := mapAt(resp, "auth")
+
+	ev.MountPoint = firstString(req["mount_point"], resp["mount_point"])
+	ev.MountAccessor = firstString(req["mount_accessor"], resp["mount_accessor"])
+	if v := firstString(req["client_token_accessor"], auth["accessor"]); v != redactedValue {
+		ev.TokenAccessor = v
+	}
+	ev.ForwardedFrom = firstString(m["forwarded_from"], req["forwarded_from"])
+
+	ev.TokenType = firstString(auth["token_type"], respAuth["token_type"])
+	ev.TokenIssueTime = firstString(auth["token_issue_time"], respAuth["token_issue_time"])
+	for _, v := range []any{auth["token_ttl"], respAuth["token_ttl"]} {
+		if ttl, ok := v.(float64); ok && ttl > 0 {
+			ev.TokenTTL = int64(ttl)
+			break
+		}
+	}
+	ev.IdentityPolicies = stringList(auth["identity_policies"])
+
+	if pr := mapAt(auth, "policy_results"); pr != nil {
+		allowed, _ := pr["allowed"].(bool)
+		ev.PolicyResults = &PolicyResults{Allowed: allowed}
+		if granting, ok := pr["granting_policies"].([]any); ok {
+			for _, g := range granting {
+				gm, _ := g.(map[string]any)
+				if gm == nil {
+					continue
+				}
+				ev.PolicyResults.GrantingPolicies = append(ev.PolicyResults.GrantingPolicies, GrantingPolicy{
+					Name:          firstString(gm["name"]),
+					NamespaceID:   firstString(gm["namespace_id"]),
+					NamespacePath: firstString(gm["namespace_path"]),
+					Type:          firstString(gm["type"]),
+				})
+			}
+		}
+	}
+
+	if headers := mapAt(req, "headers"); len(headers) > 0 {
+		ev.Headers = make(map[string]string, len(headers))
+		for k, v := range headers {
+			if s, ok := v.(string); ok {
+				ev.Headers[k] = s
+			} else if vs := stringList(v); len(vs) > 0 {
+				ev.Headers[k] = strings.Join(vs, ", ")
+			}
+		}
+	}
+}
+
+// mapAt returns the nested object at path, or nil.
+func mapAt(m map[string]any, path ...string) map[string]any {
+	for _, k := range path {
+		if m == nil {
+			return nil
 		}
+		m, _ = m[k].(map[string]any)
+	}
+	return m
+}
+
+// firstString returns the first non-empty string among values.
+func firstString(values ...any) string {
+	for _, v := range values {
+		if s, ok := v.(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// stringList converts a JSON array to its string elements.
+func stringList(v any) []string {
+	list, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(list))
+	for _, el := range list {
+		if s, ok := el.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// httpStatusCode returns the HTTP status code Vault logs for raw HTTP
+// responses in response.data.http_status_code. Callers that redact must
+// capture it first, since response.data is masked by default.
+func httpStatusCode(m map[string]any) int {
+	if code, ok := mapAt(m, "response", "data")["http_status_code"].(float64); ok {
+		return int(code)
+	}
+	return 0
+}
+
+// auditStatus reports "error" when the audit entry carries a non-empty error
+// and "ok" otherwise. Callers that redact before populating an Event should
+// capture the status first, since a redaction policy may drop the error.
+func auditStatus(m map[string]any) string {
+	// error may be null; treat any non-empty as error
+	if m["error"] != nil && fmt.Sprintf("%v", m["error"]) != "" {
+		return "error"
 	}
+	return "ok"
 }
 
 func latestValue(values [][]interface{}) float64 {
@@ -38,9 +38,9 @@ func TestNewServicePanicsOnNilBackend(t *testing.T) {
 }
 
 func TestParseRangeDefaults(t *testing.T) {
-	start, end, err := parseRange("", "")
+	start, end, err := ParseRange(TimeRange{}, DefaultMaxQueryDays)
 	if err != nil {
-		t.Fatalf("parseRange failed: %v", err)
+		t.Fatalf("ParseRange failed: %v", err)
 	}
 	if !start.Before(end) {
 		t.Error("start should be before end")
@@ -48,18 +48,18 @@ func TestParseRangeDefaults(t *testing.T) {
 }
 
 func TestParseRangeRejectsInvalidTimeFormat(t *testing.T) {
-	_, _, err := parseRange("invalid", "")
+	_, _, err := ParseRange(TimeRange{Start: "invalid"}, DefaultMaxQueryDays)
 	if err == nil {
-		t.Fatal("parseRange should reject invalid time format")
+		t.Fatal("ParseRange should reject invalid time format")
 	}
 }
 
 func TestParseRangeRejectsStartAfterEnd(t *testing.T) {
 	start := "2025-02-01T11:00:00Z"
 	end := "2025-02-01T10:00:00Z"
-	_, _, err := parseRange(start, end)
+	_, _, err := ParseRange(TimeRange{Start: start, End: end}, DefaultMaxQueryDays)
 	if err == nil {
-		t.Fatal("parseRange should reject start time after end time")
+		t.Fatal("ParseRange should reject start time after end time")
 	}
 }
 
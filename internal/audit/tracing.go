@@ -0,0 +1,38 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("vault-audit-mcp/internal/audit")
+
+// addTool registers a tool, unless it is disabled, whose calls each run in
+// a span named after the tool so Loki requests and summarization show up as
+// its children.
+func addTool[In any](s *Service, server *mcp.Server, tool *mcp.Tool, handler mcp.ToolHandlerFor[In, any]) {
+	name := tool.Name
+	if s.enabledTools != nil && !s.enabledTools[name] {
+		return
+	}
+	mcp.AddTool(server, tool, func(ctx context.Context, req *mcp.CallToolRequest, args In) (*mcp.CallToolResult, any, error) {
+		ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attribute.String("mcp.tool", name)))
+		defer span.End()
+		res, out, err := handler(ctx, req, args)
+		endSpanErr(span, err)
+		return res, out, err
+	})
+}
+
+// endSpanErr marks span as failed when err is non-nil.
+func endSpanErr(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
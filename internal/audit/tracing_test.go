@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSearchToolSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	now := time.Now().UTC()
+	backend := newFakeLoki(t, []fakeLokiLine{{Time: now.Add(-time.Minute), Entry: map[string]any{
+		"type":    "response",
+		"request": map[string]any{"id": "req-1", "operation": "read"},
+	}}})
+	session := connectService(t, NewService(backend))
+	res, err := session.CallTool(t.Context(), &mcp.CallToolParams{Name: "audit.search_events", Arguments: map[string]any{}})
+	if err != nil || res.IsError {
+		t.Fatalf("search_events failed: %v %+v", err, res)
+	}
+
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		byName[s.Name()] = s
+	}
+	tool := byName["audit.search_events"]
+	if tool == nil {
+		t.Fatal("missing tool span")
+	}
+	for name, parent := range map[string]string{
+		"audit.fetch_window":     "audit.search_events",
+		"audit.decode_window":    "audit.search_events",
+		"audit.summarize_search": "audit.search_events",
+		"loki.query_range":       "audit.fetch_window",
+	} {
+		span, p := byName[name], byName[parent]
+		if span == nil || p == nil {
+			t.Errorf("missing span %s or %s", name, parent)
+			continue
+		}
+		if span.Parent().SpanID() != p.SpanContext().SpanID() {
+			t.Errorf("%s should be a child of %s", name, parent)
+		}
+	}
+}
@@ -0,0 +1,510 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"vault-audit-mcp/internal/notify"
+)
+
+// Watch mode defaults.
+const (
+	DefaultWatchInterval  = time.Minute
+	DefaultWatchMaxEvents = 10000
+	// DefaultAlertSamples is the number of newest events attached to an
+	// alert.
+	DefaultAlertSamples = 5
+)
+
+// WatchDuration is a duration in the forms ParseDuration accepts, such as
+// "90s", "15m" or "1d", written as a string.
+type WatchDuration time.Duration
+
+// UnmarshalText parses a duration string.
+func (d *WatchDuration) UnmarshalText(b []byte) error {
+	v, err := ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = WatchDuration(v)
+	return nil
+}
+
+// MarshalText formats the duration as a Go duration string.
+func (d WatchDuration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// WatchConfig holds the alert rules evaluated in watch mode.
+type WatchConfig struct {
+	// Interval is the time between evaluations. Defaults to 1m.
+	Interval WatchDuration `json:"interval,omitempty"`
+	// StateFile persists evaluation and de-duplication state across
+	// restarts. Without it, state lives only in memory.
+	StateFile string `json:"state_file,omitempty"`
+	// MaxEvents caps the events read per rule and evaluation.
+	MaxEvents int         `json:"max_events,omitempty"`
+	RateLimit *RateLimit  `json:"rate_limit,omitempty"`
+	Rules     []AlertRule `json:"rules"`
+}
+
+// RateLimit caps the alerts sent across all rules. Alerts beyond the limit
+// are dropped and counted on the next alert sent.
+type RateLimit struct {
+	MaxAlerts int           `json:"max_alerts"`
+	Per       WatchDuration `json:"per"`
+}
+
+// AlertRule fires when the events matching its filter within the sliding
+// window reach the threshold, counted per group_by value.
+type AlertRule struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Filter      ReportFilter `json:"filter"`
+	// MinSeverity and Categories keep only events the analyzer rates at
+	// least this severe, or places in one of these categories.
+	MinSeverity string   `json:"min_severity,omitempty"`
+	Categories  []string `json:"categories,omitempty"`
+	// Window is the sliding window counted at each evaluation. It must be
+	// at least the evaluation interval.
+	Window    WatchDuration `json:"window"`
+	Threshold int           `json:"threshold,omitempty"`
+	// GroupBy counts each value of a dimension separately, e.g. actor or
+	// remote_address. Empty counts all matching events together.
+	GroupBy string `json:"group_by,omitempty"`
+	// Severity labels the alert. Defaults to the highest analyzed
+	// severity of its events.
+	Severity string `json:"severity,omitempty"`
+	// Cooldown is the minimum time between alerts of the same rule and
+	// group. Defaults to the window.
+	Cooldown WatchDuration `json:"cooldown,omitempty"`
+	// Sinks names the sinks to notify. Defaults to all sinks.
+	Sinks []string `json:"sinks,omitempty"`
+}
+
+var severityRank = map[EventSeverity]int{
+	SeverityInfo:     0,
+	SeverityLow:      1,
+	SeverityMedium:   2,
+	SeverityHigh:     3,
+	SeverityCritical: 4,
+}
+
+var eventCategories = []EventCategory{
+	CategoryAuthConfig, CategoryAuthAttempt, CategorySecretAccess, CategorySecretConfig,
+	CategoryPKI, CategoryPolicyConfig, CategoryRoleConfig, CategoryAuditConfig,
+	CategorySystemConfig, CategoryTokenMgmt, CategoryEntityMgmt, CategoryMountMgmt, CategoryOther,
+}
+
+// WatchState is the persisted state of a watcher.
+type WatchState struct {
+	Rules map[string]*RuleState `json:"rules"`
+	// Sent holds the send times within the rate limit period.
+	Sent []time.Time `json:"sent,omitempty"`
+	// Suppressed counts the alerts dropped by the rate limit since the last
+	// alert was sent.
+	Suppressed int `json:"suppressed,omitempty"`
+}
+
+// RuleState tracks one rule.
+type RuleState struct {
+	LastEvaluated time.Time `json:"last_evaluated"`
+	// Alerts holds the last alert per group.
+	Alerts map[string]*AlertState `json:"alerts,omitempty"`
+}
+
+// AlertState records the last alert of one rule and group. An alert is only
+// repeated for events newer than LastEvent, once the cooldown has passed.
+type AlertState struct {
+	LastSent  time.Time `json:"last_sent"`
+	LastEvent time.Time `json:"last_event"`
+}
+
+// Watcher evaluates alert rules and sends their alerts.
+type Watcher struct {
+	backend Backend
+	cfg     WatchConfig
+	sinks   map[string]notify.Sink
+	state   *WatchState
+}
+
+// NewWatcher validates cfg and loads the state file, if any. Every sink a
+// rule names must be in sinks.
+func NewWatcher(backend Backend, cfg WatchConfig, sinks map[string]notify.Sink) (*Watcher, error) {
+	if cfg.Interval == 0 {
+		cfg.Interval = WatchDuration(DefaultWatchInterval)
+	}
+	if cfg.MaxEvents == 0 {
+		cfg.MaxEvents = DefaultWatchMaxEvents
+	}
+	if err := cfg.validate(sinks); err != nil {
+		return nil, err
+	}
+	for i := range cfg.Rules {
+		r := &cfg.Rules[i]
+		if r.Threshold == 0 {
+			r.Threshold = 1
+		}
+		if r.Cooldown == 0 {
+			r.Cooldown = r.Window
+		}
+	}
+
+	state, err := loadWatchState(cfg.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	for name := range state.Rules {
+		if !slices.ContainsFunc(cfg.Rules, func(r AlertRule) bool { return r.Name == name }) {
+			delete(state.Rules, name)
+		}
+	}
+	return &Watcher{backend: backend, cfg: cfg, sinks: sinks, state: state}, nil
+}
+
+// Interval returns the time between evaluations.
+func (w *Watcher) Interval() time.Duration {
+	return time.Duration(w.cfg.Interval)
+}
+
+// State returns the watcher state.
+func (w *Watcher) State() *WatchState {
+	return w.state
+}
+
+func (c *WatchConfig) validate(sinks map[string]notify.Sink) error {
+	if c.Interval < WatchDuration(time.Second) {
+		return fmt.Errorf("interval must be at least 1s, got %s", time.Duration(c.Interval))
+	}
+	if c.MaxEvents < 0 {
+		return fmt.Errorf("max_events must not be negative")
+	}
+	if rl := c.RateLimit; rl != nil && (rl.MaxAlerts < 1 || rl.Per <= 0) {
+		return fmt.Errorf("rate_limit needs max_alerts of at least 1 and a positive per")
+	}
+	if len(c.Rules) == 0 {
+		return fmt.Errorf("no rules defined")
+	}
+	if len(sinks) == 0 {
+		return fmt.Errorf("no sinks defined")
+	}
+	seen := make(map[string]bool)
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		if !reportNamePattern.MatchString(r.Name) || seen[r.Name] {
+			return fmt.Errorf("rule name %q must be unique, lowercase letters, digits, - and _", r.Name)
+		}
+		seen[r.Name] = true
+		if err := r.validate(time.Duration(c.Interval), sinks); err != nil {
+			return fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r *AlertRule) validate(interval time.Duration, sinks map[string]notify.Sink) error {
+	if r.Window <= 0 {
+		return fmt.Errorf("window is required")
+	}
+	if time.Duration(r.Window) < interval {
+		return fmt.Errorf("window %s is shorter than the interval %s; events between evaluations would be missed", time.Duration(r.Window), interval)
+	}
+	if r.Threshold < 0 || r.Cooldown < 0 {
+		return fmt.Errorf("threshold and cooldown must not be negative")
+	}
+	if r.GroupBy != "" && !slices.Contains(reportDimensions, r.GroupBy) {
+		return fmt.Errorf("invalid group_by %q, must be one of %s", r.GroupBy, strings.Join(reportDimensions, ", "))
+	}
+	if _, ok := severityRank[EventSeverity(r.MinSeverity)]; r.MinSeverity != "" && !ok {
+		return fmt.Errorf("invalid min_severity %q, must be critical, high, medium, low or info", r.MinSeverity)
+	}
+	for _, c := range r.Categories {
+		if !slices.Contains(eventCategories, EventCategory(c)) {
+			return fmt.Errorf("invalid category %q", c)
+		}
+	}
+	for _, name := range r.Sinks {
+		if sinks[name] == nil {
+			return fmt.Errorf("unknown sink %q", name)
+		}
+	}
+	return r.Filter.validate()
+}
+
+func loadWatchState(path string) (*WatchState, error) {
+	state := &WatchState{Rules: make(map[string]*RuleState)}
+	if path == "" {
+		return state, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watch state: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid watch state in %s: %w", path, err)
+	}
+	if state.Rules == nil {
+		state.Rules = make(map[string]*RuleState)
+	}
+	return state, nil
+}
+
+// saveState writes the state file atomically.
+func (w *Watcher) saveState() error {
+	if w.cfg.StateFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(w.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(w.cfg.StateFile), ".watch-state-*")
+	if err != nil {
+		return fmt.Errorf("failed to write watch state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write watch state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write watch state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), w.cfg.StateFile); err != nil {
+		return fmt.Errorf("failed to write watch state: %w", err)
+	}
+	return nil
+}
+
+// alertGroup counts the matching events of one group.
+type alertGroup struct {
+	key      string
+	count    int
+	last     time.Time
+	severity EventSeverity
+	samples  []notify.Sample
+}
+
+// Evaluate evaluates every rule over the window ending at now and sends the
+// alerts that fire. It returns the alerts sent; errors of one rule or sink
+// do not stop the others. A deadline leaves the remaining counts partial,
+// and alerts built from them are marked truncated.
+func (w *Watcher) Evaluate(ctx context.Context, now time.Time) ([]notify.Alert, error) {
+	sent := []notify.Alert{}
+	var errs []error
+	for i := range w.cfg.Rules {
+		rule := &w.cfg.Rules[i]
+		alerts, err := w.evaluateRule(ctx, rule, now)
+		sent = append(sent, alerts...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", rule.Name, err))
+		}
+	}
+	if err := w.saveState(); err != nil {
+		errs = append(errs, err)
+	}
+	return sent, errors.Join(errs...)
+}
+
+func (w *Watcher) evaluateRule(ctx context.Context, rule *AlertRule, now time.Time) ([]notify.Alert, error) {
+	start := now.Add(-time.Duration(rule.Window))
+	groups, truncated, err := w.countRule(ctx, rule, start, now)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := w.state.Rules[rule.Name]
+	if rs == nil {
+		rs = &RuleState{}
+		w.state.Rules[rule.Name] = rs
+	}
+	if rs.Alerts == nil {
+		rs.Alerts = make(map[string]*AlertState)
+	}
+	rs.LastEvaluated = now
+	cooldown := time.Duration(rule.Cooldown)
+	for key, as := range rs.Alerts {
+		if as.LastEvent.Before(start) && now.Sub(as.LastSent) >= cooldown {
+			delete(rs.Alerts, key)
+		}
+	}
+
+	var sent []notify.Alert
+	var errs []error
+	for _, g := range groups {
+		if g.count < rule.Threshold {
+			continue
+		}
+		as := rs.Alerts[g.key]
+		if as != nil && (!g.last.After(as.LastEvent) || now.Sub(as.LastSent) < cooldown) {
+			continue
+		}
+
+		alert := notify.Alert{
+			Rule:        rule.Name,
+			Description: rule.Description,
+			Severity:    rule.Severity,
+			Count:       g.count,
+			Threshold:   rule.Threshold,
+			WindowStart: start.UTC(),
+			WindowEnd:   now.UTC(),
+			FiredAt:     now.UTC(),
+			Truncated:   truncated,
+			Samples:     g.samples,
+		}
+		if alert.Severity == "" {
+			alert.Severity = string(g.severity)
+		}
+		if rule.GroupBy != "" {
+			alert.GroupBy, alert.Group = rule.GroupBy, g.key
+		}
+
+		if !w.allowSend(now) {
+			w.state.Suppressed++
+			log.Printf("watch: rate limit reached, dropped alert %s", alert.Title())
+			rs.Alerts[g.key] = &AlertState{LastSent: now, LastEvent: g.last}
+			continue
+		}
+		alert.Suppressed = w.state.Suppressed
+		if err := w.send(ctx, rule, &alert); err != nil {
+			errs = append(errs, err)
+			if errors.Is(err, errNotDelivered) {
+				// Retried at the next evaluation.
+				continue
+			}
+		}
+		w.state.Sent = append(w.state.Sent, now)
+		w.state.Suppressed = 0
+		rs.Alerts[g.key] = &AlertState{LastSent: now, LastEvent: g.last}
+		sent = append(sent, alert)
+	}
+	return sent, errors.Join(errs...)
+}
+
+// countRule counts the events matching rule between start and end by
+// group, newest samples first.
+func (w *Watcher) countRule(ctx context.Context, rule *AlertRule, start, end time.Time) ([]*alertGroup, bool, error) {
+	filter := rule.Filter.searchFilter("")
+	filter.Start, filter.End = start, end
+	// Backends may not apply every field; matching again is cheap.
+	matcher, err := newSearchFilterMatcher(&filter)
+	if err != nil {
+		return nil, false, err
+	}
+	minRank := severityRank[EventSeverity(rule.MinSeverity)]
+
+	byKey := make(map[string]*alertGroup)
+	_, truncated, err := exportEvents(ctx, w.backend, filter, w.cfg.MaxEvents, func(ev *Event) error {
+		if !matcher.matches(*ev) {
+			return nil
+		}
+		if len(rule.Filter.ErrorClasses) > 0 && !slices.Contains(rule.Filter.ErrorClasses, dimensionValue(CompareErrorClass, ev)) {
+			return nil
+		}
+		analysis := AnalyzeEvent(ev)
+		if severityRank[analysis.Severity] < minRank {
+			return nil
+		}
+		if len(rule.Categories) > 0 && !slices.Contains(rule.Categories, string(analysis.Category)) {
+			return nil
+		}
+
+		key := ""
+		if rule.GroupBy != "" {
+			key = groupKey(rule.GroupBy, ev)
+		}
+		g := byKey[key]
+		if g == nil {
+			g = &alertGroup{key: key, severity: SeverityInfo}
+			byKey[key] = g
+		}
+		g.count++
+		if ev.Time.After(g.last) {
+			g.last = ev.Time
+		}
+		if severityRank[analysis.Severity] > severityRank[g.severity] {
+			g.severity = analysis.Severity
+		}
+		// Events arrive newest first.
+		if len(g.samples) < DefaultAlertSamples {
+			g.samples = append(g.samples, notify.Sample{
+				Time:      ev.Time.UTC(),
+				RequestID: ev.RequestID,
+				Actor:     eventActor(ev),
+				Namespace: ev.Namespace,
+				Operation: ev.Operation,
+				Path:      ev.Path,
+				Status:    ev.Status,
+				Severity:  string(analysis.Severity),
+				Summary:   analysis.Description,
+			})
+		}
+		return nil
+	})
+	incomplete := errors.Is(err, ErrIncomplete)
+	if err != nil && !incomplete {
+		return nil, false, err
+	}
+
+	groups := make([]*alertGroup, 0, len(byKey))
+	for _, g := range byKey {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].count != groups[j].count {
+			return groups[i].count > groups[j].count
+		}
+		return groups[i].key < groups[j].key
+	})
+	return groups, truncated || incomplete, nil
+}
+
+// allowSend reports whether the rate limit admits another alert at now.
+func (w *Watcher) allowSend(now time.Time) bool {
+	rl := w.cfg.RateLimit
+	if rl == nil {
+		w.state.Sent = nil
+		return true
+	}
+	cutoff := now.Add(-time.Duration(rl.Per))
+	w.state.Sent = slices.DeleteFunc(w.state.Sent, func(t time.Time) bool { return !t.After(cutoff) })
+	return len(w.state.Sent) < rl.MaxAlerts
+}
+
+// errNotDelivered marks an alert no sink accepted.
+var errNotDelivered = errors.New("alert not delivered")
+
+// send delivers alert to the rule's sinks. It fails with errNotDelivered
+// when every sink failed; failures of some sinks are returned alongside a
+// delivered alert.
+func (w *Watcher) send(ctx context.Context, rule *AlertRule, alert *notify.Alert) error {
+	names := rule.Sinks
+	if len(names) == 0 {
+		for name := range w.sinks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	var errs []error
+	for _, name := range names {
+		if err := w.sinks[name].Send(ctx, alert); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", name, err))
+		}
+	}
+	if len(errs) == len(names) {
+		errs = append(errs, errNotDelivered)
+	}
+	return errors.Join(errs...)
+}
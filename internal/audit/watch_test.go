@@ -0,0 +1,219 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"vault-audit-mcp/internal/notify"
+)
+
+// recordingSink records the alerts it receives, failing while err is set.
+type recordingSink struct {
+	alerts []notify.Alert
+	err    error
+}
+
+func (s *recordingSink) Send(_ context.Context, alert *notify.Alert) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.alerts = append(s.alerts, *alert)
+	return nil
+}
+
+func (s *recordingSink) take() []string {
+	var out []string
+	for _, a := range s.alerts {
+		out = append(out, a.Rule+"/"+a.Group)
+	}
+	s.alerts = nil
+	return out
+}
+
+func failedLogin(at time.Time, addr string) Event {
+	return Event{Time: at, AuditType: "response", Operation: "update", Path: "auth/userpass/login/bob",
+		Status: "error", RemoteAddr: addr, Raw: map[string]any{"error": "invalid username or password"}}
+}
+
+// watchBackend keeps its events newest first as they are added.
+func watchBackend(events ...Event) *timedBackend {
+	b := &timedBackend{}
+	addEvents(b, events...)
+	return b
+}
+
+func addEvents(b *timedBackend, events ...Event) {
+	b.events = append(b.events, events...)
+	slices.SortFunc(b.events, func(x, y Event) int { return y.Time.Compare(x.Time) })
+}
+
+func watchConfig(stateFile string) WatchConfig {
+	return WatchConfig{
+		StateFile: stateFile,
+		Rules: []AlertRule{
+			{
+				Name:      "failed_logins",
+				Filter:    ReportFilter{FieldSet: FieldSet{Statuses: []string{"error"}, PathRegexes: []string{`^auth/.+/login`}}},
+				Window:    WatchDuration(10 * time.Minute),
+				Threshold: 2,
+				GroupBy:   ReportRemoteAddress,
+				Cooldown:  WatchDuration(2 * time.Minute),
+			},
+			{
+				Name:        "critical_changes",
+				MinSeverity: "critical",
+				Window:      WatchDuration(5 * time.Minute),
+				Severity:    "page",
+			},
+		},
+	}
+}
+
+func TestWatcherEvaluate(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	backend := watchBackend(
+		failedLogin(now.Add(-1*time.Minute), "10.0.0.9"),
+		failedLogin(now.Add(-2*time.Minute), "10.0.0.9"),
+		failedLogin(now.Add(-3*time.Minute), "10.0.0.9"),
+		failedLogin(now.Add(-4*time.Minute), "10.0.0.8"),
+		// Outside the window.
+		failedLogin(now.Add(-20*time.Minute), "10.0.0.8"),
+		Event{Time: now.Add(-30 * time.Second), AuditType: "response", Display: "root", Operation: "update", Path: "sys/policy/payments"},
+		Event{Time: now.Add(-40 * time.Second), AuditType: "request", Display: "root", Operation: "update", Path: "sys/policy/payments"},
+		Event{Time: now.Add(-50 * time.Second), AuditType: "response", Display: "app", Operation: "read", Path: "secret/data/app"},
+	)
+	sink := &recordingSink{}
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	w, err := NewWatcher(backend, watchConfig(stateFile), map[string]notify.Sink{"test": sink})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+
+	alerts, err := w.Evaluate(t.Context(), now)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if got := sink.take(); strings.Join(got, ",") != "failed_logins/10.0.0.9,critical_changes/" {
+		t.Fatalf("alerts = %v", got)
+	}
+	logins := alerts[0]
+	if logins.Count != 3 || logins.Severity != "high" || len(logins.Samples) != 3 ||
+		!logins.Samples[0].Time.Equal(now.Add(-time.Minute)) || !logins.WindowStart.Equal(now.Add(-10*time.Minute)) {
+		t.Errorf("failed logins alert = %+v", logins)
+	}
+	if critical := alerts[1]; critical.Count != 1 || critical.Severity != "page" || critical.Samples[0].Actor != "root" {
+		t.Errorf("critical alert = %+v", critical)
+	}
+
+	// The same events do not fire again.
+	if _, err := w.Evaluate(t.Context(), now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.take(); len(got) != 0 {
+		t.Errorf("repeated evaluation sent %v", got)
+	}
+
+	// A new event fires again once the cooldown has passed.
+	addEvents(backend, failedLogin(now.Add(90*time.Second), "10.0.0.9"))
+	if _, err := w.Evaluate(t.Context(), now.Add(100*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.take(); len(got) != 0 {
+		t.Errorf("evaluation within the cooldown sent %v", got)
+	}
+	if _, err := w.Evaluate(t.Context(), now.Add(3*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.alerts; len(got) != 1 || got[0].Group != "10.0.0.9" || got[0].Count != 4 {
+		t.Errorf("alerts after the cooldown = %+v", got)
+	}
+	sink.take()
+
+	// State survives a restart.
+	restarted, err := NewWatcher(backend, watchConfig(stateFile), map[string]notify.Sink{"test": sink})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs := restarted.State().Rules["failed_logins"]; rs == nil || !rs.LastEvaluated.Equal(now.Add(3*time.Minute)) {
+		t.Errorf("restored rule state = %+v", rs)
+	}
+	if _, err := restarted.Evaluate(t.Context(), now.Add(4*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.take(); len(got) != 0 {
+		t.Errorf("restarted watcher sent %v", got)
+	}
+}
+
+func TestWatcherRateLimitAndDelivery(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	backend := watchBackend(
+		failedLogin(now.Add(-1*time.Minute), "10.0.0.9"),
+		failedLogin(now.Add(-2*time.Minute), "10.0.0.9"),
+		failedLogin(now.Add(-3*time.Minute), "10.0.0.8"),
+		failedLogin(now.Add(-4*time.Minute), "10.0.0.8"),
+	)
+	cfg := watchConfig("")
+	cfg.Rules = cfg.Rules[:1]
+	cfg.RateLimit = &RateLimit{MaxAlerts: 1, Per: WatchDuration(time.Hour)}
+	down := &recordingSink{err: errors.New("connection refused")}
+	w, err := NewWatcher(backend, cfg, map[string]notify.Sink{"down": down})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Undelivered alerts are retried and do not count against the limit.
+	if _, err := w.Evaluate(t.Context(), now); err == nil || !strings.Contains(err.Error(), "sink down: connection refused") {
+		t.Errorf("error = %v, want the sink failure", err)
+	}
+	if len(w.State().Sent) != 0 || len(w.State().Rules["failed_logins"].Alerts) != 0 {
+		t.Errorf("state after failed delivery = %+v", w.State())
+	}
+
+	down.err = nil
+	alerts, err := w.Evaluate(t.Context(), now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Group != "10.0.0.8" || w.State().Suppressed != 1 {
+		t.Errorf("rate limited alerts = %+v, suppressed %d", alerts, w.State().Suppressed)
+	}
+
+	addEvents(backend, failedLogin(now.Add(60*time.Minute), "10.0.0.9"), failedLogin(now.Add(61*time.Minute), "10.0.0.9"))
+	alerts, err = w.Evaluate(t.Context(), now.Add(62*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Group != "10.0.0.9" || alerts[0].Suppressed != 1 || w.State().Suppressed != 0 {
+		t.Errorf("alerts after the rate limit period = %+v", alerts)
+	}
+}
+
+func TestNewWatcherValidation(t *testing.T) {
+	sinks := map[string]notify.Sink{"test": &recordingSink{}}
+	for _, tt := range []struct {
+		edit func(*WatchConfig)
+		want string
+	}{
+		{func(c *WatchConfig) { c.Rules = nil }, "no rules"},
+		{func(c *WatchConfig) { c.Interval = WatchDuration(20 * time.Minute) }, "shorter than the interval"},
+		{func(c *WatchConfig) { c.Rules[1].Name = "failed_logins" }, "must be unique"},
+		{func(c *WatchConfig) { c.Rules[0].Sinks = []string{"pager"} }, `unknown sink "pager"`},
+		{func(c *WatchConfig) { c.Rules[1].MinSeverity = "severe" }, "invalid min_severity"},
+		{func(c *WatchConfig) { c.Rules[1].Categories = []string{"secrets"} }, "invalid category"},
+		{func(c *WatchConfig) { c.Rules[0].GroupBy = "color" }, "invalid group_by"},
+		{func(c *WatchConfig) { c.Rules[0].Filter.ErrorClasses = []string{"nope"} }, "invalid error_class"},
+		{func(c *WatchConfig) { c.RateLimit = &RateLimit{MaxAlerts: 0} }, "rate_limit"},
+	} {
+		cfg := watchConfig("")
+		tt.edit(&cfg)
+		if _, err := NewWatcher(&timedBackend{}, cfg, sinks); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("error = %v, want %q", err, tt.want)
+		}
+	}
+}
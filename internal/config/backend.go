@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"vault-audit-mcp/internal/audit"
+	"vault-audit-mcp/internal/loki"
+)
+
+// NewBackend builds the audit backend described by p: the Loki backend with
+// its redaction policy and query options, wrapped in an
+// *audit.CachingBackend when the result cache is enabled.
+func (p *Profile) NewBackend() (audit.Backend, error) {
+	client, err := loki.NewClient(p.Loki.URL, &loki.ClientOptions{
+		BearerToken:    p.Loki.BearerToken,
+		Username:       p.Loki.Username,
+		Password:       p.Loki.Password,
+		TenantID:       p.Loki.TenantID,
+		Headers:        p.Loki.Headers,
+		TLSSkipVerify:  p.Loki.TLSSkipVerify,
+		CAFile:         p.Loki.CAFile,
+		ClientCertFile: p.Loki.ClientCert,
+		ClientKeyFile:  p.Loki.ClientKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid Loki client configuration: %w", err)
+	}
+	if tenants := client.Tenants(); len(tenants) > 0 {
+		log.Printf("querying Loki tenants: %s", strings.Join(tenants, "|"))
+	}
+
+	var labelsCfg *audit.LabelConfig
+	if p.Loki.LabelsMode == LabelsCustom {
+		labelsCfg = &audit.LabelConfig{
+			BaseLabels:     p.Loki.BaseLabels,
+			UseVaultLabels: false,
+		}
+		log.Printf("using custom base labels: %v (vault label filters disabled)", p.Loki.BaseLabels)
+	}
+
+	backend := audit.NewLokiBackend(client, labelsCfg)
+	backend.SetDebug(p.DebugLog)
+	backend.SetQueryOptions(audit.QueryOptions{
+		Parallelism:  p.Limits.Parallelism,
+		InitialChunk: time.Duration(p.Limits.ChunkSize),
+		Limits: audit.QueryLimits{
+			MaxLimit: p.Limits.MaxQueryLimit,
+			MaxDays:  p.Limits.MaxQueryDays,
+		},
+	})
+
+	// Optional: JSON redaction policy replacing the strict built-in default.
+	if path := p.RedactionPolicy; path != "" {
+		policy, err := audit.LoadRedactionPolicy(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction policy: %w", err)
+		}
+		backend.SetRedactionPolicy(policy)
+		log.Printf("using redaction policy from %s (%d rules)", path, len(policy.Rules))
+	}
+
+	// Optional: cache results for settled time windows.
+	if p.Cache.MaxMB > 0 {
+		log.Printf("result cache enabled (%d MB)", p.Cache.MaxMB)
+		return audit.NewCachingBackend(backend, &audit.CacheOptions{
+			MaxBytes:    int64(p.Cache.MaxMB) << 20,
+			SettleDelay: time.Duration(p.Cache.SettleDelay),
+			Window:      time.Duration(p.Limits.ChunkSize),
+		}), nil
+	}
+	return backend, nil
+}
@@ -0,0 +1,509 @@
+// Package config loads server configuration from a YAML or TOML file with
+// named profiles, applies environment variable overrides and validates the
+// result.
+//
+// A file holds any number of profiles:
+//
+//	default_profile: prod
+//	profiles:
+//	  prod:
+//	    loki:
+//	      url: https://loki.example.com
+//	      tenant_id: vault
+//	  dev:
+//	    loki:
+//	      url: http://localhost:3100
+//
+// Without a file, the configuration is built from defaults and environment
+// variables alone.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"vault-audit-mcp/internal/audit"
+	"vault-audit-mcp/internal/tracing"
+)
+
+// Backend types.
+const (
+	BackendLoki = "loki"
+)
+
+// Labels modes.
+const (
+	// LabelsVault uses Vault-specific stream labels (vault_operation, ...).
+	LabelsVault = "vault"
+	// LabelsCustom selects streams with BaseLabels and filters on content.
+	LabelsCustom = "custom"
+)
+
+// Duration is a time.Duration written as a Go duration string ("10m").
+type Duration time.Duration
+
+// UnmarshalText implements encoding.TextUnmarshaler for YAML and TOML.
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// File is the on-disk configuration.
+type File struct {
+	DefaultProfile string              `yaml:"default_profile" toml:"default_profile"`
+	Profiles       map[string]*Profile `yaml:"profiles" toml:"profiles"`
+}
+
+// Profile is one named server configuration.
+type Profile struct {
+	// Backend is the audit log store; only "loki" is supported.
+	Backend string `yaml:"backend" toml:"backend"`
+
+	Loki    LokiConfig    `yaml:"loki" toml:"loki"`
+	Limits  LimitsConfig  `yaml:"limits" toml:"limits"`
+	Cache   CacheConfig   `yaml:"cache" toml:"cache"`
+	Tracing TracingConfig `yaml:"tracing" toml:"tracing"`
+
+	// RedactionPolicy is the path to a JSON redaction policy.
+	RedactionPolicy string `yaml:"redaction_policy" toml:"redaction_policy"`
+	// HMACKeyFile is the audit device HMAC key enabling audit.find_by_hmac.
+	HMACKeyFile string `yaml:"hmac_key_file" toml:"hmac_key_file"`
+	// IdentitySnapshot is a JSON export of Vault identity used to resolve
+	// entity, alias, group and mount names. It is reloaded when it changes.
+	IdentitySnapshot string `yaml:"identity_snapshot" toml:"identity_snapshot"`
+	// PolicyDir holds Vault ACL policy files for audit.policy_usage, one per
+	// policy, in subdirectories per namespace.
+	PolicyDir string `yaml:"policy_dir" toml:"policy_dir"`
+	// PromptsDir holds additional investigation prompt templates.
+	PromptsDir string `yaml:"prompts_dir" toml:"prompts_dir"`
+	// ReportTemplatesDir holds additional compliance report templates.
+	ReportTemplatesDir string `yaml:"report_templates_dir" toml:"report_templates_dir"`
+	// ExportDir is where audit.export writes evidence bundles.
+	ExportDir string `yaml:"export_dir" toml:"export_dir"`
+	// EnabledTools restricts the registered tools; empty enables all.
+	EnabledTools []string `yaml:"enabled_tools" toml:"enabled_tools"`
+	// MetricsAddr serves Prometheus metrics when set.
+	MetricsAddr string `yaml:"metrics_addr" toml:"metrics_addr"`
+	// DebugLog logs generated queries and sample lines.
+	DebugLog bool `yaml:"debug_log" toml:"debug_log"`
+}
+
+// LokiConfig configures the Loki connection and stream selection.
+type LokiConfig struct {
+	URL           string            `yaml:"url" toml:"url"`
+	BearerToken   string            `yaml:"bearer_token" toml:"bearer_token"`
+	Username      string            `yaml:"username" toml:"username"`
+	Password      string            `yaml:"password" toml:"password"`
+	TenantID      string            `yaml:"tenant_id" toml:"tenant_id"`
+	Headers       map[string]string `yaml:"headers" toml:"headers"`
+	TLSSkipVerify bool              `yaml:"tls_skip_verify" toml:"tls_skip_verify"`
+	CAFile        string            `yaml:"ca_file" toml:"ca_file"`
+	ClientCert    string            `yaml:"client_cert" toml:"client_cert"`
+	ClientKey     string            `yaml:"client_key" toml:"client_key"`
+
+	// LabelsMode is "vault" (default) or "custom". Custom mode requires
+	// BaseLabels and filters on log content instead of Vault labels.
+	LabelsMode string            `yaml:"labels_mode" toml:"labels_mode"`
+	BaseLabels map[string]string `yaml:"base_labels" toml:"base_labels"`
+}
+
+// LimitsConfig bounds queries.
+type LimitsConfig struct {
+	MaxQueryLimit int      `yaml:"max_query_limit" toml:"max_query_limit"`
+	MaxQueryDays  int      `yaml:"max_query_days" toml:"max_query_days"`
+	ChunkSize     Duration `yaml:"chunk_size" toml:"chunk_size"`
+	Parallelism   int      `yaml:"parallelism" toml:"parallelism"`
+	QueryTimeout  Duration `yaml:"query_timeout" toml:"query_timeout"`
+}
+
+// CacheConfig configures the result cache; MaxMB 0 disables it.
+type CacheConfig struct {
+	MaxMB       int      `yaml:"max_mb" toml:"max_mb"`
+	SettleDelay Duration `yaml:"settle_delay" toml:"settle_delay"`
+}
+
+// TracingConfig configures OpenTelemetry trace export.
+type TracingConfig struct {
+	Exporter string `yaml:"exporter" toml:"exporter"`
+	Endpoint string `yaml:"endpoint" toml:"endpoint"`
+	File     string `yaml:"file" toml:"file"`
+}
+
+// Default returns the built-in configuration.
+func Default() *Profile {
+	return &Profile{
+		Backend: BackendLoki,
+		Loki: LokiConfig{
+			URL:        "http://localhost:3100",
+			LabelsMode: LabelsVault,
+		},
+		Limits: LimitsConfig{
+			MaxQueryLimit: audit.DefaultMaxQueryLimit,
+			MaxQueryDays:  audit.DefaultMaxQueryDays,
+			ChunkSize:     Duration(10 * time.Minute),
+			Parallelism:   4,
+		},
+	}
+}
+
+// Load reads the named profile from path, applies environment overrides and
+// validates the result. An empty path uses only defaults and the
+// environment; an empty profile selects the file's default_profile, or the
+// only profile when there is just one.
+func Load(path, profile string) (*Profile, error) {
+	p := Default()
+	if path != "" {
+		f, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		selected, err := f.profile(profile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		p = selected
+	} else if profile != "" {
+		return nil, fmt.Errorf("profile %q requested but no config file given", profile)
+	}
+
+	if err := p.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func readFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var f File
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&f); err != nil {
+			return nil, fmt.Errorf("invalid YAML in %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), &f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TOML in %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("invalid TOML in %s: unknown key %q", path, undecoded[0].String())
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q (want .yaml, .yml or .toml)", ext)
+	}
+	return &f, nil
+}
+
+// profile returns the named profile layered over the defaults.
+func (f *File) profile(name string) (*Profile, error) {
+	if len(f.Profiles) == 0 {
+		return nil, fmt.Errorf("no profiles defined")
+	}
+	if name == "" {
+		name = f.DefaultProfile
+	}
+	if name == "" {
+		if len(f.Profiles) != 1 {
+			return nil, fmt.Errorf("several profiles defined; select one with --profile or default_profile (available: %s)", strings.Join(f.names(), ", "))
+		}
+		for n := range f.Profiles {
+			name = n
+		}
+	}
+	p, ok := f.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(f.names(), ", "))
+	}
+	if p == nil {
+		p = &Profile{}
+	}
+	return mergeDefaults(p), nil
+}
+
+func (f *File) names() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for n := range f.Profiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mergeDefaults fills unset fields of p with the built-in defaults.
+func mergeDefaults(p *Profile) *Profile {
+	d := Default()
+	out := *p
+	if out.Backend == "" {
+		out.Backend = d.Backend
+	}
+	if out.Loki.URL == "" {
+		out.Loki.URL = d.Loki.URL
+	}
+	if out.Loki.LabelsMode == "" {
+		out.Loki.LabelsMode = LabelsVault
+		if len(out.Loki.BaseLabels) > 0 {
+			out.Loki.LabelsMode = LabelsCustom
+		}
+	}
+	if out.Limits.MaxQueryLimit == 0 {
+		out.Limits.MaxQueryLimit = d.Limits.MaxQueryLimit
+	}
+	if out.Limits.MaxQueryDays == 0 {
+		out.Limits.MaxQueryDays = d.Limits.MaxQueryDays
+	}
+	if out.Limits.ChunkSize == 0 {
+		out.Limits.ChunkSize = d.Limits.ChunkSize
+	}
+	if out.Limits.Parallelism == 0 {
+		out.Limits.Parallelism = d.Limits.Parallelism
+	}
+	return &out
+}
+
+// applyEnv overrides file values with the documented environment variables.
+func (p *Profile) applyEnv(lookup func(string) (string, bool)) error {
+	str := func(name string, dst *string) {
+		if v, ok := lookup(name); ok && v != "" {
+			*dst = v
+		}
+	}
+	var errs []string
+	boolean := func(name string, dst *bool) {
+		if v, ok := lookup(name); ok && v != "" {
+			switch strings.ToLower(v) {
+			case "1", "true":
+				*dst = true
+			case "0", "false":
+				*dst = false
+			default:
+				errs = append(errs, fmt.Sprintf("%s: want true or false, got %q", name, v))
+			}
+		}
+	}
+	integer := func(name string, dst *int) {
+		if v, ok := lookup(name); ok && v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: want an integer, got %q", name, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	duration := func(name string, dst *Duration) {
+		if v, ok := lookup(name); ok && v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: want a duration such as 10m, got %q", name, v))
+				return
+			}
+			*dst = Duration(d)
+		}
+	}
+	jsonMap := func(name string, dst *map[string]string) bool {
+		v, ok := lookup(name)
+		if !ok || v == "" {
+			return false
+		}
+		var m map[string]string
+		if err := json.Unmarshal([]byte(v), &m); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: invalid JSON object: %v", name, err))
+			return false
+		}
+		*dst = m
+		return true
+	}
+
+	str("LOKI_URL", &p.Loki.URL)
+	str("LOKI_BEARER_TOKEN", &p.Loki.BearerToken)
+	str("LOKI_USERNAME", &p.Loki.Username)
+	str("LOKI_PASSWORD", &p.Loki.Password)
+	str("LOKI_TENANT_ID", &p.Loki.TenantID)
+	boolean("LOKI_TLS_SKIP_VERIFY", &p.Loki.TLSSkipVerify)
+	str("LOKI_CA_FILE", &p.Loki.CAFile)
+	str("LOKI_CLIENT_CERT", &p.Loki.ClientCert)
+	str("LOKI_CLIENT_KEY", &p.Loki.ClientKey)
+	jsonMap("LOKI_HEADERS", &p.Loki.Headers)
+	if jsonMap("LOKI_BASE_LABELS", &p.Loki.BaseLabels) {
+		p.Loki.LabelsMode = LabelsCustom
+	}
+	str("LOKI_LABELS_MODE", &p.Loki.LabelsMode)
+
+	integer("AUDIT_MAX_QUERY_LIMIT", &p.Limits.MaxQueryLimit)
+	integer("AUDIT_MAX_QUERY_DAYS", &p.Limits.MaxQueryDays)
+	duration("AUDIT_CHUNK_SIZE", &p.Limits.ChunkSize)
+	integer("AUDIT_QUERY_PARALLELISM", &p.Limits.Parallelism)
+	duration("AUDIT_QUERY_TIMEOUT", &p.Limits.QueryTimeout)
+	integer("AUDIT_CACHE_MAX_MB", &p.Cache.MaxMB)
+	duration("AUDIT_CACHE_SETTLE_DELAY", &p.Cache.SettleDelay)
+
+	str("AUDIT_REDACTION_POLICY", &p.RedactionPolicy)
+	str("VAULT_AUDIT_HMAC_KEY_FILE", &p.HMACKeyFile)
+	str("VAULT_AUDIT_IDENTITY_SNAPSHOT", &p.IdentitySnapshot)
+	str("VAULT_AUDIT_POLICY_DIR", &p.PolicyDir)
+	str("AUDIT_PROMPTS_DIR", &p.PromptsDir)
+	str("AUDIT_REPORT_TEMPLATES_DIR", &p.ReportTemplatesDir)
+	str("AUDIT_EXPORT_DIR", &p.ExportDir)
+	if v, ok := lookup("AUDIT_ENABLED_TOOLS"); ok && v != "" {
+		p.EnabledTools = splitList(v)
+	}
+	str("METRICS_ADDR", &p.MetricsAddr)
+	boolean("AUDIT_DEBUG_LOG", &p.DebugLog)
+
+	str("OTEL_TRACES_EXPORTER", &p.Tracing.Exporter)
+	str("OTEL_TRACES_FILE", &p.Tracing.File)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid environment: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Validate reports every problem with the profile in one error.
+func (p *Profile) Validate() error {
+	var errs []string
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	if p.Backend != BackendLoki {
+		add("backend: unsupported backend %q (want %s)", p.Backend, BackendLoki)
+	}
+	if u, err := url.Parse(p.Loki.URL); err != nil || u.Scheme == "" || u.Host == "" {
+		add("loki.url: want an absolute http(s) URL, got %q", p.Loki.URL)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		add("loki.url: unsupported scheme %q", u.Scheme)
+	}
+	switch p.Loki.LabelsMode {
+	case LabelsVault:
+	case LabelsCustom:
+		if len(p.Loki.BaseLabels) == 0 {
+			add("loki.base_labels: required when labels_mode is custom")
+		}
+	default:
+		add("loki.labels_mode: want %s or %s, got %q", LabelsVault, LabelsCustom, p.Loki.LabelsMode)
+	}
+	if (p.Loki.ClientCert == "") != (p.Loki.ClientKey == "") {
+		add("loki.client_cert and loki.client_key must be set together")
+	}
+	if p.Loki.Password != "" && p.Loki.Username == "" {
+		add("loki.password: set without loki.username")
+	}
+
+	if p.Limits.MaxQueryLimit < audit.DefaultLimit {
+		add("limits.max_query_limit: must be at least %d, got %d", audit.DefaultLimit, p.Limits.MaxQueryLimit)
+	}
+	if p.Limits.MaxQueryDays < 1 {
+		add("limits.max_query_days: must be at least 1, got %d", p.Limits.MaxQueryDays)
+	}
+	if time.Duration(p.Limits.ChunkSize) < time.Second {
+		add("limits.chunk_size: must be at least 1s, got %s", time.Duration(p.Limits.ChunkSize))
+	}
+	if p.Limits.Parallelism < 1 {
+		add("limits.parallelism: must be at least 1, got %d", p.Limits.Parallelism)
+	}
+	if p.Limits.QueryTimeout < 0 {
+		add("limits.query_timeout: must not be negative")
+	}
+	if p.Cache.MaxMB < 0 {
+		add("cache.max_mb: must not be negative")
+	}
+	if p.Cache.SettleDelay < 0 {
+		add("cache.settle_delay: must not be negative")
+	}
+
+	for field, path := range map[string]string{
+		"redaction_policy":  p.RedactionPolicy,
+		"hmac_key_file":     p.HMACKeyFile,
+		"identity_snapshot": p.IdentitySnapshot,
+		"loki.ca_file":      p.Loki.CAFile,
+		"loki.client_cert":  p.Loki.ClientCert,
+		"loki.client_key":   p.Loki.ClientKey,
+	} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			add("%s: %v", field, err)
+		}
+	}
+	for field, dir := range map[string]string{
+		"prompts_dir":          p.PromptsDir,
+		"policy_dir":           p.PolicyDir,
+		"report_templates_dir": p.ReportTemplatesDir,
+	} {
+		if dir == "" {
+			continue
+		}
+		if fi, err := os.Stat(dir); err != nil {
+			add("%s: %v", field, err)
+		} else if !fi.IsDir() {
+			add("%s: %s is not a directory", field, dir)
+		}
+	}
+
+	known := make(map[string]bool, len(audit.ToolNames))
+	for _, n := range audit.ToolNames {
+		known[n] = true
+	}
+	for _, n := range p.EnabledTools {
+		if !known[n] {
+			add("enabled_tools: unknown tool %q (available: %s)", n, strings.Join(audit.ToolNames, ", "))
+		}
+	}
+
+	switch strings.ToLower(p.Tracing.Exporter) {
+	case "", tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterConsole:
+	case tracing.ExporterFile:
+		if p.Tracing.File == "" {
+			add("tracing.file: required when tracing.exporter is file")
+		}
+	default:
+		add("tracing.exporter: want none, otlp, console or file, got %q", p.Tracing.Exporter)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Strings(errs)
+	return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(errs, "\n  - "))
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
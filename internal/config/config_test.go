@@ -0,0 +1,237 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"vault-audit-mcp/internal/audit"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const yamlConfig = `
+default_profile: prod
+profiles:
+  prod:
+    loki:
+      url: https://loki.example.com
+      tenant_id: vault
+      base_labels:
+        kubernetes_namespace_name: hashicorp-vault
+    limits:
+      chunk_size: 5m
+      query_timeout: 30s
+    enabled_tools: [audit.search_events, audit.trace]
+  dev:
+    loki:
+      url: http://localhost:3100
+    debug_log: true
+`
+
+func TestLoadYAMLProfiles(t *testing.T) {
+	path := writeConfig(t, "config.yaml", yamlConfig)
+
+	prod, err := Load(path, "")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if prod.Loki.URL != "https://loki.example.com" || prod.Loki.LabelsMode != LabelsCustom {
+		t.Errorf("unexpected loki config %+v", prod.Loki)
+	}
+	if time.Duration(prod.Limits.ChunkSize) != 5*time.Minute || time.Duration(prod.Limits.QueryTimeout) != 30*time.Second {
+		t.Errorf("unexpected limits %+v", prod.Limits)
+	}
+	if prod.Limits.MaxQueryLimit != 500 || prod.Limits.Parallelism != 4 {
+		t.Errorf("unset limits should use defaults, got %+v", prod.Limits)
+	}
+
+	dev, err := Load(path, "dev")
+	if err != nil {
+		t.Fatalf("Load dev failed: %v", err)
+	}
+	if !dev.DebugLog || dev.Loki.LabelsMode != LabelsVault {
+		t.Errorf("unexpected dev profile %+v", dev)
+	}
+
+	if _, err := Load(path, "staging"); err == nil || !strings.Contains(err.Error(), "available: dev, prod") {
+		t.Errorf("unknown profile error = %v", err)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := writeConfig(t, "config.toml", `
+[profiles.default.loki]
+url = "http://loki:3100"
+
+[profiles.default.cache]
+max_mb = 64
+settle_delay = "10m"
+`)
+	p, err := Load(path, "")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if p.Cache.MaxMB != 64 || time.Duration(p.Cache.SettleDelay) != 10*time.Minute {
+		t.Errorf("unexpected cache config %+v", p.Cache)
+	}
+}
+
+func TestNewBackendKeepsProfileLimits(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+profiles:
+  small:
+    loki:
+      url: http://localhost:3100
+    limits:
+      max_query_limit: 200
+      max_query_days: 7
+  large:
+    loki:
+      url: http://localhost:3100
+    limits:
+      max_query_limit: 1000
+`)
+	want := map[string]audit.QueryLimits{
+		"small": {MaxLimit: 200, MaxDays: 7},
+		"large": {MaxLimit: 1000, MaxDays: audit.DefaultMaxQueryDays},
+	}
+	backends := make(map[string]audit.Backend)
+	for name := range want {
+		p, err := Load(path, name)
+		if err != nil {
+			t.Fatalf("Load %s failed: %v", name, err)
+		}
+		if backends[name], err = p.NewBackend(); err != nil {
+			t.Fatalf("NewBackend %s failed: %v", name, err)
+		}
+	}
+	// Building one profile's backend must not change another's limits.
+	for name, limits := range want {
+		if got := backends[name].(*audit.LokiBackend).QueryLimits(); got != limits {
+			t.Errorf("%s limits = %+v, want %+v", name, got, limits)
+		}
+	}
+}
+
+func TestEnvironmentOverridesProfile(t *testing.T) {
+	path := writeConfig(t, "config.yaml", yamlConfig)
+	t.Setenv("LOKI_URL", "https://override.example.com")
+	t.Setenv("AUDIT_QUERY_PARALLELISM", "8")
+	t.Setenv("AUDIT_DEBUG_LOG", "true")
+
+	p, err := Load(path, "prod")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if p.Loki.URL != "https://override.example.com" || p.Limits.Parallelism != 8 || !p.DebugLog {
+		t.Errorf("environment overrides not applied: %+v", p)
+	}
+}
+
+func TestValidationReportsAllErrors(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+profiles:
+  bad:
+    backend: elasticsearch
+    loki:
+      url: loki:3100
+      labels_mode: custom
+      client_cert: /tmp/cert.pem
+    limits:
+      max_query_limit: 10
+    enabled_tools: [audit.delete_everything]
+`)
+	_, err := Load(path, "")
+	if err == nil {
+		t.Fatal("invalid profile should fail validation")
+	}
+	for _, want := range []string{
+		"backend", "loki.url", "loki.base_labels", "client_key",
+		"limits.max_query_limit", "enabled_tools",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %s:\n%v", want, err)
+		}
+	}
+}
+
+func TestUnknownKeysRejected(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+profiles:
+  prod:
+    loki:
+      adress: http://typo:3100
+`)
+	if _, err := Load(path, ""); err == nil || !strings.Contains(err.Error(), "adress") {
+		t.Errorf("unknown key error = %v", err)
+	}
+}
+
+func TestInvalidEnvironment(t *testing.T) {
+	t.Setenv("AUDIT_CACHE_MAX_MB", "lots")
+	if _, err := Load("", ""); err == nil || !strings.Contains(err.Error(), "AUDIT_CACHE_MAX_MB") {
+		t.Errorf("invalid env error = %v", err)
+	}
+}
+
+func TestNewServiceAppliesProfile(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "hmac.key")
+	if err := os.WriteFile(keyFile, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(writeConfig(t, "config.yaml", yamlConfig), "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend, err := p.NewBackend()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc, err := p.NewService(context.Background(), backend)
+	if err != nil {
+		t.Fatalf("NewService failed: %v", err)
+	}
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "0"}, nil)
+	svc.AddTools(server)
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := server.Connect(context.Background(), serverTransport, nil); err != nil {
+		t.Fatal(err)
+	}
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "test", Version: "0"}, nil).Connect(context.Background(), clientTransport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	tools, err := session.ListTools(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tool := range tools.Tools {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "audit.search_events,audit.trace" {
+		t.Errorf("tools = %v, want the profile's enabled tools", names)
+	}
+
+	// An unusable HMAC key must fail the build rather than disable the tool.
+	p.HMACKeyFile = keyFile
+	if _, err := p.NewService(context.Background(), backend); err == nil || !strings.Contains(err.Error(), "invalid HMAC key file") {
+		t.Errorf("NewService with empty key file: err = %v", err)
+	}
+}
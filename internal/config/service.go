@@ -0,0 +1,68 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"vault-audit-mcp/internal/audit"
+)
+
+// NewService builds the audit service described by p on backend: the query
+// timeout, export directory, HMAC key, identity snapshot, policy and report
+// template directories and enabled tools. The identity snapshot is reloaded
+// when the file changes until ctx is done.
+func (p *Profile) NewService(ctx context.Context, backend audit.Backend) (*audit.Service, error) {
+	svc := audit.NewService(backend)
+
+	if p.ExportDir != "" {
+		svc.SetExportDir(p.ExportDir)
+	}
+
+	// Calls that hit the deadline return partial results marked incomplete.
+	svc.SetQueryTimeout(time.Duration(p.Limits.QueryTimeout))
+
+	// Optional: audit device HMAC key enabling audit.find_by_hmac.
+	if path := p.HMACKeyFile; path != "" {
+		key, err := audit.LoadAuditHMACKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HMAC key file: %w", err)
+		}
+		svc.SetAuditHMACKey(key)
+	}
+	// Optional: identity snapshot resolving entity and mount names, reloaded
+	// when the file changes.
+	if path := p.IdentitySnapshot; path != "" {
+		identity, err := audit.LoadIdentityStore(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity snapshot: %w", err)
+		}
+		entities, mounts := identity.Counts()
+		log.Printf("using identity snapshot from %s (%d entities, %d mounts)", path, entities, mounts)
+		go identity.Watch(ctx, audit.IdentityPollInterval)
+		svc.SetIdentity(identity)
+	}
+	// Optional: ACL policy files for audit.policy_usage, read on each call.
+	if dir := p.PolicyDir; dir != "" {
+		policies, err := audit.LoadPolicies(dir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy directory: %w", err)
+		}
+		log.Printf("using %d ACL policies from %s", len(policies.Policies()), dir)
+		svc.SetPolicyDir(dir)
+	}
+	// Compliance report templates: built-ins plus optional local templates,
+	// read on each call.
+	templates, err := audit.LoadReportTemplates(p.ReportTemplatesDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report templates directory: %w", err)
+	}
+	log.Printf("using %d compliance report templates", len(templates))
+	svc.SetReportTemplatesDir(p.ReportTemplatesDir)
+
+	if err := svc.SetEnabledTools(p.EnabledTools); err != nil {
+		return nil, fmt.Errorf("invalid enabled tools: %w", err)
+	}
+	return svc, nil
+}
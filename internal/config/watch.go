@@ -0,0 +1,173 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"vault-audit-mcp/internal/audit"
+	"vault-audit-mcp/internal/notify"
+)
+
+// Watch is a watch mode rules file: the alert rules of audit.WatchConfig
+// and the sinks they notify.
+//
+//	interval: 1m
+//	state_file: /var/lib/vault-audit-mcp/watch-state.json
+//	sinks:
+//	  - name: secops
+//	    type: slack
+//	    url: ${SLACK_WEBHOOK_URL}
+//	rules:
+//	  - name: critical_changes
+//	    min_severity: critical
+//	    window: 5m
+type Watch struct {
+	audit.WatchConfig
+	Sinks []SinkConfig `json:"sinks"`
+}
+
+// SinkConfig describes one notification sink. URL, header values and
+// Password may reference environment variables as ${NAME}.
+type SinkConfig struct {
+	Name string `json:"name"`
+	// Type is webhook, slack, smtp or file.
+	Type string `json:"type"`
+	// URL is the webhook or Slack-compatible incoming webhook URL.
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Path is the file alerts are appended to.
+	Path string `json:"path,omitempty"`
+
+	// SMTP settings; Addr is host:port.
+	Addr          string   `json:"addr,omitempty"`
+	From          string   `json:"from,omitempty"`
+	To            []string `json:"to,omitempty"`
+	Username      string   `json:"username,omitempty"`
+	Password      string   `json:"password,omitempty"`
+	SubjectPrefix string   `json:"subject_prefix,omitempty"`
+}
+
+// LoadWatch reads a YAML, TOML or JSON rules file. Rule filters use the
+// field names of report template filters.
+func LoadWatch(path string) (*Watch, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	// Rules embed audit filters, which are only tagged for JSON, so the
+	// file is decoded generically and converted.
+	var doc any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml", ".json":
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid rules file %s: %w", path, err)
+		}
+	case ".toml":
+		if _, err := toml.Decode(string(data), &doc); err != nil {
+			return nil, fmt.Errorf("invalid TOML in %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported rules file extension %q (want .yaml, .yml, .toml or .json)", ext)
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var w Watch
+	if err := dec.Decode(&w); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+
+	for i := range w.Sinks {
+		s := &w.Sinks[i]
+		s.URL = os.ExpandEnv(s.URL)
+		s.Password = os.ExpandEnv(s.Password)
+		for k, v := range s.Headers {
+			s.Headers[k] = os.ExpandEnv(v)
+		}
+	}
+	if err := w.validateSinks(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &w, nil
+}
+
+func (w *Watch) validateSinks() error {
+	var errs []string
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+	seen := make(map[string]bool)
+	for i, s := range w.Sinks {
+		field := fmt.Sprintf("sinks[%d]", i)
+		if s.Name == "" || seen[s.Name] {
+			add("%s: name must be set and unique, got %q", field, s.Name)
+		}
+		seen[s.Name] = true
+		switch s.Type {
+		case notify.TypeWebhook, notify.TypeSlack:
+			if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				add("%s.url: want an absolute http(s) URL", field)
+			}
+		case notify.TypeSMTP:
+			if _, _, err := net.SplitHostPort(s.Addr); err != nil {
+				add("%s.addr: want host:port, got %q", field, s.Addr)
+			}
+			if s.From == "" || len(s.To) == 0 {
+				add("%s: from and to are required", field)
+			}
+			if s.Password != "" && s.Username == "" {
+				add("%s.password: set without username", field)
+			}
+		case notify.TypeFile:
+			if s.Path == "" {
+				add("%s.path: required", field)
+			}
+		default:
+			add("%s.type: want %s, %s, %s or %s, got %q", field, notify.TypeWebhook, notify.TypeSlack, notify.TypeSMTP, notify.TypeFile, s.Type)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Strings(errs)
+	return fmt.Errorf("invalid sinks:\n  - %s", strings.Join(errs, "\n  - "))
+}
+
+// NewSinks builds the configured sinks by name.
+func (w *Watch) NewSinks() map[string]notify.Sink {
+	sinks := make(map[string]notify.Sink, len(w.Sinks))
+	for _, s := range w.Sinks {
+		switch s.Type {
+		case notify.TypeWebhook:
+			sinks[s.Name] = notify.NewWebhook(s.URL, s.Headers)
+		case notify.TypeSlack:
+			sinks[s.Name] = notify.NewSlack(s.URL)
+		case notify.TypeSMTP:
+			sinks[s.Name] = notify.NewSMTP(notify.SMTPOptions{
+				Addr:          s.Addr,
+				From:          s.From,
+				To:            s.To,
+				Username:      s.Username,
+				Password:      s.Password,
+				SubjectPrefix: s.SubjectPrefix,
+			})
+		case notify.TypeFile:
+			sinks[s.Name] = notify.NewFile(s.Path)
+		}
+	}
+	return sinks
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"vault-audit-mcp/internal/notify"
+)
+
+const watchRules = `
+interval: 30s
+state_file: /tmp/watch-state.json
+rate_limit:
+  max_alerts: 20
+  per: 1h
+sinks:
+  - name: secops
+    type: slack
+    url: ${TEST_SLACK_URL}
+  - name: siem
+    type: webhook
+    url: https://siem.example.com/hooks/vault
+    headers:
+      Authorization: Bearer ${TEST_SIEM_TOKEN}
+  - name: mail
+    type: smtp
+    addr: smtp.example.com:587
+    from: vault-audit@example.com
+    to: [secops@example.com]
+  - name: archive
+    type: file
+    path: /var/log/vault-audit-alerts.ndjson
+rules:
+  - name: failed_logins
+    filter:
+      status: [error]
+      path_regex: ['^auth/.+/login']
+    window: 10m
+    threshold: 5
+    group_by: remote_address
+    sinks: [secops, mail]
+  - name: critical_changes
+    min_severity: critical
+    window: 1d
+`
+
+func TestLoadWatch(t *testing.T) {
+	t.Setenv("TEST_SLACK_URL", "https://hooks.slack.com/services/T0/B0/x")
+	t.Setenv("TEST_SIEM_TOKEN", "s3cret")
+	w, err := LoadWatch(writeConfig(t, "rules.yaml", watchRules))
+	if err != nil {
+		t.Fatalf("LoadWatch failed: %v", err)
+	}
+	if time.Duration(w.Interval) != 30*time.Second || w.RateLimit == nil || time.Duration(w.RateLimit.Per) != time.Hour {
+		t.Errorf("unexpected watch settings %+v", w.WatchConfig)
+	}
+	logins := w.Rules[0]
+	if logins.Threshold != 5 || time.Duration(logins.Window) != 10*time.Minute || logins.Filter.PathRegexes[0] != "^auth/.+/login" {
+		t.Errorf("unexpected rule %+v", logins)
+	}
+	if time.Duration(w.Rules[1].Window) != 24*time.Hour {
+		t.Errorf("window = %s, want 24h", time.Duration(w.Rules[1].Window))
+	}
+	if w.Sinks[0].URL != "https://hooks.slack.com/services/T0/B0/x" || w.Sinks[1].Headers["Authorization"] != "Bearer s3cret" {
+		t.Errorf("environment references not expanded: %+v", w.Sinks[:2])
+	}
+
+	sinks := w.NewSinks()
+	if _, ok := sinks["mail"].(*notify.SMTP); !ok || len(sinks) != 4 {
+		t.Errorf("sinks = %v", sinks)
+	}
+}
+
+func TestLoadWatchRejectsInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		rules, want string
+	}{
+		{"rules:\n  - name: x\n    windw: 5m\n", "windw"},
+		{"rules:\n  - name: x\n    window: soon\n", "soon"},
+		{"sinks:\n  - name: a\n    type: pager\n", "sinks[0].type"},
+		{"sinks:\n  - name: a\n    type: slack\n    url: hooks.slack.com\n", "sinks[0].url"},
+		{"sinks:\n  - name: a\n    type: smtp\n    addr: smtp.example.com\n", "sinks[0].addr"},
+		{"sinks:\n  - name: a\n    type: file\n    path: /tmp/a\n  - name: a\n    type: file\n    path: /tmp/b\n", "unique"},
+	} {
+		if _, err := LoadWatch(writeConfig(t, "rules.yaml", tt.rules)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("error = %v, want %q", err, tt.want)
+		}
+	}
+}
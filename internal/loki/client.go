@@ -3,6 +3,7 @@ package loki
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,14 +11,33 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"vault-audit-mcp/internal/metrics"
 )
 
+var tracer = otel.Tracer("vault-audit-mcp/internal/loki")
+
+// TenantHeader is the header Loki uses to select the tenant(s) to query.
+const TenantHeader = "X-Scope-OrgID"
+
 type Client struct {
 	BaseURL     string
 	HTTPClient  *http.Client
 	bearerToken string
+	username    string
+	password    string
+	headers     map[string]string
+	tenants     []string
 }
 
 // ClientOptions holds optional configuration for the Loki client.
@@ -25,8 +45,22 @@ type Client struct {
 type ClientOptions struct {
 	// BearerToken is sent as an Authorization header if non-empty.
 	BearerToken string
+	// Username and Password enable HTTP basic auth when Username is non-empty.
+	// BearerToken takes precedence when both are set.
+	Username string
+	Password string
+	// TenantID is sent as the X-Scope-OrgID header. Multiple tenants may be
+	// queried together using Loki's pipe syntax, e.g. "tenant1|tenant2".
+	TenantID string
+	// Headers are additional headers sent with every request.
+	Headers map[string]string
 	// TLSSkipVerify disables TLS certificate verification when true.
 	TLSSkipVerify bool
+	// CAFile is a PEM bundle used to verify the Loki server certificate.
+	CAFile string
+	// ClientCertFile and ClientKeyFile enable mutual TLS when both are set.
+	ClientCertFile string
+	ClientKeyFile  string
 }
 
 const (
@@ -34,7 +68,7 @@ const (
 	queryRangeInitialBackoff = 250 * time.Millisecond
 )
 
-func NewClient(baseURL string, opts *ClientOptions) *Client {
+func NewClient(baseURL string, opts *ClientOptions) (*Client, error) {
 	if opts == nil {
 		opts = &ClientOptions{}
 	}
@@ -50,18 +84,130 @@ func NewClient(baseURL string, opts *ClientOptions) *Client {
 		ExpectContinueTimeout: 1 * time.Second,
 	}
 
-	if opts.TLSSkipVerify {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec
+	tlsCfg, err := buildTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsCfg
+
+	headers := make(map[string]string, len(opts.Headers))
+	for k, v := range opts.Headers {
+		if strings.EqualFold(k, TenantHeader) {
+			return nil, fmt.Errorf("use TenantID instead of setting %s in Headers", TenantHeader)
+		}
+		headers[k] = v
 	}
 
 	return &Client{
 		BaseURL:     baseURL,
 		bearerToken: opts.BearerToken,
+		username:    opts.Username,
+		password:    opts.Password,
+		headers:     headers,
+		tenants:     splitTenants(opts.TenantID),
 		HTTPClient: &http.Client{
 			Timeout:   90 * time.Second,
 			Transport: transport,
 		},
+	}, nil
+}
+
+// buildTLSConfig returns the TLS configuration for the transport, or nil
+// when no TLS options are set so that Go's defaults apply.
+func buildTLSConfig(opts *ClientOptions) (*tls.Config, error) {
+	if !opts.TLSSkipVerify && opts.CAFile == "" && opts.ClientCertFile == "" && opts.ClientKeyFile == "" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{InsecureSkipVerify: opts.TLSSkipVerify} //nolint:gosec
+
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", opts.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (opts.ClientCertFile == "") != (opts.ClientKeyFile == "") {
+		return nil, fmt.Errorf("client certificate and key must be set together")
 	}
+	if opts.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.ClientCertFile, opts.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// splitTenants parses a pipe-separated tenant list, dropping empty entries.
+func splitTenants(s string) []string {
+	var out []string
+	for _, t := range strings.Split(s, "|") {
+		t = strings.TrimSpace(t)
+		if t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a context that selects the tenant(s) for requests made
+// with it, overriding the client's configured TenantID for that call.
+// Multiple tenants use the pipe syntax ("tenant1|tenant2"). An empty value
+// leaves ctx unchanged.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	if strings.TrimSpace(tenant) == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the per-call tenant set with WithTenant, if any.
+func TenantFromContext(ctx context.Context) string {
+	t, _ := ctx.Value(tenantKey{}).(string)
+	return t
+}
+
+// Tenants returns the tenants configured on the client.
+func (c *Client) Tenants() []string {
+	return append([]string(nil), c.tenants...)
+}
+
+// tenantHeader resolves the X-Scope-OrgID value for a request. A per-call
+// tenant must be a subset of the configured tenants when any are configured,
+// so a tool caller cannot reach tenants the operator did not expose.
+func (c *Client) tenantHeader(ctx context.Context) (string, error) {
+	requested := TenantFromContext(ctx)
+	if requested == "" {
+		return strings.Join(c.tenants, "|"), nil
+	}
+
+	selected := splitTenants(requested)
+	if len(c.tenants) > 0 {
+		for _, t := range selected {
+			allowed := false
+			for _, ct := range c.tenants {
+				if t == ct {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				return "", fmt.Errorf("tenant %q is not configured; available tenants: %s", t, strings.Join(c.tenants, ", "))
+			}
+		}
+	}
+	return strings.Join(selected, "|"), nil
 }
 
 // QueryRange calls /loki/api/v1/query_range.
@@ -82,9 +228,14 @@ func (c *Client) QueryRange(ctx context.Context, query string, start, end time.T
 	}
 	u.RawQuery = q.Encode()
 
+	tenant, err := c.tenantHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var lastErr error
 	for attempt := 1; attempt <= queryRangeMaxAttempts; attempt++ {
-		out, retryable, err := c.queryRangeOnce(ctx, u.String())
+		out, retryable, err := c.queryRangeOnce(ctx, u.String(), tenant)
 		if err == nil {
 			return out, nil
 		}
@@ -94,6 +245,7 @@ func (c *Client) QueryRange(ctx context.Context, query string, start, end time.T
 			break
 		}
 
+		metrics.LokiRetries.Inc()
 		backoff := queryRangeInitialBackoff * time.Duration(1<<(attempt-1))
 		timer := time.NewTimer(backoff)
 		select {
@@ -107,21 +259,42 @@ func (c *Client) QueryRange(ctx context.Context, query string, start, end time.T
 	return nil, lastErr
 }
 
-func (c *Client) queryRangeOnce(ctx context.Context, url string) (*QueryRangeResponse, bool, error) {
+func (c *Client) queryRangeOnce(ctx context.Context, url, tenant string) (out *QueryRangeResponse, retryable bool, err error) {
+	ctx, span := tracer.Start(ctx, "loki.query_range", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, false, err
 	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	if tenant != "" {
+		req.Header.Set(TenantHeader, tenant)
+	}
 	if c.bearerToken != "" {
 		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
 	}
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
+		metrics.LokiRequests.Inc("error")
 		return nil, isRetryableTransportErr(err), fmt.Errorf("loki HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
+	metrics.LokiRequests.Inc(strconv.Itoa(resp.StatusCode))
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 
 	// Check status code before decoding to provide better error messages.
 	if resp.StatusCode != http.StatusOK {
@@ -129,17 +302,17 @@ func (c *Client) queryRangeOnce(ctx context.Context, url string) (*QueryRangeRes
 		return nil, retryable, fmt.Errorf("loki returned status %d: %s", resp.StatusCode, resp.Status)
 	}
 
-	var out QueryRangeResponse
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+	var decoded QueryRangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
 		return nil, isRetryableDecodeErr(err), fmt.Errorf("failed to decode loki response: %w", err)
 	}
-	if out.Status != "success" {
-		if out.Error != "" {
-			return nil, false, fmt.Errorf("loki query_range failed: %s (%s)", out.Error, out.ErrorType)
+	if decoded.Status != "success" {
+		if decoded.Error != "" {
+			return nil, false, fmt.Errorf("loki query_range failed: %s (%s)", decoded.Error, decoded.ErrorType)
 		}
-		return nil, false, fmt.Errorf("loki query_range failed: status=%s", out.Status)
+		return nil, false, fmt.Errorf("loki query_range failed: status=%s", decoded.Status)
 	}
-	return &out, false, nil
+	return &decoded, false, nil
 }
 
 func isRetryableHTTPStatus(status int) bool {
@@ -0,0 +1,114 @@
+package loki
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestServer(t *testing.T, check func(r *http.Request)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		check(r)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[]}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestQueryRangeSendsTenantAndAuthHeaders(t *testing.T) {
+	var got http.Header
+	srv := newTestServer(t, func(r *http.Request) { got = r.Header.Clone() })
+
+	c, err := NewClient(srv.URL, &ClientOptions{
+		Username: "reader",
+		Password: "secret",
+		TenantID: "team-a|team-b",
+		Headers:  map[string]string{"X-Custom": "yes"},
+	})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if _, err := c.QueryRange(context.Background(), `{service="vault"}`, time.Now().Add(-time.Minute), time.Now(), 10); err != nil {
+		t.Fatalf("QueryRange failed: %v", err)
+	}
+
+	if got.Get(TenantHeader) != "team-a|team-b" {
+		t.Errorf("tenant header = %q, want team-a|team-b", got.Get(TenantHeader))
+	}
+	if got.Get("X-Custom") != "yes" {
+		t.Errorf("custom header = %q, want yes", got.Get("X-Custom"))
+	}
+	if got.Get("Authorization") == "" {
+		t.Error("basic auth header should be set")
+	}
+}
+
+func TestQueryRangePerCallTenant(t *testing.T) {
+	var tenant string
+	srv := newTestServer(t, func(r *http.Request) { tenant = r.Header.Get(TenantHeader) })
+
+	c, err := NewClient(srv.URL, &ClientOptions{TenantID: "team-a|team-b"})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	ctx := WithTenant(context.Background(), "team-b")
+	if _, err := c.QueryRange(ctx, `{service="vault"}`, time.Now().Add(-time.Minute), time.Now(), 10); err != nil {
+		t.Fatalf("QueryRange failed: %v", err)
+	}
+	if tenant != "team-b" {
+		t.Errorf("tenant header = %q, want team-b", tenant)
+	}
+
+	ctx = WithTenant(context.Background(), "team-c")
+	if _, err := c.QueryRange(ctx, `{service="vault"}`, time.Now().Add(-time.Minute), time.Now(), 10); err == nil {
+		t.Fatal("QueryRange should reject a tenant that is not configured")
+	}
+}
+
+func TestNewClientRejectsPartialClientCert(t *testing.T) {
+	if _, err := NewClient("http://localhost:3100", &ClientOptions{ClientCertFile: "cert.pem"}); err == nil {
+		t.Fatal("NewClient should reject a client certificate without a key")
+	}
+}
+
+func TestQueryRangePropagatesTraceContext(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var traceparent string
+	srv := newTestServer(t, func(r *http.Request) { traceparent = r.Header.Get("traceparent") })
+	c, err := NewClient(srv.URL, nil)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	if _, err := c.QueryRange(ctx, `{service="vault"}`, time.Now().Add(-time.Minute), time.Now(), 10); err != nil {
+		t.Fatalf("QueryRange failed: %v", err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].Name() != "loki.query_range" {
+		t.Fatalf("unexpected spans %v", spans)
+	}
+	request := spans[0]
+	if request.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("query_range span should be a child of the caller's span")
+	}
+	if !strings.Contains(traceparent, request.SpanContext().SpanID().String()) {
+		t.Errorf("traceparent %q should carry the query_range span", traceparent)
+	}
+}
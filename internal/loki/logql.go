@@ -8,10 +8,19 @@ import (
 
 type Selector struct {
 	Labels map[string]string
+	// Matchers are rendered after the equality Labels, in order.
+	Matchers []Matcher
+}
+
+// Matcher is a non-equality label matcher; Op is one of =~, != or !~.
+type Matcher struct {
+	Label string
+	Op    string
+	Value string
 }
 
 func (s Selector) String() string {
-	if len(s.Labels) == 0 {
+	if len(s.Labels) == 0 && len(s.Matchers) == 0 {
 		return "{}"
 	}
 	keys := make([]string, 0, len(s.Labels))
@@ -28,6 +37,12 @@ func (s Selector) String() string {
 		}
 		b.WriteString(fmt.Sprintf(`%s=%q`, k, s.Labels[k]))
 	}
+	for i, m := range s.Matchers {
+		if i > 0 || len(keys) > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(fmt.Sprintf(`%s%s%q`, m.Label, m.Op, m.Value))
+	}
 	b.WriteString("}")
 	return b.String()
 }
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestWriteText(t *testing.T) {
+	calls := NewCounterVec("test_calls_total", "Test calls.", "tool")
+	calls.Inc(`a"b`)
+	calls.Add(2, "plain")
+	latency := NewHistogramVec("test_latency_seconds", "Test latency.", []float64{0.1, 1}, "tool")
+	latency.Observe(0.05, "x")
+	latency.Observe(0.5, "x")
+	latency.Observe(5, "x")
+	NewGaugeFunc("test_gauge", "Test gauge.", func() float64 { return 7 })
+
+	var b strings.Builder
+	WriteText(&b)
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE test_calls_total counter",
+		`test_calls_total{tool="a\"b"} 1`,
+		`test_calls_total{tool="plain"} 2`,
+		"# TYPE test_latency_seconds histogram",
+		`test_latency_seconds_bucket{tool="x",le="0.1"} 1`,
+		`test_latency_seconds_bucket{tool="x",le="1"} 2`,
+		`test_latency_seconds_bucket{tool="x",le="+Inf"} 3`,
+		`test_latency_seconds_sum{tool="x"} 5.55`,
+		`test_latency_seconds_count{tool="x"} 3`,
+		"test_gauge 7",
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestToolMiddleware(t *testing.T) {
+	handler := ToolMiddleware([]string{"works", "fails"})(func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if req.GetParams().(*mcp.CallToolParamsRaw).Name == "fails" {
+			return nil, errors.New("boom")
+		}
+		return &mcp.CallToolResult{}, nil
+	})
+	call := func(name string) {
+		handler(context.Background(), "tools/call", &mcp.CallToolRequest{Params: &mcp.CallToolParamsRaw{Name: name}})
+	}
+	call("works")
+	call("fails")
+	call("made-up-1")
+	call("made-up-2")
+
+	if got := ToolCalls.Value("works", "ok"); got != 1 {
+		t.Errorf("ok calls = %v, want 1", got)
+	}
+	if got := ToolCalls.Value("fails", "error"); got != 1 {
+		t.Errorf("error calls = %v, want 1", got)
+	}
+	if got := ToolCalls.Value(UnknownTool, "ok"); got != 2 {
+		t.Errorf("unknown tool calls = %v, want 2", got)
+	}
+	if got := ToolCalls.Value("made-up-1", "ok"); got != 0 {
+		t.Errorf("unregistered tool name recorded as a label: %v", got)
+	}
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Server metrics.
+var (
+	ToolCalls = NewCounterVec("vault_audit_mcp_tool_calls_total",
+		"MCP tool calls by tool and outcome (ok or error).", "tool", "outcome")
+	ToolDuration = NewHistogramVec("vault_audit_mcp_tool_duration_seconds",
+		"MCP tool call latency in seconds.", DefaultBuckets, "tool")
+
+	LokiRequests = NewCounterVec("vault_audit_mcp_loki_requests_total",
+		"Loki query_range HTTP requests by status code (error when no response was received).", "code")
+	LokiRetries = NewCounterVec("vault_audit_mcp_loki_retries_total",
+		"Loki query_range requests retried after a retryable failure.")
+	LokiResponseTooLarge = NewCounterVec("vault_audit_mcp_loki_response_too_large_total",
+		"Times the per-request line limit was halved after Loki rejected a response as too large.")
+
+	EventsScanned = NewCounterVec("vault_audit_mcp_events_scanned_total",
+		"Vault audit events decoded from Loki.")
+	EventsMatched = NewCounterVec("vault_audit_mcp_events_matched_total",
+		"Vault audit events that matched a query's filters.")
+)
+
+// UnknownTool labels calls to tools that are not registered.
+const UnknownTool = "unknown"
+
+// ToolMiddleware records call counts, errors and latency for tools/call
+// requests handled by an MCP server. Calls naming a tool outside tools are
+// recorded as UnknownTool, so callers cannot create label series.
+func ToolMiddleware(tools []string) mcp.Middleware {
+	known := make(map[string]bool, len(tools))
+	for _, t := range tools {
+		known[t] = true
+	}
+	return func(next mcp.MethodHandler) mcp.MethodHandler {
+		return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+			params, ok := req.GetParams().(*mcp.CallToolParamsRaw)
+			if method != "tools/call" || !ok {
+				return next(ctx, method, req)
+			}
+			tool := params.Name
+			if !known[tool] {
+				tool = UnknownTool
+			}
+
+			start := time.Now()
+			res, err := next(ctx, method, req)
+			ToolDuration.Observe(time.Since(start).Seconds(), tool)
+
+			outcome := "ok"
+			if r, isTool := res.(*mcp.CallToolResult); err != nil || (isTool && r.IsError) {
+				outcome = "error"
+			}
+			ToolCalls.Inc(tool, outcome)
+			return res, err
+		}
+	}
+}
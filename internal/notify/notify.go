@@ -0,0 +1,319 @@
+// Package notify delivers watch mode alerts to notification sinks: generic
+// JSON webhooks, Slack-compatible incoming webhooks, SMTP and local files.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink types.
+const (
+	TypeWebhook = "webhook"
+	TypeSlack   = "slack"
+	TypeSMTP    = "smtp"
+	TypeFile    = "file"
+)
+
+// DefaultTimeout bounds a delivery when the context has no deadline.
+const DefaultTimeout = 10 * time.Second
+
+// Alert is one firing of an alert rule.
+type Alert struct {
+	Rule        string `json:"rule"`
+	Description string `json:"description,omitempty"`
+	Severity    string `json:"severity"`
+	// Group is the value of the rule's group_by dimension, empty for
+	// ungrouped rules.
+	Group       string    `json:"group,omitempty"`
+	GroupBy     string    `json:"group_by,omitempty"`
+	Count       int       `json:"count"`
+	Threshold   int       `json:"threshold"`
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+	FiredAt     time.Time `json:"fired_at"`
+	// Truncated is set when the event limit was reached; Count is a lower
+	// bound.
+	Truncated bool `json:"truncated,omitempty"`
+	// Suppressed counts the alerts dropped by the rate limit since the
+	// previous alert was sent.
+	Suppressed int      `json:"suppressed,omitempty"`
+	Samples    []Sample `json:"samples,omitempty"`
+}
+
+// Sample is one of the newest events behind an alert.
+type Sample struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Operation string    `json:"operation,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Severity  string    `json:"severity,omitempty"`
+	Summary   string    `json:"summary,omitempty"`
+}
+
+// Title is a one-line description of the alert, used as the email subject.
+func (a *Alert) Title() string {
+	s := fmt.Sprintf("[%s] %s", strings.ToUpper(a.Severity), a.Rule)
+	if a.GroupBy != "" {
+		s += fmt.Sprintf(" (%s %s)", a.GroupBy, a.Group)
+	}
+	return s
+}
+
+// Text renders the alert as plain text.
+func (a *Alert) Text() string {
+	var b strings.Builder
+	b.WriteString(a.Title() + "\n")
+	if a.Description != "" {
+		b.WriteString(a.Description + "\n")
+	}
+	count := fmt.Sprint(a.Count)
+	if a.Truncated {
+		count = "at least " + count
+	}
+	fmt.Fprintf(&b, "%s events from %s to %s (threshold %d)\n", count,
+		a.WindowStart.UTC().Format(time.RFC3339), a.WindowEnd.UTC().Format(time.RFC3339), a.Threshold)
+	if a.Suppressed > 0 {
+		fmt.Fprintf(&b, "%d earlier alerts were dropped by the rate limit\n", a.Suppressed)
+	}
+	if len(a.Samples) > 0 {
+		b.WriteString("\nNewest events:\n")
+	}
+	for _, s := range a.Samples {
+		fmt.Fprintf(&b, "  %s %s %s %s", s.Time.UTC().Format(time.RFC3339), dash(s.Actor), dash(s.Operation), dash(s.Path))
+		if s.Status == "error" {
+			b.WriteString(" (failed)")
+		}
+		if s.Summary != "" {
+			b.WriteString(" - " + s.Summary)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Sink delivers alerts.
+type Sink interface {
+	Send(ctx context.Context, alert *Alert) error
+}
+
+// Webhook posts each alert as a JSON object.
+type Webhook struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewWebhook returns a sink posting alerts to url with the given extra
+// headers, e.g. Authorization.
+func NewWebhook(url string, headers map[string]string) *Webhook {
+	return &Webhook{url: url, headers: headers, client: &http.Client{Timeout: DefaultTimeout}}
+}
+
+func (w *Webhook) Send(ctx context.Context, alert *Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	return post(ctx, w.client, w.url, w.headers, body)
+}
+
+// Slack posts alerts to a Slack incoming webhook, or any service accepting
+// the same {"text": ...} payload such as Mattermost or Rocket.Chat.
+type Slack struct {
+	url    string
+	client *http.Client
+}
+
+// NewSlack returns a sink posting alerts to a Slack-compatible webhook URL.
+func NewSlack(url string) *Slack {
+	return &Slack{url: url, client: &http.Client{Timeout: DefaultTimeout}}
+}
+
+// slackEscaper escapes the characters Slack treats as markup.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func (s *Slack) Send(ctx context.Context, alert *Alert) error {
+	title, rest, _ := strings.Cut(alert.Text(), "\n")
+	text := "*" + slackEscaper.Replace(title) + "*\n" + slackEscaper.Replace(rest)
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.client, s.url, nil, body)
+}
+
+func post(ctx context.Context, client *http.Client, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// SMTPOptions configures an SMTP sink.
+type SMTPOptions struct {
+	// Addr is the server's host:port.
+	Addr string
+	From string
+	To   []string
+	// Username and Password enable PLAIN authentication, which net/smtp
+	// only sends over TLS or to localhost.
+	Username string
+	Password string
+	// SubjectPrefix is prepended to each subject, e.g. "[vault]".
+	SubjectPrefix string
+}
+
+// SMTP emails each alert as plain text. The connection is upgraded with
+// STARTTLS when the server offers it.
+type SMTP struct {
+	opts SMTPOptions
+}
+
+// NewSMTP returns a sink emailing alerts.
+func NewSMTP(opts SMTPOptions) *SMTP {
+	return &SMTP{opts: opts}
+}
+
+func (s *SMTP) Send(ctx context.Context, alert *Alert) error {
+	host, _, err := net.SplitHostPort(s.opts.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address %q: %w", s.opts.Addr, err)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultTimeout)
+		defer cancel()
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.opts.Addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.opts.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.opts.Username, s.opts.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.opts.From); err != nil {
+		return err
+	}
+	for _, to := range s.opts.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.message(alert)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (s *SMTP) message(alert *Alert) []byte {
+	subject := alert.Title()
+	if s.opts.SubjectPrefix != "" {
+		subject = s.opts.SubjectPrefix + " " + subject
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.opts.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.opts.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", alert.FiredAt.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	for _, line := range strings.Split(strings.TrimRight(alert.Text(), "\n"), "\n") {
+		// Escaping a leading dot is left to the DATA writer.
+		b.WriteString(line + "\r\n")
+	}
+	return b.Bytes()
+}
+
+// headerValue keeps a header value on one line.
+func headerValue(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// File appends each alert to a file as one JSON line.
+type File struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFile returns a sink appending alerts to path, creating it if needed.
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Send(_ context.Context, alert *Alert) error {
+	line, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(append(line, '\n')); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,183 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testAlert() *Alert {
+	at := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	return &Alert{
+		Rule:        "failed_logins",
+		Description: "Repeated failed logins from one address",
+		Severity:    "high",
+		GroupBy:     "remote_address",
+		Group:       "10.0.0.9",
+		Count:       12,
+		Threshold:   10,
+		WindowStart: at.Add(-5 * time.Minute),
+		WindowEnd:   at,
+		FiredAt:     at,
+		Samples: []Sample{
+			{Time: at.Add(-time.Minute), Operation: "update", Path: "auth/userpass/login/<bob>", Status: "error"},
+		},
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got Alert
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode failed: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	sink := NewWebhook(srv.URL, map[string]string{"Authorization": "Bearer s3cret"})
+	if err := sink.Send(t.Context(), testAlert()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got.Rule != "failed_logins" || got.Count != 12 || len(got.Samples) != 1 || auth != "Bearer s3cret" {
+		t.Errorf("webhook received %+v with Authorization %q", got, auth)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad token", http.StatusForbidden)
+	}))
+	defer failing.Close()
+	err := NewWebhook(failing.URL, nil).Send(t.Context(), testAlert())
+	if err == nil || !strings.Contains(err.Error(), "403 Forbidden: bad token") {
+		t.Errorf("error = %v, want the status and body", err)
+	}
+}
+
+func TestSlack(t *testing.T) {
+	var payload map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode failed: %v", err)
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	if err := NewSlack(srv.URL).Send(t.Context(), testAlert()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	text := payload["text"]
+	for _, want := range []string{
+		"*[HIGH] failed_logins (remote_address 10.0.0.9)*\n",
+		"12 events from 2026-03-10T11:55:00Z to 2026-03-10T12:00:00Z (threshold 10)",
+		"auth/userpass/login/&lt;bob&gt; (failed)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("slack text lacks %q:\n%s", want, text)
+		}
+	}
+}
+
+// fakeSMTP accepts one message and returns what was received on done.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	done := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var transcript strings.Builder
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				done <- transcript.String()
+				return
+			}
+			transcript.WriteString(line)
+			switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					transcript.WriteString(line)
+				}
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				done <- transcript.String()
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), done
+}
+
+func TestSMTP(t *testing.T) {
+	addr, done := fakeSMTP(t)
+	sink := NewSMTP(SMTPOptions{
+		Addr:          addr,
+		From:          "vault-audit@example.com",
+		To:            []string{"secops@example.com", "oncall@example.com"},
+		SubjectPrefix: "[vault]",
+	})
+	if err := sink.Send(t.Context(), testAlert()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	transcript := <-done
+	for _, want := range []string{
+		"MAIL FROM:<vault-audit@example.com>",
+		"RCPT TO:<oncall@example.com>",
+		"Subject: [vault] [HIGH] failed_logins (remote_address 10.0.0.9)\r\n",
+		"To: secops@example.com, oncall@example.com\r\n",
+		"12 events from 2026-03-10T11:55:00Z",
+	} {
+		if !strings.Contains(transcript, want) {
+			t.Errorf("SMTP transcript lacks %q:\n%s", want, transcript)
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.ndjson")
+	sink := NewFile(path)
+	for range 2 {
+		if err := sink.Send(t.Context(), testAlert()); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var got Alert
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &got) != nil || got.Group != "10.0.0.9" {
+		t.Errorf("alert file = %s", data)
+	}
+}